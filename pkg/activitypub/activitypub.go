@@ -0,0 +1,216 @@
+package activitypub
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	ContentType    = "application/activity+json"
+	LDContentType  = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+	JRDContentType = "application/jrd+json"
+
+	Public = "https://www.w3.org/ns/activitystreams#Public"
+)
+
+var Context = []string{
+	"https://www.w3.org/ns/activitystreams",
+	"https://w3id.org/security/v1",
+}
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername,omitempty"`
+	Name              string     `json:"name,omitempty"`
+	Summary           string     `json:"summary,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey"`
+}
+
+// DeliveryInbox is the inbox activities for the actor should be posted to,
+// servers that host many actors prefer the shared one.
+func (a *Actor) DeliveryInbox() string {
+	if a.Endpoints != nil && a.Endpoints.SharedInbox != "" {
+		return a.Endpoints.SharedInbox
+	}
+	return a.Inbox
+}
+
+type Object struct {
+	Context      any      `json:"@context,omitempty"`
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	AttributedTo string   `json:"attributedTo,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	Summary      string   `json:"summary,omitempty"`
+	Content      string   `json:"content,omitempty"`
+	MediaType    string   `json:"mediaType,omitempty"`
+	URL          string   `json:"url,omitempty"`
+	InReplyTo    string   `json:"inReplyTo,omitempty"`
+	Published    string   `json:"published,omitempty"`
+	Updated      string   `json:"updated,omitempty"`
+	To           []string `json:"to,omitempty"`
+	Cc           []string `json:"cc,omitempty"`
+}
+
+/*
+Activity wraps an object which is either embedded or referenced by its ID
+depending on the activity, use ObjectID and DecodeObject to read it.
+*/
+type Activity struct {
+	Context any      `json:"@context,omitempty"`
+	ID      string   `json:"id"`
+	Type    string   `json:"type"`
+	Actor   string   `json:"actor"`
+	Object  any      `json:"object"`
+	To      []string `json:"to,omitempty"`
+	Cc      []string `json:"cc,omitempty"`
+}
+
+// ObjectID returns the ID of an embedded or referenced object.
+func ObjectID(obj any) string {
+	switch val := obj.(type) {
+	case string:
+		return val
+	case *Object:
+		return val.ID
+	case *Activity:
+		return val.ID
+	case map[string]any:
+		id, _ := val["id"].(string)
+		return id
+	}
+	return ""
+}
+
+// DecodeObject reads an embedded object into v.
+func DecodeObject(obj any, v any) error {
+	if _, ok := obj.(map[string]any); !ok {
+		return fmt.Errorf("object is not embedded")
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+type OrderedCollection struct {
+	Context      any    `json:"@context,omitempty"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	TotalItems   int    `json:"totalItems"`
+	OrderedItems []any  `json:"orderedItems,omitempty"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+// IsActivityRequest reports whether the client asked for an activity
+// streams document instead of html.
+func IsActivityRequest(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		if mediaType == ContentType {
+			return true
+		}
+		if mediaType == "application/ld+json" && strings.Contains(params["profile"], "activitystreams") {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateKey creates the PEM encoded keypair an actor signs requests with.
+func GenerateKey() (pubkey string, privkey string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+	privDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	pubDer, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+	pubkey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDer}))
+	privkey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDer}))
+	return pubkey, privkey, nil
+}
+
+func ParsePrivateKey(privkey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privkey))
+	if block == nil {
+		return nil, fmt.Errorf("private key is not pem encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an rsa key")
+	}
+	return rsaKey, nil
+}
+
+func ParsePublicKey(pubkey string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(pubkey))
+	if block == nil {
+		return nil, fmt.Errorf("public key is not pem encoded")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is not an rsa key")
+	}
+	return rsaKey, nil
+}
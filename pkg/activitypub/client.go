@@ -0,0 +1,101 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/picosh/pico/pkg/shared"
+)
+
+// MaxDocumentSize caps the documents read from remote servers.
+const MaxDocumentSize = 1024 * 1024
+
+// Client fetches actors and delivers activities to remote inboxes.  Actor
+// ids and inboxes come from remote documents so the client only reaches
+// public addresses.
+type Client struct {
+	HTTP      *http.Client
+	UserAgent string
+}
+
+func NewClient(userAgent string) *Client {
+	return &Client{
+		HTTP:      shared.NewPublicHttpClient(10 * time.Second),
+		UserAgent: userAgent,
+	}
+}
+
+// FetchActor reads the actor document at id, the fragment of a key ID is
+// dropped so it can be used to look up the owner of a key.
+func (c *Client) FetchActor(ctx context.Context, id string) (*Actor, error) {
+	id, _, _ = strings.Cut(id, "#")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", ContentType)
+	req.Header.Set("User-Agent", c.UserAgent)
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch actor %s responded with status %d", id, resp.StatusCode)
+	}
+
+	actor := &Actor{}
+	err = json.NewDecoder(io.LimitReader(resp.Body, MaxDocumentSize)).Decode(actor)
+	if err != nil {
+		return nil, fmt.Errorf("decode actor %s: %w", id, err)
+	}
+	if actor.ID != id {
+		return nil, fmt.Errorf("actor %s has a different id %s", id, actor.ID)
+	}
+	return actor, nil
+}
+
+// Deliver posts an activity to an inbox signed with the key of its actor.
+func (c *Client) Deliver(ctx context.Context, inbox, keyID string, key *rsa.PrivateKey, activity any) error {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("Accept", ContentType)
+	req.Header.Set("User-Agent", c.UserAgent)
+	err = SignRequest(req, keyID, key, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, MaxDocumentSize))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("deliver to %s responded with status %d", inbox, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MaxClockSkew is how far the date of a signed request may drift from ours.
+var MaxClockSkew = time.Hour
+
+var signedHeaders = []string{"(request-target)", "host", "date", "digest"}
+
+// Digest is the value of the Digest header for body.
+func Digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func signatureHeaderValue(r *http.Request, header string) string {
+	switch header {
+	case "(request-target)":
+		return fmt.Sprintf("%s %s", strings.ToLower(r.Method), r.URL.RequestURI())
+	case "host":
+		if r.Host != "" {
+			return r.Host
+		}
+		return r.URL.Host
+	}
+	return r.Header.Get(header)
+}
+
+func signingString(r *http.Request, headers []string) string {
+	lines := make([]string, 0, len(headers))
+	for _, header := range headers {
+		lines = append(lines, fmt.Sprintf("%s: %s", header, signatureHeaderValue(r, header)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+/*
+SignRequest adds the Date, Digest and Signature headers the fediverse
+expects, following draft-cavage-http-signatures with rsa-sha256.  Body is
+the request body, nil for a GET.
+*/
+func SignRequest(r *http.Request, keyID string, key *rsa.PrivateKey, body []byte) error {
+	r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	headers := signedHeaders
+	if body == nil {
+		headers = headers[:3]
+	} else {
+		r.Header.Set("Digest", Digest(body))
+	}
+
+	hashed := sha256.Sum256([]byte(signingString(r, headers)))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return err
+	}
+
+	r.Header.Set("Signature", fmt.Sprintf(
+		`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID,
+		strings.Join(headers, " "),
+		base64.StdEncoding.EncodeToString(sig),
+	))
+	return nil
+}
+
+type Signature struct {
+	KeyID     string
+	Algorithm string
+	Headers   []string
+	Signature []byte
+}
+
+func ParseSignature(value string) (*Signature, error) {
+	sig := &Signature{Headers: []string{"date"}}
+	for _, param := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			continue
+		}
+		val = strings.Trim(val, `"`)
+		switch key {
+		case "keyId":
+			sig.KeyID = val
+		case "algorithm":
+			sig.Algorithm = val
+		case "headers":
+			sig.Headers = strings.Fields(strings.ToLower(val))
+		case "signature":
+			b, err := base64.StdEncoding.DecodeString(val)
+			if err != nil {
+				return nil, fmt.Errorf("signature is not base64: %w", err)
+			}
+			sig.Signature = b
+		}
+	}
+
+	if sig.KeyID == "" || len(sig.Signature) == 0 {
+		return nil, fmt.Errorf("signature is missing keyId or signature")
+	}
+	return sig, nil
+}
+
+/*
+VerifyRequest checks the Signature header of a request against the key
+returned by fetchKey for its keyId and returns that keyId.  The signature
+has to cover the request target, the date and, when there is a body, a
+digest that matches it.
+*/
+func VerifyRequest(r *http.Request, body []byte, fetchKey func(keyID string) (*rsa.PublicKey, error)) (string, error) {
+	value := r.Header.Get("Signature")
+	if value == "" {
+		return "", fmt.Errorf("request is not signed")
+	}
+	sig, err := ParseSignature(value)
+	if err != nil {
+		return "", err
+	}
+	if sig.Algorithm != "" && sig.Algorithm != "rsa-sha256" && sig.Algorithm != "hs2019" {
+		return "", fmt.Errorf("unsupported signature algorithm %s", sig.Algorithm)
+	}
+
+	covered := map[string]bool{}
+	for _, header := range sig.Headers {
+		covered[header] = true
+	}
+	if !covered["(request-target)"] || !covered["date"] {
+		return "", fmt.Errorf("signature must cover (request-target) and date")
+	}
+
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return "", fmt.Errorf("invalid date header: %w", err)
+	}
+	if skew := time.Since(date); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return "", fmt.Errorf("date header is too far from now")
+	}
+
+	if len(body) > 0 {
+		if !covered["digest"] {
+			return "", fmt.Errorf("signature must cover the digest")
+		}
+		if r.Header.Get("Digest") != Digest(body) {
+			return "", fmt.Errorf("digest does not match body")
+		}
+	}
+
+	key, err := fetchKey(sig.KeyID)
+	if err != nil {
+		return "", fmt.Errorf("fetch key %s: %w", sig.KeyID, err)
+	}
+
+	hashed := sha256.Sum256([]byte(signingString(r, sig.Headers)))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], sig.Signature)
+	if err != nil {
+		return "", fmt.Errorf("invalid signature: %w", err)
+	}
+
+	return sig.KeyID, nil
+}
@@ -0,0 +1,115 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T) (*rsa.PrivateKey, *rsa.PublicKey) {
+	t.Helper()
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	privKey, err := ParsePrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := ParsePublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privKey, pubKey
+}
+
+func TestVerifyRequest(t *testing.T) {
+	priv, pub := testKey(t)
+	keyID := "https://remote.test/users/erock#main-key"
+	fetchKey := func(id string) (*rsa.PublicKey, error) {
+		if id != keyID {
+			return nil, fmt.Errorf("unknown key %s", id)
+		}
+		return pub, nil
+	}
+
+	body := []byte(`{"type":"Follow"}`)
+	signed := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "https://erock.prose.sh/_ap/inbox", bytes.NewReader(body))
+		if err := SignRequest(req, keyID, priv, body); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	got, err := VerifyRequest(signed(), body, fetchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != keyID {
+		t.Fatalf("want key %s, got %s", keyID, got)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *http.Request) []byte
+	}{
+		{
+			name: "tampered-body",
+			modify: func(r *http.Request) []byte {
+				return []byte(`{"type":"Delete"}`)
+			},
+		},
+		{
+			name: "tampered-path",
+			modify: func(r *http.Request) []byte {
+				r.URL.Path = "/_ap/outbox"
+				return body
+			},
+		},
+		{
+			name: "stale-date",
+			modify: func(r *http.Request) []byte {
+				r.Header.Set("Date", time.Now().Add(-2*time.Hour).UTC().Format(http.TimeFormat))
+				return body
+			},
+		},
+		{
+			name: "unsigned",
+			modify: func(r *http.Request) []byte {
+				r.Header.Del("Signature")
+				return body
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := signed()
+			b := tc.modify(req)
+			if _, err := VerifyRequest(req, b, fetchKey); err == nil {
+				t.Fatal("expected verification to fail")
+			}
+		})
+	}
+}
+
+func TestIsActivityRequest(t *testing.T) {
+	tests := map[string]bool{
+		"application/activity+json": true,
+		`application/ld+json; profile="https://www.w3.org/ns/activitystreams"`: true,
+		"text/html,application/xhtml+xml":                                      false,
+		"":                                                                     false,
+	}
+	for accept, want := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", accept)
+		if got := IsActivityRequest(req); got != want {
+			t.Errorf("%q: want %v, got %v", accept, want, got)
+		}
+	}
+}
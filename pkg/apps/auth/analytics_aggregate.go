@@ -14,6 +14,7 @@ import (
 type visitRow struct {
 	UserID    string
 	Host      string
+	PostID    string
 	Path      string
 	IPAddress string
 	Referer   string
@@ -30,6 +31,12 @@ type dayStats struct {
 	DesktopIPs map[string]bool
 }
 
+// postDayStats accumulates per-day visitor and read-through counts for a post.
+type postDayStats struct {
+	dayStats
+	ReadIPs map[string]bool
+}
+
 // pathStats accumulates per-path visitor counts for a (user_id, host, status_code) triplet.
 type pathStats struct {
 	Path   string
@@ -91,6 +98,24 @@ func RunAnalyticsAggregation(dbpool *postgres.PsqlDB, logger *slog.Logger, targe
 			continue
 		}
 
+		// Aggregate per post visitors, read-throughs and referers
+		reads, err := fetchPostReadsForPair(dbpool, pair.UserID, pair.Host, monthStart, monthEnd)
+		if err != nil {
+			logger.Error("failed to fetch post reads", "err", err, "user_id", pair.UserID, "host", pair.Host)
+			continue
+		}
+		postDays := aggregatePostDays(visits, reads)
+		if err := insertMonthlyPostVisits(dbpool, pair.UserID, postDays); err != nil {
+			logger.Error("failed to insert monthly post visits", "err", err)
+			continue
+		}
+		for postID, refs := range aggregatePostReferers(visits) {
+			if err := insertPostReferers(dbpool, pair.UserID, postID, monthStart, refs); err != nil {
+				logger.Error("failed to insert post referers", "err", err, "post_id", postID)
+				continue
+			}
+		}
+
 		// Upsert user site
 		totalUnique := countTotalUnique(dayMap)
 		if err := upsertUserSite(dbpool, pair.UserID, pair.Host, totalUnique, targetMonth); err != nil {
@@ -180,7 +205,7 @@ func fetchUserHostPairs(dbpool *postgres.PsqlDB, monthStart, monthEnd time.Time)
 
 func fetchVisitsForPair(dbpool *postgres.PsqlDB, userID, host string, monthStart, monthEnd time.Time) ([]visitRow, error) {
 	rows, err := dbpool.Db.Queryx(
-		`SELECT user_id, host, path, ip_address, referer, status, user_agent, created_at
+		`SELECT user_id, host, COALESCE(post_id::text, ''), path, ip_address, referer, status, user_agent, created_at
 		 FROM analytics_visits
 		 WHERE user_id = $1 AND host = $2 AND created_at >= $3 AND created_at < $4`,
 		userID, host, monthStart, monthEnd,
@@ -193,7 +218,7 @@ func fetchVisitsForPair(dbpool *postgres.PsqlDB, userID, host string, monthStart
 	var visits []visitRow
 	for rows.Next() {
 		var v visitRow
-		if err := rows.Scan(&v.UserID, &v.Host, &v.Path, &v.IPAddress, &v.Referer, &v.Status, &v.UserAgent, &v.CreatedAt); err != nil {
+		if err := rows.Scan(&v.UserID, &v.Host, &v.PostID, &v.Path, &v.IPAddress, &v.Referer, &v.Status, &v.UserAgent, &v.CreatedAt); err != nil {
 			return nil, err
 		}
 		visits = append(visits, v)
@@ -201,6 +226,30 @@ func fetchVisitsForPair(dbpool *postgres.PsqlDB, userID, host string, monthStart
 	return visits, rows.Err()
 }
 
+// fetchPostReadsForPair returns the read-through events of the posts served on host.
+func fetchPostReadsForPair(dbpool *postgres.PsqlDB, userID, host string, monthStart, monthEnd time.Time) ([]visitRow, error) {
+	rows, err := dbpool.Db.Queryx(
+		`SELECT user_id, host, post_id, ip_address, created_at
+		 FROM analytics_post_reads
+		 WHERE user_id = $1 AND host = $2 AND created_at >= $3 AND created_at < $4`,
+		userID, host, monthStart, monthEnd,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var reads []visitRow
+	for rows.Next() {
+		var r visitRow
+		if err := rows.Scan(&r.UserID, &r.Host, &r.PostID, &r.IPAddress, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		reads = append(reads, r)
+	}
+	return reads, rows.Err()
+}
+
 func aggregateDays(visits []visitRow) map[string]*dayStats {
 	dayMap := make(map[string]*dayStats)
 
@@ -291,6 +340,71 @@ func aggregateReferers(visits []visitRow) []pathStats {
 	return result
 }
 
+// aggregatePostDays groups daily visitors and read-throughs by post id.
+func aggregatePostDays(visits, reads []visitRow) map[string]map[string]*postDayStats {
+	byPost := make(map[string][]visitRow)
+	for _, v := range visits {
+		if v.PostID == "" {
+			continue
+		}
+		byPost[v.PostID] = append(byPost[v.PostID], v)
+	}
+
+	result := make(map[string]map[string]*postDayStats)
+	for postID, postVisits := range byPost {
+		days := make(map[string]*postDayStats)
+		for dateKey, ds := range aggregateDays(postVisits) {
+			days[dateKey] = &postDayStats{dayStats: *ds, ReadIPs: make(map[string]bool)}
+		}
+		result[postID] = days
+	}
+
+	for _, r := range reads {
+		days, ok := result[r.PostID]
+		if !ok {
+			days = make(map[string]*postDayStats)
+			result[r.PostID] = days
+		}
+
+		created := r.CreatedAt.UTC()
+		dateKey := created.Format("2006-01-02")
+		ds, ok := days[dateKey]
+		if !ok {
+			ds = &postDayStats{
+				dayStats: dayStats{
+					Date:       time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, time.UTC),
+					AllIPs:     make(map[string]bool),
+					MobileIPs:  make(map[string]bool),
+					DesktopIPs: make(map[string]bool),
+				},
+				ReadIPs: make(map[string]bool),
+			}
+			days[dateKey] = ds
+		}
+		ds.ReadIPs[r.IPAddress] = true
+	}
+	return result
+}
+
+// aggregatePostReferers groups top referers by post id.
+func aggregatePostReferers(visits []visitRow) map[string][]pathStats {
+	byPost := make(map[string][]visitRow)
+	for _, v := range visits {
+		if v.PostID == "" {
+			continue
+		}
+		byPost[v.PostID] = append(byPost[v.PostID], v)
+	}
+
+	result := make(map[string][]pathStats)
+	for postID, postVisits := range byPost {
+		if refs := aggregateReferers(postVisits); len(refs) > 0 {
+			result[postID] = refs
+		}
+	}
+	return result
+}
+
 func sortByCount(paths []pathStats) {
 	for i := 1; i < len(paths); i++ {
 		for j := i; j > 0 && len(paths[j].IPs) > len(paths[j-1].IPs); j-- {
@@ -318,6 +432,28 @@ func insertMonthlyVisits(dbpool *postgres.PsqlDB, userID, host string, dayMap ma
 	return nil
 }
 
+func insertMonthlyPostVisits(dbpool *postgres.PsqlDB, userID string, postDays map[string]map[string]*postDayStats) error {
+	for postID, days := range postDays {
+		for _, ds := range days {
+			_, err := dbpool.Db.Exec(
+				`INSERT INTO analytics_monthly_post_visits (user_id, post_id, visit_date, unique_visits, mobile_visits, desktop_visits, read_throughs)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7)
+				 ON CONFLICT (user_id, post_id, visit_date) DO UPDATE
+				 SET unique_visits = EXCLUDED.unique_visits,
+				     mobile_visits = EXCLUDED.mobile_visits,
+				     desktop_visits = EXCLUDED.desktop_visits,
+				     read_throughs = EXCLUDED.read_throughs`,
+				userID, postID, ds.Date,
+				len(ds.AllIPs), len(ds.MobileIPs), len(ds.DesktopIPs), len(ds.ReadIPs),
+			)
+			if err != nil {
+				return fmt.Errorf("insert monthly post visits for %s on %s: %w", postID, ds.Date.Format("2006-01-02"), err)
+			}
+		}
+	}
+	return nil
+}
+
 func insertTopURLs(dbpool *postgres.PsqlDB, userID, host string, month time.Time, statusCode int, paths []pathStats) error {
 	limit := 10
 	if statusCode == 404 {
@@ -365,6 +501,28 @@ func insertTopReferers(dbpool *postgres.PsqlDB, userID, host string, month time.
 	return nil
 }
 
+func insertPostReferers(dbpool *postgres.PsqlDB, userID, postID string, month time.Time, refs []pathStats) error {
+	limit := 10
+	if len(refs) > limit {
+		refs = refs[:limit]
+	}
+
+	for rank, rs := range refs {
+		_, err := dbpool.Db.Exec(
+			`INSERT INTO analytics_monthly_post_referers (user_id, post_id, month, referer, unique_visits, rank)
+			 VALUES ($1, $2, $3, $4, $5, $6)
+			 ON CONFLICT (user_id, post_id, month, referer) DO UPDATE
+			 SET unique_visits = EXCLUDED.unique_visits,
+			     rank = EXCLUDED.rank`,
+			userID, postID, month, rs.Path, len(rs.IPs), rank+1,
+		)
+		if err != nil {
+			return fmt.Errorf("insert post referer %s: %w", rs.Path, err)
+		}
+	}
+	return nil
+}
+
 func countTotalUnique(dayMap map[string]*dayStats) int {
 	allIPs := make(map[string]bool)
 	for _, ds := range dayMap {
@@ -408,7 +566,8 @@ func isMobile(userAgent string) bool {
 	return false
 }
 
-// deleteAggregatedVisits deletes raw visit data from analytics_visits for the given month.
+// deleteAggregatedVisits deletes raw visit data from analytics_visits and
+// analytics_post_reads for the given month.
 // Data is preserved in summary tables, so this is safe regardless of feature flags.
 // Raw data for the current and previous months is never deleted since visitUniqueFromRaw
 // still reads from analytics_visits for those months.
@@ -433,5 +592,15 @@ func deleteAggregatedVisits(dbpool *postgres.PsqlDB, logger *slog.Logger, monthS
 
 	deleted, _ := result.RowsAffected()
 	logger.Info("deleted aggregated visits", "month_start", monthStart.Format("2006-01"), "deleted", deleted)
+
+	result, err = dbpool.Db.Exec(`
+		DELETE FROM analytics_post_reads
+		WHERE created_at >= $1 AND created_at < $2`, monthStart, monthEnd)
+	if err != nil {
+		return fmt.Errorf("delete aggregated post reads: %w", err)
+	}
+
+	deleted, _ = result.RowsAffected()
+	logger.Info("deleted aggregated post reads", "month_start", monthStart.Format("2006-01"), "deleted", deleted)
 	return nil
 }
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAggregatePostDays(t *testing.T) {
+	day := time.Date(2026, 9, 3, 10, 0, 0, 0, time.UTC)
+	visits := []visitRow{
+		{PostID: "p1", IPAddress: "a", Status: 200, UserAgent: "Mozilla/5.0 (iPhone)", CreatedAt: day},
+		{PostID: "p1", IPAddress: "a", Status: 200, CreatedAt: day.Add(time.Hour)},
+		{PostID: "p1", IPAddress: "b", Status: 200, Referer: "lobste.rs", CreatedAt: day},
+		{PostID: "p1", IPAddress: "c", Status: 404, CreatedAt: day},
+		{PostID: "", IPAddress: "d", Status: 200, CreatedAt: day},
+		{PostID: "p2", IPAddress: "a", Status: 200, Referer: "lobste.rs", CreatedAt: day.AddDate(0, 0, 1)},
+	}
+	reads := []visitRow{
+		{PostID: "p1", IPAddress: "a", CreatedAt: day},
+		{PostID: "p1", IPAddress: "a", CreatedAt: day.Add(time.Minute)},
+		{PostID: "p3", IPAddress: "e", CreatedAt: day},
+	}
+
+	posts := aggregatePostDays(visits, reads)
+	if len(posts) != 3 {
+		t.Fatalf("expected 3 posts, got %d", len(posts))
+	}
+
+	p1 := posts["p1"]["2026-09-03"]
+	if p1 == nil {
+		t.Fatal("expected stats for p1")
+	}
+	if len(p1.AllIPs) != 2 || len(p1.MobileIPs) != 1 || len(p1.ReadIPs) != 1 {
+		t.Errorf("unexpected p1 stats: all=%d mobile=%d reads=%d", len(p1.AllIPs), len(p1.MobileIPs), len(p1.ReadIPs))
+	}
+
+	p3 := posts["p3"]["2026-09-03"]
+	if p3 == nil || len(p3.AllIPs) != 0 || len(p3.ReadIPs) != 1 {
+		t.Errorf("expected read-through without visits for p3, got %+v", p3)
+	}
+
+	refs := aggregatePostReferers(visits)
+	if len(refs) != 2 || refs["p1"][0].Path != "lobste.rs" || refs["p2"][0].Path != "lobste.rs" {
+		t.Errorf("unexpected post referers %+v", refs)
+	}
+}
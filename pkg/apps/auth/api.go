@@ -6,6 +6,7 @@ import (
 	"crypto/hmac"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -86,7 +87,7 @@ func introspectHandler(apiConfig *router.ApiConfig) http.HandlerFunc {
 		token := r.FormValue("token")
 		apiConfig.Cfg.Logger.Info("introspect token", "token", token)
 
-		user, err := apiConfig.Dbpool.FindUserByToken(token)
+		user, err := apiConfig.Dbpool.FindUserByScopedToken(token, db.TokenScopeFull)
 		if err != nil {
 			apiConfig.Cfg.Logger.Error(err.Error())
 			http.Error(w, err.Error(), http.StatusUnauthorized)
@@ -208,7 +209,7 @@ func tokenHandler(apiConfig *router.ApiConfig) http.HandlerFunc {
 			"grantType", grantType,
 		)
 
-		_, err := apiConfig.Dbpool.FindUserByToken(token)
+		_, err := apiConfig.Dbpool.FindUserByScopedToken(token, db.TokenScopeFull)
 		if err != nil {
 			apiConfig.Cfg.Logger.Error(err.Error())
 			http.Error(w, err.Error(), http.StatusUnauthorized)
@@ -263,7 +264,7 @@ func keyHandler(apiConfig *router.ApiConfig) http.HandlerFunc {
 			return
 		}
 
-		authed, err := shared.PubkeyCertVerify(key, space)
+		authed, err := shared.PubkeyCertVerify(key, space, apiConfig.Dbpool)
 		if err != nil {
 			log.Error("pubkey cert verify", "err", err)
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -358,7 +359,7 @@ func userHandler(apiConfig *router.ApiConfig) http.HandlerFunc {
 func rssHandler(apiConfig *router.ApiConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		apiToken := r.PathValue("token")
-		user, err := apiConfig.Dbpool.FindUserByToken(apiToken)
+		user, err := apiConfig.Dbpool.FindUserByScopedToken(apiToken, db.TokenScopeFeedsRead)
 		if err != nil {
 			apiConfig.Cfg.Logger.Error(
 				"could not find user for token",
@@ -388,6 +389,49 @@ func rssHandler(apiConfig *router.ApiConfig) http.HandlerFunc {
 	}
 }
 
+// analyticsHandler returns the visit summary of one of the token owner's
+// sites, daily for this month or monthly for this year with ?interval=month.
+func analyticsHandler(apiConfig *router.ApiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiToken := router.GetApiToken(r)
+		user, err := apiConfig.Dbpool.FindUserByScopedToken(apiToken, db.TokenScopeAnalyticsRead)
+		if err != nil {
+			apiConfig.Cfg.Logger.Error("could not find user for token", "err", err.Error())
+			status := http.StatusUnauthorized
+			if errors.Is(err, db.ErrTokenScope) {
+				status = http.StatusForbidden
+			}
+			http.Error(w, "invalid token", status)
+			return
+		}
+
+		opts := &db.SummaryOpts{
+			Host:     r.PathValue("host"),
+			UserID:   user.ID,
+			Interval: "day",
+			Origin:   shared.StartOfMonth(),
+		}
+		if r.URL.Query().Get("interval") == "month" {
+			opts.Interval = "month"
+			opts.Origin = shared.StartOfYear()
+		}
+
+		summary, err := apiConfig.Dbpool.VisitSummary(opts)
+		if err != nil {
+			apiConfig.Cfg.Logger.Error("could not find visit summary", "err", err.Error())
+			http.Error(w, "could not find visit summary", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(summary)
+		if err != nil {
+			apiConfig.Cfg.Logger.Error("cannot json encode", "err", err.Error())
+		}
+	}
+}
+
 func pubkeysHandler(apiConfig *router.ApiConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userName := r.PathValue("user")
@@ -875,6 +919,7 @@ func authMux(apiConfig *router.ApiConfig) *http.ServeMux {
 	mux.Handle("POST /user", userHandler(apiConfig))
 	mux.Handle("GET /rss/{token}", rssHandler(apiConfig))
 	mux.Handle("GET /pubkeys/{user}", pubkeysHandler(apiConfig))
+	mux.Handle("GET /analytics/{host}", analyticsHandler(apiConfig))
 	mux.Handle("POST /redirect", redirectHandler(apiConfig))
 	mux.Handle("POST /webhook", paymentWebhookHandler(apiConfig))
 	mux.HandleFunc("GET /main.css", fileServer.ServeHTTP)
@@ -158,6 +158,54 @@ func TestToken(t *testing.T) {
 	testResponse(t, responseRecorder, 200, "application/json")
 }
 
+func TestScopedTokens(t *testing.T) {
+	apiConfig := setupTest()
+
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{name: "introspect full", method: "POST", path: "/introspect?token=123", status: http.StatusOK},
+		{name: "introspect scoped", method: "POST", path: "/introspect?token=feeds", status: http.StatusUnauthorized},
+		{name: "token scoped", method: "POST", path: "/token?code=feeds", status: http.StatusUnauthorized},
+		{name: "rss scoped", method: "GET", path: "/rss/feeds", status: http.StatusOK},
+		{name: "analytics full", method: "GET", path: "/analytics/erock.pgs.sh", token: "123", status: http.StatusOK},
+		{name: "analytics without scope", method: "GET", path: "/analytics/erock.pgs.sh", token: "feeds", status: http.StatusForbidden},
+		{name: "analytics without token", method: "GET", path: "/analytics/erock.pgs.sh", status: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, mkpath(tc.path), strings.NewReader(""))
+			if tc.token != "" {
+				request.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			responseRecorder := httptest.NewRecorder()
+
+			mux := authMux(apiConfig)
+			mux.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != tc.status {
+				t.Errorf("Want status '%d', got '%d'", tc.status, responseRecorder.Code)
+			}
+		})
+	}
+}
+
+func TestPrivilegedAccessRequiresFullToken(t *testing.T) {
+	apiConfig := setupTest()
+
+	if !apiConfig.HasPrivilegedAccess("123") {
+		t.Error("expected full access token to be privileged")
+	}
+	if apiConfig.HasPrivilegedAccess("feeds") {
+		t.Error("expected scoped token not to be privileged")
+	}
+}
+
 func TestAuthApi(t *testing.T) {
 	apiConfig := setupTest()
 	tt := []*ApiExample{
@@ -229,13 +277,31 @@ func (a *AuthDb) FindUserByKey(username string, pubkey string) (*db.User, error)
 	return &db.User{ID: testUserID, Name: username}, nil
 }
 
+// testTokens holds a full access token and one scoped to feeds only.
+var testTokens = map[string]*db.Token{
+	"123":   {UserID: testUserID},
+	"feeds": {UserID: testUserID, Scopes: db.TokenScopeFeedsRead},
+}
+
 func (a *AuthDb) FindUserByToken(token string) (*db.User, error) {
-	if token != "123" {
+	return a.FindUserByScopedToken(token, db.TokenScopeFull)
+}
+
+func (a *AuthDb) FindUserByScopedToken(token, scope string) (*db.User, error) {
+	tkn, ok := testTokens[token]
+	if !ok {
 		return nil, fmt.Errorf("invalid token")
 	}
+	if !tkn.HasScope(scope) {
+		return nil, fmt.Errorf("%w: %s", db.ErrTokenScope, scope)
+	}
 	return &db.User{ID: testUserID, Name: testUsername}, nil
 }
 
+func (a *AuthDb) VisitSummary(opts *db.SummaryOpts) (*db.SummaryVisits, error) {
+	return &db.SummaryVisits{}, nil
+}
+
 func (a *AuthDb) HasFeatureByUser(userID string, feature string) bool {
 	return true
 }
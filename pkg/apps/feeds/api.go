@@ -102,6 +102,8 @@ func createMainRoutes(staticRoutes []router.Route) []router.Route {
 		router.NewRoute("GET", "/", router.CreatePageHandler("html/marketing.page.tmpl")),
 		router.NewRoute("GET", "/keep-alive/(.+)", keepAliveHandler),
 		router.NewRoute("GET", "/unsub/(.+)", unsubHandler),
+		// one-click unsubscribe from the List-Unsubscribe header
+		router.NewRoute("POST", "/unsub/(.+)", unsubHandler),
 		router.NewRoute("GET", "/_metrics", promhttp.Handler().ServeHTTP),
 	}
 
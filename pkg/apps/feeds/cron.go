@@ -10,14 +10,10 @@ import (
 	"math"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/adhocore/gronx"
-	"github.com/emersion/go-sasl"
-	"github.com/emersion/go-smtp"
 	"github.com/mmcdole/gofeed"
 	"github.com/picosh/pico/pkg/db"
 	"github.com/picosh/pico/pkg/shared"
@@ -128,26 +124,19 @@ func isValidItem(logger *slog.Logger, item *gofeed.Item, feedItems []*db.FeedIte
 }
 
 type Fetcher struct {
-	cfg  *shared.ConfigSite
-	db   db.DB
-	auth sasl.Client
-	gron *gronx.Gronx
-	host string
+	cfg    *shared.ConfigSite
+	db     db.DB
+	mailer shared.MailSender
+	gron   *gronx.Gronx
 }
 
 func NewFetcher(dbpool db.DB, cfg *shared.ConfigSite) *Fetcher {
-	host := os.Getenv("PICO_SMTP_HOST")
-	smtPass := os.Getenv("PICO_SMTP_PASS")
-	emailLogin := os.Getenv("PICO_SMTP_USER")
-
-	auth := sasl.NewPlainClient("", emailLogin, smtPass)
 	gron := gronx.New()
 	return &Fetcher{
-		db:   dbpool,
-		cfg:  cfg,
-		auth: auth,
-		gron: gron,
-		host: host,
+		db:     dbpool,
+		cfg:    cfg,
+		mailer: shared.NewMailer(),
+		gron:   gron,
 	}
 }
 
@@ -438,45 +427,14 @@ func (f *Fetcher) Fetch(logger *slog.Logger, fp *gofeed.Parser, url string, user
 }
 
 func (f *Fetcher) PrintText(feedTmpl *DigestFeed) (string, error) {
-	ts, err := template.ParseFiles(
-		f.cfg.StaticPath("html/digest_text.page.tmpl"),
-	)
-
-	if err != nil {
-		return "", err
-	}
-
-	w := new(strings.Builder)
-	err = ts.Execute(w, feedTmpl)
-	if err != nil {
-		return "", err
-	}
-
-	return w.String(), nil
+	return shared.RenderText(f.cfg.StaticPath("html/digest_text.page.tmpl"), feedTmpl)
 }
 
 func (f *Fetcher) PrintHtml(feedTmpl *DigestFeed) (string, error) {
-	ts, err := html.ParseFiles(
-		f.cfg.StaticPath("html/digest.page.tmpl"),
-	)
-
-	if err != nil {
-		return "", err
-	}
-
-	w := new(strings.Builder)
-	err = ts.Execute(w, feedTmpl)
-	if err != nil {
-		return "", err
-	}
-
-	return w.String(), nil
+	return shared.RenderHtml(f.cfg.StaticPath("html/digest.page.tmpl"), feedTmpl)
 }
 
-type MsgBody struct {
-	Html string
-	Text string
-}
+type MsgBody = shared.MsgBody
 
 func getUnsubURL(post *db.Post) string {
 	return fmt.Sprintf("https://feeds.pico.sh/unsub/%s", post.ID)
@@ -591,40 +549,8 @@ func (f *Fetcher) SendEmail(logger *slog.Logger, username, email, subject, unsub
 	if email == "" {
 		return fmt.Errorf("(%s) does not have an email associated with their feed post", username)
 	}
-	smtpAddr := f.host
-	fromEmail := "hello@pico.sh"
-	to := []string{email}
-	headers := map[string]string{
-		"From":             fromEmail,
-		"Subject":          subject,
-		"To":               email,
-		"MIME-Version":     "1.0",
-		"Content-Type":     `multipart/alternative; boundary="boundary123"`,
-		"List-Unsubscribe": "<" + unsubURL + ">",
-	}
-	var content strings.Builder
-	for k, v := range headers {
-		fmt.Fprintf(&content, "%s: %s\r\n", k, v)
-	}
-	content.WriteString("\r\n")
-	content.WriteString("\r\n--boundary123\r\n")
-	content.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
-	content.WriteString("\r\n" + msg.Text + "\r\n")
-	content.WriteString("--boundary123\r\n")
-	content.WriteString("Content-Type: text/html; charset=\"utf-8\"\r\n")
-	content.WriteString("\r\n" + msg.Html + "\r\n")
-	content.WriteString("--boundary123--")
-
-	reader := strings.NewReader(content.String())
 	logger.Info("sending email digest")
-	err := smtp.SendMail(
-		smtpAddr,
-		f.auth,
-		fromEmail,
-		to,
-		reader,
-	)
-	return err
+	return shared.SendListEmail(f.mailer, email, subject, unsubURL, msg, nil)
 }
 
 func (f *Fetcher) Run(now time.Time) error {
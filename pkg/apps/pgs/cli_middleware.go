@@ -39,13 +39,19 @@ func flagSet(cmdName string, sesh *pssh.SSHServerConnSession) (*flag.FlagSet, *b
 	return cmd, write
 }
 
-func flagCheck(cmd *flag.FlagSet, posArg string, cmdArgs []string) bool {
+func flagCheck(sesh *pssh.SSHServerConnSession, cmd *flag.FlagSet, posArg string, cmdArgs []string) bool {
 	_ = cmd.Parse(cmdArgs)
 
 	if posArg == "-h" || posArg == "--help" || posArg == "-help" {
 		cmd.Usage()
 		return false
 	}
+
+	write := cmd.Lookup("write")
+	if write != nil && write.Value.String() == "true" && !pssh.CanWrite(sesh) {
+		sendutils.ErrorHandler(sesh, pssh.ErrOrgViewer)
+		return false
+	}
 	return true
 }
 
@@ -107,6 +113,10 @@ func Middleware(handler *UploadAssetHandler) pssh.SSHServerMiddleware {
 					opts.bail(err)
 					return err
 				case "cache-all":
+					if !pssh.CanWrite(sesh) {
+						opts.bail(pssh.ErrOrgViewer)
+						return pssh.ErrOrgViewer
+					}
 					opts.Write = true
 					err := opts.cacheAll()
 					opts.notice()
@@ -135,7 +145,7 @@ func Middleware(handler *UploadAssetHandler) pssh.SSHServerMiddleware {
 			case "link":
 				linkCmd, write := flagSet("link", sesh)
 				linkTo := linkCmd.String("to", "", "symbolic link to this project")
-				if !flagCheck(linkCmd, projectName, cmdArgs) {
+				if !flagCheck(sesh, linkCmd, projectName, cmdArgs) {
 					return nil
 				}
 				opts.Write = *write
@@ -156,7 +166,7 @@ func Middleware(handler *UploadAssetHandler) pssh.SSHServerMiddleware {
 				return err
 			case "unlink":
 				unlinkCmd, write := flagSet("unlink", sesh)
-				if !flagCheck(unlinkCmd, projectName, cmdArgs) {
+				if !flagCheck(sesh, unlinkCmd, projectName, cmdArgs) {
 					return nil
 				}
 				opts.Write = *write
@@ -172,7 +182,7 @@ func Middleware(handler *UploadAssetHandler) pssh.SSHServerMiddleware {
 			case "retain":
 				retainCmd, write := flagSet("retain", sesh)
 				retainNum := retainCmd.Int("n", 3, "latest number of projects to keep")
-				if !flagCheck(retainCmd, projectName, cmdArgs) {
+				if !flagCheck(sesh, retainCmd, projectName, cmdArgs) {
 					return nil
 				}
 				opts.Write = *write
@@ -183,7 +193,7 @@ func Middleware(handler *UploadAssetHandler) pssh.SSHServerMiddleware {
 				return err
 			case "prune":
 				pruneCmd, write := flagSet("prune", sesh)
-				if !flagCheck(pruneCmd, projectName, cmdArgs) {
+				if !flagCheck(sesh, pruneCmd, projectName, cmdArgs) {
 					return nil
 				}
 				opts.Write = *write
@@ -194,7 +204,7 @@ func Middleware(handler *UploadAssetHandler) pssh.SSHServerMiddleware {
 				return err
 			case "rm":
 				rmCmd, write := flagSet("rm", sesh)
-				if !flagCheck(rmCmd, projectName, cmdArgs) {
+				if !flagCheck(sesh, rmCmd, projectName, cmdArgs) {
 					return nil
 				}
 				opts.Write = *write
@@ -205,7 +215,7 @@ func Middleware(handler *UploadAssetHandler) pssh.SSHServerMiddleware {
 				return err
 			case "cache":
 				cacheCmd, write := flagSet("cache", sesh)
-				if !flagCheck(cacheCmd, projectName, cmdArgs) {
+				if !flagCheck(sesh, cacheCmd, projectName, cmdArgs) {
 					return nil
 				}
 				opts.Write = *write
@@ -218,7 +228,7 @@ func Middleware(handler *UploadAssetHandler) pssh.SSHServerMiddleware {
 				formName := projectName
 				formsCmd, write := flagSet("forms", sesh)
 				rmForm := formsCmd.Bool("rm", false, "delete form data")
-				if !flagCheck(formsCmd, formName, cmdArgs) {
+				if !flagCheck(sesh, formsCmd, formName, cmdArgs) {
 					return nil
 				}
 				opts.Write = *write
@@ -246,7 +256,7 @@ func Middleware(handler *UploadAssetHandler) pssh.SSHServerMiddleware {
 					"acl",
 					"list of pico usernames or sha256 public keys, delimited by commas",
 				)
-				if !flagCheck(aclCmd, projectName, cmdArgs) {
+				if !flagCheck(sesh, aclCmd, projectName, cmdArgs) {
 					return nil
 				}
 				opts.Write = *write
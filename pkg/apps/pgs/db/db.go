@@ -14,6 +14,9 @@ type PgsDB interface {
 
 	FindFeature(userID string, name string) (*db.FeatureFlag, error)
 	InsertAccessLog(*db.AccessLog) error
+	IsSshCertRevoked(caPubkey string, serial int64) (bool, error)
+	FindOrgMember(orgID, userID string) (*db.OrgMember, error)
+	FindUserByScopedToken(token, scope string) (*db.User, error)
 
 	InsertProject(userID, name, projectDir string) (string, error)
 	UpdateProject(userID, name string) error
@@ -18,6 +18,7 @@ type MemoryDB struct {
 	Feature     *db.FeatureFlag
 	Features    []*db.FeatureFlag
 	FormEntries []*db.FormEntry
+	Tokens      []*db.Token
 }
 
 var _ PgsDB = (*MemoryDB)(nil)
@@ -206,6 +207,30 @@ func (me *MemoryDB) InsertAccessLog(*db.AccessLog) error {
 	return errNotImpl
 }
 
+func (me *MemoryDB) IsSshCertRevoked(caPubkey string, serial int64) (bool, error) {
+	return false, nil
+}
+
+func (me *MemoryDB) FindOrgMember(orgID, userID string) (*db.OrgMember, error) {
+	return nil, errNotImpl
+}
+
+func (me *MemoryDB) FindUserByScopedToken(token, scope string) (*db.User, error) {
+	for _, tkn := range me.Tokens {
+		if tkn.Token != token {
+			continue
+		}
+		err := tkn.Authorize(scope)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		tkn.LastUsedAt = &now
+		return me.FindUser(tkn.UserID)
+	}
+	return nil, fmt.Errorf("token not found")
+}
+
 func (me *MemoryDB) InsertFormEntry(userID, name string, data map[string]interface{}) error {
 	id := uuid.NewString()
 	now := time.Now()
@@ -107,6 +107,36 @@ func (me *PgsPsqlDB) InsertAccessLog(log *db.AccessLog) error {
 	return err
 }
 
+func (me *PgsPsqlDB) IsSshCertRevoked(caPubkey string, serial int64) (bool, error) {
+	return db.IsSshCertRevoked(me.Db, caPubkey, serial)
+}
+
+func (me *PgsPsqlDB) FindOrgMember(orgID, userID string) (*db.OrgMember, error) {
+	member := &db.OrgMember{}
+	err := me.Db.Get(
+		member,
+		`SELECT org_members.id, org_id, orgs.name as org_name, user_id, members.name as user_name, role, org_members.created_at
+		FROM org_members
+		INNER JOIN app_users orgs ON orgs.id = org_members.org_id
+		INNER JOIN app_users members ON members.id = org_members.user_id
+		WHERE org_id = $1 AND user_id = $2;`,
+		orgID,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func (me *PgsPsqlDB) FindUserByScopedToken(token, scope string) (*db.User, error) {
+	tkn, err := db.FindScopedToken(me.Db, me.Logger, token, scope)
+	if err != nil {
+		return nil, err
+	}
+	return me.FindUser(tkn.UserID)
+}
+
 func (me *PgsPsqlDB) InsertProject(userID, name, projectDir string) (string, error) {
 	if !shared.IsValidSubdomain(name) {
 		return "", fmt.Errorf("'%s' is not a valid project name, must match /^[a-z0-9-]+$/", name)
@@ -70,10 +70,149 @@ CREATE TABLE IF NOT EXISTS form_entries (
 		ON DELETE CASCADE
 		ON UPDATE CASCADE
 );
+
+CREATE TABLE IF NOT EXISTS ssh_cert_authorities (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL UNIQUE,
+	public_key TEXT NOT NULL,
+	private_key TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT ssh_cert_authorities_user_id_fk
+		FOREIGN KEY(user_id) REFERENCES app_users(id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS ssh_certs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	ca_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	serial INTEGER NOT NULL,
+	key_id TEXT NOT NULL,
+	principals TEXT NOT NULL,
+	public_key TEXT NOT NULL,
+	valid_after DATETIME NOT NULL,
+	valid_before DATETIME NOT NULL,
+	revoked_at DATETIME,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (ca_id, serial),
+	CONSTRAINT ssh_certs_ca_id_fk
+		FOREIGN KEY(ca_id) REFERENCES ssh_cert_authorities(id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS org_members (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	org_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	role TEXT NOT NULL DEFAULT 'viewer',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (org_id, user_id),
+	CONSTRAINT org_members_org_id_fk
+		FOREIGN KEY(org_id) REFERENCES app_users(id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE,
+	CONSTRAINT org_members_user_id_fk
+		FOREIGN KEY(user_id) REFERENCES app_users(id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	token TEXT NOT NULL UNIQUE,
+	scopes TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME NOT NULL DEFAULT '2100-01-01 00:00:00',
+	last_used_at DATETIME,
+	UNIQUE (user_id, name),
+	CONSTRAINT tokens_user_id_fk
+		FOREIGN KEY(user_id) REFERENCES app_users(id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+`
+
+var sqliteSshCerts = `
+CREATE TABLE IF NOT EXISTS ssh_cert_authorities (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL UNIQUE,
+	public_key TEXT NOT NULL,
+	private_key TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT ssh_cert_authorities_user_id_fk
+		FOREIGN KEY(user_id) REFERENCES app_users(id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS ssh_certs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	ca_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	serial INTEGER NOT NULL,
+	key_id TEXT NOT NULL,
+	principals TEXT NOT NULL,
+	public_key TEXT NOT NULL,
+	valid_after DATETIME NOT NULL,
+	valid_before DATETIME NOT NULL,
+	revoked_at DATETIME,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (ca_id, serial),
+	CONSTRAINT ssh_certs_ca_id_fk
+		FOREIGN KEY(ca_id) REFERENCES ssh_cert_authorities(id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+`
+
+var sqliteOrgMembers = `
+CREATE TABLE IF NOT EXISTS org_members (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	org_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	role TEXT NOT NULL DEFAULT 'viewer',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (org_id, user_id),
+	CONSTRAINT org_members_org_id_fk
+		FOREIGN KEY(org_id) REFERENCES app_users(id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE,
+	CONSTRAINT org_members_user_id_fk
+		FOREIGN KEY(user_id) REFERENCES app_users(id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+`
+
+var sqliteTokens = `
+CREATE TABLE IF NOT EXISTS tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	token TEXT NOT NULL UNIQUE,
+	scopes TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME NOT NULL DEFAULT '2100-01-01 00:00:00',
+	last_used_at DATETIME,
+	UNIQUE (user_id, name),
+	CONSTRAINT tokens_user_id_fk
+		FOREIGN KEY(user_id) REFERENCES app_users(id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
 `
 
 var sqliteMigrations = []string{
 	"", // migration #0 is reserved for schema initialization
+	sqliteSshCerts,
+	sqliteOrgMembers,
+	sqliteTokens,
 }
 
 func NewSqliteDB(databaseUrl string, logger *slog.Logger) (*PgsPsqlDB, error) {
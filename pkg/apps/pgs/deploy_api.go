@@ -0,0 +1,368 @@
+package pgs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/pssh"
+	sendutils "github.com/picosh/pico/pkg/send/utils"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/shared/router"
+	"golang.org/x/crypto/ssh"
+)
+
+// errDeployHalted stops reading the upload once the session has been closed,
+// e.g. when the storage quota has been reached.
+var errDeployHalted = errors.New("deploy halted")
+
+type DeployFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	URL  string `json:"url"`
+}
+
+type DeployError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type DeploySummary struct {
+	Project    string         `json:"project"`
+	URL        string         `json:"url"`
+	Files      []*DeployFile  `json:"files"`
+	Errors     []*DeployError `json:"errors"`
+	TotalFiles int            `json:"total_files"`
+	TotalBytes int64          `json:"total_bytes"`
+	Report     *SiteReport    `json:"report,omitempty"`
+}
+
+// deployChannel stands in for the ssh channel when uploads come in over
+// http so the deploy can reuse the same handler as rsync, scp, and sftp.
+type deployChannel struct {
+	stderr bytes.Buffer
+}
+
+func (c *deployChannel) Read(_ []byte) (int, error)     { return 0, io.EOF }
+func (c *deployChannel) Write(data []byte) (int, error) { return len(data), nil }
+func (c *deployChannel) Close() error                   { return nil }
+func (c *deployChannel) CloseWrite() error              { return nil }
+func (c *deployChannel) SendRequest(_ string, _ bool, _ []byte) (bool, error) {
+	return false, nil
+}
+func (c *deployChannel) Stderr() io.ReadWriter { return &c.stderr }
+
+var _ ssh.Channel = (*deployChannel)(nil)
+
+func newDeploySession(ctx context.Context, logger *slog.Logger, user *db.User) *pssh.SSHServerConnSession {
+	conn := pssh.NewSSHServerConn(ctx, logger, nil, nil)
+	sctx, cancel := context.WithCancel(conn.Ctx)
+	s := &pssh.SSHServerConnSession{
+		Channel:       &deployChannel{},
+		SSHServerConn: conn,
+		Ctx:           sctx,
+		CancelFunc:    cancel,
+	}
+	pssh.SetUser(s, user)
+	return s
+}
+
+// countingReader records how many bytes were read since uploads from a
+// multipart form do not report their size up front.
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+type deployFn func(fpath string, mtime time.Time, reader io.Reader) error
+
+// cleanDeployPath normalizes an archive entry name relative to the project
+// root and rejects anything that would escape it.
+func cleanDeployPath(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	fpath := path.Clean("/" + name)
+	if fpath == "/" {
+		return ""
+	}
+	return fpath
+}
+
+func readTarArchive(body io.Reader, fn deployFn) error {
+	buf := bufio.NewReader(body)
+	var reader io.Reader = buf
+	// gzip magic number
+	magic, _ := buf.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			return fmt.Errorf("invalid gzip archive: %w", err)
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		reader = gz
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		err = fn(hdr.Name, hdr.ModTime, tr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func readZipArchive(body io.Reader, fn deployFn) error {
+	// zip stores its directory at the end of the file so we need
+	// random access to the whole upload
+	tmp, err := os.CreateTemp("", "pgs-deploy-*.zip")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	size, err := io.Copy(tmp, body)
+	if err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	for _, file := range zr.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		fp, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("invalid zip entry %s: %w", file.Name, err)
+		}
+		err = fn(file.Name, file.Modified, fp)
+		_ = fp.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readMultipartFiles(r *http.Request, fn deployFn) error {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid multipart form: %w", err)
+		}
+
+		// `part.FileName()` strips directories so we read the raw
+		// filename to preserve the file's location within the site
+		_, params, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+		fname := params["filename"]
+		if fname == "" {
+			_ = part.Close()
+			continue
+		}
+
+		err = fn(fname, time.Now(), part)
+		_ = part.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// deployHandler uploads a tar (optionally gzipped), zip, or multipart form
+// of files to a project.  Every file goes through the same upload handler
+// as our ssh uploads so denylists, quotas, and cache purging all apply.
+func (web *WebRouter) deployHandler(w http.ResponseWriter, r *http.Request) {
+	logger := web.Cfg.Logger
+	projectName := r.PathValue("project")
+
+	token := router.GetApiToken(r)
+	if token == "" {
+		router.JSONError(w, "must provide an api token", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := web.Cfg.DB.FindUserByScopedToken(token, db.TokenScopePgsDeploy)
+	if err != nil {
+		if errors.Is(err, db.ErrTokenScope) {
+			router.JSONError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		router.JSONError(w, "invalid api token", http.StatusUnauthorized)
+		return
+	}
+
+	if !shared.IsValidSubdomain(projectName) {
+		router.JSONError(
+			w,
+			fmt.Sprintf("'%s' is not a valid project name, must match /^[a-z0-9-]+$/", projectName),
+			http.StatusUnprocessableEntity,
+		)
+		return
+	}
+
+	logger = shared.LoggerWithUser(logger, user).With(
+		"service", "deploy-api",
+		"project", projectName,
+	)
+
+	sesh := newDeploySession(r.Context(), logger, user)
+	defer func() {
+		_ = sesh.Close()
+		_ = sesh.SSHServerConn.Close()
+	}()
+
+	handler := web.Uploader
+	err = handler.Validate(sesh)
+	if err != nil {
+		logger.Error("deploy validation", "err", err)
+		router.JSONError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	// no reason to accept more data than the user is allowed to store
+	ff := getFeatureFlag(sesh)
+	r.Body = http.MaxBytesReader(w, r.Body, int64(ff.Data.StorageMax))
+
+	summary := &DeploySummary{
+		Project: projectName,
+		URL:     web.Cfg.AssetURL(user.Name, projectName, ""),
+		Files:   []*DeployFile{},
+		Errors:  []*DeployError{},
+	}
+
+	deploy := func(name string, mtime time.Time, reader io.Reader) error {
+		fpath := cleanDeployPath(name)
+		if fpath == "" {
+			return nil
+		}
+		if sesh.Context().Err() != nil {
+			return errDeployHalted
+		}
+
+		counter := &countingReader{Reader: reader}
+		entry := &sendutils.FileEntry{
+			Filepath: path.Join("/", projectName, fpath),
+			Mode:     0644,
+			Reader:   counter,
+			Mtime:    mtime.Unix(),
+		}
+
+		_, err := handler.Write(sesh, entry)
+		if err != nil {
+			summary.Errors = append(summary.Errors, &DeployError{
+				Path:  fpath,
+				Error: err.Error(),
+			})
+			if sesh.Context().Err() != nil {
+				return errDeployHalted
+			}
+			return nil
+		}
+
+		summary.Files = append(summary.Files, &DeployFile{
+			Path: fpath,
+			Size: counter.n,
+			URL:  web.Cfg.AssetURL(user.Name, projectName, strings.TrimPrefix(fpath, "/")),
+		})
+		summary.TotalFiles += 1
+		summary.TotalBytes += counter.n
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		err = readMultipartFiles(r, deploy)
+	case "application/zip", "application/x-zip-compressed":
+		err = readZipArchive(r.Body, deploy)
+	default:
+		err = readTarArchive(r.Body, deploy)
+	}
+	if err != nil && !errors.Is(err, errDeployHalted) {
+		logger.Error("deploy", "err", err)
+		router.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// `?report=1` crawls the site after the deploy, see `genSiteReport`
+	if report := r.URL.Query().Get("report"); report != "" && report != "0" && report != "false" {
+		project := getProject(sesh)
+		bucket, _ := getBucket(sesh)
+		if project != nil {
+			summary.Report, err = genSiteReport(
+				web.Cfg.Storage,
+				bucket,
+				project.ProjectDir,
+				reportFileMax(r.URL.Query().Get("report_file_max")),
+			)
+			if err != nil {
+				logger.Error("could not generate site report", "err", err)
+			}
+		}
+	}
+
+	logger.Info(
+		"deploy complete",
+		"files", summary.TotalFiles,
+		"bytes", summary.TotalBytes,
+		"errors", len(summary.Errors),
+	)
+
+	status := http.StatusOK
+	if len(summary.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err = json.NewEncoder(w).Encode(summary)
+	if err != nil {
+		logger.Error("json encode", "err", err)
+	}
+}
@@ -0,0 +1,224 @@
+package pgs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/storage"
+)
+
+func newDeployTestRouter(t *testing.T) (*WebRouter, *PgsDb, storage.StorageServe) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dbpool := NewPgsDb(logger)
+	dbpool.Tokens = append(dbpool.Tokens,
+		&db.Token{Token: "deploy-token", UserID: dbpool.Users[0].ID, Scopes: db.TokenScopePgsDeploy},
+		&db.Token{Token: "pipe-token", UserID: dbpool.Users[0].ID, Scopes: db.TokenScopePipePub},
+	)
+
+	st, err := storage.NewStorageMemory(map[string]map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubsub := NewPubsubChan()
+	t.Cleanup(func() {
+		_ = pubsub.Close()
+	})
+	cfg := NewPgsConfig(logger, dbpool, st, pubsub)
+	cfg.Domain = "pgs.test"
+	return NewWebRouter(cfg), dbpool, st
+}
+
+func newDeployRequest(token, contentType string, body io.Reader) *http.Request {
+	request := httptest.NewRequest("POST", "https://pgs.test/api/deploy/site", body)
+	request.Header.Set("Content-Type", contentType)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return request
+}
+
+func newTestTarball(t *testing.T, files map[string]string) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, contents := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(contents)),
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func readDeployObject(t *testing.T, st storage.StorageServe, userID, fpath string) string {
+	t.Helper()
+	bucket, err := st.GetBucket(shared.GetAssetBucketName(userID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, _, err := st.GetObject(bucket, fpath)
+	if err != nil {
+		t.Fatalf("expected %s to be deployed: %v", fpath, err)
+	}
+	defer func() {
+		_ = obj.Close()
+	}()
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDeployApiTarball(t *testing.T) {
+	router, dbpool, st := newDeployTestRouter(t)
+
+	body := newTestTarball(t, map[string]string{
+		"index.html":      "hello world!",
+		"./css/main.css":  "body {}",
+		"../../escape.js": "alert(1)",
+	})
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, newDeployRequest("deploy-token", "application/gzip", body))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("want status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	summary := &DeploySummary{}
+	if err := json.NewDecoder(recorder.Body).Decode(summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.TotalFiles != 3 {
+		t.Errorf("want 3 files, got %d", summary.TotalFiles)
+	}
+	if summary.TotalBytes != int64(len("hello world!")+len("body {}")+len("alert(1)")) {
+		t.Errorf("unexpected total bytes %d", summary.TotalBytes)
+	}
+	if summary.URL != "https://testusr-site.pgs.test/" {
+		t.Errorf("unexpected url %s", summary.URL)
+	}
+
+	userID := dbpool.Users[0].ID
+	if got := readDeployObject(t, st, userID, "site/index.html"); got != "hello world!" {
+		t.Errorf("want index.html contents, got %q", got)
+	}
+	if got := readDeployObject(t, st, userID, "site/css/main.css"); got != "body {}" {
+		t.Errorf("want main.css contents, got %q", got)
+	}
+	// entries are not allowed to escape the project
+	if got := readDeployObject(t, st, userID, "site/escape.js"); got != "alert(1)" {
+		t.Errorf("want escape.js contents, got %q", got)
+	}
+}
+
+func TestDeployApiMultipart(t *testing.T) {
+	router, dbpool, st := newDeployTestRouter(t)
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for name, contents := range map[string]string{
+		"index.html":   "hello world!",
+		"sub/about.md": "about",
+		".env":         "SECRET=1",
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="file"; filename="`+name+`"`)
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = part.Write([]byte(contents))
+	}
+	_ = mw.Close()
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, newDeployRequest("deploy-token", mw.FormDataContentType(), body))
+
+	// dotfiles are rejected by the default denylist
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("want status 422, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	summary := &DeploySummary{}
+	if err := json.NewDecoder(recorder.Body).Decode(summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.TotalFiles != 2 {
+		t.Errorf("want 2 files, got %d", summary.TotalFiles)
+	}
+	if len(summary.Errors) != 1 || summary.Errors[0].Path != "/.env" {
+		t.Errorf("want .env to be rejected, got %+v", summary.Errors)
+	}
+
+	userID := dbpool.Users[0].ID
+	if got := readDeployObject(t, st, userID, "site/sub/about.md"); got != "about" {
+		t.Errorf("want about.md contents, got %q", got)
+	}
+}
+
+func TestDeployApiAuth(t *testing.T) {
+	router, _, _ := newDeployTestRouter(t)
+
+	tt := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{name: "missing", token: "", status: http.StatusUnauthorized},
+		{name: "invalid", token: "nope", status: http.StatusUnauthorized},
+		{name: "wrong-scope", token: "pipe-token", status: http.StatusForbidden},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			body := newTestTarball(t, map[string]string{"index.html": "hello world!"})
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, newDeployRequest(tc.token, "application/x-tar", body))
+			if recorder.Code != tc.status {
+				t.Errorf("want status %d, got %d", tc.status, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestDeployApiNotOnTunnelRouter(t *testing.T) {
+	router, _, _ := newDeployTestRouter(t)
+	tunnel := newWebRouter(router.Cfg)
+	if tunnel.Uploader != nil {
+		t.Fatal("tunnel routers should not build an uploader")
+	}
+
+	recorder := httptest.NewRecorder()
+	tunnel.ServeHTTP(recorder, newDeployRequest("deploy-token", "application/gzip", newTestTarball(t, nil)))
+	if recorder.Code == http.StatusOK {
+		t.Fatal("expected the deploy api to be missing from tunnel routers")
+	}
+}
@@ -0,0 +1,258 @@
+package pgs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/picosh/pico/pkg/pssh"
+	sendutils "github.com/picosh/pico/pkg/send/utils"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/storage"
+)
+
+// linksFile keeps track of the symlinks and hard links uploaded to a
+// project.  Object storage has no notion of links so each one is stored as
+// an alias that gets served like the file or directory it points to.
+const linksFile = "_pgs_links"
+
+// A project can easily have more links than fit in the other special files.
+var maxLinksFileSize = int64(256 * shared.KB)
+
+// Link is an alias from one path in a project to another path in the same
+// project or to an external url.  Both paths are relative to the project
+// root and start with a slash, e.g. "/docs/latest" -> "/docs/v2".
+type Link struct {
+	From string
+	To   string
+}
+
+// parseLinksText parses the `_pgs_links` format, one "from to" pair per
+// line.  Malformed lines are skipped since the file is managed by us.
+func parseLinksText(text string) []*Link {
+	links := []*Link{}
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		parts := reSplitWhitespace.Split(trimmed, -1)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "/") || !isToPart(parts[1]) {
+			continue
+		}
+		links = append(links, &Link{From: parts[0], To: parts[1]})
+	}
+	return links
+}
+
+func linksText(links []*Link) string {
+	slices.SortFunc(links, func(a, b *Link) int {
+		return strings.Compare(a.From, b.From)
+	})
+	var sb strings.Builder
+	for _, link := range links {
+		sb.WriteString(fmt.Sprintf("%s %s\n", link.From, link.To))
+	}
+	return sb.String()
+}
+
+// linksToRedirects turns aliases into rewrite rules so they go through the
+// same routing as `_redirects`.  We don't know whether a link points to a
+// file or a directory so both are covered, the wildcard rule comes first
+// to keep "/link/" resolving to the index.html of the target directory.
+func linksToRedirects(links []*Link) []*RedirectRule {
+	rules := []*RedirectRule{}
+	for _, link := range links {
+		if isUrl(link.To) {
+			rules = append(rules, &RedirectRule{
+				From:   link.From,
+				To:     link.To,
+				Status: 301,
+				Query:  map[string]string{},
+			})
+			continue
+		}
+		rules = append(
+			rules,
+			&RedirectRule{
+				From:   path.Join(link.From, "*"),
+				To:     path.Join(link.To, ":splat"),
+				Status: 200,
+				Query:  map[string]string{},
+			},
+			&RedirectRule{
+				From:   link.From,
+				To:     link.To,
+				Status: 200,
+				Query:  map[string]string{},
+			},
+		)
+	}
+	return rules
+}
+
+func readLinks(st storage.StorageServe, bucket storage.Bucket, projectDir string) []*Link {
+	fp, _, err := st.GetObject(bucket, filepath.Join(projectDir, linksFile))
+	if err != nil {
+		return []*Link{}
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+	data, err := io.ReadAll(io.LimitReader(fp, maxLinksFileSize))
+	if err != nil {
+		return []*Link{}
+	}
+	return parseLinksText(string(data))
+}
+
+func writeLinks(st storage.StorageServe, bucket storage.Bucket, projectDir string, links []*Link) error {
+	text := linksText(links)
+	if int64(len(text)) > maxLinksFileSize {
+		return fmt.Errorf("too many links in project, %s is limited to %.2fmb", linksFile, shared.BytesToMB(int(maxLinksFileSize)))
+	}
+	_, _, err := st.PutObject(
+		bucket,
+		filepath.Join("/", projectDir, linksFile),
+		bytes.NewReader([]byte(text)),
+		&storage.ObjectInfo{},
+	)
+	return err
+}
+
+// linkFromEntry converts a link uploaded to a project into an alias.
+// Symlinks are relative to the directory of the link, absolute symlinks
+// point somewhere on the client's machine and can't be resolved.  Hard
+// links already name another file in the upload.  Either way the target
+// has to stay within the project.
+func linkFromEntry(projectName string, entry *sendutils.FileEntry) (*Link, error) {
+	from := path.Clean(strings.TrimPrefix(entry.Filepath, "/"+projectName))
+	if from == "." || from == "/" {
+		return nil, fmt.Errorf("ERROR: (%s) a project cannot be a link", entry.Filepath)
+	}
+	if !strings.HasPrefix(from, "/") {
+		from = "/" + from
+	}
+
+	target := entry.LinkTarget
+	if isUrl(target) {
+		return &Link{From: from, To: target}, nil
+	}
+
+	var rel string
+	if entry.HardLink {
+		rel = strings.TrimPrefix(path.Clean(target), "/")
+		prefix := projectName + "/"
+		if !strings.HasPrefix(rel, prefix) {
+			return nil, fmt.Errorf("ERROR: (%s) hard link target %s is outside of project", entry.Filepath, target)
+		}
+		rel = strings.TrimPrefix(rel, prefix)
+	} else {
+		if path.IsAbs(target) {
+			return nil, fmt.Errorf("ERROR: (%s) absolute symlink %s cannot be resolved, use a relative target", entry.Filepath, target)
+		}
+		rel = path.Join(strings.TrimPrefix(path.Dir(from), "/"), target)
+		if rel == ".." || strings.HasPrefix(rel, "../") {
+			return nil, fmt.Errorf("ERROR: (%s) symlink target %s is outside of project", entry.Filepath, target)
+		}
+	}
+
+	to := path.Join("/", rel)
+	if to == from {
+		return nil, fmt.Errorf("ERROR: (%s) link points to itself", entry.Filepath)
+	}
+	return &Link{From: from, To: to}, nil
+}
+
+// writeLink stores a symlink or hard link as an alias in the project's
+// `_pgs_links` instead of uploading any contents, a hard linked file is
+// therefore only stored once.
+func (h *UploadAssetHandler) writeLink(s *pssh.SSHServerConnSession, bucket storage.Bucket, projectName string, entry *sendutils.FileEntry) (string, error) {
+	logger := pssh.GetLogger(s).With(
+		"file", entry.Filepath,
+		"target", entry.LinkTarget,
+		"hardlink", entry.HardLink,
+	)
+	user := pssh.GetUser(s)
+
+	link, err := linkFromEntry(projectName, entry)
+	if err != nil {
+		logger.Error("could not resolve link", "err", err.Error())
+		return "", err
+	}
+
+	h.linksMu.Lock()
+	defer h.linksMu.Unlock()
+
+	links := readLinks(h.Cfg.Storage, bucket, projectName)
+	links = slices.DeleteFunc(links, func(l *Link) bool {
+		return l.From == link.From
+	})
+	links = append(links, link)
+	if err := writeLinks(h.Cfg.Storage, bucket, projectName, links); err != nil {
+		logger.Error("could not write links", "err", err.Error())
+		return "", err
+	}
+
+	// a file stored under the same name would shadow the alias
+	assetFilepath := shared.GetAssetFileName(entry)
+	obj, _, err := h.Cfg.Storage.GetObject(bucket, assetFilepath)
+	if err == nil {
+		_ = obj.Close()
+		if err := h.Cfg.Storage.DeleteObject(bucket, assetFilepath); err != nil {
+			logger.Error("could not remove file replaced by link", "err", err.Error())
+		}
+	}
+
+	logger.Info("stored link", "from", link.From, "to", link.To)
+
+	surrogate := getSurrogateKey(user.Name, projectName)
+	h.Cfg.CacheClearingQueue <- surrogate
+
+	url := h.Cfg.AssetURL(user.Name, projectName, strings.TrimPrefix(link.From, "/"))
+	return fmt.Sprintf("%s -> %s", url, link.To), nil
+}
+
+// deleteLink removes the alias for the given path, it reports false when
+// the path is not a link.
+func (h *UploadAssetHandler) deleteLink(bucket storage.Bucket, projectName string, entry *sendutils.FileEntry) (bool, error) {
+	from := path.Join("/", strings.TrimPrefix(entry.Filepath, "/"+projectName))
+
+	h.linksMu.Lock()
+	defer h.linksMu.Unlock()
+
+	links := readLinks(h.Cfg.Storage, bucket, projectName)
+	next := slices.DeleteFunc(slices.Clone(links), func(l *Link) bool {
+		return l.From == from
+	})
+	if len(next) == len(links) {
+		return false, nil
+	}
+	return true, writeLinks(h.Cfg.Storage, bucket, projectName, next)
+}
+
+// listLinks returns the aliases beneath a directory of the project as
+// regular entries so they can be synced and removed like any other file.
+func listLinks(links []*Link, projectName, dir string, recursive bool) []os.FileInfo {
+	prefix := path.Join("/", strings.TrimPrefix(dir, "/"+projectName))
+	if prefix != "/" {
+		prefix += "/"
+	}
+	entries := []os.FileInfo{}
+	for _, link := range links {
+		if !strings.HasPrefix(link.From, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(link.From, prefix)
+		if !recursive && strings.Contains(name, "/") {
+			continue
+		}
+		entries = append(entries, &sendutils.VirtualFile{FName: name})
+	}
+	return entries
+}
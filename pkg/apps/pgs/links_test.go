@@ -0,0 +1,128 @@
+package pgs
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	sendutils "github.com/picosh/pico/pkg/send/utils"
+)
+
+func TestLinkFromEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   *sendutils.FileEntry
+		want    *Link
+		wantErr bool
+	}{
+		{
+			name:  "relative-symlink",
+			entry: &sendutils.FileEntry{Filepath: "/test/docs/latest", LinkTarget: "v2"},
+			want:  &Link{From: "/docs/latest", To: "/docs/v2"},
+		},
+		{
+			name:  "parent-symlink",
+			entry: &sendutils.FileEntry{Filepath: "/test/docs/home.html", LinkTarget: "../index.html"},
+			want:  &Link{From: "/docs/home.html", To: "/index.html"},
+		},
+		{
+			name:  "url-symlink",
+			entry: &sendutils.FileEntry{Filepath: "/test/gh", LinkTarget: "https://github.com/picosh"},
+			want:  &Link{From: "/gh", To: "https://github.com/picosh"},
+		},
+		{
+			name:  "hard-link",
+			entry: &sendutils.FileEntry{Filepath: "/test/copy.html", LinkTarget: "/test/css/../index.html", HardLink: true},
+			want:  &Link{From: "/copy.html", To: "/index.html"},
+		},
+		{
+			name:    "escapes-project",
+			entry:   &sendutils.FileEntry{Filepath: "/test/docs/secret", LinkTarget: "../../other/index.html"},
+			wantErr: true,
+		},
+		{
+			name:    "absolute-symlink",
+			entry:   &sendutils.FileEntry{Filepath: "/test/passwd", LinkTarget: "/etc/passwd"},
+			wantErr: true,
+		},
+		{
+			name:    "hard-link-other-project",
+			entry:   &sendutils.FileEntry{Filepath: "/test/copy.html", LinkTarget: "/other/index.html", HardLink: true},
+			wantErr: true,
+		},
+		{
+			name:    "self",
+			entry:   &sendutils.FileEntry{Filepath: "/test/loop", LinkTarget: "loop"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := linkFromEntry("test", tc.entry)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestLinksText(t *testing.T) {
+	links := []*Link{
+		{From: "/gh", To: "https://github.com/picosh"},
+		{From: "/docs/latest", To: "/docs/v2"},
+	}
+	text := linksText(links)
+	want := "/docs/latest /docs/v2\n/gh https://github.com/picosh\n"
+	if text != want {
+		t.Fatalf("want %q, got %q", want, text)
+	}
+
+	got := parseLinksText(text + "bogus\n")
+	if diff := cmp.Diff(links, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCalcRoutesLinks(t *testing.T) {
+	redirects := linksToRedirects([]*Link{
+		{From: "/docs/latest", To: "/docs/v2"},
+		{From: "/home.html", To: "/index.html"},
+		{From: "/gh", To: "https://github.com/picosh"},
+	})
+
+	tests := []struct {
+		fp     string
+		want   string
+		status int
+	}{
+		{fp: "/docs/latest/", want: "test/docs/v2/index.html", status: 200},
+		{fp: "/docs/latest/install.html", want: "test/docs/v2/install.html", status: 200},
+		{fp: "/home.html", want: "test/index.html", status: 200},
+		{fp: "/gh", want: "https://github.com/picosh", status: 301},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.fp, func(t *testing.T) {
+			routes := calcRoutes("test", tc.fp, redirects)
+			found := slices.ContainsFunc(routes, func(r *HttpReply) bool {
+				return r.Filepath == tc.want && r.Status == tc.status
+			})
+			if !found {
+				for _, r := range routes {
+					t.Logf("route %s %d", r.Filepath, r.Status)
+				}
+				t.Fatalf("expected route %s (%d)", tc.want, tc.status)
+			}
+		})
+	}
+}
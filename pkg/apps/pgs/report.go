@@ -0,0 +1,411 @@
+package pgs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/picosh/pico/pkg/pssh"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/storage"
+	"golang.org/x/net/html"
+)
+
+// defaultReportFileMax is the size above which the site report flags a file.
+var defaultReportFileMax = int64(5 * shared.MB)
+
+// maxReportHtmlSize caps how much of a single html file we scan for links.
+var maxReportHtmlSize = int64(5 * shared.MB)
+
+// linkAttrs are the attributes that reference other files on the site.
+var linkAttrs = []string{"href", "src", "srcset", "poster"}
+
+type BrokenLink struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type OversizedFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+type SiteReport struct {
+	Project          string           `json:"project"`
+	FileMax          int64            `json:"file_max"`
+	BrokenLinks      []*BrokenLink    `json:"broken_links"`
+	OversizedFiles   []*OversizedFile `json:"oversized_files"`
+	UnmatchedHeaders []string         `json:"unmatched_headers"`
+	ScannedHtmlFiles int              `json:"scanned_html_files"`
+	ScannedFiles     int              `json:"scanned_files"`
+}
+
+func (r *SiteReport) HasIssues() bool {
+	return len(r.BrokenLinks) > 0 || len(r.OversizedFiles) > 0 || len(r.UnmatchedHeaders) > 0
+}
+
+func (r *SiteReport) String() string {
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(
+		&sb,
+		"site report for %s (%d files, %d html):\r\n",
+		r.Project,
+		r.ScannedFiles,
+		r.ScannedHtmlFiles,
+	)
+	if !r.HasIssues() {
+		sb.WriteString("  no issues found\r\n")
+		return sb.String()
+	}
+
+	if len(r.BrokenLinks) > 0 {
+		_, _ = fmt.Fprintf(&sb, "  broken links (%d):\r\n", len(r.BrokenLinks))
+		for _, link := range r.BrokenLinks {
+			_, _ = fmt.Fprintf(&sb, "    %s -> %s\r\n", link.Source, link.Target)
+		}
+	}
+
+	if len(r.OversizedFiles) > 0 {
+		_, _ = fmt.Fprintf(
+			&sb,
+			"  files over %.2fmb (%d):\r\n",
+			shared.BytesToMB(int(r.FileMax)),
+			len(r.OversizedFiles),
+		)
+		for _, file := range r.OversizedFiles {
+			_, _ = fmt.Fprintf(&sb, "    %s (%.2fmb)\r\n", file.Path, shared.BytesToMB(int(file.Size)))
+		}
+	}
+
+	if len(r.UnmatchedHeaders) > 0 {
+		_, _ = fmt.Fprintf(&sb, "  _headers rules that match no files (%d):\r\n", len(r.UnmatchedHeaders))
+		for _, rule := range r.UnmatchedHeaders {
+			_, _ = fmt.Fprintf(&sb, "    %s\r\n", rule)
+		}
+	}
+
+	return sb.String()
+}
+
+type siteChecker struct {
+	st         storage.StorageServe
+	bucket     storage.Bucket
+	projectDir string
+	redirects  []*RedirectRule
+	// asset paths (e.g. "project/css/main.css") mapped to their size
+	files map[string]int64
+	dirs  map[string]bool
+}
+
+func (c *siteChecker) readSpecialFile(fname string) string {
+	fp, _, err := c.st.GetObject(c.bucket, filepath.Join(c.projectDir, fname))
+	if err != nil {
+		return ""
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+	data, err := io.ReadAll(io.LimitReader(fp, maxSpecialFileSize))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func (c *siteChecker) exists(assetPath string) bool {
+	_, ok := c.files[strings.TrimPrefix(assetPath, "/")]
+	return ok
+}
+
+// resolves mirrors how the asset handler walks the routes for a request
+// so links are only flagged when a visitor would actually get a 404.
+func (c *siteChecker) resolves(fpath string) bool {
+	routes := calcRoutes(c.projectDir, fpath, c.redirects)
+	for _, route := range routes {
+		if hasProtocol(route.Filepath) {
+			return true
+		}
+		if route.Status == http.StatusNotFound {
+			continue
+		}
+		if checkIsRedirect(route.Status) {
+			if strings.HasSuffix(route.Filepath, "/") {
+				if c.exists(path.Join(c.projectDir, route.Filepath, "index.html")) {
+					return true
+				}
+				continue
+			}
+			return true
+		}
+		if c.exists(route.Filepath) {
+			return true
+		}
+	}
+
+	// directories without an index.html get a generated listing
+	dir := strings.Trim(fpath, "/")
+	return c.dirs[path.Join(c.projectDir, dir)]
+}
+
+// findLinks returns every local reference in an html document resolved
+// against the page's location within the site.
+func findLinks(pagePath string, reader io.Reader) []string {
+	links := []string{}
+	tokenizer := html.NewTokenizer(reader)
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			return links
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		for {
+			key, val, more := tokenizer.TagAttr()
+			attr := string(key)
+			if slices.Contains(linkAttrs, attr) {
+				refs := []string{string(val)}
+				if attr == "srcset" {
+					refs = parseSrcset(string(val))
+				}
+				for _, ref := range refs {
+					link := resolveLink(pagePath, ref)
+					if link != "" {
+						links = append(links, link)
+					}
+				}
+			}
+			if !more {
+				break
+			}
+		}
+	}
+}
+
+func parseSrcset(srcset string) []string {
+	refs := []string{}
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) > 0 {
+			refs = append(refs, fields[0])
+		}
+	}
+	return refs
+}
+
+func resolveLink(pagePath, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return ""
+	}
+
+	link := u.Path
+	if !strings.HasPrefix(link, "/") {
+		link = path.Join(path.Dir(pagePath), link)
+		if strings.HasSuffix(u.Path, "/") && !strings.HasSuffix(link, "/") {
+			link += "/"
+		}
+	}
+	return link
+}
+
+func isHtmlFile(fpath string) bool {
+	ext := strings.ToLower(filepath.Ext(fpath))
+	return ext == ".html" || ext == ".htm"
+}
+
+// genSiteReport crawls every html file in a project for links that do not
+// resolve, flags files larger than fileMax, and finds `_headers` rules
+// that do not match any files.
+func genSiteReport(st storage.StorageServe, bucket storage.Bucket, projectDir string, fileMax int64) (*SiteReport, error) {
+	if fileMax <= 0 {
+		fileMax = defaultReportFileMax
+	}
+
+	report := &SiteReport{
+		Project:          projectDir,
+		FileMax:          fileMax,
+		BrokenLinks:      []*BrokenLink{},
+		OversizedFiles:   []*OversizedFile{},
+		UnmatchedHeaders: []string{},
+	}
+
+	entries, err := st.ListObjects(bucket, projectDir+"/", true)
+	if err != nil {
+		return nil, err
+	}
+
+	checker := &siteChecker{
+		st:         st,
+		bucket:     bucket,
+		projectDir: projectDir,
+		files:      map[string]int64{},
+		dirs:       map[string]bool{projectDir: true},
+	}
+	for _, entry := range entries {
+		name := strings.TrimPrefix(entry.Name(), "/")
+		if name == "" {
+			continue
+		}
+		assetPath := path.Join(projectDir, name)
+		if entry.IsDir() {
+			checker.dirs[assetPath] = true
+			continue
+		}
+		if path.Base(name) == "._pico_keep_dir" {
+			checker.dirs[path.Dir(assetPath)] = true
+			continue
+		}
+		checker.files[assetPath] = entry.Size()
+		checker.dirs[path.Dir(assetPath)] = true
+	}
+	report.ScannedFiles = len(checker.files)
+
+	links := readLinks(st, bucket, projectDir)
+	checker.redirects = linksToRedirects(links)
+	redirects, err := parseRedirectText(checker.readSpecialFile("_redirects"))
+	if err == nil {
+		checker.redirects = append(checker.redirects, redirects...)
+	}
+
+	names := []string{}
+	for assetPath := range checker.files {
+		names = append(names, assetPath)
+	}
+	slices.Sort(names)
+
+	for _, assetPath := range names {
+		size := checker.files[assetPath]
+		fpath := "/" + strings.TrimPrefix(assetPath, projectDir+"/")
+		if size > fileMax {
+			report.OversizedFiles = append(report.OversizedFiles, &OversizedFile{
+				Path: fpath,
+				Size: size,
+			})
+		}
+
+		if !isHtmlFile(fpath) {
+			continue
+		}
+
+		fp, _, err := st.GetObject(bucket, assetPath)
+		if err != nil {
+			continue
+		}
+		links := findLinks(fpath, io.LimitReader(fp, maxReportHtmlSize))
+		_ = fp.Close()
+		report.ScannedHtmlFiles += 1
+
+		seen := map[string]bool{}
+		for _, link := range links {
+			if seen[link] {
+				continue
+			}
+			seen[link] = true
+			if !checker.resolves(link) {
+				report.BrokenLinks = append(report.BrokenLinks, &BrokenLink{
+					Source: fpath,
+					Target: link,
+				})
+			}
+		}
+	}
+
+	headers, err := parseHeaderText(checker.readSpecialFile("_headers"))
+	if err == nil {
+		for _, rule := range headers {
+			rr, err := regexp.Compile(rule.Path)
+			matched := false
+			if err == nil {
+				for _, assetPath := range names {
+					if rr.MatchString(assetPath) {
+						matched = true
+						break
+					}
+				}
+			}
+			if !matched {
+				report.UnmatchedHeaders = append(report.UnmatchedHeaders, rule.Path)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// reportFileMax parses sizes like "500kb" or "2mb" and falls back to bytes.
+func reportFileMax(size string) int64 {
+	size = strings.ToLower(strings.TrimSpace(size))
+	mult := int64(1)
+	if strings.HasSuffix(size, "kb") {
+		mult = int64(shared.KB)
+		size = strings.TrimSuffix(size, "kb")
+	} else if strings.HasSuffix(size, "mb") {
+		mult = int64(shared.MB)
+		size = strings.TrimSuffix(size, "mb")
+	}
+	num, err := strconv.ParseFloat(size, 64)
+	if err != nil || num <= 0 {
+		return defaultReportFileMax
+	}
+	return int64(num * float64(mult))
+}
+
+// ReportMiddleware prints a site report once an upload finishes when the
+// client sets `PICO_PGS_REPORT`, e.g.
+//
+//	rsync -e "ssh -o SetEnv=PICO_PGS_REPORT=1" ...
+//
+// Setting it to "strict" also fails the command when issues are found so
+// CI pipelines can catch broken deploys.
+func ReportMiddleware(handler *UploadAssetHandler) pssh.SSHServerMiddleware {
+	return func(next pssh.SSHServerHandler) pssh.SSHServerHandler {
+		return func(sesh *pssh.SSHServerConnSession) error {
+			err := next(sesh)
+
+			mode := sesh.Getenv("PICO_PGS_REPORT")
+			if mode == "" || mode == "0" || mode == "false" {
+				return err
+			}
+
+			project := getProject(sesh)
+			if project == nil {
+				return err
+			}
+			bucket, berr := getBucket(sesh)
+			if berr != nil {
+				return err
+			}
+
+			logger := pssh.GetLogger(sesh)
+			report, rerr := genSiteReport(
+				handler.Cfg.Storage,
+				bucket,
+				project.ProjectDir,
+				reportFileMax(sesh.Getenv("PICO_PGS_REPORT_FILE_MAX")),
+			)
+			if rerr != nil {
+				logger.Error("could not generate site report", "err", rerr)
+				return err
+			}
+
+			_, _ = fmt.Fprint(sesh.Stderr(), report.String())
+			if err == nil && mode == "strict" && report.HasIssues() {
+				return fmt.Errorf("site report found issues with %s", project.Name)
+			}
+			return err
+		}
+	}
+}
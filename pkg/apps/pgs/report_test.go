@@ -0,0 +1,115 @@
+package pgs
+
+import (
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/picosh/pico/pkg/storage"
+)
+
+func TestResolveLink(t *testing.T) {
+	tt := []struct {
+		page string
+		ref  string
+		want string
+	}{
+		{page: "/index.html", ref: "about", want: "/about"},
+		{page: "/blog/post.html", ref: "../css/main.css?v=1", want: "/css/main.css"},
+		{page: "/blog/post.html", ref: "./", want: "/blog/"},
+		{page: "/index.html", ref: "/img/a.png#frag", want: "/img/a.png"},
+		{page: "/index.html", ref: "#top", want: ""},
+		{page: "/index.html", ref: "https://example.com/x", want: ""},
+		{page: "/index.html", ref: "//cdn.example.com/x.js", want: ""},
+		{page: "/index.html", ref: "mailto:me@example.com", want: ""},
+		{page: "/index.html", ref: "?page=2", want: ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.ref, func(t *testing.T) {
+			got := resolveLink(tc.page, tc.ref)
+			if got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGenSiteReport(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	st, err := storage.NewStorageFS(logger, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket, err := st.UpsertBucket("static-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"site/index.html": `<html><head>
+<link rel="stylesheet" href="css/main.css">
+<script src="/missing.js"></script>
+</head><body>
+<a href="/about">about</a>
+<a href="/old">old</a>
+<a href="/blog/">blog</a>
+<a href="/img/">images</a>
+<a href="/nope.html">nope</a>
+<a href="https://example.com">external</a>
+<a href="#top">top</a>
+<img srcset="img/a.png 1x, /img/b.png 2x">
+</body></html>`,
+		"site/about.html":      "<a href='index.html'>home</a>",
+		"site/blog/index.html": "<a href='../about'>about</a><a href='post'>post</a>",
+		"site/css/main.css":    "body {}",
+		"site/img/a.png":       "png",
+		"site/big.bin":         strings.Repeat("a", 2000),
+		"site/_redirects":      "/old /about 301",
+		"site/_headers":        "/css/*\n  X-Test: 1\n/nothing/*\n  X-Test: 1",
+	}
+	for fpath, contents := range files {
+		_, _, err := st.PutObject(bucket, fpath, strings.NewReader(contents), &storage.ObjectInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := genSiteReport(st, bucket, "site", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broken := []string{}
+	for _, link := range report.BrokenLinks {
+		broken = append(broken, link.Source+" -> "+link.Target)
+	}
+	slices.Sort(broken)
+	want := []string{
+		"/blog/index.html -> /blog/post",
+		"/index.html -> /img/b.png",
+		"/index.html -> /missing.js",
+		"/index.html -> /nope.html",
+	}
+	if !slices.Equal(broken, want) {
+		t.Errorf("want broken links %v, got %v", want, broken)
+	}
+
+	if len(report.OversizedFiles) != 1 || report.OversizedFiles[0].Path != "/big.bin" {
+		t.Errorf("want /big.bin to be oversized, got %+v", report.OversizedFiles)
+	}
+
+	if !slices.Equal(report.UnmatchedHeaders, []string{"/nothing/*"}) {
+		t.Errorf("want /nothing/* to be unmatched, got %v", report.UnmatchedHeaders)
+	}
+
+	if report.ScannedHtmlFiles != 3 {
+		t.Errorf("want 3 html files scanned, got %d", report.ScannedHtmlFiles)
+	}
+
+	if !report.HasIssues() || !strings.Contains(report.String(), "/index.html -> /nope.html") {
+		t.Errorf("unexpected report output: %s", report.String())
+	}
+}
@@ -30,6 +30,7 @@ func createSshServer(cfg *PgsConfig, ctx context.Context, cacheClearingQueue cha
 	)
 
 	sshAuth := shared.NewSshAuthHandler(cfg.DB, logger, "pgs")
+	sshAuth.OrgContext = true
 
 	webTunnel := &tunkit.WebTunnelHandler{
 		Logger:      logger,
@@ -52,11 +53,13 @@ func createSshServer(cfg *PgsConfig, ctx context.Context, cacheClearingQueue cha
 			scp.Middleware(handler),
 			rsync.Middleware(handler),
 			auth.Middleware(handler),
+			ReportMiddleware(handler),
 			Middleware(handler),
 			pssh.LogMiddleware(handler, handler.Cfg.DB),
 		},
 		[]pssh.SSHServerMiddleware{
 			sftp.Middleware(handler),
+			ReportMiddleware(handler),
 			pssh.LogMiddleware(handler, handler.Cfg.DB),
 		},
 		map[string]pssh.SSHServerChannelMiddleware{
@@ -12,10 +12,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	pgsdb "github.com/picosh/pico/pkg/apps/pgs/db"
 	"github.com/picosh/pico/pkg/db"
 	"github.com/picosh/pico/pkg/pssh"
@@ -168,6 +170,128 @@ func TestSshServerSftp(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 }
 
+func TestSshServerSftpLinks(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dbpool := pgsdb.NewDBMemory(logger)
+	dbpool.SetupTestData()
+	st, err := storage.NewStorageMemory(map[string]map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubsub := NewPubsubChan()
+	defer func() {
+		_ = pubsub.Close()
+	}()
+
+	_ = os.Setenv("PGS_SSH_PORT", "0")
+
+	cfg := NewPgsConfig(logger, dbpool, st, pubsub)
+	done := make(chan error)
+	readyCh := make(chan *pssh.SSHServer)
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	go StartSshServerForTesting(cfg, done, readyCh)
+	defer close(done)
+
+	server := <-readyCh
+	if server == nil {
+		t.Fatal("failed to create ssh server")
+	}
+
+	var actualAddr string
+	for i := 0; i < 100; i++ {
+		server.Mu.Lock()
+		listener := server.Listener
+		server.Mu.Unlock()
+		if listener != nil {
+			actualAddr = listener.Addr().String()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if actualAddr == "" {
+		t.Fatal("server listener not ready")
+	}
+
+	user := GenerateUser()
+	dbpool.Pubkeys = append(dbpool.Pubkeys, &db.PublicKey{
+		ID:     "nice-pubkey",
+		UserID: dbpool.Users[0].ID,
+		Key:    shared.KeyForKeyText(user.signer.PublicKey()),
+	})
+
+	conn, err := user.NewClientAddr(actualAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	f, err := client.Create("test/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("<html>home</html>")); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	if err := client.Symlink("index.html", "test/home.html"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Link("test/index.html", "test/copy.html"); err != nil {
+		t.Fatal(err)
+	}
+	// escaping the project is rejected
+	if err := client.Symlink("../../etc/passwd", "test/passwd"); err == nil {
+		t.Fatal("expected symlink outside of project to fail")
+	}
+
+	bucket, err := st.GetBucket(shared.GetAssetBucketName(dbpool.Users[0].ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := readLinks(st, bucket, "test")
+	want := []*Link{
+		{From: "/copy.html", To: "/index.html"},
+		{From: "/home.html", To: "/index.html"},
+	}
+	if diff := cmp.Diff(want, links); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// links show up like files while the file tracking them stays hidden
+	entries, err := client.ReadDir("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	slices.Sort(names)
+	if diff := cmp.Diff([]string{"copy.html", "home.html", "index.html"}, names); diff != "" {
+		t.Fatal(diff)
+	}
+
+	if err := client.Remove("test/home.html"); err != nil {
+		t.Fatal(err)
+	}
+	links = readLinks(st, bucket, "test")
+	if diff := cmp.Diff(want[:1], links); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
 func TestSshServerRsync(t *testing.T) {
 	opts := &slog.HandlerOptions{
 		AddSource: true,
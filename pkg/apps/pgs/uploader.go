@@ -17,6 +17,7 @@ import (
 
 	"github.com/picosh/pico/pkg/db"
 	"github.com/picosh/pico/pkg/pssh"
+	"github.com/picosh/pico/pkg/send/protocols/rsync"
 	sendutils "github.com/picosh/pico/pkg/send/utils"
 	"github.com/picosh/pico/pkg/shared"
 	"github.com/picosh/pico/pkg/storage"
@@ -112,6 +113,8 @@ type FileData struct {
 type UploadAssetHandler struct {
 	Cfg                *PgsConfig
 	CacheClearingQueue chan string
+	// guards the read-modify-write of `_pgs_links`
+	linksMu sync.Mutex
 }
 
 func NewUploadAssetHandler(cfg *PgsConfig, ch chan string, ctx context.Context) *UploadAssetHandler {
@@ -177,6 +180,10 @@ func (h *UploadAssetHandler) List(s *pssh.SSHServerConnSession, fpath string, is
 	bucketName := shared.GetAssetBucketName(user.ID)
 	bucket, err := h.Cfg.Storage.GetBucket(bucketName)
 	if err != nil {
+		// dry-runs do not create the bucket, see `Validate`
+		if rsync.IsDryRun(s) {
+			return fileList, nil
+		}
 		return fileList, err
 	}
 
@@ -203,6 +210,16 @@ func (h *UploadAssetHandler) List(s *pssh.SSHServerConnSession, fpath string, is
 		}
 
 		fileList = append(fileList, foundList...)
+
+		// links are listed in place of the file that tracks them
+		projectName := strings.Split(strings.Trim(cleanFilename, "/"), "/")[0]
+		if isDir && projectName != "" {
+			fileList = slices.DeleteFunc(fileList, func(f os.FileInfo) bool {
+				return f.Name() == linksFile
+			})
+			links := readLinks(h.Cfg.Storage, bucket, projectName)
+			fileList = append(fileList, listLinks(links, projectName, cleanFilename, recursive)...)
+		}
 	}
 
 	return fileList, nil
@@ -225,9 +242,22 @@ func (h *UploadAssetHandler) Validate(s *pssh.SSHServerConnSession) error {
 	setFeatureFlag(s, ff)
 
 	assetBucket := shared.GetAssetBucketName(user.ID)
-	bucket, err := h.Cfg.Storage.UpsertBucket(assetBucket)
-	if err != nil {
-		return err
+	var bucket storage.Bucket
+	if rsync.IsDryRun(s) {
+		// a dry-run must not write anything, when the bucket does not
+		// exist yet every file is simply reported as new
+		bucket, err = h.Cfg.Storage.GetBucket(assetBucket)
+		if err != nil {
+			logger.Info("dry-run for user without a bucket", "user", user.Name)
+			s.SetValue(ctxBucketKey{}, storage.Bucket{Name: assetBucket})
+			s.SetValue(ctxStorageSizeKey{}, uint64(0))
+			return nil
+		}
+	} else {
+		bucket, err = h.Cfg.Storage.UpsertBucket(assetBucket)
+		if err != nil {
+			return err
+		}
 	}
 	s.SetValue(ctxBucketKey{}, bucket)
 
@@ -281,6 +311,10 @@ func mtimeToTime(entry *sendutils.FileEntry) time.Time {
 }
 
 func (h *UploadAssetHandler) Write(s *pssh.SSHServerConnSession, entry *sendutils.FileEntry) (string, error) {
+	if !pssh.CanWrite(s) {
+		return "", pssh.ErrOrgViewer
+	}
+
 	logger := pssh.GetLogger(s)
 	user := pssh.GetUser(s)
 
@@ -327,6 +361,10 @@ func (h *UploadAssetHandler) Write(s *pssh.SSHServerConnSession, entry *sendutil
 		return "", fmt.Errorf(msg, project.Blocked)
 	}
 
+	if entry.LinkTarget != "" {
+		return h.writeLink(s, bucket, projectName, entry)
+	}
+
 	info := &storage.ObjectInfo{
 		LastModified: mtimeToTime(entry),
 	}
@@ -454,10 +492,14 @@ func (h *UploadAssetHandler) Write(s *pssh.SSHServerConnSession, entry *sendutil
 
 func isSpecialFile(entry string) bool {
 	fname := filepath.Base(entry)
-	return fname == "_headers" || fname == "_redirects" || fname == "_pgs_ignore"
+	return fname == "_headers" || fname == "_redirects" || fname == "_pgs_ignore" || fname == linksFile
 }
 
 func (h *UploadAssetHandler) Delete(s *pssh.SSHServerConnSession, entry *sendutils.FileEntry) error {
+	if !pssh.CanWrite(s) {
+		return pssh.ErrOrgViewer
+	}
+
 	logger := pssh.GetLogger(s)
 	user := pssh.GetUser(s)
 
@@ -488,6 +530,16 @@ func (h *UploadAssetHandler) Delete(s *pssh.SSHServerConnSession, entry *senduti
 
 	logger.Info("deleting file")
 
+	isLink, err := h.deleteLink(bucket, projectName, entry)
+	if err != nil {
+		return err
+	}
+	if isLink {
+		surrogate := getSurrogateKey(user.Name, projectName)
+		h.Cfg.CacheClearingQueue <- surrogate
+		return nil
+	}
+
 	// Check if this path represents a directory (has a . _pico_keep_dir marker)
 	keepDirPath := filepath.Join(assetFilepath, "._pico_keep_dir")
 	keepDirReader, _, keepDirErr := h.Cfg.Storage.GetObject(bucket, keepDirPath)
@@ -137,6 +137,7 @@ func StartApiServer(cfg *PgsConfig) {
 	ctx := context.Background()
 
 	router := NewWebRouter(cfg)
+	go runCacheQueue(router.Uploader.Cfg, ctx)
 	httpCache := NewPgsHttpCache(router.Cfg, router)
 	go CacheMgmt(ctx, cfg.CacheClearingQueue, cfg, httpCache.Cache)
 
@@ -161,10 +162,20 @@ type WebRouter struct {
 	UserRouter     *http.ServeMux
 	RedirectsCache *expirable.LRU[string, []*RedirectRule]
 	HeadersCache   *expirable.LRU[string, []*HeaderRule]
+	Uploader       *UploadAssetHandler
 }
 
 func NewWebRouter(cfg *PgsConfig) *WebRouter {
 	router := newWebRouter(cfg)
+	// deploys get their own cache clearing queue so purges are published
+	// to pubsub instead of being consumed by `WatchCacheClear`
+	uploadCfg := *cfg
+	uploadCfg.CacheClearingQueue = make(chan string, 100)
+	router.Uploader = &UploadAssetHandler{
+		Cfg:                &uploadCfg,
+		CacheClearingQueue: uploadCfg.CacheClearingQueue,
+	}
+	router.RootRouter.HandleFunc("POST /api/deploy/{project}", router.deployHandler)
 	go router.WatchCacheClear()
 	return router
 }
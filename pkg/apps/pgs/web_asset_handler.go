@@ -74,6 +74,11 @@ func (h *ApiAssetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		// links behave like the files they point to so they take
+		// precedence over user defined redirects
+		links := readLinks(h.Cfg.Storage, h.Bucket, h.ProjectDir)
+		redirects = append(linksToRedirects(links), redirects...)
+
 		h.RedirectsCache.Add(redirectsCacheKey, redirects)
 	}
 
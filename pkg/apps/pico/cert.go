@@ -0,0 +1,219 @@
+package pico
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+	"golang.org/x/crypto/ssh"
+)
+
+// maxPubkeySize caps how much of stdin we read when issuing a cert.
+const maxPubkeySize = 16 * 1024
+
+func (c *Cmd) findOrCreateCA() (*db.SshCertAuthority, error) {
+	ca, err := c.Dbpool.FindSshCertAuthority(c.User.ID)
+	if err == nil {
+		return ca, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to find cert authority: %w", err)
+	}
+
+	pubkey, privkey, err := shared.NewCertAuthority()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate cert authority: %w", err)
+	}
+	sealed, err := shared.SealCertAuthority(c.CertSecret, privkey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal cert authority: %w", err)
+	}
+
+	ca, err = c.Dbpool.InsertSshCertAuthority(c.User.ID, pubkey, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cert authority: %w", err)
+	}
+	return ca, nil
+}
+
+func (c *Cmd) cert(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("must provide a cert command: [issue, ls, revoke, ca]")
+	}
+
+	switch args[0] {
+	case "issue":
+		return c.certIssue(args[1:])
+	case "ls":
+		return c.certList()
+	case "revoke":
+		if len(args) < 2 {
+			return fmt.Errorf("must provide a serial or key id to revoke")
+		}
+		return c.certRevoke(args[1])
+	case "ca":
+		ca, err := c.findOrCreateCA()
+		if err != nil {
+			return err
+		}
+		c.output(ca.PublicKey)
+		return nil
+	default:
+		return fmt.Errorf("unknown cert command %q, must be one of [issue, ls, revoke, ca]", args[0])
+	}
+}
+
+func (c *Cmd) certIssue(args []string) error {
+	fs := flag.NewFlagSet("cert issue", flag.ContinueOnError)
+	fs.SetOutput(c.SshSession.Stderr())
+	principal := fs.String("principal", "", "comma separated services the cert is valid for, e.g. pgs,pipe")
+	ttl := fs.Duration("ttl", shared.DefaultCertTTL, "how long the cert is valid for")
+	keyID := fs.String("key-id", "", "name of the cert, displayed in access logs")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.SshSession, maxPubkeySize))
+	if err != nil {
+		return fmt.Errorf("failed to read public key from stdin: %w", err)
+	}
+	pubkey, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse public key from stdin: %w", err)
+	}
+
+	var principals []string
+	for _, princ := range strings.Split(*principal, ",") {
+		princ = strings.TrimSpace(princ)
+		if princ != "" {
+			principals = append(principals, princ)
+		}
+	}
+
+	ca, err := c.findOrCreateCA()
+	if err != nil {
+		return err
+	}
+	signer, err := shared.OpenCertAuthority(c.CertSecret, ca.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse cert authority: %w", err)
+	}
+
+	serial, err := shared.NewCertSerial()
+	if err != nil {
+		return err
+	}
+
+	cert, err := shared.SignUserCert(signer, pubkey, &shared.CertOpts{
+		KeyID:      *keyID,
+		Principals: principals,
+		TTL:        *ttl,
+		Serial:     serial,
+	})
+	if err != nil {
+		return err
+	}
+
+	validAfter := time.Unix(int64(cert.ValidAfter), 0).UTC()
+	validBefore := time.Unix(int64(cert.ValidBefore), 0).UTC()
+	err = c.Dbpool.InsertSshCert(&db.SshCert{
+		CaID:        ca.ID,
+		UserID:      c.User.ID,
+		Serial:      int64(cert.Serial),
+		KeyID:       cert.KeyId,
+		Principals:  strings.Join(cert.ValidPrincipals, ","),
+		PublicKey:   shared.KeyForKeyText(pubkey),
+		ValidAfter:  &validAfter,
+		ValidBefore: &validBefore,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record cert: %w", err)
+	}
+
+	c.output(strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert))) + " " + cert.KeyId)
+	return nil
+}
+
+func (c *Cmd) certList() error {
+	certs, err := c.Dbpool.FindSshCertsByUser(c.User.ID)
+	if err != nil {
+		return fmt.Errorf("failed to find certs: %w", err)
+	}
+
+	if len(certs) == 0 {
+		c.output("no certs found")
+		return nil
+	}
+
+	now := time.Now()
+	writer := tabwriter.NewWriter(c.Session, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "Serial\tKey ID\tPrincipals\tExpires\tStatus\r")
+	for _, cert := range certs {
+		status := "active"
+		if cert.RevokedAt != nil {
+			status = "revoked"
+		} else if cert.ValidBefore != nil && now.After(*cert.ValidBefore) {
+			status = "expired"
+		}
+
+		expires := ""
+		if cert.ValidBefore != nil {
+			expires = cert.ValidBefore.Format(time.RFC3339)
+		}
+
+		_, _ = fmt.Fprintf(
+			writer,
+			"%d\t%s\t%s\t%s\t%s\r\n",
+			cert.Serial,
+			cert.KeyID,
+			cert.Principals,
+			expires,
+			status,
+		)
+	}
+	return writer.Flush()
+}
+
+// certRevoke revokes the cert with serial or, when id isn't a number,
+// every active cert with that key id.
+func (c *Cmd) certRevoke(id string) error {
+	if serial, err := strconv.ParseInt(id, 10, 64); err == nil {
+		err = c.Dbpool.RevokeSshCert(c.User.ID, serial)
+		if err != nil {
+			return fmt.Errorf("failed to revoke cert: %w", err)
+		}
+		c.output(fmt.Sprintf("revoked cert %d", serial))
+		return nil
+	}
+
+	certs, err := c.Dbpool.FindSshCertsByUser(c.User.ID)
+	if err != nil {
+		return fmt.Errorf("failed to find certs: %w", err)
+	}
+
+	revoked := 0
+	for _, cert := range certs {
+		if cert.KeyID != id || cert.RevokedAt != nil {
+			continue
+		}
+		err = c.Dbpool.RevokeSshCert(c.User.ID, cert.Serial)
+		if err != nil {
+			return fmt.Errorf("failed to revoke cert %d: %w", cert.Serial, err)
+		}
+		revoked++
+	}
+
+	if revoked == 0 {
+		return fmt.Errorf("no active certs found with key id %q", id)
+	}
+	c.output(fmt.Sprintf("revoked %d cert(s) with key id %s", revoked, id))
+	return nil
+}
@@ -42,6 +42,7 @@ type Cmd struct {
 	Log        *slog.Logger
 	Dbpool     db.DB
 	Write      bool
+	CertSecret string
 }
 
 func (c *Cmd) output(out string) {
@@ -49,13 +50,22 @@ func (c *Cmd) output(out string) {
 }
 
 func (c *Cmd) help() {
-	helpStr := "Commands: [help, user, logs, access_logs, chat, not-found]\n"
+	helpStr := "Commands: [help, user, logs, access_logs, chat, not-found, cert, org]\n"
 	helpStr += "help - this message\n"
 	helpStr += "user - display user information (returns name, id, account created, pico+ expiration)\n"
 	helpStr += "logs - stream user logs\n"
 	helpStr += "access_logs - fetch access logs from the last 30 days\n"
 	helpStr += "chat - IRC chat (must enable pty with `-t` to the SSH command)\n"
 	helpStr += "not-found - return all status 404 requests for a host (hostname.com [year|month])\n"
+	helpStr += "cert issue --principal pgs --ttl 1h --key-id ci < id.pub - sign an ssh cert for a public key\n"
+	helpStr += "cert ls - list issued ssh certs\n"
+	helpStr += "cert revoke {serial|key-id} - revoke issued ssh certs\n"
+	helpStr += "cert ca - print the public key that signs your ssh certs\n"
+	helpStr += "org create {name} - create an org, then deploy to it with `ssh {name}@pgs.sh`\n"
+	helpStr += "org ls - list orgs you are a member of\n"
+	helpStr += "org members {org} - list org members and their roles\n"
+	helpStr += "org add {org} {user} [owner|deployer|viewer] - add or update an org member\n"
+	helpStr += "org rm {org} {user} - remove an org member\n"
 	c.output(helpStr)
 }
 
@@ -176,6 +186,8 @@ func (c *Cmd) logs(ctx context.Context) error {
 type CliHandler struct {
 	DBPool db.DB
 	Logger *slog.Logger
+	// CertSecret encrypts the ssh cert authority private keys at rest.
+	CertSecret string
 }
 
 func Middleware(handler *CliHandler) pssh.SSHServerMiddleware {
@@ -189,6 +201,11 @@ func Middleware(handler *CliHandler) pssh.SSHServerMiddleware {
 				return next(sesh)
 			}
 
+			if !pssh.CanManage(sesh) {
+				sesh.Fatal(pssh.ErrOrgOwner)
+				return pssh.ErrOrgOwner
+			}
+
 			user, err := getUser(sesh, dbpool)
 			if err != nil {
 				_, _ = fmt.Fprintf(sesh.Stderr(), "detected ssh command: %s\n", args)
@@ -247,9 +264,26 @@ func Middleware(handler *CliHandler) pssh.SSHServerMiddleware {
 				Log:        log,
 				Dbpool:     dbpool,
 				Write:      false,
+				CertSecret: handler.CertSecret,
 			}
 
 			cmd := strings.TrimSpace(args[0])
+			if cmd == "cert" {
+				err = opts.cert(args[1:])
+				if err != nil {
+					sesh.Fatal(err)
+				}
+				return nil
+			}
+
+			if cmd == "org" {
+				err = opts.org(args[1:])
+				if err != nil {
+					sesh.Fatal(err)
+				}
+				return nil
+			}
+
 			if len(args) == 1 {
 				switch cmd {
 				case "help":
@@ -51,6 +51,10 @@ func (h *UploadHandler) getAuthorizedKeyFile(user *db.User) (*sendutils.VirtualF
 }
 
 func (h *UploadHandler) Delete(s *pssh.SSHServerConnSession, entry *sendutils.FileEntry) error {
+	if !pssh.CanManage(s) {
+		return pssh.ErrOrgOwner
+	}
+
 	return errors.New("unsupported")
 }
 
@@ -285,6 +289,10 @@ func (h *UploadHandler) ProcessAuthorizedKeys(text []byte, logger *slog.Logger,
 }
 
 func (h *UploadHandler) Write(s *pssh.SSHServerConnSession, entry *sendutils.FileEntry) (string, error) {
+	if !pssh.CanManage(s) {
+		return "", pssh.ErrOrgOwner
+	}
+
 	logger := pssh.GetLogger(s)
 	user := pssh.GetUser(s)
 
@@ -0,0 +1,197 @@
+package pico
+
+import (
+	"fmt"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+)
+
+var orgRoles = []string{db.OrgRoleOwner, db.OrgRoleDeployer, db.OrgRoleViewer}
+
+func (c *Cmd) org(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("must provide an org command: [create, ls, members, add, rm]")
+	}
+
+	switch args[0] {
+	case "create":
+		if len(args) < 2 {
+			return fmt.Errorf("must provide an org name")
+		}
+		return c.orgCreate(args[1])
+	case "ls":
+		return c.orgList()
+	case "members":
+		if len(args) < 2 {
+			return fmt.Errorf("must provide an org name")
+		}
+		return c.orgMembers(args[1])
+	case "add":
+		if len(args) < 3 {
+			return fmt.Errorf("must provide an org name and username")
+		}
+		role := db.OrgRoleViewer
+		if len(args) > 3 {
+			role = args[3]
+		}
+		return c.orgAdd(args[1], args[2], role)
+	case "rm":
+		if len(args) < 3 {
+			return fmt.Errorf("must provide an org name and username")
+		}
+		return c.orgRemove(args[1], args[2])
+	default:
+		return fmt.Errorf("unknown org command %q, must be one of [create, ls, members, add, rm]", args[0])
+	}
+}
+
+// findOrg returns the org along with the current user's membership.
+func (c *Cmd) findOrg(name string) (*db.User, *db.OrgMember, error) {
+	org, err := c.Dbpool.FindUserByName(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("org %q not found", name)
+	}
+	member, err := c.Dbpool.FindOrgMember(org.ID, c.User.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("org %q not found", name)
+	}
+	return org, member, nil
+}
+
+func (c *Cmd) orgCreate(name string) error {
+	org, err := c.Dbpool.InsertOrg(c.User.ID, name)
+	if err != nil {
+		return fmt.Errorf("failed to create org: %w", err)
+	}
+	c.output(fmt.Sprintf("created org %s, connect to it with `ssh %s@pgs.sh`", org.Name, org.Name))
+	return nil
+}
+
+func (c *Cmd) orgList() error {
+	orgs, err := c.Dbpool.FindOrgsByUser(c.User.ID)
+	if err != nil {
+		return fmt.Errorf("failed to find orgs: %w", err)
+	}
+
+	if len(orgs) == 0 {
+		c.output("no orgs found")
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(c.Session, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "Org\tRole\tJoined\r")
+	for _, org := range orgs {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\r\n", org.OrgName, org.Role, org.CreatedAt.Format(time.RFC3339))
+	}
+	return writer.Flush()
+}
+
+func (c *Cmd) orgMembers(name string) error {
+	org, _, err := c.findOrg(name)
+	if err != nil {
+		return err
+	}
+
+	members, err := c.Dbpool.FindOrgMembers(org.ID)
+	if err != nil {
+		return fmt.Errorf("failed to find org members: %w", err)
+	}
+
+	writer := tabwriter.NewWriter(c.Session, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "User\tRole\tJoined\r")
+	for _, member := range members {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\r\n", member.UserName, member.Role, member.CreatedAt.Format(time.RFC3339))
+	}
+	return writer.Flush()
+}
+
+func (c *Cmd) orgAdd(name, username, role string) error {
+	if !slices.Contains(orgRoles, role) {
+		return fmt.Errorf("invalid role %q, must be one of %v", role, orgRoles)
+	}
+
+	org, member, err := c.findOrg(name)
+	if err != nil {
+		return err
+	}
+	if member.Role != db.OrgRoleOwner {
+		return fmt.Errorf("only org owners can manage members")
+	}
+
+	user, err := c.Dbpool.FindUserByName(username)
+	if err != nil {
+		return fmt.Errorf("user %q not found", username)
+	}
+	if user.ID == org.ID {
+		return fmt.Errorf("an org cannot be a member of itself")
+	}
+	if user.ID == c.User.ID && role != db.OrgRoleOwner {
+		err = c.ensureOtherOwner(org.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Dbpool.UpsertOrgMember(org.ID, user.ID, role)
+	if err != nil {
+		return fmt.Errorf("failed to add org member: %w", err)
+	}
+	c.output(fmt.Sprintf("%s is now a %s of %s", user.Name, role, org.Name))
+	return nil
+}
+
+// orgRemove removes a member from the org.  Owners can remove anyone,
+// everyone else can only remove themselves.
+func (c *Cmd) orgRemove(name, username string) error {
+	org, member, err := c.findOrg(name)
+	if err != nil {
+		return err
+	}
+
+	user, err := c.Dbpool.FindUserByName(username)
+	if err != nil {
+		return fmt.Errorf("user %q not found", username)
+	}
+	if member.Role != db.OrgRoleOwner && user.ID != c.User.ID {
+		return fmt.Errorf("only org owners can manage members")
+	}
+
+	target, err := c.Dbpool.FindOrgMember(org.ID, user.ID)
+	if err != nil {
+		return fmt.Errorf("%s is not a member of %s", user.Name, org.Name)
+	}
+	if target.Role == db.OrgRoleOwner {
+		err = c.ensureOtherOwner(org.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Dbpool.RemoveOrgMember(org.ID, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to remove org member: %w", err)
+	}
+	c.output(fmt.Sprintf("removed %s from %s", user.Name, org.Name))
+	return nil
+}
+
+// ensureOtherOwner prevents an org from being left without an owner.
+func (c *Cmd) ensureOtherOwner(orgID string) error {
+	members, err := c.Dbpool.FindOrgMembers(orgID)
+	if err != nil {
+		return fmt.Errorf("failed to find org members: %w", err)
+	}
+	owners := 0
+	for _, member := range members {
+		if member.Role == db.OrgRoleOwner {
+			owners++
+		}
+	}
+	if owners <= 1 {
+		return fmt.Errorf("an org must have at least one owner")
+	}
+	return nil
+}
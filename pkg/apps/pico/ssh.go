@@ -62,8 +62,9 @@ func StartSshServer() {
 	)
 
 	cliHandler := &CliHandler{
-		Logger: logger,
-		DBPool: dbpool,
+		Logger:     logger,
+		DBPool:     dbpool,
+		CertSecret: shared.GetEnv("PICO_CERT_CA_SECRET", ""),
 	}
 
 	sshAuth := shared.NewSshAuthHandler(dbpool, logger, "pico")
@@ -98,6 +99,10 @@ func StartSshServer() {
 			auth.Middleware(handler),
 			func(next pssh.SSHServerHandler) pssh.SSHServerHandler {
 				return func(sesh *pssh.SSHServerConnSession) error {
+					if !pssh.CanManage(sesh) {
+						sesh.Fatal(pssh.ErrOrgOwner)
+						return pssh.ErrOrgOwner
+					}
 					shrd := &tui.SharedModel{
 						Session: sesh,
 						Cfg:     cfg,
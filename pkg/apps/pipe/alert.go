@@ -0,0 +1,313 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	psub "github.com/picosh/pico/pkg/pubsub"
+	"github.com/picosh/pico/pkg/shared"
+)
+
+const (
+	// flapWindows is how many monitor windows of history are inspected
+	// when deciding whether a monitor is flapping.
+	flapWindows = 10
+	// flapThreshold is how many outages inside flapWindows mark a monitor
+	// as flapping.  Alerts are held back until it settles.
+	flapThreshold = 2
+)
+
+const webhookSignatureHeader = "X-Pico-Signature"
+
+// emailConfirmResendAfter is how long an unconfirmed email target waits
+// before another confirmation email can be sent to it.
+const emailConfirmResendAfter = time.Hour
+
+// MonitorAlert is the payload delivered to monitor notification targets.
+type MonitorAlert struct {
+	Topic     string     `json:"topic"`
+	Status    string     `json:"status"`
+	Reason    string     `json:"reason,omitempty"`
+	Window    string     `json:"window"`
+	LastPing  *time.Time `json:"last_ping"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (a *MonitorAlert) Subject() string {
+	if a.Status == db.PipeMonitorDown {
+		return shared.SanitizeHeader(fmt.Sprintf("ALERT: %s is unhealthy", a.Topic))
+	}
+	return shared.SanitizeHeader(fmt.Sprintf("RESOLVED: %s is healthy", a.Topic))
+}
+
+func (a *MonitorAlert) Text() string {
+	lastPing := "never"
+	if a.LastPing != nil {
+		lastPing = a.LastPing.UTC().Format(time.RFC3339)
+	}
+	text := fmt.Sprintf(
+		"%s\n\nTopic: %s\nStatus: %s\nWindow: %s\nLast Ping: %s\n",
+		a.Subject(),
+		a.Topic,
+		a.Status,
+		a.Window,
+		lastPing,
+	)
+	if a.Reason != "" {
+		text += fmt.Sprintf("Reason: %s\n", a.Reason)
+	}
+	return text
+}
+
+/*
+MonitorAlerter periodically checks every monitor with notification
+targets and notifies them when the monitor goes down or recovers.  The
+last notified status is stored on the monitor so each transition is only
+delivered once, even when several pipe instances run an alerter.
+
+Webhooks are only delivered to public addresses and email targets only
+once the address confirmed them.
+*/
+type MonitorAlerter struct {
+	DBPool db.DB
+	Logger *slog.Logger
+	PubSub psub.PubSub
+	Mailer shared.MailSender
+	Client *http.Client
+}
+
+func NewMonitorAlerter(dbpool db.DB, logger *slog.Logger, pubsub psub.PubSub) *MonitorAlerter {
+	return &MonitorAlerter{
+		DBPool: dbpool,
+		Logger: logger,
+		PubSub: pubsub,
+		Mailer: shared.NewMailer(),
+		Client: shared.NewPublicHttpClient(10 * time.Second),
+	}
+}
+
+func (a *MonitorAlerter) Loop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Run(ctx, time.Now().UTC()); err != nil {
+				a.Logger.Error("monitor alerter", "err", err)
+			}
+		}
+	}
+}
+
+func (a *MonitorAlerter) Run(ctx context.Context, now time.Time) error {
+	monitors, err := a.DBPool.FindPipeMonitorsWithTargets()
+	if err != nil {
+		return err
+	}
+
+	for _, monitor := range monitors {
+		err := a.check(ctx, monitor, now)
+		if err != nil {
+			a.Logger.Error("check monitor", "topic", monitor.Topic, "err", err)
+		}
+	}
+
+	return nil
+}
+
+func (a *MonitorAlerter) check(ctx context.Context, monitor *db.PipeMonitor, now time.Time) error {
+	var history []*db.PipeMonitorHistory
+	if monitor.WindowDur > 0 {
+		from := now.Add(-flapWindows * monitor.WindowDur)
+		hist, err := a.DBPool.FindPipeMonitorHistory(monitor.ID, from, now)
+		if err != nil {
+			return fmt.Errorf("failed to fetch history: %w", err)
+		}
+		history = hist
+	}
+
+	alert := nextMonitorAlert(monitor, history, now)
+	if alert == nil {
+		return nil
+	}
+
+	targets, err := a.DBPool.FindPipeMonitorTargets(monitor.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch targets: %w", err)
+	}
+
+	// record the transition first so a failing target can't cause the
+	// alert to be resent to every other target on the next run, only the
+	// alerter that records it sends the alert
+	changed, err := a.DBPool.UpdatePipeMonitorAlertStatus(monitor.ID, monitor.AlertStatus, alert.Status, &now)
+	if err != nil {
+		return fmt.Errorf("failed to update alert status: %w", err)
+	}
+	if !changed {
+		return nil
+	}
+
+	a.Logger.Info("sending monitor alert", "topic", monitor.Topic, "status", alert.Status, "targets", len(targets))
+	for _, target := range targets {
+		if target.ConfirmedAt == nil {
+			continue
+		}
+		err := a.deliver(ctx, target, alert)
+		if err != nil {
+			a.Logger.Error(
+				"deliver monitor alert",
+				"topic", monitor.Topic,
+				"kind", target.Kind,
+				"target", target.Target,
+				"err", err,
+			)
+		}
+	}
+
+	return nil
+}
+
+func (a *MonitorAlerter) deliver(ctx context.Context, target *db.PipeMonitorTarget, alert *MonitorAlert) error {
+	switch target.Kind {
+	case db.PipeTargetEmail:
+		text := alert.Text()
+		return a.Mailer.Send(target.Target, alert.Subject(), text, textToHtml(text), nil)
+	case db.PipeTargetWebhook:
+		body, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.Target, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(webhookSignatureHeader, signWebhook(target.Secret, body))
+
+		resp, err := a.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("webhook responded with %s", resp.Status)
+		}
+		return nil
+	case db.PipeTargetTopic:
+		body, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+
+		pubCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		return a.PubSub.Pub(
+			pubCtx,
+			fmt.Sprintf("monitor-alert (%s)", alert.Topic),
+			&requestRW{data: append(body, '\n')},
+			[]*psub.Channel{psub.NewChannel(target.Target)},
+			false,
+		)
+	default:
+		return fmt.Errorf("unknown target kind %q", target.Kind)
+	}
+}
+
+/*
+nextMonitorAlert returns the alert that should be sent for a monitor or
+nil when its status hasn't changed since the last alert.  Monitors that
+never received a ping are ignored and a monitor that has never alerted is
+assumed to be up.
+
+Flapping is detected from the monitor's history: every gap between two
+consecutive healthy windows is an outage.  While the number of outages in
+the last flapWindows windows is at or above flapThreshold, transitions are
+held back and once the monitor settles a single alert is sent for
+whatever state it ended up in.
+*/
+func nextMonitorAlert(monitor *db.PipeMonitor, history []*db.PipeMonitorHistory, now time.Time) *MonitorAlert {
+	if monitor.LastPing == nil {
+		return nil
+	}
+
+	status := db.PipeMonitorUp
+	reason := ""
+	if err := monitor.Status(); err != nil {
+		status = db.PipeMonitorDown
+		reason = err.Error()
+	}
+
+	prev := monitor.AlertStatus
+	if prev == "" {
+		prev = db.PipeMonitorUp
+	}
+	if status == prev {
+		return nil
+	}
+
+	if isFlapping(monitor, history) {
+		return nil
+	}
+
+	return &MonitorAlert{
+		Topic:     monitor.Topic,
+		Status:    status,
+		Reason:    reason,
+		Window:    monitor.WindowDur.String(),
+		LastPing:  monitor.LastPing,
+		CreatedAt: now,
+	}
+}
+
+func isFlapping(monitor *db.PipeMonitor, history []*db.PipeMonitorHistory) bool {
+	var ends []time.Time
+	for _, h := range history {
+		if h.WindowEnd != nil {
+			ends = append(ends, *h.WindowEnd)
+		}
+	}
+	slices.SortFunc(ends, func(a, b time.Time) int { return a.Compare(b) })
+
+	outages := 0
+	for i := 1; i < len(ends); i++ {
+		if ends[i].Sub(ends[i-1]) > monitor.WindowDur {
+			outages++
+		}
+	}
+	return outages >= flapThreshold
+}
+
+// signWebhook returns the hex encoded HMAC-SHA256 of body, prefixed with
+// the algorithm, for the X-Pico-Signature header.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func textToHtml(text string) string {
+	return "<pre>" + html.EscapeString(text) + "</pre>"
+}
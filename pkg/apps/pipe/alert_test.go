@@ -0,0 +1,243 @@
+package pipe
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+)
+
+func healthyMonitor(now time.Time) *db.PipeMonitor {
+	winEnd := now.Add(30 * time.Minute)
+	lastPing := now.Add(-5 * time.Minute)
+	return &db.PipeMonitor{
+		ID:        "monitor",
+		Topic:     "alice/cron",
+		WindowDur: time.Hour,
+		WindowEnd: &winEnd,
+		LastPing:  &lastPing,
+	}
+}
+
+func historyAt(ends ...time.Time) []*db.PipeMonitorHistory {
+	var history []*db.PipeMonitorHistory
+	for _, end := range ends {
+		e := end
+		history = append(history, &db.PipeMonitorHistory{WindowDur: time.Hour, WindowEnd: &e})
+	}
+	return history
+}
+
+func TestNextMonitorAlert(t *testing.T) {
+	now := time.Now().UTC()
+
+	monitor := healthyMonitor(now)
+	if alert := nextMonitorAlert(monitor, nil, now); alert != nil {
+		t.Errorf("expected no alert for a healthy monitor, got %s", alert.Status)
+	}
+
+	expired := now.Add(-10 * time.Minute)
+	monitor.WindowEnd = &expired
+	alert := nextMonitorAlert(monitor, nil, now)
+	if alert == nil || alert.Status != db.PipeMonitorDown {
+		t.Fatalf("expected down alert, got %v", alert)
+	}
+	if alert.Reason == "" {
+		t.Error("expected down alert to include a reason")
+	}
+
+	monitor.AlertStatus = db.PipeMonitorDown
+	if alert := nextMonitorAlert(monitor, nil, now); alert != nil {
+		t.Errorf("expected down alert to only be sent once, got %s", alert.Status)
+	}
+
+	monitor = healthyMonitor(now)
+	monitor.AlertStatus = db.PipeMonitorDown
+	alert = nextMonitorAlert(monitor, nil, now)
+	if alert == nil || alert.Status != db.PipeMonitorUp {
+		t.Fatalf("expected recovery alert, got %v", alert)
+	}
+
+	monitor.LastPing = nil
+	monitor.AlertStatus = ""
+	if alert := nextMonitorAlert(monitor, nil, now); alert != nil {
+		t.Errorf("expected no alert for a monitor that was never pinged, got %s", alert.Status)
+	}
+}
+
+func TestNextMonitorAlert_Flapping(t *testing.T) {
+	now := time.Now().UTC()
+	monitor := healthyMonitor(now)
+	monitor.AlertStatus = db.PipeMonitorDown
+
+	// healthy windows with two outages in between
+	flapping := historyAt(
+		now.Add(-7*time.Hour),
+		now.Add(-5*time.Hour),
+		now.Add(-4*time.Hour),
+		now.Add(-2*time.Hour),
+	)
+	if alert := nextMonitorAlert(monitor, flapping, now); alert != nil {
+		t.Errorf("expected flapping monitor to hold back alerts, got %s", alert.Status)
+	}
+
+	stable := historyAt(
+		now.Add(-4*time.Hour),
+		now.Add(-3*time.Hour),
+		now.Add(-time.Hour),
+	)
+	if alert := nextMonitorAlert(monitor, stable, now); alert == nil {
+		t.Error("expected alert once the monitor settled")
+	}
+}
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"topic":"alice/cron"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	_, _ = mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := signWebhook("secret", body); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestMonitorAlerter_Webhook(t *testing.T) {
+	var received *MonitorAlert
+	var signature string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(webhookSignatureHeader)
+		_ = json.Unmarshal(body, &received)
+	}))
+	defer srv.Close()
+
+	now := time.Now().UTC()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dbpool := NewTestDB(logger)
+	monitor := healthyMonitor(now)
+	expired := now.Add(-time.Minute)
+	monitor.WindowEnd = &expired
+	dbpool.PipeMonitors = append(dbpool.PipeMonitors, monitor)
+	_ = dbpool.UpsertPipeMonitorTarget(monitor.ID, db.PipeTargetWebhook, srv.URL, "secret", "")
+
+	alerter := NewMonitorAlerter(dbpool, logger, nil)
+	// the test server listens on loopback
+	alerter.Client = srv.Client()
+	if err := alerter.Run(context.Background(), now); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if received == nil || received.Status != db.PipeMonitorDown || received.Topic != "alice/cron" {
+		t.Fatalf("expected down alert for alice/cron, got %v", received)
+	}
+	if signature != signWebhook("secret", body) {
+		t.Errorf("unexpected signature %s", signature)
+	}
+	if dbpool.PipeMonitors[0].AlertStatus != db.PipeMonitorDown {
+		t.Errorf("expected alert status to be recorded, got %q", dbpool.PipeMonitors[0].AlertStatus)
+	}
+
+	received = nil
+	if err := alerter.Run(context.Background(), now); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if received != nil {
+		t.Error("expected the alert to only be delivered once")
+	}
+}
+
+func TestMonitorAlerter_WebhookRejectsPrivateAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	alerter := NewMonitorAlerter(NewTestDB(logger), logger, nil)
+	target := &db.PipeMonitorTarget{Kind: db.PipeTargetWebhook, Target: srv.URL}
+	err := alerter.deliver(context.Background(), target, &MonitorAlert{Topic: "alice/cron"})
+	if err == nil || called {
+		t.Fatal("expected webhook to a loopback address to be refused")
+	}
+}
+
+func TestMonitorAlerter_EmailRequiresConfirmation(t *testing.T) {
+	now := time.Now().UTC()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dbpool := NewTestDB(logger)
+	monitor := healthyMonitor(now)
+	expired := now.Add(-time.Minute)
+	monitor.WindowEnd = &expired
+	dbpool.PipeMonitors = append(dbpool.PipeMonitors, monitor)
+	_ = dbpool.UpsertPipeMonitorTarget(monitor.ID, db.PipeTargetEmail, "alice@example.com", "", "token")
+
+	mailer := &fakeMailer{}
+	alerter := NewMonitorAlerter(dbpool, logger, nil)
+	alerter.Mailer = mailer
+	if err := alerter.Run(context.Background(), now); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if len(mailer.sent) != 0 {
+		t.Fatalf("expected no email to an unconfirmed address, got %v", mailer.sent)
+	}
+
+	if _, err := dbpool.ConfirmPipeMonitorTarget("token"); err != nil {
+		t.Fatal(err)
+	}
+	dbpool.PipeMonitors[0].AlertStatus = ""
+	if err := alerter.Run(context.Background(), now); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0] != "alice@example.com" {
+		t.Fatalf("expected alert to the confirmed address, got %v", mailer.sent)
+	}
+}
+
+func TestMonitorAlerter_SendsOnceAcrossAlerters(t *testing.T) {
+	now := time.Now().UTC()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dbpool := NewTestDB(logger)
+	monitor := healthyMonitor(now)
+	expired := now.Add(-time.Minute)
+	monitor.WindowEnd = &expired
+	dbpool.PipeMonitors = append(dbpool.PipeMonitors, monitor)
+	_ = dbpool.UpsertPipeMonitorTarget(monitor.ID, db.PipeTargetEmail, "alice@example.com", "", "")
+
+	// both alerters read the monitor before either records the alert
+	stale := copyPipeMonitor(monitor)
+	mailer := &fakeMailer{}
+	first := NewMonitorAlerter(dbpool, logger, nil)
+	first.Mailer = mailer
+	second := NewMonitorAlerter(dbpool, logger, nil)
+	second.Mailer = mailer
+
+	if err := first.check(context.Background(), monitor, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.check(context.Background(), stale, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.sent) != 1 {
+		t.Fatalf("expected a single alert, got %d", len(mailer.sent))
+	}
+}
+
+func TestMonitorAlert_SubjectHasNoLineBreaks(t *testing.T) {
+	alert := &MonitorAlert{Topic: "alice/cron\r\nBcc: everyone@example.com", Status: db.PipeMonitorDown}
+	if strings.ContainsAny(alert.Subject(), "\r\n") {
+		t.Errorf("expected subject to be sanitized, got %q", alert.Subject())
+	}
+}
@@ -85,6 +85,34 @@ func (w writeFlusher) Write(p []byte) (n int, err error) {
 
 var _ io.Writer = writeFlusher{}
 
+/*
+tokenTopic authorizes requests that carry an api token.  Requests without
+one stay on public topics, with one they act on the token owner's topic,
+which the token must hold every scope for.  The pipe client reaches
+another user's topic by its full path so it must have the admin feature.
+*/
+func tokenTopic(r *http.Request, topic string, scopes ...string) (string, bool, int, error) {
+	token := router.GetApiToken(r)
+	if token == "" {
+		return topic, false, 0, nil
+	}
+
+	dbpool := router.GetDB(r)
+	var user *db.User
+	for _, scope := range scopes {
+		usr, err := dbpool.FindUserByScopedToken(token, scope)
+		if errors.Is(err, db.ErrTokenScope) {
+			return topic, false, http.StatusForbidden, err
+		}
+		if err != nil {
+			return topic, false, http.StatusUnauthorized, fmt.Errorf("invalid token")
+		}
+		user = usr
+	}
+
+	return "/" + toTopic(user.Name, topic), true, 0, nil
+}
+
 func handleSub(pubsub bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := router.GetLogger(r)
@@ -94,9 +122,19 @@ func handleSub(pubsub bool) http.HandlerFunc {
 
 		topic = cleanRegex.ReplaceAllString(topic, "")
 
+		topic, private, status, err := tokenTopic(r, topic, db.TokenScopePipeSub+":"+topic)
+		if err != nil {
+			logger.Error("sub token error", "topic", topic, "info", clientInfo, "err", err.Error())
+			http.Error(w, err.Error(), status)
+			return
+		}
+
 		logger.Info("sub", "topic", topic, "info", clientInfo, "pubsub", pubsub)
 
 		params := "-p"
+		if private {
+			params = ""
+		}
 		if r.URL.Query().Get("persist") == "true" {
 			params += " -k"
 		}
@@ -147,9 +185,19 @@ func handlePub(pubsub bool) http.HandlerFunc {
 
 		topic = cleanRegex.ReplaceAllString(topic, "")
 
+		topic, private, status, err := tokenTopic(r, topic, db.TokenScopePipePub)
+		if err != nil {
+			logger.Error("pub token error", "topic", topic, "info", clientInfo, "err", err.Error())
+			http.Error(w, err.Error(), status)
+			return
+		}
+
 		logger.Info("pub", "topic", topic, "info", clientInfo)
 
 		params := "-p"
+		if private {
+			params = ""
+		}
 		if pubsub {
 			params += " -b=false"
 		}
@@ -286,6 +334,18 @@ func handlePipe() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := router.GetLogger(r)
 
+		clientInfo := shared.NewPicoPipeClient()
+		topic, _ := url.PathUnescape(router.GetField(r, 0))
+
+		topic = cleanRegex.ReplaceAllString(topic, "")
+
+		topic, private, status, err := tokenTopic(r, topic, db.TokenScopePipePub, db.TokenScopePipeSub+":"+topic)
+		if err != nil {
+			logger.Error("pipe token error", "topic", topic, "info", clientInfo, "err", err.Error())
+			http.Error(w, err.Error(), status)
+			return
+		}
+
 		c, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			logger.Error("pipe upgrade error", "err", err.Error())
@@ -296,16 +356,14 @@ func handlePipe() http.HandlerFunc {
 			_ = c.Close()
 		}()
 
-		clientInfo := shared.NewPicoPipeClient()
-		topic, _ := url.PathUnescape(router.GetField(r, 0))
-
-		topic = cleanRegex.ReplaceAllString(topic, "")
-
 		logger.Info("pipe", "topic", topic, "info", clientInfo)
 
-		params := "-p -c"
-		if r.URL.Query().Get("status") == "true" {
-			params = params[:len(params)-3]
+		params := "-p"
+		if private {
+			params = ""
+		}
+		if r.URL.Query().Get("status") != "true" {
+			params += " -c"
 		}
 
 		if r.URL.Query().Get("replay") == "true" {
@@ -437,7 +495,7 @@ func handlePipe() http.HandlerFunc {
 func rssHandler(cfg *shared.ConfigSite, dbpool db.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		apiToken, _ := url.PathUnescape(router.GetField(r, 0))
-		user, err := dbpool.FindUserByToken(apiToken)
+		user, err := dbpool.FindUserByScopedToken(apiToken, db.TokenScopeFeedsRead)
 		if err != nil {
 			cfg.Logger.Error(
 				"could not find user for token",
@@ -471,11 +529,31 @@ func rssHandler(cfg *shared.ConfigSite, dbpool db.DB) http.HandlerFunc {
 	}
 }
 
+// confirmTargetHandler confirms an email target of a monitor with the link
+// sent to the address.
+func confirmTargetHandler(cfg *shared.ConfigSite, dbpool db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, err := dbpool.ConfirmPipeMonitorTarget(router.GetField(r, 0))
+		if err != nil {
+			http.Error(w, "confirmation link not found", http.StatusNotFound)
+			return
+		}
+		cfg.Logger.Info("confirmed pipe monitor target", "monitor", target.MonitorID, "kind", target.Kind)
+
+		w.Header().Set("Content-Type", "text/plain")
+		_, err = fmt.Fprintf(w, "Success! %s will receive alerts for this monitor.", target.Target)
+		if err != nil {
+			cfg.Logger.Error("error with confirm response writer", "err", err)
+		}
+	}
+}
+
 func createMainRoutes(staticRoutes []router.Route, cfg *shared.ConfigSite, dbpool db.DB) []router.Route {
 	routes := []router.Route{
 		router.NewRoute("GET", "/", router.CreatePageHandler("html/marketing.page.tmpl")),
 		router.NewRoute("GET", "/check", router.CheckHandler),
 		router.NewRoute("GET", "/rss/(.+)", rssHandler(cfg, dbpool)),
+		router.NewRoute("GET", "/monitors/confirm/(.+)", confirmTargetHandler(cfg, dbpool)),
 		router.NewRoute("GET", "/_metrics", promhttp.Handler().ServeHTTP),
 	}
 
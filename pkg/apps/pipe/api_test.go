@@ -0,0 +1,70 @@
+package pipe
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/shared/router"
+)
+
+func TestTokenTopic(t *testing.T) {
+	logger := slog.Default()
+	dbh := NewTestDB(logger)
+	dbh.AddUser(&db.User{ID: "alice-id", Name: "alice"})
+	dbh.AddToken(&db.Token{Token: "full", UserID: "alice-id"})
+	dbh.AddToken(&db.Token{Token: "pub", UserID: "alice-id", Scopes: db.TokenScopePipePub})
+	dbh.AddToken(&db.Token{Token: "sub-logs", UserID: "alice-id", Scopes: db.TokenScopePipeSub + ":logs"})
+
+	apiConfig := &router.ApiConfig{
+		Cfg:    &shared.ConfigSite{Logger: logger},
+		Dbpool: dbh,
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		topic   string
+		scopes  []string
+		want    string
+		private bool
+		status  int
+	}{
+		{name: "no token", topic: "logs", scopes: []string{db.TokenScopePipePub}, want: "logs"},
+		{name: "full", token: "full", topic: "logs", scopes: []string{db.TokenScopePipePub}, want: "/alice/logs", private: true},
+		{name: "pub", token: "pub", topic: "logs", scopes: []string{db.TokenScopePipePub}, want: "/alice/logs", private: true},
+		{name: "pub cannot sub", token: "pub", topic: "logs", scopes: []string{db.TokenScopePipeSub + ":logs"}, status: http.StatusForbidden},
+		{name: "sub topic", token: "sub-logs", topic: "logs", scopes: []string{db.TokenScopePipeSub + ":logs"}, want: "/alice/logs", private: true},
+		{name: "sub other topic", token: "sub-logs", topic: "secrets", scopes: []string{db.TokenScopePipeSub + ":secrets"}, status: http.StatusForbidden},
+		{name: "sub cannot pipe", token: "sub-logs", topic: "logs", scopes: []string{db.TokenScopePipePub, db.TokenScopePipeSub + ":logs"}, status: http.StatusForbidden},
+		{name: "unknown", token: "nope", topic: "logs", scopes: []string{db.TokenScopePipePub}, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/topic/"+tt.topic, nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			r = r.WithContext(apiConfig.CreateCtx(context.Background(), ""))
+
+			topic, private, status, err := tokenTopic(r, tt.topic, tt.scopes...)
+			if tt.status != 0 {
+				if err == nil || status != tt.status {
+					t.Fatalf("expected status %d, got %d (%v)", tt.status, status, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if topic != tt.want || private != tt.private {
+				t.Errorf("expected %s (private %v), got %s (private %v)", tt.want, tt.private, topic, private)
+			}
+		})
+	}
+}
@@ -9,6 +9,10 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
+	"net"
+	"net/mail"
+	"net/url"
 	"slices"
 	"strings"
 	"sync/atomic"
@@ -97,6 +101,20 @@ func Middleware(handler *CliHandler) pssh.SSHServerMiddleware {
 					sesh.Fatal(err)
 				}
 				return next(sesh)
+			case "acl":
+				err := handler.acl(cliCmd, user)
+				if err != nil {
+					logger.Error("acl cmd", "err", err)
+					sesh.Fatal(err)
+				}
+				return next(sesh)
+			case "topics":
+				err := handler.topics(cliCmd, user)
+				if err != nil {
+					logger.Error("topics cmd", "err", err)
+					sesh.Fatal(err)
+				}
+				return next(sesh)
 			case "rss":
 				rss, err := MonitorRss(handler.DBPool, user, handler.Cfg.Domain)
 				_, _ = fmt.Fprintln(sesh, rss)
@@ -142,6 +160,9 @@ func Middleware(handler *CliHandler) pssh.SSHServerMiddleware {
 					select {
 					case <-pipeCtx.Done():
 						return
+					case sig := <-sesh.Signals():
+						logger.Info("received signal, shutting down", "signal", sig)
+						return
 					case <-ticker.C:
 						_, err := sesh.SendRequest("ping@pico.sh", false, nil)
 						if err != nil {
@@ -171,6 +192,18 @@ func Middleware(handler *CliHandler) pssh.SSHServerMiddleware {
 					logger.Error("pipe cmd", "err", err)
 					sesh.Fatal(err)
 				}
+			case "req":
+				err := handler.req(cliCmd, topic, clientID)
+				if err != nil {
+					logger.Error("req cmd", "err", err)
+					sesh.Fatal(err)
+				}
+			case "serve":
+				err := handler.serve(cliCmd, topic, clientID)
+				if err != nil {
+					logger.Error("serve cmd", "err", err)
+					sesh.Fatal(err)
+				}
 			case "uptime":
 				err := handler.uptime(cliCmd, topic, user)
 				if err != nil {
@@ -191,6 +224,12 @@ type CliHandler struct {
 	Cfg     *shared.ConfigSite
 	Waiters *syncmap.Map[string, []string]
 	Access  *syncmap.Map[string, []string]
+	Limits  *PipeLimits
+	Limiter *psub.RateLimiter
+	Mailer  shared.MailSender
+	// ConnLimits caches the user limits of each ssh connection so the
+	// plan is only looked up once per connection.
+	ConnLimits *syncmap.Map[*pssh.SSHServerConn, psub.RateLimits]
 }
 
 func (h *CliHandler) GetLogger(s *pssh.SSHServerConnSession) *slog.Logger {
@@ -226,9 +265,24 @@ Commands:
   sub <topic> [flags]         Subscribe to messages from a topic
   pipe <topic> [flags]        Bidirectional messaging between clients
 
+Request/reply commands:
+  req <topic> [flags]         Send stdin as a request and wait for a single reply
+  serve <topic> [flags]       Answer one request, replying with stdin
+
+Access commands:
+  acl <topic> ls              List stored access for a topic
+  acl <topic> add <who> [perm] Grant read, write or rw access to a user or key fingerprint
+  acl <topic> rm <who>        Revoke stored access
+  topics                      List owned topics and their access lists
+
 Monitoring commands:
   monitor <topic> <duration>  Create/update a health monitor for a topic
   monitor <topic> -d          Delete a monitor
+  monitor <topic> notify ls   List alert targets for a monitor
+  monitor <topic> notify add <email|webhook|topic> <target>
+                              Send down and recovery alerts to a target
+  monitor <topic> notify rm <email|webhook|topic> <target>
+                              Remove an alert target
   status                      Show health status of all monitors
   uptime                      Show uptime for a topic
   rss                         Get RSS feed of monitor alerts
@@ -362,6 +416,10 @@ func (handler *CliHandler) monitor(cmd *CliCmd, user *db.User) error {
 	})
 	resolvedTopic := result.Name
 
+	if len(cmdArgs) > 0 && cmdArgs[0] == "notify" {
+		return handler.monitorNotify(cmd, user, resolvedTopic, cmdArgs[1:])
+	}
+
 	if *del {
 		handler.Logger.Info("removing pipe monitor", "topic", resolvedTopic)
 		err := handler.DBPool.RemovePipeMonitor(user.ID, resolvedTopic)
@@ -406,6 +464,331 @@ func (handler *CliHandler) monitor(cmd *CliCmd, user *db.User) error {
 	return nil
 }
 
+func (handler *CliHandler) monitorNotify(cmd *CliCmd, user *db.User, topic string, args []string) error {
+	if len(args) == 0 {
+		_, _ = fmt.Fprintln(cmd.sesh, "Usage: monitor <topic> notify ls")
+		_, _ = fmt.Fprintln(cmd.sesh, "       monitor <topic> notify add <email|webhook|topic> <target>")
+		_, _ = fmt.Fprintln(cmd.sesh, "       monitor <topic> notify rm <email|webhook|topic> <target>")
+		return fmt.Errorf("notify action is required")
+	}
+
+	monitor, err := handler.DBPool.FindPipeMonitorByTopic(user.ID, topic)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("monitor not found: %s", topic)
+		}
+		return fmt.Errorf("failed to find monitor: %w", err)
+	}
+
+	action := strings.TrimSpace(args[0])
+	if action == "ls" {
+		targets, err := handler.DBPool.FindPipeMonitorTargets(monitor.ID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch notification targets: %w", err)
+		}
+
+		if len(targets) == 0 {
+			_, _ = fmt.Fprintln(cmd.sesh, "no notification targets found")
+			return nil
+		}
+
+		writer := tabwriter.NewWriter(cmd.sesh, 0, 0, 2, ' ', tabwriter.TabIndent)
+		_, _ = fmt.Fprintln(writer, "Kind\tTarget\tStatus")
+		for _, target := range targets {
+			status := "active"
+			if target.ConfirmedAt == nil {
+				status = "unconfirmed"
+			}
+			_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\r\n", target.Kind, target.Target, status)
+		}
+		_ = writer.Flush()
+		return nil
+	}
+
+	if action != "add" && action != "rm" {
+		return fmt.Errorf("unknown notify action %q, must be one of: ls, add, rm", action)
+	}
+
+	if len(args) < 3 {
+		return fmt.Errorf("target kind and target are required")
+	}
+
+	kind := strings.TrimSpace(args[1])
+	target := strings.TrimSpace(args[2])
+	switch kind {
+	case db.PipeTargetEmail:
+		addr, err := mail.ParseAddress(target)
+		if err != nil {
+			return fmt.Errorf("invalid email %q: %w", target, err)
+		}
+		target = addr.Address
+	case db.PipeTargetWebhook:
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook url %q", target)
+		}
+		// hosts are checked again when the webhook is delivered since
+		// they can resolve to anything later on
+		ip := net.ParseIP(u.Hostname())
+		if u.Hostname() == "localhost" || (ip != nil && !shared.IsPublicIP(ip)) {
+			return fmt.Errorf("invalid webhook url %q: must be a public address", target)
+		}
+	case db.PipeTargetTopic:
+		target = resolveTopic(TopicResolveInput{
+			UserName: cmd.userName,
+			Topic:    target,
+			IsAdmin:  cmd.isAdmin,
+			IsPublic: false,
+		}).Name
+	default:
+		return fmt.Errorf("invalid target kind %q, must be one of: email, webhook, topic", kind)
+	}
+
+	if action == "rm" {
+		handler.Logger.Info("removing pipe monitor target", "topic", topic, "kind", kind, "target", target)
+		err := handler.DBPool.RemovePipeMonitorTarget(monitor.ID, kind, target)
+		if err != nil {
+			return fmt.Errorf("failed to remove notification target: %w", err)
+		}
+		_, _ = fmt.Fprintf(cmd.sesh, "notification target removed: %s %s\r\n", kind, target)
+		return nil
+	}
+
+	if kind == db.PipeTargetEmail {
+		return handler.addEmailTarget(cmd, monitor, target)
+	}
+
+	secret := ""
+	if kind == db.PipeTargetWebhook {
+		secret, err = newWebhookSecret()
+		if err != nil {
+			return err
+		}
+	}
+
+	handler.Logger.Info("upserting pipe monitor target", "topic", topic, "kind", kind, "target", target)
+	err = handler.DBPool.UpsertPipeMonitorTarget(monitor.ID, kind, target, secret, "")
+	if err != nil {
+		return fmt.Errorf("failed to add notification target: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(cmd.sesh, "notification target added: %s %s\r\n", kind, target)
+	if secret != "" {
+		_, _ = fmt.Fprintf(cmd.sesh, "webhook secret (used to sign the %s header): %s\r\n", webhookSignatureHeader, secret)
+	}
+	return nil
+}
+
+// addEmailTarget adds an email target that only receives alerts once the
+// link sent to the address was opened, so monitors can't be used to send
+// email to arbitrary addresses.  Confirmation emails are sent at most once
+// per emailConfirmResendAfter for an address.
+func (handler *CliHandler) addEmailTarget(cmd *CliCmd, monitor *db.PipeMonitor, email string) error {
+	targets, err := handler.DBPool.FindPipeMonitorTargets(monitor.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch notification targets: %w", err)
+	}
+	for _, target := range targets {
+		if target.Kind != db.PipeTargetEmail || target.Target != email {
+			continue
+		}
+		if target.ConfirmedAt != nil {
+			_, _ = fmt.Fprintf(cmd.sesh, "notification target already added: %s %s\r\n", target.Kind, email)
+			return nil
+		}
+		if target.UpdatedAt != nil && time.Since(*target.UpdatedAt) < emailConfirmResendAfter {
+			return fmt.Errorf("a confirmation email was already sent to %s, open the link in it to start receiving alerts", email)
+		}
+	}
+
+	token, err := newWebhookSecret()
+	if err != nil {
+		return err
+	}
+
+	handler.Logger.Info("upserting pipe monitor target", "topic", monitor.Topic, "kind", db.PipeTargetEmail, "target", email)
+	err = handler.DBPool.UpsertPipeMonitorTarget(monitor.ID, db.PipeTargetEmail, email, "", token)
+	if err != nil {
+		return fmt.Errorf("failed to add notification target: %w", err)
+	}
+
+	confirmURL := fmt.Sprintf("%s/monitors/confirm/%s", handler.Cfg.ReadURL(), token)
+	text := fmt.Sprintf(
+		"%s asked to send alerts for the pipe monitor %s to this address.\n\nConfirm to start receiving them:\n%s\n\nIgnore this email if you did not expect it.\n",
+		cmd.userName,
+		monitor.Topic,
+		confirmURL,
+	)
+	subject := shared.SanitizeHeader(fmt.Sprintf("Confirm alerts for %s", monitor.Topic))
+	err = handler.Mailer.Send(email, subject, text, textToHtml(text), nil)
+	if err != nil {
+		return fmt.Errorf("failed to send confirmation email: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(cmd.sesh, "confirmation email sent to %s, alerts are sent once the link in it is opened\r\n", email)
+	return nil
+}
+
+func (handler *CliHandler) acl(cmd *CliCmd, user *db.User) error {
+	if user == nil {
+		return fmt.Errorf("access denied")
+	}
+
+	args := cmd.sesh.Command()
+	if len(args) < 3 {
+		_, _ = fmt.Fprintln(cmd.sesh, "Usage: acl <topic> ls")
+		_, _ = fmt.Fprintln(cmd.sesh, "       acl <topic> add <username|fingerprint> [read|write|rw]")
+		_, _ = fmt.Fprintln(cmd.sesh, "       acl <topic> rm <username|fingerprint>")
+		return fmt.Errorf("topic and action are required")
+	}
+
+	topic := strings.TrimSpace(args[1])
+	action := strings.TrimSpace(args[2])
+
+	// Resolve to fully qualified topic name
+	result := resolveTopic(TopicResolveInput{
+		UserName: cmd.userName,
+		Topic:    topic,
+		IsPublic: false,
+	})
+	resolvedTopic := result.Name
+
+	switch action {
+	case "ls":
+		acls, err := handler.DBPool.FindPipeAclsByTopic(user.ID, resolvedTopic)
+		if err != nil {
+			return fmt.Errorf("failed to fetch acls: %w", err)
+		}
+
+		if len(acls) == 0 {
+			_, _ = fmt.Fprintln(cmd.sesh, "no acls found")
+			return nil
+		}
+
+		writer := tabwriter.NewWriter(cmd.sesh, 0, 0, 2, ' ', tabwriter.TabIndent)
+		_, _ = fmt.Fprintln(writer, "Principal\tPermission")
+		for _, acl := range acls {
+			_, _ = fmt.Fprintf(writer, "%s\t%s\r\n", acl.Principal, acl.Permission)
+		}
+		_ = writer.Flush()
+		return nil
+	case "add":
+		if len(args) < 4 {
+			return fmt.Errorf("principal is required")
+		}
+
+		principal := strings.TrimSpace(args[3])
+		perm := db.PipeAclReadWrite
+		if len(args) > 4 {
+			perm = strings.TrimSpace(args[4])
+		}
+
+		if !slices.Contains([]string{db.PipeAclRead, db.PipeAclWrite, db.PipeAclReadWrite}, perm) {
+			return fmt.Errorf("invalid permission %q, must be one of: read, write, rw", perm)
+		}
+
+		handler.Logger.Info("upserting pipe acl", "topic", resolvedTopic, "principal", principal, "perm", perm)
+		err := handler.DBPool.UpsertPipeAcl(user.ID, resolvedTopic, principal, perm)
+		if err != nil {
+			return fmt.Errorf("failed to add acl: %w", err)
+		}
+
+		_, _ = fmt.Fprintf(cmd.sesh, "acl added: %s %s %s\r\n", resolvedTopic, principal, perm)
+		return nil
+	case "rm":
+		if len(args) < 4 {
+			return fmt.Errorf("principal is required")
+		}
+
+		principal := strings.TrimSpace(args[3])
+		handler.Logger.Info("removing pipe acl", "topic", resolvedTopic, "principal", principal)
+		err := handler.DBPool.RemovePipeAcl(user.ID, resolvedTopic, principal)
+		if err != nil {
+			return fmt.Errorf("failed to remove acl: %w", err)
+		}
+
+		_, _ = fmt.Fprintf(cmd.sesh, "acl removed: %s %s\r\n", resolvedTopic, principal)
+		return nil
+	default:
+		return fmt.Errorf("unknown acl action %q, must be one of: ls, add, rm", action)
+	}
+}
+
+func (handler *CliHandler) topics(cmd *CliCmd, user *db.User) error {
+	if user == nil {
+		return fmt.Errorf("access denied")
+	}
+
+	acls, err := handler.DBPool.FindPipeAclsByUser(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch acls: %w", err)
+	}
+
+	topicAcls := map[string][]string{}
+	for _, acl := range acls {
+		topicAcls[acl.Topic] = append(topicAcls[acl.Topic], fmt.Sprintf("%s:%s", acl.Principal, acl.Permission))
+	}
+
+	active := map[string]bool{}
+	prefix := fmt.Sprintf("%s/", cmd.userName)
+	for topic := range handler.PubSub.GetChannels() {
+		if strings.HasPrefix(topic, prefix) {
+			active[topic] = true
+			if _, ok := topicAcls[topic]; !ok {
+				topicAcls[topic] = nil
+			}
+		}
+	}
+
+	if len(topicAcls) == 0 {
+		_, _ = fmt.Fprintln(cmd.sesh, "no topics found")
+		return nil
+	}
+
+	topics := slices.Sorted(maps.Keys(topicAcls))
+
+	writer := tabwriter.NewWriter(cmd.sesh, 0, 0, 2, ' ', tabwriter.TabIndent)
+	_, _ = fmt.Fprintln(writer, "Topic\tActive\tAccess List")
+	for _, topic := range topics {
+		_, _ = fmt.Fprintf(
+			writer,
+			"%s\t%t\t%s\r\n",
+			topic,
+			active[topic],
+			strings.Join(topicAcls[topic], ", "),
+		)
+	}
+	_ = writer.Flush()
+	return nil
+}
+
+// storedAccess reports whether a persistent topic acl grants the current
+// user perm on a topic owned by someone else, e.g. "alice/topic".
+func (handler *CliHandler) storedAccess(cmd *CliCmd, topic string, perm string) bool {
+	owner, _, found := strings.Cut(topic, "/")
+	if !found || owner == cmd.userName {
+		return false
+	}
+
+	ownerUser, err := handler.DBPool.FindUserByName(owner)
+	if err != nil || ownerUser == nil {
+		return false
+	}
+
+	acls, err := handler.DBPool.FindPipeAclsByTopic(ownerUser.ID, topic)
+	if err != nil {
+		return false
+	}
+
+	for _, acl := range acls {
+		if acl.Allows(perm) && checkAccess([]string{acl.Principal}, cmd.userName, cmd.sesh) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (handler *CliHandler) status(cmd *CliCmd, user *db.User) error {
 	if user == nil {
 		return fmt.Errorf("access denied")
@@ -674,6 +1057,7 @@ func (handler *CliHandler) pub(cmd *CliCmd, topic string, clientID string) error
 		HasExistingAccess:  hasExistingAccess,
 		IsAccessCreator:    accessListCreator,
 		HasUserAccess:      checkAccess(existingAccessList, cmd.userName, cmd.sesh),
+		HasStoredAccess:    handler.storedAccess(cmd, initialResult.WithoutUser, db.PipeAclWrite),
 	})
 	name = result.Name
 
@@ -810,13 +1194,17 @@ func (handler *CliHandler) pub(cmd *CliCmd, topic string, clientID string) error
 	_ = handler.PubSub.SetDispatcher(dsp, []*psub.Channel{channel})
 
 	err := handler.PubSub.Pub(
-		cmd.pipeCtx,
+		handler.limitCtx(cmd, name),
 		clientID,
 		throttledRW,
 		[]*psub.Channel{channel},
 		*block,
 	)
 
+	if errors.Is(err, psub.ErrLimitExceeded) {
+		return err
+	}
+
 	if !*clean {
 		_, _ = fmt.Fprintln(cmd.sesh, "msg sent!")
 	}
@@ -830,6 +1218,58 @@ func (handler *CliHandler) pub(cmd *CliCmd, topic string, clientID string) error
 	return nil
 }
 
+// limitCtx attaches the publishing limits for the current user to the
+// pipe context.  Admins are only subject to topic limits.
+func (handler *CliHandler) limitCtx(cmd *CliCmd, topic string) context.Context {
+	if handler.Limiter == nil || handler.Limits == nil {
+		return cmd.pipeCtx
+	}
+
+	userKey := cmd.sesh.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(userKey); err == nil {
+		userKey = host
+	}
+	if cmd.user != nil {
+		userKey = cmd.user.ID
+	}
+
+	limiter := handler.Limiter.For(userKey, handler.userLimits(cmd), handler.Limits.Topic)
+	return psub.WithLimiter(cmd.pipeCtx, limiter)
+}
+
+// userLimits returns the limits of the user's plan, cached for the
+// lifetime of the ssh connection.
+func (handler *CliHandler) userLimits(cmd *CliCmd) psub.RateLimits {
+	if cmd.isAdmin {
+		return psub.RateLimits{}
+	}
+	if cmd.user == nil {
+		return handler.Limits.Free
+	}
+
+	conn := cmd.sesh.SSHServerConn
+	if handler.ConnLimits != nil {
+		if limits, ok := handler.ConnLimits.Load(conn); ok {
+			return limits
+		}
+	}
+
+	limits := handler.Limits.Free
+	ff, _ := handler.DBPool.FindFeature(cmd.user.ID, "plus")
+	if ff != nil && ff.IsValid() {
+		limits = handler.Limits.Plus
+	}
+
+	if handler.ConnLimits != nil {
+		if _, loaded := handler.ConnLimits.LoadOrStore(conn, limits); !loaded {
+			context.AfterFunc(conn.Context(), func() {
+				handler.ConnLimits.Delete(conn)
+			})
+		}
+	}
+	return limits
+}
+
 func (handler *CliHandler) updateMonitor(cmd *CliCmd, topic string) {
 	if cmd.user == nil {
 		return
@@ -1000,6 +1440,7 @@ func (handler *CliHandler) sub(cmd *CliCmd, topic string, clientID string) error
 		HasExistingAccess:  hasExistingAccess,
 		IsAccessCreator:    accessListCreator,
 		HasUserAccess:      checkAccess(existingAccessList, cmd.userName, cmd.sesh),
+		HasStoredAccess:    handler.storedAccess(cmd, initialResult.WithoutUser, db.PipeAclRead),
 	})
 	name = result.Name
 
@@ -1097,6 +1538,7 @@ func (handler *CliHandler) pipe(cmd *CliCmd, topic string, clientID string) erro
 		HasExistingAccess:  hasExistingAccess,
 		IsAccessCreator:    accessListCreator,
 		HasUserAccess:      checkAccess(existingAccessList, cmd.userName, cmd.sesh),
+		HasStoredAccess:    handler.storedAccess(cmd, initialResult.WithoutUser, db.PipeAclReadWrite),
 	})
 	name = result.Name
 
@@ -1131,7 +1573,7 @@ func (handler *CliHandler) pipe(cmd *CliCmd, topic string, clientID string) erro
 	_ = handler.PubSub.SetDispatcher(dsp, []*psub.Channel{channel})
 
 	readErr, writeErr := handler.PubSub.Pipe(
-		cmd.pipeCtx,
+		handler.limitCtx(cmd, name),
 		clientID,
 		throttledRW,
 		[]*psub.Channel{
@@ -1140,7 +1582,7 @@ func (handler *CliHandler) pipe(cmd *CliCmd, topic string, clientID string) erro
 		*replay,
 	)
 
-	if readErr != nil && !*clean {
+	if errors.Is(readErr, psub.ErrLimitExceeded) || (readErr != nil && !*clean) {
 		return readErr
 	}
 
@@ -1153,6 +1595,241 @@ func (handler *CliHandler) pipe(cmd *CliCmd, topic string, clientID string) erro
 	return nil
 }
 
+// rpcTopic resolves the topic used by "req" and "serve".  Neither command
+// creates an access list, they only honor the ones created by pub, sub,
+// pipe and acl.
+func (handler *CliHandler) rpcTopic(cmd *CliCmd, topic string, public bool, perm string) (string, error) {
+	initialResult := resolveTopic(TopicResolveInput{
+		UserName: cmd.userName,
+		Topic:    topic,
+		IsAdmin:  cmd.isAdmin,
+		IsPublic: public,
+	})
+
+	existingAccessList, hasExistingAccess := handler.Access.Load(initialResult.WithoutUser)
+	result := resolveTopic(TopicResolveInput{
+		UserName:           cmd.userName,
+		Topic:              topic,
+		IsAdmin:            cmd.isAdmin,
+		IsPublic:           public,
+		ExistingAccessList: existingAccessList,
+		HasExistingAccess:  hasExistingAccess,
+		HasUserAccess:      checkAccess(existingAccessList, cmd.userName, cmd.sesh),
+		HasStoredAccess:    handler.storedAccess(cmd, initialResult.WithoutUser, perm),
+	})
+
+	if result.AccessDenied {
+		return "", fmt.Errorf("access denied")
+	}
+
+	return result.Name, nil
+}
+
+func (handler *CliHandler) req(cmd *CliCmd, topic string, clientID string) error {
+	reqCmd := flagSet("req", cmd.sesh)
+	public := reqCmd.Bool("p", false, "Send the request to a public topic")
+	timeout := reqCmd.Duration("t", 30*time.Second, "Timeout as a Go duration to wait for a reply. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', 'h'.")
+
+	if !flagCheck(reqCmd, topic, cmd.args) {
+		return fmt.Errorf("invalid cmd args")
+	}
+
+	if reqCmd.NArg() == 1 && topic == "" {
+		topic = reqCmd.Arg(0)
+	}
+
+	if topic == "" {
+		return fmt.Errorf("must provide a topic")
+	}
+
+	handler.Logger.Info(
+		"flags parsed",
+		"cmd", "req",
+		"public", *public,
+		"timeout", *timeout,
+		"topic", topic,
+	)
+
+	name, err := handler.rpcTopic(cmd, topic, *public, db.PipeAclWrite)
+	if err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(cmd.sesh, maxRequestSize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxRequestSize {
+		return fmt.Errorf("request exceeds %d bytes", maxRequestSize)
+	}
+
+	ctx, cancel := context.WithTimeout(handler.limitCtx(cmd, name), *timeout)
+	defer cancel()
+
+	replyTopic := replyTopicPrefix + uuid.NewString()
+	replyChannel := psub.NewChannel(replyTopic)
+	_ = handler.PubSub.SetDispatcher(&replyDispatcher{}, []*psub.Channel{replyChannel})
+
+	reply := &replyRW{ReadWriter: cmd.sesh}
+	subDone := make(chan error, 1)
+	go func() {
+		subDone <- handler.PubSub.Sub(ctx, clientID, reply, []*psub.Channel{replyChannel}, false)
+	}()
+
+	// wait for the reply subscription before anyone can respond to it
+	for !hasClient(handler.PubSub, replyTopic, clientID) {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timeout reached, exiting")
+		case <-time.After(1 * time.Millisecond):
+		}
+	}
+
+	channel := psub.NewChannel(name)
+	_ = handler.PubSub.SetDispatcher(&psub.RoundRobinDispatcher{}, []*psub.Channel{channel})
+
+	err = handler.PubSub.Pub(
+		ctx,
+		clientID,
+		&requestRW{data: encodeRequest(replyTopic, body)},
+		[]*psub.Channel{channel},
+		true,
+	)
+	if errors.Is(err, psub.ErrLimitExceeded) {
+		return err
+	}
+
+	err = <-subDone
+	if ctx.Err() != nil {
+		if reply.received.Load() {
+			return fmt.Errorf("timeout reached before reply finished")
+		}
+		return fmt.Errorf("timeout reached, exiting")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	handler.updateMonitor(cmd, name)
+
+	return nil
+}
+
+func (handler *CliHandler) serve(cmd *CliCmd, topic string, clientID string) error {
+	serveCmd := flagSet("serve", cmd.sesh)
+	public := serveCmd.Bool("p", false, "Serve requests sent to a public topic")
+	serveCmd.Usage = func() {
+		_, _ = fmt.Fprintf(serveCmd.Output(), `Usage: serve <topic> [flags]
+
+Waits for a single request sent with "req", writes it to stdout and then
+closes stdout.  Everything written to stdin afterwards is sent back to
+the requester as the reply.  Requests are load balanced when multiple
+responders serve the same topic.
+
+The server never runs commands, pipe each request into a local command
+instead:
+
+  mkfifo reply
+  while true; do
+    ssh %s serve <topic> < reply | cmd > reply
+  done
+
+`, toSshCmd(handler.Cfg))
+		serveCmd.PrintDefaults()
+	}
+
+	if !flagCheck(serveCmd, topic, cmd.args) {
+		return fmt.Errorf("invalid cmd args")
+	}
+
+	args := serveCmd.Args()
+	if len(args) > 0 && topic == "" {
+		topic = args[0]
+		args = args[1:]
+	}
+
+	if topic == "" {
+		return fmt.Errorf("must provide a topic")
+	}
+
+	if len(args) > 0 {
+		return fmt.Errorf(
+			"serve does not run commands, pipe the request into a local command instead: ssh %s serve %s < reply | %s > reply",
+			toSshCmd(handler.Cfg), topic, strings.Join(args, " "),
+		)
+	}
+
+	handler.Logger.Info(
+		"flags parsed",
+		"cmd", "serve",
+		"public", *public,
+		"topic", topic,
+	)
+
+	name, err := handler.rpcTopic(cmd, topic, *public, db.PipeAclRead)
+	if err != nil {
+		return err
+	}
+
+	channel := psub.NewChannel(name)
+	_ = handler.PubSub.SetDispatcher(&psub.RoundRobinDispatcher{}, []*psub.Channel{channel})
+
+	subCtx, cancel := context.WithCancel(cmd.pipeCtx)
+	defer cancel()
+
+	capture := newRequestCapture()
+	subDone := make(chan error, 1)
+	go func() {
+		subDone <- handler.PubSub.Sub(subCtx, clientID, capture, []*psub.Channel{channel}, true)
+	}()
+
+	var msg []byte
+	select {
+	case msg = <-capture.msg:
+		cancel()
+		<-subDone
+	case err := <-subDone:
+		return err
+	}
+
+	replyTopic, body, err := decodeRequest(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = cmd.sesh.Write(body)
+	if err != nil {
+		return err
+	}
+	// signal EOF so the local command knows the request is complete
+	_ = cmd.sesh.CloseWrite()
+
+	err = handler.PubSub.Pub(
+		handler.limitCtx(cmd, replyTopic),
+		clientID,
+		cmd.sesh,
+		[]*psub.Channel{psub.NewChannel(replyTopic)},
+		false,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func hasClient(pubsub psub.PubSub, topic string, clientID string) bool {
+	for name, channel := range pubsub.GetChannels() {
+		if name != topic {
+			continue
+		}
+		_, ok := channel.Clients.Load(clientID)
+		return ok
+	}
+	return false
+}
+
 func toSshCmd(cfg *shared.ConfigSite) string {
 	port := ""
 	if cfg.PortOverride != "22" {
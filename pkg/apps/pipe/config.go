@@ -1,8 +1,10 @@
 package pipe
 
 import (
+	"strconv"
 	"strings"
 
+	psub "github.com/picosh/pico/pkg/pubsub"
 	"github.com/picosh/pico/pkg/shared"
 )
 
@@ -22,3 +24,44 @@ func NewConfigSite(service string) *shared.ConfigSite {
 		Space:    "pipe",
 	}
 }
+
+// PipeLimits are the publishing limits enforced for pipe users and topics.
+// Frames are capped by psub.MaxFrameSize unless a lower frame size is
+// configured, messages by the plan's message size.
+type PipeLimits struct {
+	Free  psub.RateLimits
+	Plus  psub.RateLimits
+	Topic psub.RateLimits
+}
+
+func NewPipeLimits() *PipeLimits {
+	return &PipeLimits{
+		Free:  rateLimitsFromEnv("PIPE_LIMIT", 1000, 10*1024*1024, 0, 100*1024*1024),
+		Plus:  rateLimitsFromEnv("PIPE_PLUS_LIMIT", 10000, 100*1024*1024, 0, 1024*1024*1024),
+		Topic: rateLimitsFromEnv("PIPE_TOPIC_LIMIT", 10000, 100*1024*1024, 0, 0),
+	}
+}
+
+func rateLimitsFromEnv(prefix string, msgs, bytes float64, size, message int) psub.RateLimits {
+	limits := psub.RateLimits{
+		MessagesPerSecond: msgs,
+		BytesPerSecond:    bytes,
+		MaxFrameSize:      size,
+		MaxMessageSize:    message,
+	}
+
+	if v, err := strconv.ParseFloat(shared.GetEnv(prefix+"_MSGS_PER_SEC", ""), 64); err == nil {
+		limits.MessagesPerSecond = v
+	}
+	if v, err := strconv.ParseFloat(shared.GetEnv(prefix+"_BYTES_PER_SEC", ""), 64); err == nil {
+		limits.BytesPerSecond = v
+	}
+	if v, err := strconv.Atoi(shared.GetEnv(prefix+"_MAX_FRAME_SIZE", "")); err == nil {
+		limits.MaxFrameSize = v
+	}
+	if v, err := strconv.Atoi(shared.GetEnv(prefix+"_MAX_MESSAGE_SIZE", "")); err == nil {
+		limits.MaxMessageSize = v
+	}
+
+	return limits
+}
@@ -0,0 +1,123 @@
+package pipe
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	psub "github.com/picosh/pico/pkg/pubsub"
+)
+
+// replyTopicPrefix namespaces the reply topics generated by "req".  Pipe
+// usernames cannot start with an underscore so only the server is able
+// to publish to them.
+const replyTopicPrefix = "_reply/"
+
+const replyToHeader = "reply-to: "
+
+// maxRequestSize keeps a request, including its reply-to header, inside a
+// single psub.MaxFrameSize read of the broker so a round robin dispatcher
+// hands the whole request to exactly one responder.  The header is well
+// under the 256 bytes reserved for it.
+const maxRequestSize = psub.MaxFrameSize - 256
+
+func encodeRequest(replyTo string, body []byte) []byte {
+	return append([]byte(replyToHeader+replyTo+"\n"), body...)
+}
+
+func decodeRequest(msg []byte) (string, []byte, error) {
+	header, body, found := bytes.Cut(msg, []byte("\n"))
+	replyTo, ok := strings.CutPrefix(string(header), replyToHeader)
+	if !found || !ok || !strings.HasPrefix(replyTo, replyTopicPrefix) {
+		return "", nil, fmt.Errorf("malformed request")
+	}
+	return replyTo, body, nil
+}
+
+// requestRW hands the broker an encoded request in a single read so the
+// publisher doesn't emit a trailing empty message.
+type requestRW struct {
+	data []byte
+}
+
+func (r *requestRW) Read(p []byte) (int, error) {
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	if len(r.data) == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *requestRW) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// requestCapture receives the first non-empty message sent to a "serve"
+// subscriber.
+type requestCapture struct {
+	once sync.Once
+	msg  chan []byte
+}
+
+func newRequestCapture() *requestCapture {
+	return &requestCapture{msg: make(chan []byte, 1)}
+}
+
+func (r *requestCapture) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (r *requestCapture) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	r.once.Do(func() {
+		r.msg <- bytes.Clone(p)
+	})
+	return len(p), nil
+}
+
+// replyRW forwards a reply to the requester and records whether any of it
+// arrived.
+type replyRW struct {
+	io.ReadWriter
+	received atomic.Bool
+}
+
+func (r *replyRW) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		r.received.Store(true)
+	}
+	return r.ReadWriter.Write(p)
+}
+
+/*
+replyDispatcher delivers messages from the first client that publishes to
+a reply topic and drops everything else.  This guarantees a requester
+only ever sees a single response even when multiple responders picked up
+the same request.
+*/
+type replyDispatcher struct {
+	mu       sync.Mutex
+	clientID string
+	fallback psub.MulticastDispatcher
+}
+
+func (d *replyDispatcher) Dispatch(msg psub.ChannelMessage, subscribers []*psub.Client, channelDone chan struct{}) error {
+	d.mu.Lock()
+	if d.clientID == "" && len(msg.Data) > 0 {
+		d.clientID = msg.ClientID
+	}
+	owner := d.clientID
+	d.mu.Unlock()
+
+	if owner != msg.ClientID {
+		return nil
+	}
+
+	return d.fallback.Dispatch(msg, subscribers, channelDone)
+}
@@ -0,0 +1,61 @@
+package pipe
+
+import (
+	"testing"
+
+	psub "github.com/picosh/pico/pkg/pubsub"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	replyTo, body, err := decodeRequest(encodeRequest("_reply/abc", []byte("line one\nline two")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replyTo != "_reply/abc" {
+		t.Errorf("expected reply topic %q, got %q", "_reply/abc", replyTo)
+	}
+	if string(body) != "line one\nline two" {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	for _, msg := range []string{"no header", "reply-to: alice/topic\nbody", "\nbody"} {
+		if _, _, err := decodeRequest([]byte(msg)); err == nil {
+			t.Errorf("expected %q to be rejected", msg)
+		}
+	}
+}
+
+func TestReplyDispatcherFirstResponderWins(t *testing.T) {
+	dsp := &replyDispatcher{}
+	sub := psub.NewClient("sub", nil, psub.ChannelDirectionOutput, false, false, false)
+	done := make(chan struct{})
+
+	received := make(chan psub.ChannelMessage, 4)
+	go func() {
+		for msg := range sub.Data {
+			received <- msg
+		}
+	}()
+
+	msgs := []psub.ChannelMessage{
+		{ClientID: "second", Data: nil},
+		{ClientID: "first", Data: []byte("a")},
+		{ClientID: "second", Data: []byte("b")},
+		{ClientID: "first", Data: []byte("c")},
+	}
+	for _, msg := range msgs {
+		if err := dsp.Dispatch(msg, []*psub.Client{sub}, done); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, expected := range []string{"a", "c"} {
+		msg := <-received
+		if msg.ClientID != "first" || string(msg.Data) != expected {
+			t.Errorf("expected %q from first, got %q from %s", expected, msg.Data, msg.ClientID)
+		}
+	}
+	if len(received) != 0 {
+		t.Errorf("expected no further messages, got %d", len(received))
+	}
+}
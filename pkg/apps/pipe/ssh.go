@@ -2,9 +2,14 @@ package pipe
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/antoniomika/syncmap"
 	"github.com/picosh/pico/pkg/db/postgres"
@@ -35,14 +40,45 @@ func StartSshServer() {
 	cfg.Port = port
 	cfg.PortOverride = portOverride
 
-	pubsub := psub.NewMulticast(logger)
+	var pubsub psub.PubSub = psub.NewMulticast(logger)
+	if federationID := shared.GetEnv("PIPE_FEDERATION_ID", ""); federationID != "" {
+		federation, err := startFederation(ctx, logger, federationID)
+		if err != nil {
+			logger.Error("could not start federation", "err", err)
+		} else {
+			pubsub = federation
+		}
+	}
+
 	handler := &CliHandler{
-		Logger:  logger,
-		DBPool:  dbh,
-		PubSub:  pubsub,
-		Cfg:     cfg,
-		Waiters: syncmap.New[string, []string](),
-		Access:  syncmap.New[string, []string](),
+		Logger:     logger,
+		DBPool:     dbh,
+		PubSub:     pubsub,
+		Cfg:        cfg,
+		Waiters:    syncmap.New[string, []string](),
+		Access:     syncmap.New[string, []string](),
+		Limits:     NewPipeLimits(),
+		Limiter:    psub.NewRateLimiter(),
+		Mailer:     shared.NewMailer(),
+		ConnLimits: syncmap.New[*pssh.SSHServerConn, psub.RateLimits](),
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				handler.Limiter.Cleanup(time.Minute)
+			}
+		}
+	}()
+
+	if strings.ToLower(shared.GetEnv("PIPE_MONITOR_ALERTS", "true")) == "true" {
+		alerter := NewMonitorAlerter(dbh, logger, pubsub)
+		go alerter.Loop(ctx, time.Minute)
 	}
 
 	sshAuth := shared.NewSshAuthHandler(dbh, logger, "pipe")
@@ -99,3 +135,38 @@ func StartSshServer() {
 	<-done
 	exit()
 }
+
+// startFederation bridges this pipe server with its peers so topics work
+// across multiple instances.  It refuses to start without a shared secret
+// since the federation endpoint lets peers publish to any topic.
+func startFederation(ctx context.Context, logger *slog.Logger, id string) (*psub.Federation, error) {
+	peers := []string{}
+	for peer := range strings.SplitSeq(shared.GetEnv("PIPE_FEDERATION_PEERS", ""), ",") {
+		peer = strings.TrimSpace(peer)
+		if peer != "" {
+			peers = append(peers, peer)
+		}
+	}
+	port := shared.GetEnv("PIPE_FEDERATION_PORT", "3100")
+	secret := shared.GetEnv("PIPE_FEDERATION_SECRET", "")
+	if secret == "" {
+		return nil, errors.New("PIPE_FEDERATION_SECRET is required for federation")
+	}
+
+	federation := psub.NewFederation(logger, id, peers, psub.NewHTTPFederationTransport(secret))
+
+	mux := http.NewServeMux()
+	mux.Handle(psub.FederationPath, psub.FederationHandler(federation, secret))
+
+	go func() {
+		logger.Info("starting federation server", "id", id, "port", port, "peers", peers)
+		err := http.ListenAndServe(":"+port, mux)
+		if err != nil {
+			logger.Error("federation server", "err", err)
+		}
+	}()
+
+	go federation.Start(ctx)
+
+	return federation, nil
+}
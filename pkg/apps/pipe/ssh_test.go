@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/ed25519"
 	"crypto/rand"
+	"database/sql"
 	"fmt"
 	"io"
 	"log/slog"
@@ -31,6 +32,9 @@ type TestDB struct {
 	Pubkeys      []*db.PublicKey
 	Features     []*db.FeatureFlag
 	PipeMonitors []*db.PipeMonitor
+	PipeTargets  []*db.PipeMonitorTarget
+	PipeAcls     []*db.PipeAcl
+	Tokens       []*db.Token
 }
 
 func NewTestDB(logger *slog.Logger) *TestDB {
@@ -113,6 +117,28 @@ func (t *TestDB) AddUser(user *db.User) {
 	t.Users = append(t.Users, &cp)
 }
 
+func (t *TestDB) AddToken(token *db.Token) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cp := *token
+	t.Tokens = append(t.Tokens, &cp)
+}
+
+func (t *TestDB) FindUserByScopedToken(token, scope string) (*db.User, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, tkn := range t.Tokens {
+		if tkn.Token != token {
+			continue
+		}
+		if !tkn.HasScope(scope) {
+			return nil, fmt.Errorf("%w: %s", db.ErrTokenScope, scope)
+		}
+		return t.findUserLocked(tkn.UserID)
+	}
+	return nil, sql.ErrNoRows
+}
+
 func (t *TestDB) AddPubkey(pubkey *db.PublicKey) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -194,6 +220,10 @@ func copyPipeMonitor(m *db.PipeMonitor) *db.PipeMonitor {
 		p := *m.LastPing
 		cp.LastPing = &p
 	}
+	if m.AlertedAt != nil {
+		a := *m.AlertedAt
+		cp.AlertedAt = &a
+	}
 	if m.CreatedAt != nil {
 		c := *m.CreatedAt
 		cp.CreatedAt = &c
@@ -236,10 +266,191 @@ func (t *TestDB) FindPipeMonitorHistory(monitorID string, from, to time.Time) ([
 	return nil, nil
 }
 
+func (t *TestDB) UpsertPipeAcl(userID, topic, principal, permission string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, acl := range t.PipeAcls {
+		if acl.UserID == userID && acl.Topic == topic && acl.Principal == principal {
+			acl.Permission = permission
+			return nil
+		}
+	}
+	now := time.Now()
+	t.PipeAcls = append(t.PipeAcls, &db.PipeAcl{
+		ID:         fmt.Sprintf("acl-%s-%s-%s", userID, topic, principal),
+		UserID:     userID,
+		Topic:      topic,
+		Principal:  principal,
+		Permission: permission,
+		CreatedAt:  &now,
+	})
+	return nil
+}
+
+func (t *TestDB) RemovePipeAcl(userID, topic, principal string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, acl := range t.PipeAcls {
+		if acl.UserID == userID && acl.Topic == topic && acl.Principal == principal {
+			t.PipeAcls = append(t.PipeAcls[:i], t.PipeAcls[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("acl not found")
+}
+
+func (t *TestDB) FindPipeAclsByTopic(userID, topic string) ([]*db.PipeAcl, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var acls []*db.PipeAcl
+	for _, acl := range t.PipeAcls {
+		if acl.UserID == userID && acl.Topic == topic {
+			cp := *acl
+			acls = append(acls, &cp)
+		}
+	}
+	return acls, nil
+}
+
+func (t *TestDB) FindPipeAclsByUser(userID string) ([]*db.PipeAcl, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var acls []*db.PipeAcl
+	for _, acl := range t.PipeAcls {
+		if acl.UserID == userID {
+			cp := *acl
+			acls = append(acls, &cp)
+		}
+	}
+	return acls, nil
+}
+
+func (t *TestDB) UpsertPipeMonitorTarget(monitorID, kind, target, secret, token string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	var confirmedAt *time.Time
+	if token == "" {
+		confirmedAt = &now
+	}
+	for _, tg := range t.PipeTargets {
+		if tg.MonitorID == monitorID && tg.Kind == kind && tg.Target == target {
+			tg.Secret = secret
+			tg.Token = token
+			tg.ConfirmedAt = confirmedAt
+			tg.UpdatedAt = &now
+			return nil
+		}
+	}
+	t.PipeTargets = append(t.PipeTargets, &db.PipeMonitorTarget{
+		ID:          fmt.Sprintf("target-%s-%s-%s", monitorID, kind, target),
+		MonitorID:   monitorID,
+		Kind:        kind,
+		Target:      target,
+		Secret:      secret,
+		Token:       token,
+		ConfirmedAt: confirmedAt,
+		CreatedAt:   &now,
+		UpdatedAt:   &now,
+	})
+	return nil
+}
+
+func (t *TestDB) ConfirmPipeMonitorTarget(token string) (*db.PipeMonitorTarget, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tg := range t.PipeTargets {
+		if token != "" && tg.Token == token {
+			if tg.ConfirmedAt == nil {
+				now := time.Now()
+				tg.ConfirmedAt = &now
+			}
+			cp := *tg
+			return &cp, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (t *TestDB) RemovePipeMonitorTarget(monitorID, kind, target string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, tg := range t.PipeTargets {
+		if tg.MonitorID == monitorID && tg.Kind == kind && tg.Target == target {
+			t.PipeTargets = append(t.PipeTargets[:i], t.PipeTargets[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (t *TestDB) FindPipeMonitorTargets(monitorID string) ([]*db.PipeMonitorTarget, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var targets []*db.PipeMonitorTarget
+	for _, tg := range t.PipeTargets {
+		if tg.MonitorID == monitorID {
+			cp := *tg
+			targets = append(targets, &cp)
+		}
+	}
+	return targets, nil
+}
+
+func (t *TestDB) FindPipeMonitorsWithTargets() ([]*db.PipeMonitor, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var monitors []*db.PipeMonitor
+	for _, m := range t.PipeMonitors {
+		for _, tg := range t.PipeTargets {
+			if tg.MonitorID == m.ID {
+				monitors = append(monitors, copyPipeMonitor(m))
+				break
+			}
+		}
+	}
+	return monitors, nil
+}
+
+func (t *TestDB) UpdatePipeMonitorAlertStatus(monitorID, prev, status string, alertedAt *time.Time) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, m := range t.PipeMonitors {
+		if m.ID == monitorID {
+			if m.AlertStatus != prev {
+				return false, nil
+			}
+			m.AlertStatus = status
+			if alertedAt != nil {
+				a := *alertedAt
+				m.AlertedAt = &a
+			}
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("monitor not found")
+}
+
+// fakeMailer records the recipients of emails instead of sending them.
+type fakeMailer struct {
+	mu    sync.Mutex
+	sent  []string
+	texts []string
+}
+
+func (m *fakeMailer) Send(email, subject, text, html string, headers map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, email)
+	m.texts = append(m.texts, text)
+	return nil
+}
+
 type TestSSHServer struct {
 	Cfg         *shared.ConfigSite
 	DBPool      *TestDB
 	PipeHandler *CliHandler
+	Mailer      *fakeMailer
 	Cancel      context.CancelFunc
 }
 
@@ -266,6 +477,7 @@ func NewTestSSHServer(t *testing.T) *TestSSHServer {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	pubsub := psub.NewMulticast(logger)
+	mailer := &fakeMailer{}
 	handler := &CliHandler{
 		Logger:  logger,
 		DBPool:  dbpool,
@@ -273,6 +485,7 @@ func NewTestSSHServer(t *testing.T) *TestSSHServer {
 		Cfg:     cfg,
 		Waiters: syncmap.New[string, []string](),
 		Access:  syncmap.New[string, []string](),
+		Mailer:  mailer,
 	}
 
 	sshAuth := shared.NewSshAuthHandler(dbpool, logger, "pipe")
@@ -322,6 +535,7 @@ func NewTestSSHServer(t *testing.T) *TestSSHServer {
 		Cfg:         cfg,
 		DBPool:      dbpool,
 		PipeHandler: handler,
+		Mailer:      mailer,
 		Cancel:      cancel,
 	}
 }
@@ -1745,6 +1959,102 @@ func TestMonitor_MissingTopic(t *testing.T) {
 
 // Status CLI Tests
 
+func TestMonitor_NotifyAddListRemove(t *testing.T) {
+	server := NewTestSSHServer(t)
+	defer server.Shutdown()
+
+	user := GenerateUser("alice")
+	RegisterUserWithServer(server, user)
+
+	client, err := user.NewClient()
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	_, _ = user.RunCommand(client, "monitor cron 1h")
+
+	output, _ := user.RunCommand(client, "monitor cron notify add email alice@example.com")
+	if !strings.Contains(output, "confirmation email sent to alice@example.com") {
+		t.Errorf("expected email target confirmation, got: %s", output)
+	}
+	if len(server.Mailer.sent) != 1 || server.Mailer.sent[0] != "alice@example.com" {
+		t.Fatalf("expected a confirmation email, got %v", server.Mailer.sent)
+	}
+	if !strings.Contains(server.Mailer.texts[0], "/monitors/confirm/") {
+		t.Errorf("expected confirm link in %s", server.Mailer.texts[0])
+	}
+
+	output, _ = user.RunCommand(client, "monitor cron notify add email alice@example.com")
+	if !strings.Contains(output, "already sent") || len(server.Mailer.sent) != 1 {
+		t.Errorf("expected confirmation not to be resent right away, got: %s", output)
+	}
+
+	output, _ = user.RunCommand(client, "monitor cron notify add webhook http://127.0.0.1:8080/hook")
+	if !strings.Contains(output, "must be a public address") {
+		t.Errorf("expected loopback webhook to be rejected, got: %s", output)
+	}
+
+	output, _ = user.RunCommand(client, "monitor cron notify add webhook https://example.com/hook")
+	if !strings.Contains(output, "webhook secret") {
+		t.Errorf("expected webhook secret to be shown, got: %s", output)
+	}
+
+	output, _ = user.RunCommand(client, "monitor cron notify add topic alerts")
+	if !strings.Contains(output, "notification target added: topic alice/alerts") {
+		t.Errorf("expected topic target to be resolved, got: %s", output)
+	}
+
+	output, _ = user.RunCommand(client, "monitor cron notify ls")
+	for _, want := range []string{"alice@example.com", "unconfirmed", "https://example.com/hook", "alice/alerts"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %s in target list, got: %s", want, output)
+		}
+	}
+
+	output, _ = user.RunCommand(client, "monitor cron notify rm topic alerts")
+	if !strings.Contains(output, "notification target removed: topic alice/alerts") {
+		t.Errorf("expected topic target removal, got: %s", output)
+	}
+
+	monitor, _ := server.DBPool.FindPipeMonitorByTopic("alice-id", "alice/cron")
+	targets, _ := server.DBPool.FindPipeMonitorTargets(monitor.ID)
+	if len(targets) != 2 {
+		t.Errorf("expected 2 remaining targets, got %d", len(targets))
+	}
+}
+
+func TestMonitor_NotifyInvalidTarget(t *testing.T) {
+	server := NewTestSSHServer(t)
+	defer server.Shutdown()
+
+	user := GenerateUser("alice")
+	RegisterUserWithServer(server, user)
+
+	client, err := user.NewClient()
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	output, _ := user.RunCommand(client, "monitor missing notify ls")
+	if !strings.Contains(output, "monitor not found") {
+		t.Errorf("expected missing monitor error, got: %s", output)
+	}
+
+	_, _ = user.RunCommand(client, "monitor cron 1h")
+
+	output, _ = user.RunCommand(client, "monitor cron notify add webhook ftp://example.com")
+	if !strings.Contains(output, "invalid webhook url") {
+		t.Errorf("expected invalid webhook error, got: %s", output)
+	}
+
+	output, _ = user.RunCommand(client, "monitor cron notify add sms 555")
+	if !strings.Contains(output, "invalid target kind") {
+		t.Errorf("expected invalid kind error, got: %s", output)
+	}
+}
+
 func TestStatus_UnauthenticatedUserDenied(t *testing.T) {
 	server := NewTestSSHServer(t)
 	defer server.Shutdown()
@@ -2389,3 +2699,318 @@ func TestSSH_WildcardSub(t *testing.T) {
 		t.Errorf("expected SSH wildcard subscriber output to contain 'prose-event', got: %q", output)
 	}
 }
+
+func TestAcl_UnauthenticatedUserDenied(t *testing.T) {
+	server := NewTestSSHServer(t)
+	defer server.Shutdown()
+
+	user := GenerateUser("anonymous")
+
+	client, err := user.NewClient()
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	output, err := user.RunCommand(client, "acl mytopic ls")
+	if err != nil {
+		t.Logf("command error (expected): %v", err)
+	}
+
+	if !strings.Contains(output, "access denied") {
+		t.Errorf("expected 'access denied', got: %s", output)
+	}
+}
+
+func TestAcl_AddListRemove(t *testing.T) {
+	server := NewTestSSHServer(t)
+	defer server.Shutdown()
+
+	user := GenerateUser("alice")
+	RegisterUserWithServer(server, user)
+
+	client, err := user.NewClient()
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	output, err := user.RunCommand(client, "acl shared add bob read")
+	if err != nil {
+		t.Logf("command completed with: %v", err)
+	}
+	if !strings.Contains(output, "acl added: alice/shared bob read") {
+		t.Errorf("expected acl added confirmation, got: %s", output)
+	}
+
+	output, _ = user.RunCommand(client, "acl shared ls")
+	if !strings.Contains(output, "bob") || !strings.Contains(output, "read") {
+		t.Errorf("expected bob in acl list, got: %s", output)
+	}
+
+	output, _ = user.RunCommand(client, "topics")
+	if !strings.Contains(output, "alice/shared") || !strings.Contains(output, "bob:read") {
+		t.Errorf("expected topic with acl in topics output, got: %s", output)
+	}
+
+	output, _ = user.RunCommand(client, "acl shared rm bob")
+	if !strings.Contains(output, "acl removed: alice/shared bob") {
+		t.Errorf("expected acl removed confirmation, got: %s", output)
+	}
+
+	output, _ = user.RunCommand(client, "acl shared ls")
+	if !strings.Contains(output, "no acls found") {
+		t.Errorf("expected no acls after removal, got: %s", output)
+	}
+}
+
+func TestAcl_InvalidPermission(t *testing.T) {
+	server := NewTestSSHServer(t)
+	defer server.Shutdown()
+
+	user := GenerateUser("alice")
+	RegisterUserWithServer(server, user)
+
+	client, err := user.NewClient()
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	output, _ := user.RunCommand(client, "acl shared add bob admin")
+	if !strings.Contains(output, "invalid permission") {
+		t.Errorf("expected invalid permission error, got: %s", output)
+	}
+}
+
+func TestAcl_StoredAccessAllowsPublish(t *testing.T) {
+	server := NewTestSSHServer(t)
+	defer server.Shutdown()
+
+	alice := GenerateUser("alice")
+	bob := GenerateUser("bob")
+	RegisterUserWithServer(server, alice)
+	RegisterUserWithServer(server, bob)
+
+	if err := server.DBPool.UpsertPipeAcl("alice-id", "alice/stored", "bob", db.PipeAclWrite); err != nil {
+		t.Fatalf("failed to add acl: %v", err)
+	}
+
+	aliceClient, err := alice.NewClient()
+	if err != nil {
+		t.Fatalf("failed to connect alice: %v", err)
+	}
+	defer func() { _ = aliceClient.Close() }()
+
+	aliceSession, err := aliceClient.NewSession()
+	if err != nil {
+		t.Fatalf("failed to create alice session: %v", err)
+	}
+	defer func() { _ = aliceSession.Close() }()
+
+	aliceStdout, err := aliceSession.StdoutPipe()
+	if err != nil {
+		t.Fatalf("failed to get alice stdout: %v", err)
+	}
+
+	// no -a flag: access comes from the stored acl
+	if err := aliceSession.Start("sub stored -c"); err != nil {
+		t.Fatalf("failed to start alice sub: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	bobClient, err := bob.NewClient()
+	if err != nil {
+		t.Fatalf("failed to connect bob: %v", err)
+	}
+	defer func() { _ = bobClient.Close() }()
+
+	_, err = bob.RunCommandWithStdin(bobClient, "pub alice/stored -c", "bob via acl")
+	if err != nil {
+		t.Logf("bob pub completed: %v", err)
+	}
+
+	aliceReceived := make([]byte, 100)
+	n, _ := aliceStdout.Read(aliceReceived)
+
+	if !strings.Contains(string(aliceReceived[:n]), "bob via acl") {
+		t.Errorf("alice should receive bob's message via stored acl, got: %q", string(aliceReceived[:n]))
+	}
+}
+
+func TestAcl_ReadOnlyDoesNotAllowPublish(t *testing.T) {
+	server := NewTestSSHServer(t)
+	defer server.Shutdown()
+
+	alice := GenerateUser("alice")
+	bob := GenerateUser("bob")
+	RegisterUserWithServer(server, alice)
+	RegisterUserWithServer(server, bob)
+
+	if err := server.DBPool.UpsertPipeAcl("alice-id", "alice/readonly", "bob", db.PipeAclRead); err != nil {
+		t.Fatalf("failed to add acl: %v", err)
+	}
+
+	bobClient, err := bob.NewClient()
+	if err != nil {
+		t.Fatalf("failed to connect bob: %v", err)
+	}
+	defer func() { _ = bobClient.Close() }()
+
+	bobSession, err := bobClient.NewSession()
+	if err != nil {
+		t.Fatalf("failed to create bob session: %v", err)
+	}
+	defer func() { _ = bobSession.Close() }()
+
+	if err := bobSession.Start("pub alice/readonly -c"); err != nil {
+		t.Fatalf("failed to start bob pub: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// bob is kept in his own namespace while waiting for subscribers
+	if _, ok := server.PipeHandler.Waiters.Load("bob/alice/readonly"); !ok {
+		t.Errorf("expected bob to wait on his own namespace")
+	}
+	if _, ok := server.PipeHandler.Waiters.Load("alice/readonly"); ok {
+		t.Errorf("bob should not be able to publish to alice/readonly with read access")
+	}
+}
+
+func TestReqServe_RoundTrip(t *testing.T) {
+	server := NewTestSSHServer(t)
+	defer server.Shutdown()
+
+	user := GenerateUser("alice")
+	RegisterUserWithServer(server, user)
+
+	serveClient, err := user.NewClient()
+	if err != nil {
+		t.Fatalf("failed to connect responder: %v", err)
+	}
+	defer func() { _ = serveClient.Close() }()
+
+	reqClient, err := user.NewClient()
+	if err != nil {
+		t.Fatalf("failed to connect requester: %v", err)
+	}
+	defer func() { _ = reqClient.Close() }()
+
+	serveSession, err := serveClient.NewSession()
+	if err != nil {
+		t.Fatalf("failed to create serve session: %v", err)
+	}
+	defer func() { _ = serveSession.Close() }()
+
+	serveStdin, err := serveSession.StdinPipe()
+	if err != nil {
+		t.Fatalf("failed to get serve stdin: %v", err)
+	}
+
+	serveStdout, err := serveSession.StdoutPipe()
+	if err != nil {
+		t.Fatalf("failed to get serve stdout: %v", err)
+	}
+
+	if err := serveSession.Start("serve rpc"); err != nil {
+		t.Fatalf("failed to start serve: %v", err)
+	}
+
+	request := make(chan string, 1)
+	go func() {
+		// the server closes stdout once the full request was written
+		body, _ := io.ReadAll(serveStdout)
+		request <- string(body)
+		_, _ = serveStdin.Write([]byte("pong: " + string(body)))
+		_ = serveStdin.Close()
+	}()
+
+	output, err := user.RunCommandWithStdin(reqClient, "req rpc -t 5s", "ping")
+	if err != nil {
+		t.Fatalf("failed to run req: %v", err)
+	}
+
+	select {
+	case body := <-request:
+		if body != "ping" {
+			t.Errorf("responder expected request %q, got %q", "ping", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("responder never received the request")
+	}
+
+	if output != "pong: ping" {
+		t.Errorf("requester expected reply %q, got %q", "pong: ping", output)
+	}
+}
+
+func TestReq_TimeoutWithoutResponder(t *testing.T) {
+	server := NewTestSSHServer(t)
+	defer server.Shutdown()
+
+	user := GenerateUser("alice")
+	RegisterUserWithServer(server, user)
+
+	client, err := user.NewClient()
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	output, err := user.RunCommand(client, "req nobody -t 100ms")
+	if err != nil {
+		t.Fatalf("failed to run req: %v", err)
+	}
+
+	if !strings.Contains(output, "timeout reached") {
+		t.Errorf("expected timeout error, got: %s", output)
+	}
+}
+
+func TestServe_RequiresTopic(t *testing.T) {
+	server := NewTestSSHServer(t)
+	defer server.Shutdown()
+
+	user := GenerateUser("alice")
+	RegisterUserWithServer(server, user)
+
+	client, err := user.NewClient()
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	output, err := user.RunCommand(client, "serve")
+	if err != nil {
+		t.Fatalf("failed to run serve: %v", err)
+	}
+
+	if !strings.Contains(output, "must provide a topic") {
+		t.Errorf("expected missing topic error, got: %s", output)
+	}
+}
+
+func TestServe_RejectsCommand(t *testing.T) {
+	server := NewTestSSHServer(t)
+	defer server.Shutdown()
+
+	user := GenerateUser("alice")
+	RegisterUserWithServer(server, user)
+
+	client, err := user.NewClient()
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	output, err := user.RunCommand(client, "serve rpc -- cat")
+	if err != nil {
+		t.Fatalf("failed to run serve: %v", err)
+	}
+
+	if !strings.Contains(output, "serve does not run commands") {
+		t.Errorf("expected command to be rejected, got: %s", output)
+	}
+}
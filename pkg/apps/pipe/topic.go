@@ -31,6 +31,7 @@ type TopicResolveInput struct {
 	HasExistingAccess  bool
 	IsAccessCreator    bool
 	HasUserAccess      bool
+	HasStoredAccess    bool
 }
 
 // TopicResolveOutput contains the resolved topic name and any error.
@@ -59,6 +60,11 @@ func resolveTopic(input TopicResolveInput) TopicResolveOutput {
 		withoutUser = input.Topic
 	}
 
+	// Persistent acls stored by the topic owner grant access to their namespace
+	if input.HasStoredAccess && !input.IsAdmin && !input.IsPublic {
+		return TopicResolveOutput{Name: withoutUser, WithoutUser: withoutUser}
+	}
+
 	if input.HasExistingAccess && len(input.ExistingAccessList) > 0 && !input.IsAdmin {
 		if input.HasUserAccess || input.IsAccessCreator {
 			name = withoutUser
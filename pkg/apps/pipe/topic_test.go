@@ -276,6 +276,34 @@ func TestResolveTopic(t *testing.T) {
 				WithoutUser: "newtopic",
 			},
 		},
+		{
+			name: "stored acl grants access to owner namespace",
+			input: TopicResolveInput{
+				UserName:        "bob",
+				Topic:           "alice/shared",
+				IsAdmin:         false,
+				IsPublic:        false,
+				HasStoredAccess: true,
+			},
+			expect: TopicResolveOutput{
+				Name:        "alice/shared",
+				WithoutUser: "alice/shared",
+			},
+		},
+		{
+			name: "without stored acl user stays in own namespace",
+			input: TopicResolveInput{
+				UserName:        "charlie",
+				Topic:           "alice/shared",
+				IsAdmin:         false,
+				IsPublic:        false,
+				HasStoredAccess: false,
+			},
+			expect: TopicResolveOutput{
+				Name:        "charlie/alice/shared",
+				WithoutUser: "alice/shared",
+			},
+		},
 		{
 			name: "admin bypasses access control",
 			input: TopicResolveInput{
@@ -0,0 +1,316 @@
+package prose
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/picosh/pico/pkg/activitypub"
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/shared/router"
+)
+
+func writeActivityJSON(w http.ResponseWriter, contentType string, v any) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// negotiate serves the activity streams document of a page to fediverse
+// servers and the html page to everyone else.
+func negotiate(page http.HandlerFunc, activity http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if activitypub.IsActivityRequest(r) {
+			activity(w, r)
+			return
+		}
+		page(w, r)
+	}
+}
+
+func (f *Federation) findUser(w http.ResponseWriter, r *http.Request) (*db.User, bool) {
+	username := router.GetUsernameFromRequest(r)
+	user, err := f.Db.FindUserByName(username)
+	if err != nil {
+		http.Error(w, "blog not found", http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
+
+func (f *Federation) webfingerHandler(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	acct, ok := strings.CutPrefix(resource, "acct:")
+	if !ok {
+		http.Error(w, "resource must be an acct uri", http.StatusBadRequest)
+		return
+	}
+	username, domain, _ := strings.Cut(acct, "@")
+	if f.Handle(username) != fmt.Sprintf("%s@%s", username, domain) {
+		http.Error(w, "account not found", http.StatusNotFound)
+		return
+	}
+
+	user, err := f.Db.FindUserByName(username)
+	if err != nil {
+		http.Error(w, "account not found", http.StatusNotFound)
+		return
+	}
+
+	blogURL := f.Cfg.BlogURL(user.Name)
+	actorURL := f.ActorURL(user.Name)
+	writeActivityJSON(w, activitypub.JRDContentType, &activitypub.WebFinger{
+		Subject: "acct:" + f.Handle(user.Name),
+		Aliases: []string{blogURL, actorURL},
+		Links: []activitypub.WebFingerLink{
+			{Rel: "self", Type: activitypub.ContentType, Href: actorURL},
+			{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: blogURL},
+		},
+	})
+}
+
+func (f *Federation) actorHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := f.findUser(w, r)
+	if !ok {
+		return
+	}
+
+	actor, err := f.Actor(user)
+	if err != nil {
+		f.Logger.Error("actor", "err", err.Error(), "user", user.Name)
+		http.Error(w, "could not load actor", http.StatusInternalServerError)
+		return
+	}
+	writeActivityJSON(w, activitypub.ContentType, actor)
+}
+
+func (f *Federation) outboxHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := f.findUser(w, r)
+	if !ok {
+		return
+	}
+
+	pager, err := f.Db.FindPostsByUser(&db.Pager{Num: 20, Page: 0}, user.ID, f.Cfg.Space)
+	if err != nil {
+		f.Logger.Error("outbox", "err", err.Error(), "user", user.Name)
+		http.Error(w, "could not load posts", http.StatusInternalServerError)
+		return
+	}
+
+	items := []any{}
+	for _, post := range pager.Data {
+		if !f.isFederated(post) {
+			continue
+		}
+		activity := f.postActivity(user.Name, "Create", post)
+		activity.Context = nil
+		activity.ID = f.PostObject(user.Name, post).ID + "#create"
+		items = append(items, activity)
+	}
+
+	writeActivityJSON(w, activitypub.ContentType, &activitypub.OrderedCollection{
+		Context:      activitypub.Context,
+		ID:           f.OutboxURL(user.Name),
+		Type:         "OrderedCollection",
+		TotalItems:   len(items),
+		OrderedItems: items,
+	})
+}
+
+// followersHandler only reveals how many followers a blog has.
+func (f *Federation) followersHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := f.findUser(w, r)
+	if !ok {
+		return
+	}
+
+	followers, err := f.Db.FindFollowersByUser(user.ID)
+	if err != nil {
+		f.Logger.Error("followers", "err", err.Error(), "user", user.Name)
+		http.Error(w, "could not load followers", http.StatusInternalServerError)
+		return
+	}
+
+	writeActivityJSON(w, activitypub.ContentType, &activitypub.OrderedCollection{
+		Context:    activitypub.Context,
+		ID:         f.FollowersURL(user.Name),
+		Type:       "OrderedCollection",
+		TotalItems: len(followers),
+	})
+}
+
+func (f *Federation) postObjectHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := f.findUser(w, r)
+	if !ok {
+		return
+	}
+
+	slug, _ := url.PathUnescape(router.GetField(r, 0))
+	slug = strings.TrimSuffix(slug, "/")
+	post, err := f.Db.FindPostWithSlug(slug, user.ID, f.Cfg.Space)
+	if err != nil || !f.isFederated(post) {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return
+	}
+
+	obj := f.PostObject(user.Name, post)
+	obj.Context = activitypub.Context
+	writeActivityJSON(w, activitypub.ContentType, obj)
+}
+
+// verifyInbox checks the signature of an inbox request and returns the
+// actor that signed it.
+func (f *Federation) verifyInbox(r *http.Request, body []byte) (*activitypub.Actor, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var actor *activitypub.Actor
+	_, err := activitypub.VerifyRequest(r, body, func(keyID string) (*rsa.PublicKey, error) {
+		var err error
+		actor, err = f.Client.FetchActor(ctx, keyID)
+		if err != nil {
+			return nil, err
+		}
+		if actor.PublicKey.ID != keyID {
+			return nil, fmt.Errorf("key %s does not belong to %s", keyID, actor.ID)
+		}
+		return activitypub.ParsePublicKey(actor.PublicKey.PublicKeyPem)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return actor, nil
+}
+
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Host != "" && ua.Host == ub.Host
+}
+
+func (f *Federation) inboxHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := f.findUser(w, r)
+	if !ok {
+		return
+	}
+	logger := f.Logger.With("user", user.Name)
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, activitypub.MaxDocumentSize))
+	if err != nil {
+		http.Error(w, "could not read body", http.StatusBadRequest)
+		return
+	}
+
+	actor, err := f.verifyInbox(r, body)
+	if err != nil {
+		logger.Info("inbox signature", "err", err.Error())
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	activity := &activitypub.Activity{}
+	err = json.Unmarshal(body, activity)
+	if err != nil {
+		http.Error(w, "invalid activity", http.StatusBadRequest)
+		return
+	}
+	if activity.Actor != actor.ID {
+		http.Error(w, "activity was not signed by its actor", http.StatusUnauthorized)
+		return
+	}
+
+	logger = logger.With("actor", actor.ID, "type", activity.Type)
+	logger.Info("inbox activity")
+
+	switch activity.Type {
+	case "Follow":
+		if activitypub.ObjectID(activity.Object) != f.ActorURL(user.Name) {
+			http.Error(w, "can only follow this blog", http.StatusBadRequest)
+			return
+		}
+		err = f.Db.UpsertFollower(user.ID, actor.ID, actor.DeliveryInbox())
+		if err != nil {
+			break
+		}
+
+		accept := f.activity(user.Name, "Accept", activity)
+		accept.To = []string{actor.ID}
+		accept.Cc = nil
+		go func() {
+			err := f.deliver(user, actor.Inbox, accept)
+			if err != nil {
+				logger.Error("deliver accept", "err", err.Error())
+			}
+		}()
+	case "Undo":
+		undone := &activitypub.Activity{}
+		if activitypub.DecodeObject(activity.Object, undone) == nil && undone.Type == "Follow" {
+			err = f.Db.RemoveFollower(user.ID, actor.ID)
+		}
+	case "Create", "Update":
+		err = f.receiveReply(user, actor, activity)
+	case "Delete":
+		objectID := activitypub.ObjectID(activity.Object)
+		if objectID == actor.ID {
+			err = f.Db.RemoveFollower(user.ID, actor.ID)
+		} else if sameHost(objectID, actor.ID) {
+			err = f.Db.RemovePostMentionsBySource(objectID)
+		}
+	}
+
+	if err != nil {
+		logger.Error("inbox", "err", err.Error())
+		http.Error(w, "could not process activity", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// receiveReply stores notes replying to one of the posts of user, other
+// notes that end up in the inbox are ignored.
+func (f *Federation) receiveReply(user *db.User, actor *activitypub.Actor, activity *activitypub.Activity) error {
+	note := &activitypub.Object{}
+	err := activitypub.DecodeObject(activity.Object, note)
+	if err != nil || note.Type != "Note" || note.InReplyTo == "" {
+		return nil
+	}
+	if note.AttributedTo != actor.ID || !sameHost(note.ID, actor.ID) {
+		return nil
+	}
+
+	post, err := f.findPostByURL(user, note.InReplyTo)
+	if err != nil {
+		return nil
+	}
+
+	name := actor.Name
+	if name == "" {
+		name = actor.PreferredUsername
+	}
+	authorURL := actor.URL
+	if authorURL == "" {
+		authorURL = actor.ID
+	}
+
+	return f.Db.UpsertPostMention(&db.PostMention{
+		PostID:     post.ID,
+		Kind:       db.MentionReply,
+		Source:     note.ID,
+		AuthorName: name,
+		AuthorURL:  authorURL,
+		Content:    shared.HtmlPolicy().Sanitize(note.Content),
+	})
+}
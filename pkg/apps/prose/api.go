@@ -2,8 +2,10 @@ package prose
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"html/template"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -38,6 +40,7 @@ type PostItemData struct {
 	UpdatedAtISO   string
 	UpdatedTimeAgo string
 	Padding        string
+	Lang           string
 }
 
 type BlogPageData struct {
@@ -45,6 +48,9 @@ type BlogPageData struct {
 	PageTitle  string
 	URL        template.URL
 	RSSURL     template.URL
+	SearchURL  template.URL
+	ActorURL   template.URL
+	Webmention template.URL
 	Username   string
 	Readme     *ReadmeTxt
 	Header     *HeaderTxt
@@ -53,6 +59,10 @@ type BlogPageData struct {
 	WithStyles bool
 	CssURL     template.URL
 	HasFilter  bool
+	Lang       string
+	// Newsletter is where the subscribe form posts to, empty when the blog
+	// has no newsletter.
+	Newsletter template.URL
 }
 
 type ReadPageData struct {
@@ -62,6 +72,8 @@ type ReadPageData struct {
 	Posts     []PostItemData
 	Tags      []string
 	HasFilter bool
+	Lang      string
+	Langs     []string
 }
 
 type PostPageData struct {
@@ -90,6 +102,31 @@ type PostPageData struct {
 	UpdatedAtISO string
 	UpdatedAt    string
 	List         *shared.ListParsedText
+	Webmention   template.URL
+	Mentions     []MentionData
+	Series       *SeriesNavData
+	CommentsOn   bool
+	CommentCmd   string
+	Comments     []CommentData
+	Lang         string
+	Translations []TranslationData
+	ReadThrough  template.URL
+}
+
+type CommentData struct {
+	Username  string
+	BlogURL   template.URL
+	Text      string
+	CreatedAt string
+}
+
+type MentionData struct {
+	Kind       string
+	Source     template.URL
+	AuthorName string
+	AuthorURL  template.URL
+	Content    template.HTML
+	CreatedAt  string
 }
 
 type HeaderTxt struct {
@@ -167,13 +204,16 @@ func blogHandler(w http.ResponseWriter, r *http.Request) {
 	logger = shared.LoggerWithUser(logger, user)
 
 	tag := r.URL.Query().Get("tag")
+	lang := queryLang(r)
 	pager := &db.Pager{Num: 250, Page: 0}
 	var posts []*db.Post
 	var p *db.Paginate[*db.Post]
-	if tag == "" {
-		p, err = dbpool.FindPostsByUser(pager, user.ID, cfg.Space)
-	} else {
+	if tag != "" {
 		p, err = dbpool.FindUserPostsByTag(pager, tag, user.ID, cfg.Space)
+	} else if lang != "" {
+		p, err = dbpool.FindUserPostsByLang(pager, lang, user.ID, cfg.Space)
+	} else {
+		p, err = dbpool.FindPostsByUser(pager, user.ID, cfg.Space)
 	}
 	posts = p.Data
 
@@ -183,20 +223,8 @@ func blogHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ts, err := router.RenderTemplate(cfg, []string{
-		cfg.StaticPath("html/blog-default.partial.tmpl"),
-		cfg.StaticPath("html/blog-aside.partial.tmpl"),
-		cfg.StaticPath("html/blog.page.tmpl"),
-	})
-
 	curl := shared.CreateURLFromRequest(cfg, r)
 
-	if err != nil {
-		logger.Error("render template", "err", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	headerTxt := &HeaderTxt{
 		Title:      GetBlogName(username),
 		Bio:        "",
@@ -205,6 +233,8 @@ func blogHandler(w http.ResponseWriter, r *http.Request) {
 		WithStyles: true,
 	}
 	readmeTxt := &ReadmeTxt{}
+	theme := ""
+	newsletter := false
 
 	readme, err := dbpool.FindPostWithFilename("_readme.md", user.ID, cfg.Space)
 	if err == nil {
@@ -212,6 +242,8 @@ func blogHandler(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			logger.Error("readme", "err", err.Error())
 		}
+		theme = parsedText.Theme
+		newsletter = parsedText.Newsletter != ""
 		headerTxt.Bio = parsedText.Description
 		headerTxt.Layout = parsedText.Layout
 		headerTxt.Image = template.URL(parsedText.Image)
@@ -261,30 +293,43 @@ func blogHandler(w http.ResponseWriter, r *http.Request) {
 			PublishAtISO:   post.PublishAt.Format(time.RFC3339),
 			UpdatedTimeAgo: shared.TimeAgo(post.UpdatedAt),
 			UpdatedAtISO:   post.UpdatedAt.Format(time.RFC3339),
+			Lang:           post.Data.Lang,
 		}
 		postCollection = append(postCollection, p)
 	}
 
+	rssURL := cfg.RssBlogURL(curl, username, tag)
+	if lang != "" && tag == "" {
+		rssURL += "?lang=" + url.QueryEscape(lang)
+	}
+
 	data := BlogPageData{
 		Site:       *cfg.GetSiteData(),
 		PageTitle:  headerTxt.Title,
 		URL:        template.URL(cfg.FullBlogURL(curl, username)),
-		RSSURL:     template.URL(cfg.RssBlogURL(curl, username, tag)),
+		RSSURL:     template.URL(rssURL),
+		SearchURL:  template.URL(cfg.FullPostURL(curl, username, "search")),
+		ActorURL:   template.URL(cfg.BlogURL(username) + "/_ap/actor"),
+		Webmention: template.URL(cfg.BlogURL(username) + "/_webmention"),
 		Readme:     readmeTxt,
 		Header:     headerTxt,
 		Username:   username,
 		Posts:      postCollection,
 		HasCSS:     hasCSS,
 		CssURL:     template.URL(cfg.CssURL(username)),
-		HasFilter:  tag != "",
+		HasFilter:  tag != "" || lang != "",
 		WithStyles: headerTxt.WithStyles,
+		Lang:       lang,
+	}
+	if newsletter {
+		data.Newsletter = template.URL(subscribeURL(cfg, username))
 	}
 
-	err = ts.Execute(w, data)
-	if err != nil {
-		logger.Error("template execute", "err", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	page := "blog.html"
+	if tag != "" {
+		page = "tag.html"
 	}
+	renderPage(w, r, user.ID, theme, page, data)
 }
 
 func postRawHandler(w http.ResponseWriter, r *http.Request) {
@@ -313,7 +358,7 @@ func postRawHandler(w http.ResponseWriter, r *http.Request) {
 	logger = shared.LoggerWithUser(logger, user)
 
 	post, err := dbpool.FindPostWithSlug(slug, user.ID, cfg.Space)
-	if err != nil {
+	if err != nil || !canView(r, post) {
 		logger.Info("post not found")
 		http.Error(w, "post not found", http.StatusNotFound)
 		return
@@ -388,6 +433,8 @@ func postHandler(w http.ResponseWriter, r *http.Request) {
 	ogImageCard := ""
 	hasCSS := false
 	withStyles := true
+	showMentions := false
+	theme := ""
 	var data PostPageData
 
 	css, err := dbpool.FindPostWithFilename("_styles.css", user.ID, cfg.Space)
@@ -418,13 +465,18 @@ func postHandler(w http.ResponseWriter, r *http.Request) {
 			blogName = readmeParsed.Title
 		}
 		withStyles = readmeParsed.WithStyles
+		showMentions = readmeParsed.Mentions
 		ogImage = readmeParsed.Image
 		ogImageCard = readmeParsed.ImageCard
 		favicon = readmeParsed.Favicon
+		theme = readmeParsed.Theme
 	}
 
 	diff := ""
 	post, err := dbpool.FindPostWithSlug(slug, user.ID, cfg.Space)
+	if err == nil && !canView(r, post) {
+		err = fmt.Errorf("draft requires a preview token")
+	}
 	if err == nil {
 		logger.Info("post found", "id", post.ID, "filename", post.FileSize)
 		ext := filepath.Ext(post.Filename)
@@ -492,6 +544,61 @@ func postHandler(w http.ResponseWriter, r *http.Request) {
 			Diff:         template.HTML(diff),
 			WithStyles:   withStyles,
 			List:         list,
+			Webmention:   template.URL(cfg.BlogURL(username) + "/_webmention"),
+		}
+
+		data.Lang = post.Data.Lang
+		data.Translations = translations(cfg, curl, post)
+		if !unlisted && dbpool.HasFeatureByUser(user.ID, "analytics") {
+			data.ReadThrough = template.URL(readThroughURL(cfg.FullBlogURL(curl, username), post.Slug))
+		}
+
+		data.Series, err = seriesNav(r, post)
+		if err != nil {
+			logger.Error("series nav", "err", err.Error())
+		}
+
+		if commentsEnabled(dbpool, cfg.Space, post) {
+			data.CommentsOn = true
+			data.CommentCmd = fmt.Sprintf(
+				"ssh %s comment %s/%s < comment.txt",
+				strings.Split(cfg.Domain, ":")[0],
+				username,
+				post.Slug,
+			)
+			comments, err := dbpool.FindPostComments(post.ID)
+			if err != nil {
+				logger.Error("find comments", "err", err.Error())
+			}
+			for _, comment := range comments {
+				if !comment.Approved {
+					continue
+				}
+				data.Comments = append(data.Comments, CommentData{
+					Username:  comment.Username,
+					BlogURL:   template.URL(cfg.FullBlogURL(curl, comment.Username)),
+					Text:      comment.Text,
+					CreatedAt: comment.CreatedAt.Format(time.DateOnly),
+				})
+			}
+		}
+
+		if showMentions {
+			mentions, err := dbpool.FindPostMentions(post.ID)
+			if err != nil {
+				logger.Error("find mentions", "err", err.Error())
+			}
+			for _, mention := range mentions {
+				data.Mentions = append(data.Mentions, MentionData{
+					Kind:       mention.Kind,
+					Source:     template.URL(mention.Source),
+					AuthorName: mention.AuthorName,
+					AuthorURL:  template.URL(mention.AuthorURL),
+					// content is sanitized when the mention is received
+					Content:   template.HTML(mention.Content),
+					CreatedAt: mention.CreatedAt.Format(time.DateOnly),
+				})
+			}
 		}
 	} else {
 		logger.Info("post not found")
@@ -543,22 +650,8 @@ func postHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
 	}
 
-	ts, err := router.RenderTemplate(cfg, []string{
-		cfg.StaticPath("html/list.partial.tmpl"),
-		cfg.StaticPath("html/post.page.tmpl"),
-	})
-
-	if err != nil {
-		logger.Error("render template", "err", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-
 	logger.Info("executing template", "title", data.Title, "url", data.URL, "hasCSS", data.HasCSS)
-	err = ts.Execute(w, data)
-	if err != nil {
-		logger.Error("template", "err", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	renderPage(w, r, user.ID, theme, "post.html", data)
 }
 
 func readHandler(w http.ResponseWriter, r *http.Request) {
@@ -568,12 +661,29 @@ func readHandler(w http.ResponseWriter, r *http.Request) {
 
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 	tag := r.URL.Query().Get("tag")
-	var pager *db.Paginate[*db.Post]
-	var err error
-	if tag == "" {
-		pager, err = dbpool.FindPostsByFeed(&db.Pager{Num: 30, Page: page}, cfg.Space)
+
+	langs, err := dbpool.FindPostLangs(cfg.Space)
+	if err != nil {
+		logger.Error("find post langs", "err", err.Error())
+	}
+	langs = primaryLangs(langs)
+	// ?lang=* turns off the negotiation with Accept-Language
+	query := ""
+	lang := queryLang(r)
+	if r.URL.Query().Has("lang") {
+		query = "&lang=" + url.QueryEscape(r.URL.Query().Get("lang"))
 	} else {
+		lang = negotiateLang(r.Header.Get("Accept-Language"), langs)
+		w.Header().Add("Vary", "Accept-Language")
+	}
+
+	var pager *db.Paginate[*db.Post]
+	if tag != "" {
 		pager, err = dbpool.FindPostsByTag(&db.Pager{Num: 30, Page: page}, tag, cfg.Space)
+	} else if lang != "" {
+		pager, err = dbpool.FindPostsByLang(&db.Pager{Num: 30, Page: page}, lang, cfg.Space)
+	} else {
+		pager, err = dbpool.FindPostsByFeed(&db.Pager{Num: 30, Page: page}, cfg.Space)
 	}
 
 	if err != nil {
@@ -596,6 +706,7 @@ func readHandler(w http.ResponseWriter, r *http.Request) {
 		if tag != "" {
 			nextPage = fmt.Sprintf("%s&tag=%s", nextPage, tag)
 		}
+		nextPage += query
 	}
 
 	prevPage := ""
@@ -604,6 +715,7 @@ func readHandler(w http.ResponseWriter, r *http.Request) {
 		if tag != "" {
 			prevPage = fmt.Sprintf("%s&tag=%s", prevPage, tag)
 		}
+		prevPage += query
 	}
 
 	tags, err := dbpool.FindPopularTags(cfg.Space)
@@ -617,6 +729,8 @@ func readHandler(w http.ResponseWriter, r *http.Request) {
 		PrevPage:  prevPage,
 		Tags:      tags,
 		HasFilter: tag != "",
+		Lang:      lang,
+		Langs:     langs,
 	}
 
 	curl := shared.NewCreateURL(cfg)
@@ -631,6 +745,7 @@ func readHandler(w http.ResponseWriter, r *http.Request) {
 			PublishAtISO:   post.PublishAt.Format(time.RFC3339),
 			UpdatedTimeAgo: shared.TimeAgo(post.UpdatedAt),
 			UpdatedAtISO:   post.UpdatedAt.Format(time.RFC3339),
+			Lang:           post.Data.Lang,
 		}
 		data.Posts = append(data.Posts, item)
 	}
@@ -658,13 +773,16 @@ func rssBlogHandler(w http.ResponseWriter, r *http.Request) {
 	logger.Info("fetching blog rss")
 
 	tag := r.URL.Query().Get("tag")
+	lang := queryLang(r)
 	pager := &db.Pager{Num: 10, Page: 0}
 	var posts []*db.Post
 	var p *db.Paginate[*db.Post]
-	if tag == "" {
-		p, err = dbpool.FindPostsByUser(pager, user.ID, cfg.Space)
-	} else {
+	if tag != "" {
 		p, err = dbpool.FindUserPostsByTag(pager, tag, user.ID, cfg.Space)
+	} else if lang != "" {
+		p, err = dbpool.FindUserPostsByLang(pager, lang, user.ID, cfg.Space)
+	} else {
+		p, err = dbpool.FindPostsByUser(pager, user.ID, cfg.Space)
 	}
 	posts = p.Data
 
@@ -796,6 +914,15 @@ func rssBlogHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Could not generate atom rss feed", http.StatusInternalServerError)
 	}
 
+	// advertise the hubs the publish scheduler pings so readers can
+	// subscribe to updates instead of polling
+	if hubs := WebSubHubs(); tag == "" && lang == "" && len(hubs) > 0 {
+		for _, hub := range hubs {
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="hub"`, hub))
+		}
+		curl := shared.NewCreateURL(cfg)
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="self"`, cfg.RssBlogURL(curl, username, "")))
+	}
 	w.Header().Add("Content-Type", "application/atom+xml; charset=utf-8")
 	_, err = w.Write([]byte(rss))
 	if err != nil {
@@ -808,7 +935,13 @@ func rssHandler(w http.ResponseWriter, r *http.Request) {
 	logger := router.GetLogger(r)
 	cfg := router.GetCfg(r)
 
-	pager, err := dbpool.FindPostsByFeed(&db.Pager{Num: 25, Page: 0}, cfg.Space)
+	var pager *db.Paginate[*db.Post]
+	var err error
+	if lang := queryLang(r); lang != "" {
+		pager, err = dbpool.FindPostsByLang(&db.Pager{Num: 25, Page: 0}, lang, cfg.Space)
+	} else {
+		pager, err = dbpool.FindPostsByFeed(&db.Pager{Num: 25, Page: 0}, cfg.Space)
+	}
 	if err != nil {
 		logger.Error("find all posts", "err", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -939,14 +1072,17 @@ func createStaticRoutes() []router.Route {
 	}
 }
 
-func createMainRoutes(staticRoutes []router.Route) []router.Route {
+func createMainRoutes(staticRoutes []router.Route, fed *Federation, nl *Newsletter) []router.Route {
 	routes := []router.Route{
 		router.NewRoute("GET", "/", readHandler),
 		router.NewRoute("GET", "/read", readHandler),
+		router.NewRoute("GET", "/search", searchHandler),
 		router.NewRoute("GET", "/check", router.CheckHandler),
 		router.NewRoute("GET", "/rss", rssHandler),
 		router.NewRoute("GET", "/rss.atom", rssHandler),
 		router.NewRoute("GET", "/_metrics", promhttp.Handler().ServeHTTP),
+		router.NewCorsRoute("GET", "/.well-known/webfinger", fed.webfingerHandler),
+		router.NewRoute("POST", "/_newsletter/bounce", nl.bounceHandler),
 	}
 
 	routes = append(
@@ -994,9 +1130,9 @@ func imgRequest(w http.ResponseWriter, r *http.Request) {
 	imgproxy.ServeHTTP(w, r)
 }
 
-func createSubdomainRoutes(staticRoutes []router.Route) []router.Route {
+func createSubdomainRoutes(staticRoutes []router.Route, fed *Federation, nl *Newsletter) []router.Route {
 	routes := []router.Route{
-		router.NewRoute("GET", "/", blogHandler),
+		router.NewRoute("GET", "/", negotiate(blogHandler, fed.actorHandler)),
 		router.NewRoute("GET", "/_styles.css", blogStyleHandler),
 		router.NewRoute("GET", "/robots.txt", robotsHandler),
 		router.NewRoute("GET", "/rss", rssBlogHandler),
@@ -1005,6 +1141,19 @@ func createSubdomainRoutes(staticRoutes []router.Route) []router.Route {
 		router.NewRoute("GET", "/feed.xml", rssBlogHandler),
 		router.NewRoute("GET", "/atom", rssBlogHandler),
 		router.NewRoute("GET", "/blog/index.xml", rssBlogHandler),
+		router.NewRoute("GET", "/search", blogSearchHandler),
+		router.NewRoute("GET", "/series/(.+)", seriesHandler),
+		router.NewRoute("GET", "/_read/(.+)", readThroughHandler),
+		router.NewCorsRoute("GET", "/.well-known/webfinger", fed.webfingerHandler),
+		router.NewRoute("GET", "/_ap/actor", fed.actorHandler),
+		router.NewRoute("POST", "/_ap/inbox", fed.inboxHandler),
+		router.NewRoute("GET", "/_ap/outbox", fed.outboxHandler),
+		router.NewRoute("GET", "/_ap/followers", fed.followersHandler),
+		router.NewRoute("POST", "/_webmention", fed.webmentionHandler),
+		router.NewRoute("POST", "/_newsletter/subscribe", nl.subscribeHandler),
+		router.NewRoute("GET", "/_newsletter/confirm/(.+)", nl.confirmHandler),
+		router.NewRoute("GET", "/_newsletter/unsub/(.+)", nl.unsubPageHandler),
+		router.NewRoute("POST", "/_newsletter/unsub/(.+)", nl.unsubHandler),
 	}
 
 	routes = append(
@@ -1019,8 +1168,8 @@ func createSubdomainRoutes(staticRoutes []router.Route) []router.Route {
 		router.NewRoute("GET", "/(.+).lxt", postRawHandler),
 		router.NewRoute("GET", `/(.+\.(?:jpg|jpeg|png|gif|webp|svg|ico))/(.+)`, imgRequest),
 		router.NewRoute("GET", `/(.+\.(?:jpg|jpeg|png|gif|webp|svg|ico))$`, imgRequest),
-		router.NewRoute("GET", "/(.+).html", postHandler),
-		router.NewRoute("GET", "/(.+)", postHandler),
+		router.NewRoute("GET", "/(.+).html", negotiate(postHandler, fed.postObjectHandler)),
+		router.NewRoute("GET", "/(.+)", negotiate(postHandler, fed.postObjectHandler)),
 	)
 
 	return routes
@@ -1047,8 +1196,21 @@ func StartApiServer() {
 		staticRoutes = router.CreatePProfRoutes(staticRoutes)
 	}
 
-	mainRoutes := createMainRoutes(staticRoutes)
-	subdomainRoutes := createSubdomainRoutes(staticRoutes)
+	fed := NewFederation(cfg, dbpool)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var events io.Writer
+	if strings.ToLower(shared.GetEnv("PICO_PIPE_ENABLED", "true")) == "true" {
+		events = CreatePubPublishEvents(ctx, logger)
+	}
+	nl := NewNewsletter(cfg, dbpool)
+	scheduler := NewPublishScheduler(cfg, dbpool, fed, nl, events)
+	go scheduler.Loop(ctx, time.Minute)
+	go nl.Loop(ctx, time.Minute)
+
+	mainRoutes := createMainRoutes(staticRoutes, fed, nl)
+	subdomainRoutes := createSubdomainRoutes(staticRoutes, fed, nl)
 
 	apiConfig := &router.ApiConfig{
 		Cfg:     cfg,
@@ -0,0 +1,259 @@
+package prose
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/pssh"
+	"github.com/picosh/pico/pkg/shared"
+)
+
+// maxCommentSize is the most bytes a comment can have.
+const maxCommentSize = 4 * 1024
+
+// postComments reads the comments setting from the front-matter of a post,
+// nil means the post uses the setting of the blog.
+func postComments(post *db.Post) *bool {
+	if strings.HasSuffix(post.Filename, ".lxt") {
+		return shared.ListParseText(post.Text).Comments
+	}
+	parsed, err := shared.ParseText(post.Text)
+	if err != nil {
+		return nil
+	}
+	return parsed.Comments
+}
+
+// commentsEnabled reports whether readers can comment on a post.  Comments
+// are enabled for the whole blog in _readme.md and each post can opt in or
+// out in its own front-matter.
+func commentsEnabled(dbpool db.DB, space string, post *db.Post) bool {
+	if enabled := postComments(post); enabled != nil {
+		return *enabled
+	}
+	readme, err := dbpool.FindPostWithFilename("_readme.md", post.UserID, space)
+	if err != nil {
+		return false
+	}
+	parsed, err := shared.ParseText(readme.Text)
+	if err != nil || parsed.Comments == nil {
+		return false
+	}
+	return *parsed.Comments
+}
+
+// CommentCmd implements the comment commands of the prose ssh server for
+// User.
+type CommentCmd struct {
+	Cfg  *shared.ConfigSite
+	Db   db.DB
+	User *db.User
+	Out  io.Writer
+}
+
+func (c *CommentCmd) output(format string, args ...any) {
+	_, _ = fmt.Fprintf(c.Out, format+"\r\n", args...)
+}
+
+// Comment leaves the comment read from text on target, a post formatted
+// as {user}/{slug}.  Comments are held for the author of the post to
+// approve unless they comment on their own post.
+func (c *CommentCmd) Comment(target string, text io.Reader) error {
+	username, slug, ok := strings.Cut(strings.Trim(target, "/"), "/")
+	if !ok || username == "" || slug == "" {
+		return fmt.Errorf("post must be formatted as {user}/{slug}, got %q", target)
+	}
+
+	author, err := c.Db.FindUserByName(username)
+	if err != nil {
+		return fmt.Errorf("blog %s not found", username)
+	}
+	post, err := c.Db.FindPostWithSlug(slug, author.ID, c.Cfg.Space)
+	if err != nil || post.Hidden || post.PublishAt.After(time.Now()) {
+		return fmt.Errorf("post %s not found", target)
+	}
+	if !commentsEnabled(c.Db, c.Cfg.Space, post) {
+		return fmt.Errorf("comments are disabled for %s", target)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(text, maxCommentSize+1))
+	if err != nil {
+		return err
+	}
+	if len(raw) > maxCommentSize {
+		return fmt.Errorf("comment has exceeded maximum size (%d bytes)", maxCommentSize)
+	}
+	body := strings.TrimSpace(string(raw))
+	if body == "" {
+		return fmt.Errorf("comment is empty, provide it on stdin")
+	}
+	if !shared.IsTextFile(body) {
+		return fmt.Errorf("comment must be plain text (utf-8)")
+	}
+
+	comment, err := c.Db.InsertPostComment(&db.PostComment{
+		PostID:   post.ID,
+		UserID:   c.User.ID,
+		Text:     body,
+		Approved: post.UserID == c.User.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	curl := shared.NewCreateURL(c.Cfg)
+	postURL := c.Cfg.FullPostURL(curl, author.Name, post.Slug)
+	if comment.Approved {
+		c.output("comment published: %s#comments", postURL)
+	} else {
+		c.output("comment %s is waiting for %s to approve it: %s", comment.ID, author.Name, postURL)
+	}
+	return nil
+}
+
+// commentExcerpt squashes a comment onto a single short line for tables.
+func commentExcerpt(text string) string {
+	excerpt := strings.Join(strings.Fields(text), " ")
+	if len([]rune(excerpt)) > 50 {
+		excerpt = string([]rune(excerpt)[:50]) + "..."
+	}
+	return excerpt
+}
+
+// List prints the comments on the posts of the user.
+func (c *CommentCmd) List() error {
+	comments, err := c.Db.FindPostCommentsByOwner(c.User.ID, c.Cfg.Space)
+	if err != nil {
+		return err
+	}
+	if len(comments) == 0 {
+		c.output("no comments found")
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(c.Out, 0, 0, 1, ' ', tabwriter.TabIndent)
+	_, _ = fmt.Fprintln(writer, "ID\tPost\tAuthor\tStatus\tCreated At\tComment")
+	for _, comment := range comments {
+		status := "pending"
+		if comment.Approved {
+			status = "approved"
+		}
+		_, _ = fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\t%s\t%s\t%s\r\n",
+			comment.ID,
+			comment.PostSlug,
+			comment.Username,
+			status,
+			comment.CreatedAt.Format(time.DateTime),
+			commentExcerpt(comment.Text),
+		)
+	}
+	return writer.Flush()
+}
+
+func (c *CommentCmd) Approve(commentID string) error {
+	err := c.Db.ApprovePostComment(c.User.ID, commentID)
+	if err != nil {
+		return err
+	}
+	c.output("comment %s approved", commentID)
+	return nil
+}
+
+func (c *CommentCmd) Remove(commentID string) error {
+	err := c.Db.RemovePostComment(c.User.ID, commentID)
+	if err != nil {
+		return err
+	}
+	c.output("comment %s removed", commentID)
+	return nil
+}
+
+func commentsHelp(out io.Writer) error {
+	_, _ = fmt.Fprintf(out, "Commands: [comment, comments ls, comments approve, comments rm]\r\n\r\n")
+	writer := tabwriter.NewWriter(out, 0, 0, 1, ' ', tabwriter.TabIndent)
+	_, _ = fmt.Fprintln(writer, "Cmd\tDesc")
+	_, _ = fmt.Fprintf(writer, "%s\t%s\r\n", "comment {user}/{slug}", "comment on a post, the comment is read from stdin")
+	_, _ = fmt.Fprintf(writer, "%s\t%s\r\n", "comments ls", "list the comments on your posts")
+	_, _ = fmt.Fprintf(writer, "%s\t%s\r\n", "comments approve {id}", "show a comment on your post")
+	_, _ = fmt.Fprintf(writer, "%s\t%s\r\n", "comments rm {id}", "remove a comment from your post")
+	return writer.Flush()
+}
+
+// commenter returns who a comment is attributed to, in an org context
+// that is the member and not the org.
+func commenter(sesh *pssh.SSHServerConnSession, dbpool db.DB, user *db.User) (*db.User, error) {
+	memberID := pssh.GetOrgMemberID(sesh)
+	if memberID == "" {
+		return user, nil
+	}
+	return dbpool.FindUser(memberID)
+}
+
+/*
+Middleware handles the comment commands, every other command is passed on
+so `ssh prose.sh {name}` keeps publishing posts from stdin.
+*/
+func Middleware(dbpool db.DB, cfg *shared.ConfigSite) pssh.SSHServerMiddleware {
+	return func(next pssh.SSHServerHandler) pssh.SSHServerHandler {
+		return func(sesh *pssh.SSHServerConnSession) error {
+			args := sesh.Command()
+			isComment := len(args) == 2 && args[0] == "comment"
+			isComments := len(args) >= 2 && args[0] == "comments"
+			if !isComment && !isComments {
+				return next(sesh)
+			}
+
+			logger := pssh.GetLogger(sesh)
+			user := pssh.GetUser(sesh)
+			if user == nil {
+				err := fmt.Errorf("user not found")
+				_, _ = fmt.Fprintln(sesh.Stderr(), err)
+				return err
+			}
+			logger = shared.LoggerWithUser(logger, user)
+
+			cmd := &CommentCmd{
+				Cfg:  cfg,
+				Db:   dbpool,
+				User: user,
+				Out:  sesh,
+			}
+
+			var err error
+			switch {
+			case isComment && !pssh.CanWrite(sesh):
+				err = pssh.ErrOrgViewer
+			case isComment:
+				logger.Info("comment cmd", "post", args[1])
+				cmd.User, err = commenter(sesh, dbpool, user)
+				if err == nil {
+					err = cmd.Comment(args[1], sesh)
+				}
+			case args[1] == "ls":
+				err = cmd.List()
+			case (args[1] == "approve" || args[1] == "rm") && len(args) == 3 && !pssh.CanManage(sesh):
+				err = pssh.ErrOrgOwner
+			case args[1] == "approve" && len(args) == 3:
+				logger.Info("approve comment cmd", "id", args[2])
+				err = cmd.Approve(args[2])
+			case args[1] == "rm" && len(args) == 3:
+				logger.Info("rm comment cmd", "id", args[2])
+				err = cmd.Remove(args[2])
+			default:
+				return commentsHelp(sesh)
+			}
+
+			if err != nil {
+				logger.Error("comment cmd", "err", err.Error())
+				_, _ = fmt.Fprintln(sesh.Stderr(), err)
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package prose
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/picosh/pico/pkg/db"
+)
+
+func TestCommentsEnabled(t *testing.T) {
+	ft := newProseTest(t)
+
+	if commentsEnabled(ft.dbpool, "prose", ft.post) {
+		t.Fatal("comments should be disabled by default")
+	}
+
+	readme := &db.Post{ID: "readme", UserID: ft.user.ID, Filename: "_readme.md", Space: "prose", Text: "---\ncomments: true\n---\n"}
+	ft.dbpool.Posts = append(ft.dbpool.Posts, readme)
+	if !commentsEnabled(ft.dbpool, "prose", ft.post) {
+		t.Fatal("comments should be enabled for the blog")
+	}
+
+	ft.post.Text = "---\ncomments: false\n---\n# Hello"
+	if commentsEnabled(ft.dbpool, "prose", ft.post) {
+		t.Fatal("post should disable comments")
+	}
+
+	readme.Text = "# readme"
+	ft.post.Text = "---\ncomments: true\n---\n# Hello"
+	if !commentsEnabled(ft.dbpool, "prose", ft.post) {
+		t.Fatal("post should enable comments")
+	}
+}
+
+func TestCommentCmd(t *testing.T) {
+	t.Chdir("../../..")
+	ft := newProseTest(t)
+	reader := &db.User{ID: "user-2", Name: "reader"}
+	ft.dbpool.Users = append(ft.dbpool.Users, reader)
+
+	out := &bytes.Buffer{}
+	readerCmd := &CommentCmd{Cfg: ft.fed.Cfg, Db: ft.dbpool, User: reader, Out: out}
+	authorCmd := &CommentCmd{Cfg: ft.fed.Cfg, Db: ft.dbpool, User: ft.user, Out: out}
+
+	err := readerCmd.Comment("erock/hello", strings.NewReader("first!"))
+	if err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Fatalf("expected comments to be disabled, got %v", err)
+	}
+
+	ft.post.Text = "---\ncomments: true\n---\n# Hello\n\nfrom prose"
+	for _, target := range []string{"erock", "nobody/hello", "erock/nope"} {
+		if err := readerCmd.Comment(target, strings.NewReader("hi")); err == nil {
+			t.Errorf("%s: expected an error", target)
+		}
+	}
+	if err := readerCmd.Comment("erock/hello", strings.NewReader("  \n")); err == nil {
+		t.Error("expected empty comment to fail")
+	}
+	if err := readerCmd.Comment("erock/hello", strings.NewReader(strings.Repeat("a", maxCommentSize+1))); err == nil {
+		t.Error("expected large comment to fail")
+	}
+
+	err = readerCmd.Comment("erock/hello", strings.NewReader("<b>great</b> post\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "waiting for erock to approve") {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	err = readerCmd.Comment("erock/hello", strings.NewReader("spam"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	postPage := func() string {
+		rec := httptest.NewRecorder()
+		ft.serve(rec, httptest.NewRequest(http.MethodGet, ft.postURL, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("post: want 200, got %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	body := postPage()
+	if strings.Contains(body, "great") {
+		t.Fatal("pending comment was rendered")
+	}
+	if !strings.Contains(body, "ssh prose.test comment erock/hello") {
+		t.Fatal("post page does not explain how to comment")
+	}
+
+	if err := readerCmd.Approve("comment-1"); err == nil {
+		t.Fatal("reader should not be able to approve comments")
+	}
+	if err := authorCmd.Approve("comment-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := authorCmd.Remove("comment-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if err := authorCmd.List(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "comment-1") || !strings.Contains(out.String(), "approved") || strings.Contains(out.String(), "comment-2") {
+		t.Fatalf("unexpected comment list %q", out.String())
+	}
+
+	body = postPage()
+	if !strings.Contains(body, "&lt;b&gt;great&lt;/b&gt; post") {
+		t.Fatal("approved comment was not rendered escaped")
+	}
+	if strings.Contains(body, "spam") {
+		t.Fatal("removed comment was rendered")
+	}
+
+	out.Reset()
+	if err := authorCmd.Comment("erock/hello", strings.NewReader("thanks")); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "comment published") {
+		t.Fatalf("author comments should be published right away, got %q", out.String())
+	}
+}
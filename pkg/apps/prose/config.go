@@ -24,6 +24,7 @@ func NewConfigSite(service string) *shared.ConfigSite {
 		Port:     port,
 		Protocol: protocol,
 		DbURL:    dbURL,
+		Secret:   shared.GetEnv("PICO_SECRET", ""),
 		Space:    "prose",
 		AllowedExt: []string{
 			".md",
@@ -0,0 +1,408 @@
+package prose
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/pssh"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/shared/router"
+	"github.com/picosh/pico/pkg/storage"
+)
+
+// exportDir is the virtual directory rsync can download an export from.
+const exportDir = "_export/"
+
+// exportFeed is where the feed of the blog is stored in an export, every
+// feed url of the blog links to it.
+const exportFeed = "atom.xml"
+
+// exportPageSize is how many posts are read from the database at a time.
+var exportPageSize = 1000
+
+var (
+	feedPaths   = []string{"rss", "rss.xml", "atom", "atom.xml", "feed.xml", "blog/index.xml"}
+	exportAttr  = regexp.MustCompile(`(\s(?:href|src)=")([^"]*)(")`)
+	exportImage = regexp.MustCompile(`^(.+\.(?:jpg|jpeg|png|gif|webp|svg|ico))/.+$`)
+)
+
+// ExportFile is a file of a static export, Page is requested from the blog
+// and Object is read from the image storage of the user.
+type ExportFile struct {
+	Name    string
+	Page    string
+	Object  string
+	ModTime time.Time
+}
+
+/*
+Export renders the blog of User as static files.  Pages are rendered by the
+same handlers and templates that serve the blog so the export looks exactly
+like it, themes included.  Links between exported files are rewritten to
+relative paths so the export works from any directory or host, links to
+pages that cannot be exported keep pointing to the blog.
+*/
+type Export struct {
+	Cfg     *shared.ConfigSite
+	Db      db.DB
+	Storage storage.StorageServe
+	User    *db.User
+
+	serve   http.HandlerFunc
+	blogURL string
+	files   []*ExportFile
+	// names maps the path of a page on the blog to its exported file.
+	names  map[string]string
+	assets []string
+}
+
+func NewExport(cfg *shared.ConfigSite, dbpool db.DB, st storage.StorageServe, user *db.User) *Export {
+	apiConfig := &router.ApiConfig{Cfg: cfg, Dbpool: dbpool, Storage: st}
+	fed := NewFederation(cfg, dbpool)
+	nl := NewNewsletter(cfg, dbpool)
+	return &Export{
+		Cfg:     cfg,
+		Db:      dbpool,
+		Storage: st,
+		User:    user,
+		serve: router.CreateServe(
+			createMainRoutes(nil, fed, nl),
+			createSubdomainRoutes(createStaticRoutes(), fed, nl),
+			apiConfig,
+		),
+		blogURL: cfg.FullBlogURL(shared.NewCreateURL(cfg), user.Name),
+		names:   map[string]string{},
+	}
+}
+
+// exportName makes a tag or series name safe to use as a filename.
+func exportName(name string) string {
+	return strings.NewReplacer("/", "-", "\\", "-", "..", "-").Replace(name)
+}
+
+func (e *Export) add(name, page, object string, modTime time.Time) {
+	e.files = append(e.files, &ExportFile{
+		Name:    name,
+		Page:    page,
+		Object:  object,
+		ModTime: modTime,
+	})
+	e.names[strings.Trim(page, "/")] = name
+}
+
+// Plan lists the files of the export without rendering them.
+func (e *Export) Plan() ([]*ExportFile, error) {
+	if e.files != nil {
+		return e.files, nil
+	}
+
+	posts := []*db.Post{}
+	for page := 0; ; page++ {
+		pager := &db.Pager{Num: exportPageSize, Page: page}
+		found, err := e.Db.FindPostsByUser(pager, e.User.ID, e.Cfg.Space)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, found.Data...)
+		if len(found.Data) < exportPageSize || page+1 >= found.Total {
+			break
+		}
+	}
+
+	now := time.Now()
+	e.add("index.html", "/", "", now)
+	e.add(exportFeed, "/rss", "", now)
+	for _, feed := range feedPaths {
+		e.names[feed] = exportFeed
+	}
+
+	tags := []string{}
+	series := []string{}
+	for _, post := range posts {
+		modTime := now
+		if post.UpdatedAt != nil {
+			modTime = *post.UpdatedAt
+		}
+		e.add(post.Slug+".html", "/"+post.Slug, "", modTime)
+
+		for _, tag := range post.Tags {
+			if tag != "" && !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		if post.Data.Series != "" && !slices.Contains(series, post.Data.Series) {
+			series = append(series, post.Data.Series)
+		}
+	}
+
+	for _, tag := range tags {
+		e.add("tags/"+exportName(tag)+".html", "/?tag="+url.QueryEscape(tag), "", now)
+		e.names["?tag="+tag] = "tags/" + exportName(tag) + ".html"
+	}
+	for _, name := range series {
+		e.add("series/"+exportName(name)+".html", "/series/"+url.PathEscape(name), "", now)
+		e.names["series/"+name] = "series/" + exportName(name) + ".html"
+	}
+
+	css, err := e.Db.FindPostWithFilename("_styles.css", e.User.ID, e.Cfg.Space)
+	if err == nil && css.UpdatedAt != nil {
+		e.add("_styles.css", "/_styles.css", "", *css.UpdatedAt)
+	}
+
+	if e.Storage != nil {
+		bucket, err := e.Storage.GetBucket(shared.GetAssetBucketName(e.User.ID))
+		if err == nil {
+			images, err := e.Storage.ListObjects(bucket, "prose/", true)
+			if err != nil {
+				return nil, err
+			}
+			for _, img := range images {
+				name := strings.TrimPrefix(img.Name(), "/")
+				if img.IsDir() || name == "" {
+					continue
+				}
+				e.add(name, "/"+name, path.Join("prose", name), img.ModTime())
+			}
+		}
+	}
+
+	return e.files, nil
+}
+
+type exportResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *exportResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *exportResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *exportResponse) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// request renders a page of the blog like a visitor would see it.
+func (e *Export) request(page string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, e.blogURL+page, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html")
+
+	res := &exportResponse{header: http.Header{}}
+	e.serve(res, req)
+	if res.status != http.StatusOK {
+		return nil, fmt.Errorf("%s: responded with status %d", page, res.status)
+	}
+	return res.body.Bytes(), nil
+}
+
+// target finds the exported file a link points to.  Static assets of the
+// site are exported once they are linked.
+func (e *Export) target(link string) (string, bool) {
+	rest := ""
+	switch {
+	case strings.HasPrefix(link, e.blogURL):
+		rest = strings.TrimPrefix(link, e.blogURL)
+		if rest != "" && rest[0] != '/' && rest[0] != '?' && rest[0] != '#' {
+			return "", false
+		}
+	case strings.HasPrefix(link, "/") && !strings.HasPrefix(link, "//"):
+		rest = link
+	default:
+		return "", false
+	}
+
+	u, err := url.Parse(rest)
+	if err != nil {
+		return "", false
+	}
+	p := strings.Trim(u.Path, "/")
+	fragment := ""
+	if u.Fragment != "" {
+		fragment = "#" + u.Fragment
+	}
+
+	if tag := u.Query().Get("tag"); p == "" && tag != "" {
+		name, ok := e.names["?tag="+tag]
+		return name + fragment, ok
+	}
+	if name, ok := e.names[p]; ok {
+		return name + fragment, true
+	}
+	if match := exportImage.FindStringSubmatch(p); match != nil {
+		name, ok := e.names[match[1]]
+		return name, ok
+	}
+	for _, route := range createStaticRoutes() {
+		if route.Regex.MatchString("/" + p) {
+			if !slices.Contains(e.assets, p) {
+				e.assets = append(e.assets, p)
+			}
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// rewrite makes the links of an exported page relative to where the page
+// is stored, links that cannot be exported point back to the blog.
+func (e *Export) rewrite(name string, page []byte) []byte {
+	prefix := strings.Repeat("../", strings.Count(name, "/"))
+	return exportAttr.ReplaceAllFunc(page, func(attr []byte) []byte {
+		parts := exportAttr.FindSubmatch(attr)
+		link := html.UnescapeString(string(parts[2]))
+
+		target, ok := e.target(link)
+		if ok {
+			link = prefix + target
+		} else if strings.HasPrefix(link, "/") && !strings.HasPrefix(link, "//") {
+			link = e.blogURL + link
+		} else {
+			return attr
+		}
+		return []byte(string(parts[1]) + html.EscapeString(link) + string(parts[3]))
+	})
+}
+
+// Render reads the contents of an exported file.
+func (e *Export) Render(file *ExportFile) ([]byte, error) {
+	if file.Object != "" {
+		bucket, err := e.Storage.GetBucket(shared.GetAssetBucketName(e.User.ID))
+		if err != nil {
+			return nil, err
+		}
+		reader, _, err := e.Storage.GetObject(bucket, file.Object)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = reader.Close()
+		}()
+		return io.ReadAll(reader)
+	}
+
+	contents, err := e.request(file.Page)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(file.Name, ".html") {
+		contents = e.rewrite(file.Name, contents)
+	}
+	return contents, nil
+}
+
+// Walk renders every file of the export, including the static assets the
+// pages link to.  Files that fail to render are reported to skip and left
+// out of the export.
+func (e *Export) Walk(fn func(file *ExportFile, contents []byte) error, skip func(file *ExportFile, err error)) error {
+	files, err := e.Plan()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		contents, err := e.Render(file)
+		if err != nil {
+			skip(file, err)
+			continue
+		}
+		err = fn(file, contents)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, asset := range e.assets {
+		file := &ExportFile{Name: asset, Page: "/" + asset, ModTime: time.Now()}
+		contents, err := e.request(file.Page)
+		if err != nil {
+			skip(file, err)
+			continue
+		}
+		err = fn(file, contents)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteTar streams the export as a tar archive, every file is written as
+// soon as it is rendered.
+func (e *Export) WriteTar(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := e.Walk(func(file *ExportFile, contents []byte) error {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    file.Name,
+			Mode:    0644,
+			Size:    int64(len(contents)),
+			ModTime: file.ModTime,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(contents)
+		return err
+	}, func(file *ExportFile, err error) {
+		e.Cfg.Logger.Error("export file", "user", e.User.Name, "file", file.Name, "err", err)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+/*
+ExportMiddleware handles `export`, it writes the blog of the user to stdout
+as a tar archive of static html:
+
+	ssh prose.sh export > blog.tar
+*/
+func ExportMiddleware(dbpool db.DB, cfg *shared.ConfigSite, st storage.StorageServe) pssh.SSHServerMiddleware {
+	return func(next pssh.SSHServerHandler) pssh.SSHServerHandler {
+		return func(sesh *pssh.SSHServerConnSession) error {
+			args := sesh.Command()
+			if len(args) != 1 || args[0] != "export" {
+				return next(sesh)
+			}
+
+			logger := pssh.GetLogger(sesh)
+			user := pssh.GetUser(sesh)
+			if user == nil {
+				err := fmt.Errorf("user not found")
+				_, _ = fmt.Fprintln(sesh.Stderr(), err)
+				return err
+			}
+			logger = shared.LoggerWithUser(logger, user)
+			logger.Info("export cmd")
+
+			err := NewExport(cfg, dbpool, st, user).WriteTar(sesh)
+			if err != nil {
+				logger.Error("export", "err", err.Error())
+				_, _ = fmt.Fprintln(sesh.Stderr(), err)
+			}
+			return err
+		}
+	}
+}
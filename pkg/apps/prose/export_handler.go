@@ -0,0 +1,169 @@
+package prose
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/filehandlers"
+	"github.com/picosh/pico/pkg/pssh"
+	sendutils "github.com/picosh/pico/pkg/send/utils"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/storage"
+)
+
+// exportTTL is how long a rendered export is reused, rsync lists a
+// directory before reading each of its files.
+const exportTTL = 5 * time.Minute
+
+type renderedExport struct {
+	files     map[string][]byte
+	infos     []os.FileInfo
+	createdAt time.Time
+}
+
+// ExportHandler serves a static export of the blog as the read-only
+// directory _export/ so it can be downloaded with rsync.
+type ExportHandler struct {
+	DBPool  db.DB
+	Cfg     *shared.ConfigSite
+	Storage storage.StorageServe
+
+	mu      sync.Mutex
+	exports map[string]*renderedExport
+}
+
+var _ filehandlers.ReadWriteHandler = (*ExportHandler)(nil)
+
+func NewExportHandler(dbpool db.DB, cfg *shared.ConfigSite, st storage.StorageServe) *ExportHandler {
+	return &ExportHandler{
+		DBPool:  dbpool,
+		Cfg:     cfg,
+		Storage: st,
+		exports: map[string]*renderedExport{},
+	}
+}
+
+// exportPath is the path of a file inside of _export/, ok is false for
+// paths outside of it.
+func exportPath(fpath string) (string, bool) {
+	name := strings.TrimPrefix(path.Clean("/"+fpath), "/")
+	if name+"/" == exportDir {
+		return "", true
+	}
+	if !strings.HasPrefix(name, exportDir) {
+		return "", false
+	}
+	return strings.TrimPrefix(name, exportDir), true
+}
+
+func (h *ExportHandler) render(s *pssh.SSHServerConnSession) (*renderedExport, error) {
+	user := pssh.GetUser(s)
+	if user == nil {
+		err := fmt.Errorf("could not get user from ctx")
+		pssh.GetLogger(s).Error("error getting user from ctx", "err", err)
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id, rendered := range h.exports {
+		if time.Since(rendered.createdAt) > exportTTL {
+			delete(h.exports, id)
+		}
+	}
+	if rendered, ok := h.exports[user.ID]; ok {
+		return rendered, nil
+	}
+
+	logger := shared.LoggerWithUser(pssh.GetLogger(s), user)
+	rendered := &renderedExport{
+		files:     map[string][]byte{},
+		createdAt: time.Now(),
+	}
+	err := NewExport(h.Cfg, h.DBPool, h.Storage, user).Walk(
+		func(file *ExportFile, contents []byte) error {
+			rendered.files[file.Name] = contents
+			rendered.infos = append(rendered.infos, &sendutils.VirtualFile{
+				FName:    file.Name,
+				FSize:    int64(len(contents)),
+				FModTime: file.ModTime,
+			})
+			return nil
+		},
+		func(file *ExportFile, err error) {
+			logger.Error("export file", "file", file.Name, "err", err)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	h.exports[user.ID] = rendered
+	return rendered, nil
+}
+
+func (h *ExportHandler) List(s *pssh.SSHServerConnSession, fpath string, isDir bool, recursive bool) ([]os.FileInfo, error) {
+	name, ok := exportPath(fpath)
+	if !ok {
+		return []os.FileInfo{}, nil
+	}
+
+	rendered, err := h.render(s)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return rendered.infos, nil
+	}
+
+	for _, info := range rendered.infos {
+		if info.Name() == name {
+			return []os.FileInfo{info}, nil
+		}
+	}
+	return []os.FileInfo{}, nil
+}
+
+func (h *ExportHandler) Read(s *pssh.SSHServerConnSession, entry *sendutils.FileEntry) (os.FileInfo, sendutils.ReadAndReaderAtCloser, error) {
+	name, ok := exportPath(entry.Filepath)
+	if !ok || name == "" {
+		return nil, nil, os.ErrNotExist
+	}
+
+	rendered, err := h.render(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	contents, ok := rendered.files[name]
+	if !ok {
+		return nil, nil, os.ErrNotExist
+	}
+
+	fileInfo := &sendutils.VirtualFile{
+		FName:    name,
+		FSize:    int64(len(contents)),
+		FModTime: time.Now(),
+	}
+	for _, info := range rendered.infos {
+		if info.Name() == name {
+			fileInfo.FModTime = info.ModTime()
+		}
+	}
+	reader := sendutils.NopReadAndReaderAtCloser(bytes.NewReader(contents))
+	return fileInfo, reader, nil
+}
+
+func (h *ExportHandler) Write(s *pssh.SSHServerConnSession, entry *sendutils.FileEntry) (string, error) {
+	return "", fmt.Errorf("ERROR: (%s) %s is read-only, skipping", entry.Filepath, exportDir)
+}
+
+func (h *ExportHandler) Delete(s *pssh.SSHServerConnSession, entry *sendutils.FileEntry) error {
+	return fmt.Errorf("%s is read-only", exportDir)
+}
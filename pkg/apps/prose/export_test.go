@@ -0,0 +1,169 @@
+package prose
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/storage"
+)
+
+func TestExport(t *testing.T) {
+	t.Chdir("../../..")
+	ft := newProseTest(t)
+	ft.post.Username = ft.user.Name
+	ft.post.Tags = []string{"go"}
+	ft.post.Text = "---\ntags: go\n---\n# Hello\n\nfrom prose ![cat](/cat.png/s:300) [next](/part-one) [search](/search)"
+
+	publishAt := time.Now().Add(-2 * time.Hour)
+	ft.user.CreatedAt = &publishAt
+	ft.dbpool.Posts = append(ft.dbpool.Posts, &db.Post{
+		ID:        "post-2",
+		UserID:    ft.user.ID,
+		Username:  ft.user.Name,
+		Filename:  "part-one.md",
+		Slug:      "part-one",
+		Title:     "Part one",
+		Text:      "# Part one",
+		Space:     "prose",
+		PublishAt: &publishAt,
+		UpdatedAt: &publishAt,
+		Tags:      []string{"go"},
+		Data:      db.PostData{Series: "tour"},
+	})
+
+	st, err := storage.NewStorageMemory(map[string]map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket, err := st.UpsertBucket(shared.GetAssetBucketName(ft.user.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = st.PutObject(bucket, "/prose/cat.png", strings.NewReader("meow"), &storage.ObjectInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	err = NewExport(ft.fed.Cfg, ft.dbpool, st, ft.user).WriteTar(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	tr := tar.NewReader(out)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = string(contents)
+	}
+
+	for _, name := range []string{
+		"index.html",
+		"hello.html",
+		"part-one.html",
+		"tags/go.html",
+		"series/tour.html",
+		"atom.xml",
+		"cat.png",
+		"smol.css",
+	} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("export is missing %s, got %v", name, keys(files))
+		}
+	}
+	if files["cat.png"] != "meow" {
+		t.Errorf("image was not exported: %q", files["cat.png"])
+	}
+
+	checks := map[string][]string{
+		"index.html": {
+			`href="hello.html"`,
+			`href="part-one.html"`,
+			`href="atom.xml"`,
+			`href="smol.css"`,
+			`href="http://erock.prose.test/search"`,
+		},
+		"hello.html": {
+			`src="cat.png"`,
+			`href="part-one.html"`,
+			`href="tags/go.html"`,
+			`href="http://erock.prose.test/search"`,
+			"from prose",
+		},
+		"tags/go.html": {
+			`href="../hello.html"`,
+			`href="../smol.css"`,
+		},
+		"series/tour.html": {
+			`href="../part-one.html"`,
+		},
+	}
+	for name, wants := range checks {
+		for _, want := range wants {
+			if !strings.Contains(files[name], want) {
+				t.Errorf("%s is missing %s:\n%s", name, want, files[name])
+			}
+		}
+	}
+}
+
+func keys(m map[string]string) []string {
+	names := []string{}
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestExportPlanPages(t *testing.T) {
+	ft := newProseTest(t)
+	pageSize := exportPageSize
+	exportPageSize = 1
+	t.Cleanup(func() { exportPageSize = pageSize })
+
+	publishAt := time.Now().Add(-2 * time.Hour)
+	ft.dbpool.Posts = append(ft.dbpool.Posts, &db.Post{
+		ID:        "post-2",
+		UserID:    ft.user.ID,
+		Filename:  "part-one.md",
+		Slug:      "part-one",
+		Space:     "prose",
+		PublishAt: &publishAt,
+	})
+
+	st, err := storage.NewStorageMemory(map[string]map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := NewExport(ft.fed.Cfg, ft.dbpool, st, ft.user).Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	for _, name := range []string{"hello.html", "part-one.html"} {
+		if !slices.Contains(names, name) {
+			t.Errorf("expected %s in an export spanning several pages, got %v", name, names)
+		}
+	}
+}
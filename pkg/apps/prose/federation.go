@@ -0,0 +1,299 @@
+package prose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/picosh/pico/pkg/activitypub"
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+)
+
+/*
+Federation makes prose blogs followable from the fediverse.  Every blog is
+an ActivityPub actor whose followers receive Create, Update and Delete
+activities as posts are published, edited and removed.  Replies from the
+fediverse and incoming webmentions are stored with the post they mention.
+
+Activities use the canonical subdomain urls of a blog so they stay stable
+no matter which domain the blog was visited on.
+*/
+type Federation struct {
+	Cfg    *shared.ConfigSite
+	Db     db.DB
+	Client *activitypub.Client
+	Logger *slog.Logger
+}
+
+func NewFederation(cfg *shared.ConfigSite, dbpool db.DB) *Federation {
+	userAgent := fmt.Sprintf("prose (+%s://%s)", cfg.Protocol, cfg.Domain)
+	return &Federation{
+		Cfg:    cfg,
+		Db:     dbpool,
+		Client: activitypub.NewClient(userAgent),
+		Logger: cfg.Logger,
+	}
+}
+
+func (f *Federation) ActorURL(username string) string {
+	return f.Cfg.BlogURL(username) + "/_ap/actor"
+}
+
+func (f *Federation) KeyID(username string) string {
+	return f.ActorURL(username) + "#main-key"
+}
+
+func (f *Federation) InboxURL(username string) string {
+	return f.Cfg.BlogURL(username) + "/_ap/inbox"
+}
+
+func (f *Federation) OutboxURL(username string) string {
+	return f.Cfg.BlogURL(username) + "/_ap/outbox"
+}
+
+func (f *Federation) FollowersURL(username string) string {
+	return f.Cfg.BlogURL(username) + "/_ap/followers"
+}
+
+func (f *Federation) WebmentionURL(username string) string {
+	return f.Cfg.BlogURL(username) + "/_webmention"
+}
+
+// Handle is the account name fediverse users search for to follow a blog.
+func (f *Federation) Handle(username string) string {
+	return fmt.Sprintf("%s@%s", username, strings.Split(f.Cfg.Domain, ":")[0])
+}
+
+// userKey returns the keypair of a blog, creating it on first use.
+func (f *Federation) userKey(userID string) (*db.FederationKey, error) {
+	key, err := f.Db.FindFederationKey(userID)
+	if err == nil {
+		return key, nil
+	}
+
+	pubkey, privkey, err := activitypub.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return f.Db.InsertFederationKey(userID, pubkey, privkey)
+}
+
+func (f *Federation) Actor(user *db.User) (*activitypub.Actor, error) {
+	key, err := f.userKey(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	actorURL := f.ActorURL(user.Name)
+	actor := &activitypub.Actor{
+		Context:           activitypub.Context,
+		ID:                actorURL,
+		Type:              "Person",
+		PreferredUsername: user.Name,
+		Name:              GetBlogName(user.Name),
+		URL:               f.Cfg.BlogURL(user.Name),
+		Inbox:             f.InboxURL(user.Name),
+		Outbox:            f.OutboxURL(user.Name),
+		Followers:         f.FollowersURL(user.Name),
+		PublicKey: activitypub.PublicKey{
+			ID:           f.KeyID(user.Name),
+			Owner:        actorURL,
+			PublicKeyPem: key.PublicKey,
+		},
+	}
+
+	readme, err := f.Db.FindPostWithFilename("_readme.md", user.ID, f.Cfg.Space)
+	if err == nil {
+		parsedText, err := shared.ParseText(readme.Text)
+		if err == nil {
+			if parsedText.Title != "" {
+				actor.Name = parsedText.Title
+			}
+			actor.Summary = html.EscapeString(parsedText.Description)
+		}
+	}
+
+	return actor, nil
+}
+
+// isFederated reports whether followers can see a post.  Scheduled posts
+// are federated by the PublishScheduler once their publish date arrives.
+func (f *Federation) isFederated(post *db.Post) bool {
+	return isPublished(post)
+}
+
+// isPublished reports whether a post is live on the blog.
+func isPublished(post *db.Post) bool {
+	if post == nil || post.Hidden || post.Data.Scheduled {
+		return false
+	}
+	if post.PublishAt != nil && post.PublishAt.After(time.Now()) {
+		return false
+	}
+	ext := filepath.Ext(post.Filename)
+	return ext == ".md" || ext == ".lxt"
+}
+
+func (f *Federation) PostObject(username string, post *db.Post) *activitypub.Object {
+	content := fmt.Sprintf("<p>%s</p>", html.EscapeString(post.Text))
+	if filepath.Ext(post.Filename) == ".md" {
+		parsedText, err := shared.ParseText(post.Text)
+		if err == nil {
+			content = parsedText.Html
+		}
+	}
+
+	postURL := f.Cfg.PostURL(username, post.Slug)
+	obj := &activitypub.Object{
+		ID:           postURL,
+		Type:         "Article",
+		AttributedTo: f.ActorURL(username),
+		Name:         shared.FilenameToTitle(post.Filename, post.Title),
+		Summary:      html.EscapeString(post.Description),
+		Content:      content,
+		MediaType:    "text/html",
+		URL:          postURL,
+		To:           []string{activitypub.Public},
+		Cc:           []string{f.FollowersURL(username)},
+	}
+	if post.PublishAt != nil {
+		obj.Published = post.PublishAt.UTC().Format(time.RFC3339)
+	}
+	if post.UpdatedAt != nil {
+		obj.Updated = post.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return obj
+}
+
+func (f *Federation) activity(username, typ string, object any) *activitypub.Activity {
+	id := fmt.Sprintf(
+		"%s#%s-%d",
+		f.ActorURL(username),
+		strings.ToLower(typ),
+		time.Now().UnixNano(),
+	)
+	return &activitypub.Activity{
+		Context: activitypub.Context,
+		ID:      id,
+		Type:    typ,
+		Actor:   f.ActorURL(username),
+		Object:  object,
+		To:      []string{activitypub.Public},
+		Cc:      []string{f.FollowersURL(username)},
+	}
+}
+
+func (f *Federation) postActivity(username, typ string, post *db.Post) *activitypub.Activity {
+	if typ == "Delete" {
+		tombstone := map[string]any{
+			"id":   f.Cfg.PostURL(username, post.Slug),
+			"type": "Tombstone",
+		}
+		return f.activity(username, typ, tombstone)
+	}
+
+	obj := f.PostObject(username, post)
+	return f.activity(username, typ, obj)
+}
+
+// deliver signs an activity with the key of the user and posts it to inbox.
+func (f *Federation) deliver(user *db.User, inbox string, activity any) error {
+	key, err := f.userKey(user.ID)
+	if err != nil {
+		return err
+	}
+	privkey, err := activitypub.ParsePrivateKey(key.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return f.Client.Deliver(ctx, inbox, f.KeyID(user.Name), privkey, activity)
+}
+
+// Broadcast delivers an activity to every follower of the user, once per
+// inbox since followers on the same server usually share one.
+func (f *Federation) Broadcast(user *db.User, activity any) error {
+	followers, err := f.Db.FindFollowersByUser(user.ID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	seen := map[string]bool{}
+	for _, follower := range followers {
+		if seen[follower.Inbox] {
+			continue
+		}
+		seen[follower.Inbox] = true
+
+		err := f.deliver(user, follower.Inbox, activity)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", follower.Actor, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PostSaved tells followers about a post that was published, edited or
+// that is no longer visible.
+func (f *Federation) PostSaved(user *db.User, prev *db.Post, post *db.Post) error {
+	was := f.isFederated(prev)
+	is := f.isFederated(post)
+
+	typ := ""
+	switch {
+	case is && !was:
+		typ = "Create"
+	case is && was:
+		typ = "Update"
+	case was && !is:
+		typ = "Delete"
+	default:
+		return nil
+	}
+
+	f.Logger.Info("federating post", "user", user.Name, "slug", post.Slug, "type", typ)
+	return f.Broadcast(user, f.postActivity(user.Name, typ, post))
+}
+
+func (f *Federation) PostRemoved(user *db.User, post *db.Post) error {
+	if !f.isFederated(post) {
+		return nil
+	}
+	f.Logger.Info("federating post", "user", user.Name, "slug", post.Slug, "type", "Delete")
+	return f.Broadcast(user, f.postActivity(user.Name, "Delete", post))
+}
+
+// findPostByURL returns the post of a user a reply or webmention targets.
+func (f *Federation) findPostByURL(user *db.User, target string, blogURLs ...string) (*db.Post, error) {
+	target, _, _ = strings.Cut(target, "#")
+	blogURLs = append(blogURLs, f.Cfg.BlogURL(user.Name))
+	for _, blogURL := range blogURLs {
+		rest, ok := strings.CutPrefix(target, strings.TrimSuffix(blogURL, "/")+"/")
+		if !ok || rest == "" {
+			continue
+		}
+		slug, err := url.PathUnescape(strings.TrimSuffix(rest, "/"))
+		if err != nil {
+			continue
+		}
+		slug = strings.TrimSuffix(slug, ".html")
+		post, err := f.Db.FindPostWithSlug(slug, user.ID, f.Cfg.Space)
+		if err != nil {
+			continue
+		}
+		if f.isFederated(post) {
+			return post, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a post of %s", target, user.Name)
+}
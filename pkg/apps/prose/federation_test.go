@@ -0,0 +1,342 @@
+package prose
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/picosh/pico/pkg/activitypub"
+	"github.com/picosh/pico/pkg/db"
+)
+
+// fakeRemote is a fediverse server with a single actor that records the
+// activities delivered to its inbox after checking their signature.
+type fakeRemote struct {
+	t        *testing.T
+	srv      *httptest.Server
+	key      *rsa.PrivateKey
+	actor    *activitypub.Actor
+	fetchKey func(keyID string) (*rsa.PublicKey, error)
+	received chan *activitypub.Activity
+	pages    map[string]string
+	mu       sync.Mutex
+}
+
+func newFakeRemote(t *testing.T) *fakeRemote {
+	pubkey, privkey, err := activitypub.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := activitypub.ParsePrivateKey(privkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote := &fakeRemote{
+		t:        t,
+		key:      key,
+		received: make(chan *activitypub.Activity, 10),
+		pages:    map[string]string{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/alice", func(w http.ResponseWriter, r *http.Request) {
+		writeActivityJSON(w, activitypub.ContentType, remote.actor)
+	})
+	mux.HandleFunc("POST /inbox", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, err := activitypub.VerifyRequest(r, body, remote.fetchKey)
+		if err != nil {
+			t.Errorf("inbox signature: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		activity := &activitypub.Activity{}
+		if err := json.Unmarshal(body, activity); err != nil {
+			t.Errorf("inbox activity: %v", err)
+		}
+		remote.received <- activity
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("GET /pages/{name}", func(w http.ResponseWriter, r *http.Request) {
+		remote.mu.Lock()
+		page, ok := remote.pages[r.PathValue("name")]
+		remote.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		_, _ = w.Write([]byte(page))
+	})
+	remote.srv = httptest.NewServer(mux)
+	t.Cleanup(remote.srv.Close)
+
+	actorURL := remote.srv.URL + "/users/alice"
+	remote.actor = &activitypub.Actor{
+		ID:                actorURL,
+		Type:              "Person",
+		PreferredUsername: "alice",
+		Name:              "Alice",
+		URL:               remote.srv.URL + "/@alice",
+		Inbox:             remote.srv.URL + "/inbox",
+		PublicKey: activitypub.PublicKey{
+			ID:           actorURL + "#main-key",
+			Owner:        actorURL,
+			PublicKeyPem: pubkey,
+		},
+	}
+	return remote
+}
+
+func (r *fakeRemote) setPage(name, contents string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if contents == "" {
+		delete(r.pages, name)
+	} else {
+		r.pages[name] = contents
+	}
+	return r.srv.URL + "/pages/" + name
+}
+
+// signed builds an inbox request for activity signed by the remote actor.
+func (r *fakeRemote) signed(target string, activity any) *http.Request {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	req.Header.Set("Content-Type", activitypub.ContentType)
+	err = activitypub.SignRequest(req, r.actor.PublicKey.ID, r.key, body)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return req
+}
+
+func (r *fakeRemote) next() *activitypub.Activity {
+	select {
+	case activity := <-r.received:
+		return activity
+	case <-time.After(5 * time.Second):
+		r.t.Fatal("timed out waiting for delivery")
+	}
+	return nil
+}
+
+func (r *fakeRemote) none() {
+	select {
+	case activity := <-r.received:
+		r.t.Fatalf("unexpected delivery of %s", activity.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (ft *proseTest) follow(t *testing.T) {
+	t.Helper()
+	follow := &activitypub.Activity{
+		ID:     ft.remote.srv.URL + "/follows/1",
+		Type:   "Follow",
+		Actor:  ft.remote.actor.ID,
+		Object: ft.fed.ActorURL("erock"),
+	}
+	rec := ft.do(ft.remote.signed("http://erock.prose.test/_ap/inbox", follow))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("follow: want 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	accept := ft.remote.next()
+	if accept.Type != "Accept" || activitypub.ObjectID(accept.Object) != follow.ID {
+		t.Fatalf("expected accept of follow, got %+v", accept)
+	}
+}
+
+func TestFederationDiscovery(t *testing.T) {
+	ft := newProseTest(t)
+
+	req := httptest.NewRequest(http.MethodGet, "http://prose.test/.well-known/webfinger?resource=acct:erock@prose.test", nil)
+	rec := ft.do(req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("webfinger: want 200, got %d", rec.Code)
+	}
+	finger := &activitypub.WebFinger{}
+	if err := json.Unmarshal(rec.Body.Bytes(), finger); err != nil {
+		t.Fatal(err)
+	}
+	if finger.Links[0].Href != "http://erock.prose.test/_ap/actor" {
+		t.Fatalf("unexpected webfinger %+v", finger)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://prose.test/.well-known/webfinger?resource=acct:nobody@prose.test", nil)
+	if rec := ft.do(req); rec.Code != http.StatusNotFound {
+		t.Fatalf("webfinger unknown user: want 404, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://erock.prose.test/", nil)
+	req.Header.Set("Accept", activitypub.ContentType)
+	rec = ft.do(req)
+	actor := &activitypub.Actor{}
+	if err := json.Unmarshal(rec.Body.Bytes(), actor); err != nil {
+		t.Fatal(err)
+	}
+	if actor.Inbox != "http://erock.prose.test/_ap/inbox" || actor.PublicKey.PublicKeyPem == "" {
+		t.Fatalf("unexpected actor %+v", actor)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, ft.postURL, nil)
+	req.Header.Set("Accept", activitypub.LDContentType)
+	rec = ft.do(req)
+	obj := &activitypub.Object{}
+	if err := json.Unmarshal(rec.Body.Bytes(), obj); err != nil {
+		t.Fatal(err)
+	}
+	if obj.ID != ft.postURL || obj.Type != "Article" || !strings.Contains(obj.Content, "from prose") {
+		t.Fatalf("unexpected post object %+v", obj)
+	}
+}
+
+func TestFederationDelivery(t *testing.T) {
+	ft := newProseTest(t)
+	ft.follow(t)
+
+	followers, _ := ft.dbpool.FindFollowersByUser(ft.user.ID)
+	if len(followers) != 1 || followers[0].Inbox != ft.remote.actor.Inbox {
+		t.Fatalf("expected follower with inbox, got %+v", followers)
+	}
+
+	if err := ft.fed.PostSaved(ft.user, nil, ft.post); err != nil {
+		t.Fatal(err)
+	}
+	create := ft.remote.next()
+	if create.Type != "Create" || activitypub.ObjectID(create.Object) != ft.postURL {
+		t.Fatalf("expected create of post, got %+v", create)
+	}
+
+	edited := *ft.post
+	edited.Text = "# Hello\n\nedited"
+	if err := ft.fed.PostSaved(ft.user, ft.post, &edited); err != nil {
+		t.Fatal(err)
+	}
+	if update := ft.remote.next(); update.Type != "Update" {
+		t.Fatalf("expected update, got %s", update.Type)
+	}
+
+	hidden := edited
+	hidden.Hidden = true
+	if err := ft.fed.PostSaved(ft.user, nil, &hidden); err != nil {
+		t.Fatal(err)
+	}
+	ft.remote.none()
+
+	future := time.Now().Add(24 * time.Hour)
+	scheduled := edited
+	scheduled.PublishAt = &future
+	if err := ft.fed.PostSaved(ft.user, &edited, &scheduled); err != nil {
+		t.Fatal(err)
+	}
+	if del := ft.remote.next(); del.Type != "Delete" {
+		t.Fatalf("expected delete when a post is unpublished, got %s", del.Type)
+	}
+
+	if err := ft.fed.PostRemoved(ft.user, &edited); err != nil {
+		t.Fatal(err)
+	}
+	if del := ft.remote.next(); del.Type != "Delete" || activitypub.ObjectID(del.Object) != ft.postURL {
+		t.Fatalf("expected delete of post, got %+v", del)
+	}
+
+	undo := &activitypub.Activity{
+		ID:    ft.remote.srv.URL + "/follows/1#undo",
+		Type:  "Undo",
+		Actor: ft.remote.actor.ID,
+		Object: map[string]any{
+			"id":     ft.remote.srv.URL + "/follows/1",
+			"type":   "Follow",
+			"actor":  ft.remote.actor.ID,
+			"object": ft.fed.ActorURL("erock"),
+		},
+	}
+	if rec := ft.do(ft.remote.signed("http://erock.prose.test/_ap/inbox", undo)); rec.Code != http.StatusAccepted {
+		t.Fatalf("undo: want 202, got %d", rec.Code)
+	}
+	if followers, _ := ft.dbpool.FindFollowersByUser(ft.user.ID); len(followers) != 0 {
+		t.Fatalf("expected follower to be removed, got %+v", followers)
+	}
+}
+
+func TestFederationInboxSignature(t *testing.T) {
+	ft := newProseTest(t)
+	follow := &activitypub.Activity{
+		ID:     ft.remote.srv.URL + "/follows/1",
+		Type:   "Follow",
+		Actor:  ft.remote.actor.ID,
+		Object: ft.fed.ActorURL("erock"),
+	}
+
+	body, _ := json.Marshal(follow)
+	unsigned := httptest.NewRequest(http.MethodPost, "http://erock.prose.test/_ap/inbox", bytes.NewReader(body))
+	if rec := ft.do(unsigned); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unsigned: want 401, got %d", rec.Code)
+	}
+
+	// signed by alice, claiming to be someone else
+	follow.Actor = ft.remote.srv.URL + "/users/mallory"
+	if rec := ft.do(ft.remote.signed("http://erock.prose.test/_ap/inbox", follow)); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("spoofed actor: want 401, got %d", rec.Code)
+	}
+
+	if followers, _ := ft.dbpool.FindFollowersByUser(ft.user.ID); len(followers) != 0 {
+		t.Fatalf("expected no followers, got %+v", followers)
+	}
+}
+
+func TestFederationReplies(t *testing.T) {
+	ft := newProseTest(t)
+	noteID := ft.remote.srv.URL + "/notes/1"
+	create := &activitypub.Activity{
+		ID:    noteID + "#create",
+		Type:  "Create",
+		Actor: ft.remote.actor.ID,
+		Object: map[string]any{
+			"id":           noteID,
+			"type":         "Note",
+			"attributedTo": ft.remote.actor.ID,
+			"inReplyTo":    ft.postURL,
+			"content":      `<p>nice post<script>alert(1)</script></p>`,
+		},
+	}
+	if rec := ft.do(ft.remote.signed("http://erock.prose.test/_ap/inbox", create)); rec.Code != http.StatusAccepted {
+		t.Fatalf("reply: want 202, got %d", rec.Code)
+	}
+
+	mentions, _ := ft.dbpool.FindPostMentions(ft.post.ID)
+	if len(mentions) != 1 {
+		t.Fatalf("expected reply to be stored, got %d mentions", len(mentions))
+	}
+	if mentions[0].Kind != db.MentionReply || mentions[0].AuthorName != "Alice" {
+		t.Fatalf("unexpected mention %+v", mentions[0])
+	}
+	if mentions[0].Content != "<p>nice post</p>" {
+		t.Fatalf("expected sanitized content, got %q", mentions[0].Content)
+	}
+
+	del := &activitypub.Activity{
+		ID:     noteID + "#delete",
+		Type:   "Delete",
+		Actor:  ft.remote.actor.ID,
+		Object: map[string]any{"id": noteID, "type": "Tombstone"},
+	}
+	if rec := ft.do(ft.remote.signed("http://erock.prose.test/_ap/inbox", del)); rec.Code != http.StatusAccepted {
+		t.Fatalf("delete: want 202, got %d", rec.Code)
+	}
+	if mentions, _ := ft.dbpool.FindPostMentions(ft.post.ID); len(mentions) != 0 {
+		t.Fatalf("expected reply to be removed, got %d mentions", len(mentions))
+	}
+}
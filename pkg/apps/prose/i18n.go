@@ -0,0 +1,115 @@
+package prose
+
+import (
+	"html/template"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+)
+
+type TranslationData struct {
+	Lang    string
+	URL     template.URL
+	Current bool
+}
+
+// primaryLang strips the region and script subtags, de-AT becomes de.
+func primaryLang(lang string) string {
+	primary, _, _ := strings.Cut(strings.ToLower(lang), "-")
+	return primary
+}
+
+// primaryLangs reduces language tags to their distinct primary subtags.
+func primaryLangs(langs []string) []string {
+	primary := []string{}
+	for _, lang := range langs {
+		if p := primaryLang(lang); !slices.Contains(primary, p) {
+			primary = append(primary, p)
+		}
+	}
+	sort.Strings(primary)
+	return primary
+}
+
+/*
+negotiateLang picks the language of the read feed from an Accept-Language
+header.  Only the primary subtag matters because the feed filters on it,
+a reader asking for de-DE gets posts written in de and de-AT.  It returns
+an empty string when none of the available languages are acceptable.
+*/
+func negotiateLang(header string, available []string) string {
+	type choice struct {
+		lang string
+		q    float64
+	}
+	choices := []choice{}
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if lang == "" || lang == "*" || q <= 0 {
+			continue
+		}
+		choices = append(choices, choice{lang: primaryLang(lang), q: q})
+	}
+	sort.SliceStable(choices, func(i, j int) bool {
+		return choices[i].q > choices[j].q
+	})
+
+	for _, c := range choices {
+		for _, lang := range available {
+			if primaryLang(lang) == c.lang {
+				return c.lang
+			}
+		}
+	}
+	return ""
+}
+
+// queryLang is the language a feed is filtered by through ?lang=.
+func queryLang(r *http.Request) string {
+	lang := r.URL.Query().Get("lang")
+	if !shared.IsLangTag(lang) {
+		return ""
+	}
+	return lang
+}
+
+// translations lists the language versions of a post for the hreflang
+// alternates and the language switcher.  It is empty when the post does
+// not link to any translation.
+func translations(cfg *shared.ConfigSite, curl *shared.CreateURL, post *db.Post) []TranslationData {
+	if post.Data.Lang == "" || len(post.Data.Translations) == 0 {
+		return nil
+	}
+
+	items := []TranslationData{{
+		Lang:    post.Data.Lang,
+		URL:     template.URL(cfg.FullPostURL(curl, post.Username, post.Slug)),
+		Current: true,
+	}}
+	for lang, slug := range post.Data.Translations {
+		if strings.EqualFold(lang, post.Data.Lang) {
+			continue
+		}
+		items = append(items, TranslationData{
+			Lang: lang,
+			URL:  template.URL(cfg.FullPostURL(curl, post.Username, slug)),
+		})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Lang < items[j].Lang
+	})
+	return items
+}
@@ -0,0 +1,92 @@
+package prose
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+)
+
+func TestNegotiateLang(t *testing.T) {
+	available := []string{"de-at", "en"}
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: ""},
+		{header: "de-DE,de;q=0.9,en;q=0.8", want: "de"},
+		{header: "fr-FR, en;q=0.5", want: "en"},
+		{header: "en;q=0.2, de;q=0.7", want: "de"},
+		{header: "de;q=0, en", want: "en"},
+		{header: "fr, *", want: ""},
+		{header: "de;q=nope", want: ""},
+	}
+	for _, tt := range tests {
+		got := negotiateLang(tt.header, available)
+		if got != tt.want {
+			t.Errorf("negotiateLang(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestTranslations(t *testing.T) {
+	t.Chdir("../../..")
+	ft := newProseTest(t)
+	ft.post.Username = ft.user.Name
+	ft.post.Data = db.PostData{Lang: "en", Translations: map[string]string{"de": "hallo"}}
+
+	publishAt := time.Now().Add(-time.Hour)
+	ft.dbpool.Posts = append(ft.dbpool.Posts, &db.Post{
+		ID:        "post-de",
+		UserID:    ft.user.ID,
+		Username:  ft.user.Name,
+		Filename:  "hallo.md",
+		Slug:      "hallo",
+		Title:     "Hallo",
+		Text:      "# Hallo",
+		Space:     "prose",
+		PublishAt: &publishAt,
+		UpdatedAt: &publishAt,
+		Data:      db.PostData{Lang: "de", Translations: map[string]string{"en": "hello"}},
+	})
+
+	rec := httptest.NewRecorder()
+	ft.serve(rec, httptest.NewRequest(http.MethodGet, ft.postURL, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("post: want 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<link rel="alternate" hreflang="de" href="http://erock.prose.test/hallo" />`,
+		`<link rel="alternate" hreflang="en" href="http://erock.prose.test/hello" />`,
+		`lang="en"`,
+		`<strong>en</strong>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in post page", want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	ft.serve(rec, httptest.NewRequest(http.MethodGet, "http://erock.prose.test/?lang=de", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("blog: want 200, got %d", rec.Code)
+	}
+	body = rec.Body.String()
+	if !strings.Contains(body, ">Hallo</a>") || strings.Contains(body, ">Hello</a>") {
+		t.Errorf("expected only the german post on the blog")
+	}
+	if !strings.Contains(body, "/rss?lang=de") {
+		t.Errorf("expected rss link to keep the language filter")
+	}
+}
+
+func TestPrimaryLangs(t *testing.T) {
+	got := strings.Join(primaryLangs([]string{"en", "de-at", "de", "en-gb"}), ",")
+	if got != "de,en" {
+		t.Errorf("expected de,en, got %s", got)
+	}
+}
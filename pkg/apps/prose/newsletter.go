@@ -0,0 +1,382 @@
+package prose
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"mime"
+	"path/filepath"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/shared/router"
+)
+
+const (
+	// newsletterDigestInterval is how long posts wait for a digest, so a
+	// blog sends at most one digest per interval.
+	newsletterDigestInterval = 24 * time.Hour
+	// newsletterMaxAge keeps posts that go live with an old publish date,
+	// e.g. when a blog is imported, from being emailed.
+	newsletterMaxAge = 7 * 24 * time.Hour
+	// newsletterResendAfter is how long a pending subscription waits before
+	// another confirmation email can be requested.
+	newsletterResendAfter = time.Hour
+	// maxNewsletterBounces is how many bounces in a row drop a subscriber.
+	maxNewsletterBounces = 3
+)
+
+/*
+Newsletter emails new posts to the subscribers of a blog.  Blogs opt in
+with `newsletter: post` or `newsletter: digest` in _readme.md and readers
+subscribe with the form on the blog, a subscription is only active once
+the link sent to the address is opened.
+
+Posts are queued as they go live, including scheduled posts, and Run
+delivers the queue: one email per post or a digest of every queued post
+at most once per newsletterDigestInterval.
+*/
+type Newsletter struct {
+	Cfg    *shared.ConfigSite
+	Db     db.DB
+	Mailer shared.MailSender
+	Logger *slog.Logger
+	// BounceSecret authenticates bounce reports of the smtp relay, bounce
+	// reports are disabled without it.
+	BounceSecret string
+}
+
+func NewNewsletter(cfg *shared.ConfigSite, dbpool db.DB) *Newsletter {
+	return &Newsletter{
+		Cfg:          cfg,
+		Db:           dbpool,
+		Mailer:       shared.NewMailer(),
+		Logger:       cfg.Logger,
+		BounceSecret: shared.GetEnv("PROSE_NEWSLETTER_BOUNCE_SECRET", ""),
+	}
+}
+
+// NewsletterPostData is a post in a newsletter email.
+type NewsletterPostData struct {
+	Title       string
+	Description string
+	URL         string
+	Contents    template.HTML
+}
+
+// NewsletterPageData renders the newsletter and confirmation emails, and
+// the unsubscribe page.
+type NewsletterPageData struct {
+	Site       shared.SitePageData
+	BlogName   string
+	BlogURL    string
+	Posts      []*NewsletterPostData
+	Inline     bool
+	ConfirmURL string
+	UnsubURL   string
+}
+
+// newsletterBlog is the newsletter setting of a blog.
+type newsletterBlog struct {
+	Mode string
+	Name string
+	URL  string
+}
+
+func (n *Newsletter) blog(user *db.User) *newsletterBlog {
+	blog := &newsletterBlog{
+		Name: GetBlogName(user.Name),
+		URL:  n.Cfg.BlogURL(user.Name),
+	}
+	readme, err := n.Db.FindPostWithFilename("_readme.md", user.ID, n.Cfg.Space)
+	if err != nil {
+		return blog
+	}
+	parsed, err := shared.ParseText(readme.Text)
+	if err != nil {
+		return blog
+	}
+	blog.Mode = parsed.Newsletter
+	if parsed.Title != "" {
+		blog.Name = parsed.Title
+	}
+	return blog
+}
+
+// subscribeURL is where the subscribe form on a blog posts to, it uses the
+// canonical url of the blog so the form also works on exported blogs.
+func subscribeURL(cfg *shared.ConfigSite, username string) string {
+	return cfg.BlogURL(username) + "/_newsletter/subscribe"
+}
+
+func (n *Newsletter) confirmURL(username, token string) string {
+	return fmt.Sprintf("%s/_newsletter/confirm/%s", n.Cfg.BlogURL(username), token)
+}
+
+func (n *Newsletter) unsubURL(username, token string) string {
+	return fmt.Sprintf("%s/_newsletter/unsub/%s", n.Cfg.BlogURL(username), token)
+}
+
+// PostSaved queues a post for the subscribers of the blog when it goes
+// live, a post is only ever emailed once.
+func (n *Newsletter) PostSaved(user *db.User, prev *db.Post, post *db.Post) error {
+	if !isPublished(post) || isPublished(prev) {
+		return nil
+	}
+	if post.PublishAt != nil && post.PublishAt.Before(time.Now().Add(-newsletterMaxAge)) {
+		return nil
+	}
+	if n.blog(user).Mode == "" {
+		return nil
+	}
+	n.Logger.Info("queue post for newsletter", "user", user.Name, "slug", post.Slug)
+	return n.Db.InsertNewsletterPost(user.ID, post.ID)
+}
+
+func (n *Newsletter) Loop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := n.Run(time.Now().UTC()); err != nil {
+				n.Logger.Error("newsletter", "err", err)
+			}
+		}
+	}
+}
+
+// Run emails the queued posts of every blog.
+func (n *Newsletter) Run(now time.Time) error {
+	queued, err := n.Db.FindPendingNewsletterPosts()
+	if err != nil {
+		return err
+	}
+
+	users := []string{}
+	byUser := map[string][]*db.NewsletterPost{}
+	for _, item := range queued {
+		if _, ok := byUser[item.UserID]; !ok {
+			users = append(users, item.UserID)
+		}
+		byUser[item.UserID] = append(byUser[item.UserID], item)
+	}
+
+	for _, userID := range users {
+		err := n.send(userID, byUser[userID], now)
+		if err != nil {
+			n.Logger.Error("send newsletter", "user", userID, "err", err)
+		}
+	}
+	return nil
+}
+
+// send marks the queued posts as sent before emailing them so a failing
+// delivery never emails subscribers twice.  Posts that were removed or
+// unpublished since they were queued are dropped, as is the queue of a
+// blog that disabled its newsletter.
+func (n *Newsletter) send(userID string, queued []*db.NewsletterPost, now time.Time) error {
+	user, err := n.Db.FindUser(userID)
+	if err != nil {
+		return err
+	}
+	blog := n.blog(user)
+	first := queued[0].CreatedAt
+	if blog.Mode == "digest" && first != nil && now.Sub(*first) < newsletterDigestInterval {
+		return nil
+	}
+
+	ids := []string{}
+	posts := []*NewsletterPostData{}
+	for _, item := range queued {
+		ids = append(ids, item.ID)
+		post, err := n.Db.FindPost(item.PostID)
+		if err != nil || !isPublished(post) {
+			continue
+		}
+		data, err := n.postData(user, post)
+		if err != nil {
+			n.Logger.Error("render newsletter post", "user", user.Name, "slug", post.Slug, "err", err)
+			continue
+		}
+		posts = append(posts, data)
+	}
+
+	err = n.Db.MarkNewsletterPostsSent(ids, now)
+	if err != nil {
+		return err
+	}
+	if blog.Mode == "" || len(posts) == 0 {
+		return nil
+	}
+
+	subs, err := n.Db.FindNewsletterSubscribers(user.ID, db.SubscriberConfirmed)
+	if err != nil {
+		return err
+	}
+
+	type issue struct {
+		subject string
+		posts   []*NewsletterPostData
+	}
+	issues := []issue{}
+	if blog.Mode == "digest" {
+		issues = append(issues, issue{
+			subject: fmt.Sprintf("%s: %d new posts", blog.Name, len(posts)),
+			posts:   posts,
+		})
+		if len(posts) == 1 {
+			issues[0].subject = fmt.Sprintf("%s: %s", blog.Name, posts[0].Title)
+		}
+	} else {
+		for _, post := range posts {
+			issues = append(issues, issue{
+				subject: fmt.Sprintf("%s: %s", blog.Name, post.Title),
+				posts:   []*NewsletterPostData{post},
+			})
+		}
+	}
+
+	n.Logger.Info(
+		"sending newsletter",
+		"user", user.Name,
+		"mode", blog.Mode,
+		"posts", len(posts),
+		"subscribers", len(subs),
+	)
+	var errs []error
+	for _, iss := range issues {
+		for _, sub := range subs {
+			// dropped by a bounce of an earlier issue
+			if sub.Status != db.SubscriberConfirmed {
+				continue
+			}
+			err := n.deliver(user, blog, sub, iss.subject, iss.posts)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", sub.Email, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// postData renders a post the same way the rss feed does.
+func (n *Newsletter) postData(user *db.User, post *db.Post) (*NewsletterPostData, error) {
+	ts, err := template.New("rss.page.tmpl").Funcs(router.FuncMap).ParseFiles(
+		n.Cfg.StaticPath("html/list.partial.tmpl"),
+		n.Cfg.StaticPath("html/rss.page.tmpl"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &PostPageData{}
+	switch filepath.Ext(post.Filename) {
+	case ".md":
+		parsed, err := shared.ParseText(post.Text)
+		if err != nil {
+			return nil, err
+		}
+		data.Contents = template.HTML(parsed.Html)
+	case ".lxt":
+		data.List = shared.ListParseText(post.Text)
+	}
+
+	var tpl bytes.Buffer
+	err = ts.Execute(&tpl, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NewsletterPostData{
+		Title:       shared.FilenameToTitle(post.Filename, post.Title),
+		Description: post.Description,
+		URL:         n.Cfg.FullPostURL(shared.NewCreateURL(n.Cfg), user.Name, post.Slug),
+		Contents:    template.HTML(tpl.String()),
+	}, nil
+}
+
+func (n *Newsletter) render(name string, data *NewsletterPageData) (*shared.MsgBody, error) {
+	text, err := shared.RenderText(n.Cfg.StaticPath(fmt.Sprintf("html/%s_text.page.tmpl", name)), data)
+	if err != nil {
+		return nil, err
+	}
+	html, err := shared.RenderHtml(n.Cfg.StaticPath(fmt.Sprintf("html/%s.page.tmpl", name)), data)
+	if err != nil {
+		return nil, err
+	}
+	return &shared.MsgBody{Text: text, Html: html}, nil
+}
+
+// deliver emails posts to a subscriber.  Addresses the smtp relay rejects
+// for good are dropped right away.
+func (n *Newsletter) deliver(user *db.User, blog *newsletterBlog, sub *db.NewsletterSubscriber, subject string, posts []*NewsletterPostData) error {
+	data := &NewsletterPageData{
+		BlogName: blog.Name,
+		BlogURL:  blog.URL,
+		Posts:    posts,
+		Inline:   true,
+		UnsubURL: n.unsubURL(user.Name, sub.Token),
+	}
+	msg, err := n.render("newsletter", data)
+	if err != nil {
+		return err
+	}
+	// cap body size like the feeds digest
+	if len(msg.Html)+len(msg.Text) > 5*shared.MB {
+		data.Inline = false
+		msg, err = n.render("newsletter", data)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = shared.SendListEmail(n.Mailer, sub.Email, mime.QEncoding.Encode("utf-8", subject), data.UnsubURL, msg, nil)
+	if err != nil {
+		if shared.IsPermanentMailError(err) {
+			return errors.Join(err, n.bounce(sub, true))
+		}
+		return err
+	}
+
+	if sub.Bounces > 0 {
+		sub.Bounces = 0
+		_, err = n.Db.UpsertNewsletterSubscriber(sub)
+	}
+	return err
+}
+
+func (n *Newsletter) sendConfirm(user *db.User, blog *newsletterBlog, sub *db.NewsletterSubscriber) error {
+	data := &NewsletterPageData{
+		BlogName:   blog.Name,
+		BlogURL:    blog.URL,
+		ConfirmURL: n.confirmURL(user.Name, sub.Token),
+	}
+	msg, err := n.render("newsletter_confirm", data)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("Confirm your subscription to %s", blog.Name)
+	return n.Mailer.Send(sub.Email, mime.QEncoding.Encode("utf-8", subject), msg.Text, msg.Html, nil)
+}
+
+// bounce records an undeliverable email, subscribers are dropped after a
+// permanent bounce or maxNewsletterBounces soft bounces in a row.
+func (n *Newsletter) bounce(sub *db.NewsletterSubscriber, permanent bool) error {
+	if sub.Status != db.SubscriberConfirmed && sub.Status != db.SubscriberPending {
+		return nil
+	}
+	sub.Bounces += 1
+	if permanent || sub.Bounces >= maxNewsletterBounces {
+		sub.Status = db.SubscriberBounced
+	}
+	n.Logger.Info("newsletter bounce", "subscriber", sub.ID, "bounces", sub.Bounces, "status", sub.Status)
+	_, err := n.Db.UpsertNewsletterSubscriber(sub)
+	return err
+}
@@ -0,0 +1,260 @@
+package prose
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/shared/router"
+)
+
+func (n *Newsletter) write(w http.ResponseWriter, r *http.Request, txt string) {
+	w.Header().Add("Content-Type", "text/plain")
+	_, err := w.Write([]byte(txt))
+	if err != nil {
+		router.GetLogger(r).Error("could not write to writer", "err", err)
+	}
+}
+
+// findBlog returns the blog of the request when its newsletter is enabled.
+func (n *Newsletter) findBlog(w http.ResponseWriter, r *http.Request) (*db.User, *newsletterBlog, bool) {
+	username := router.GetUsernameFromRequest(r)
+	user, err := n.Db.FindUserByName(username)
+	if err != nil {
+		http.Error(w, "blog not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	blog := n.blog(user)
+	if blog.Mode == "" {
+		http.Error(w, "newsletter not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	return user, blog, true
+}
+
+// findSubscriber returns the subscription of the token in the url, tokens
+// only work on the blog they were sent for.
+func (n *Newsletter) findSubscriber(w http.ResponseWriter, r *http.Request) (*db.User, *newsletterBlog, *db.NewsletterSubscriber, bool) {
+	user, blog, ok := n.findBlog(w, r)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	sub, err := n.Db.FindNewsletterSubscriberByToken(router.GetField(r, 0))
+	if err != nil || sub.UserID != user.ID {
+		http.Error(w, "subscription not found", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+	return user, blog, sub, true
+}
+
+/*
+subscribeHandler starts a double opt-in subscription with the form on the
+blog.  The response never tells whether an address is already subscribed,
+and pending subscriptions only get a new confirmation email every
+newsletterResendAfter so the form can not be used to flood a mailbox.
+*/
+func (n *Newsletter) subscribeHandler(w http.ResponseWriter, r *http.Request) {
+	logger := router.GetLogger(r)
+	user, blog, ok := n.findBlog(w, r)
+	if !ok {
+		return
+	}
+	logger = shared.LoggerWithUser(logger, user)
+
+	success := fmt.Sprintf(
+		"Almost there! Open the link we emailed you to confirm your subscription to %s.",
+		blog.Name,
+	)
+	// hidden field of the form, only bots fill it in
+	if r.FormValue("website") != "" {
+		n.write(w, r, success)
+		return
+	}
+
+	addr, err := mail.ParseAddress(r.FormValue("email"))
+	if err != nil || len(addr.Address) > 255 {
+		http.Error(w, "invalid email address", http.StatusBadRequest)
+		return
+	}
+	email := strings.ToLower(addr.Address)
+
+	sub, err := n.Db.FindNewsletterSubscriber(user.ID, email)
+	if err != nil {
+		sub = &db.NewsletterSubscriber{UserID: user.ID, Email: email}
+	} else if sub.Status == db.SubscriberConfirmed {
+		n.write(w, r, success)
+		return
+	} else if sub.Status == db.SubscriberPending && sub.UpdatedAt != nil && time.Since(*sub.UpdatedAt) < newsletterResendAfter {
+		n.write(w, r, success)
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		logger.Error("newsletter token", "err", err)
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+	sub.Token = token
+	sub.Status = db.SubscriberPending
+	sub.Bounces = 0
+	sub.ConfirmedAt = nil
+	sub, err = n.Db.UpsertNewsletterSubscriber(sub)
+	if err != nil {
+		logger.Error("upsert newsletter subscriber", "err", err)
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("newsletter subscribe", "subscriber", sub.ID)
+	err = n.sendConfirm(user, blog, sub)
+	if err != nil {
+		logger.Error("send newsletter confirmation", "err", err)
+		http.Error(w, "could not send confirmation email", http.StatusInternalServerError)
+		return
+	}
+	n.write(w, r, success)
+}
+
+func (n *Newsletter) confirmHandler(w http.ResponseWriter, r *http.Request) {
+	logger := router.GetLogger(r)
+	user, blog, sub, ok := n.findSubscriber(w, r)
+	if !ok {
+		return
+	}
+	logger = shared.LoggerWithUser(logger, user)
+
+	switch sub.Status {
+	case db.SubscriberConfirmed:
+	case db.SubscriberPending:
+		now := time.Now()
+		sub.Status = db.SubscriberConfirmed
+		sub.ConfirmedAt = &now
+		_, err := n.Db.UpsertNewsletterSubscriber(sub)
+		if err != nil {
+			logger.Error("confirm newsletter subscriber", "err", err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		logger.Info("newsletter confirm", "subscriber", sub.ID)
+	default:
+		http.Error(w, "subscription not found", http.StatusNotFound)
+		return
+	}
+
+	n.write(w, r, fmt.Sprintf("Success! New posts of %s will be emailed to you.", blog.Name))
+}
+
+// unsubPageHandler asks to confirm the unsubscribe link of newsletter
+// emails, a GET must not unsubscribe since link scanners of mail providers
+// open every link of an email.
+func (n *Newsletter) unsubPageHandler(w http.ResponseWriter, r *http.Request) {
+	logger := router.GetLogger(r)
+	cfg := router.GetCfg(r)
+	user, blog, sub, ok := n.findSubscriber(w, r)
+	if !ok {
+		return
+	}
+
+	data := NewsletterPageData{
+		Site:     *cfg.GetSiteData(),
+		BlogName: blog.Name,
+		BlogURL:  blog.URL,
+		UnsubURL: n.unsubURL(user.Name, sub.Token),
+	}
+	ts, err := router.RenderTemplate(cfg, []string{
+		cfg.StaticPath("html/newsletter_unsub.page.tmpl"),
+	})
+	if err != nil {
+		logger.Error("render template", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = ts.Execute(w, data)
+	if err != nil {
+		logger.Error("template execute", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// unsubHandler unsubscribes with the form of the unsubscribe page and the
+// one-click unsubscribe of mail clients.
+func (n *Newsletter) unsubHandler(w http.ResponseWriter, r *http.Request) {
+	logger := router.GetLogger(r)
+	user, blog, sub, ok := n.findSubscriber(w, r)
+	if !ok {
+		return
+	}
+	logger = shared.LoggerWithUser(logger, user)
+
+	if sub.Status != db.SubscriberUnsubscribed {
+		sub.Status = db.SubscriberUnsubscribed
+		_, err := n.Db.UpsertNewsletterSubscriber(sub)
+		if err != nil {
+			logger.Error("unsubscribe newsletter subscriber", "err", err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		logger.Info("newsletter unsubscribe", "subscriber", sub.ID)
+	}
+
+	n.write(w, r, fmt.Sprintf("Success! You have been unsubscribed from %s.", blog.Name))
+}
+
+// NewsletterBounce is the bounce report the smtp relay posts for an
+// address, soft bounces are temporary delivery failures.
+type NewsletterBounce struct {
+	Email     string `json:"email"`
+	Permanent bool   `json:"permanent"`
+}
+
+/*
+bounceHandler receives the bounces the smtp relay reports after accepting
+an email, authenticated with BounceSecret as a bearer token:
+
+	curl -H "Authorization: Bearer $SECRET" \
+		-d '{"email":"reader@example.com","permanent":true}' \
+		https://prose.sh/_newsletter/bounce
+*/
+func (n *Newsletter) bounceHandler(w http.ResponseWriter, r *http.Request) {
+	logger := router.GetLogger(r)
+	if n.BounceSecret == "" {
+		http.Error(w, "bounce reports are disabled", http.StatusNotFound)
+		return
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(n.BounceSecret)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var report NewsletterBounce
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4*1024)).Decode(&report)
+	if err != nil || report.Email == "" {
+		http.Error(w, "invalid bounce report", http.StatusBadRequest)
+		return
+	}
+
+	subs, err := n.Db.FindNewsletterSubscribersByEmail(strings.ToLower(report.Email))
+	if err != nil {
+		logger.Error("find newsletter subscribers", "err", err)
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+	for _, sub := range subs {
+		err := n.bounce(sub, report.Permanent)
+		if err != nil {
+			logger.Error("newsletter bounce", "err", err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
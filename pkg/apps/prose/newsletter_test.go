@@ -0,0 +1,326 @@
+package prose
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-smtp"
+	"github.com/picosh/pico/pkg/db"
+)
+
+type fakeMail struct {
+	To      string
+	Subject string
+	Text    string
+	Html    string
+	Headers map[string]string
+}
+
+// fakeMailer records emails instead of sending them, Fail rejects the
+// addresses it returns an error for.
+type fakeMailer struct {
+	mu   sync.Mutex
+	Sent []*fakeMail
+	Fail func(email string) error
+}
+
+func (m *fakeMailer) Send(email, subject, text, html string, headers map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Fail != nil {
+		if err := m.Fail(email); err != nil {
+			return err
+		}
+	}
+	m.Sent = append(m.Sent, &fakeMail{To: email, Subject: subject, Text: text, Html: html, Headers: headers})
+	return nil
+}
+
+func (m *fakeMailer) reset() []*fakeMail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sent := m.Sent
+	m.Sent = nil
+	return sent
+}
+
+func (ft *proseTest) newsletter(mode string) {
+	ft.dbpool.Posts = append(ft.dbpool.Posts, &db.Post{
+		ID:       "readme",
+		UserID:   ft.user.ID,
+		Filename: "_readme.md",
+		Slug:     "_readme",
+		Text:     fmt.Sprintf("---\ntitle: erock writes\nnewsletter: %s\n---\n", mode),
+		Space:    "prose",
+		Hidden:   true,
+	})
+}
+
+func (ft *proseTest) subscriber(email, status string) *db.NewsletterSubscriber {
+	sub, _ := ft.dbpool.UpsertNewsletterSubscriber(&db.NewsletterSubscriber{
+		UserID: ft.user.ID,
+		Email:  email,
+		Token:  "token-" + email,
+		Status: status,
+	})
+	return sub
+}
+
+func TestNewsletterSubscribe(t *testing.T) {
+	t.Chdir("../../..")
+	ft := newProseTest(t)
+
+	subscribe := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "http://erock.prose.test/_newsletter/subscribe", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return ft.do(req)
+	}
+	reader := url.Values{"email": {"Reader <Reader@Example.com>"}}
+
+	if rec := subscribe(reader); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected no newsletter without opting in, got %d", rec.Code)
+	}
+	if strings.Contains(ft.do(httptest.NewRequest("GET", "http://erock.prose.test/", nil)).Body.String(), `id="newsletter"`) {
+		t.Error("subscribe form should not be rendered without a newsletter")
+	}
+
+	ft.newsletter("post")
+	body := ft.do(httptest.NewRequest("GET", "http://erock.prose.test/", nil)).Body.String()
+	if !strings.Contains(body, `action="http://erock.prose.test/_newsletter/subscribe"`) {
+		t.Errorf("expected subscribe form, got %s", body)
+	}
+
+	if rec := subscribe(url.Values{"email": {"nope"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected invalid email to fail, got %d", rec.Code)
+	}
+	if rec := subscribe(url.Values{"email": {"bot@example.com"}, "website": {"spam"}}); rec.Code != http.StatusOK {
+		t.Errorf("expected bots to be ignored quietly, got %d", rec.Code)
+	}
+	if sent := ft.mailer.reset(); len(sent) != 0 {
+		t.Fatalf("expected no email, got %d", len(sent))
+	}
+
+	if rec := subscribe(reader); rec.Code != http.StatusOK {
+		t.Fatalf("subscribe failed %d: %s", rec.Code, rec.Body.String())
+	}
+	sent := ft.mailer.reset()
+	if len(sent) != 1 || sent[0].To != "reader@example.com" {
+		t.Fatalf("expected a confirmation email, got %+v", sent)
+	}
+	sub, err := ft.dbpool.FindNewsletterSubscriber(ft.user.ID, "reader@example.com")
+	if err != nil || sub.Status != db.SubscriberPending {
+		t.Fatalf("expected pending subscriber, got %+v %v", sub, err)
+	}
+	confirmURL := "http://erock.prose.test/_newsletter/confirm/" + sub.Token
+	if !strings.Contains(sent[0].Text, confirmURL) || !strings.Contains(sent[0].Html, confirmURL) {
+		t.Errorf("expected confirm link in %+v", sent[0])
+	}
+	if !strings.Contains(sent[0].Subject, "erock writes") {
+		t.Errorf("unexpected subject %q", sent[0].Subject)
+	}
+
+	// pending subscriptions are not confirmed again right away
+	subscribe(reader)
+	if sent := ft.mailer.reset(); len(sent) != 0 {
+		t.Errorf("expected no second confirmation, got %d", len(sent))
+	}
+
+	if rec := ft.do(httptest.NewRequest("GET", "http://erock.prose.test/_newsletter/confirm/nope", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("expected unknown token to fail, got %d", rec.Code)
+	}
+	if rec := ft.do(httptest.NewRequest("GET", confirmURL, nil)); rec.Code != http.StatusOK {
+		t.Fatalf("confirm failed %d: %s", rec.Code, rec.Body.String())
+	}
+	sub, _ = ft.dbpool.FindNewsletterSubscriber(ft.user.ID, "reader@example.com")
+	if sub.Status != db.SubscriberConfirmed || sub.ConfirmedAt == nil {
+		t.Fatalf("expected confirmed subscriber, got %+v", sub)
+	}
+
+	// link scanners open the unsubscribe link, it only shows the form
+	unsubURL := "http://erock.prose.test/_newsletter/unsub/" + sub.Token
+	rec := ft.do(httptest.NewRequest("GET", unsubURL, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `method="POST"`) {
+		t.Fatalf("expected unsubscribe form %d: %s", rec.Code, rec.Body.String())
+	}
+	sub, _ = ft.dbpool.FindNewsletterSubscriber(ft.user.ID, "reader@example.com")
+	if sub.Status != db.SubscriberConfirmed {
+		t.Fatalf("expected GET to keep the subscription, got %s", sub.Status)
+	}
+
+	// one-click unsubscribe from the List-Unsubscribe header
+	rec = ft.do(httptest.NewRequest("POST", "http://erock.prose.test/_newsletter/unsub/"+sub.Token, strings.NewReader("List-Unsubscribe=One-Click")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unsubscribe failed %d: %s", rec.Code, rec.Body.String())
+	}
+	sub, _ = ft.dbpool.FindNewsletterSubscriber(ft.user.ID, "reader@example.com")
+	if sub.Status != db.SubscriberUnsubscribed {
+		t.Errorf("expected unsubscribed, got %s", sub.Status)
+	}
+	if rec := ft.do(httptest.NewRequest("GET", confirmURL, nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("confirm link should not subscribe again, got %d", rec.Code)
+	}
+}
+
+func TestNewsletterRun(t *testing.T) {
+	t.Chdir("../../..")
+	ft := newProseTest(t)
+	ft.newsletter("post")
+	ft.subscriber("reader@example.com", db.SubscriberConfirmed)
+	ft.subscriber("pending@example.com", db.SubscriberPending)
+
+	if err := ft.nl.PostSaved(ft.user, ft.post, ft.post); err != nil {
+		t.Fatal(err)
+	}
+	if len(ft.dbpool.Issues) != 0 {
+		t.Fatal("edits should not be emailed")
+	}
+	if err := ft.nl.PostSaved(ft.user, nil, ft.post); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ft.nl.Run(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	sent := ft.mailer.reset()
+	if len(sent) != 1 || sent[0].To != "reader@example.com" {
+		t.Fatalf("expected one email to the confirmed subscriber, got %+v", sent)
+	}
+	mail := sent[0]
+	unsubURL := "http://erock.prose.test/_newsletter/unsub/token-reader@example.com"
+	if mail.Headers["List-Unsubscribe"] != "<"+unsubURL+">" || mail.Headers["List-Unsubscribe-Post"] == "" {
+		t.Errorf("unexpected headers %v", mail.Headers)
+	}
+	if !strings.Contains(mail.Html, "from prose") || !strings.Contains(mail.Html, ft.postURL) || !strings.Contains(mail.Text, unsubURL) {
+		t.Errorf("unexpected email %+v", mail)
+	}
+
+	if err := ft.nl.Run(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.nl.PostSaved(ft.user, nil, ft.post); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.nl.Run(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if sent := ft.mailer.reset(); len(sent) != 0 {
+		t.Errorf("posts should only be emailed once, got %d", len(sent))
+	}
+}
+
+func TestNewsletterDigest(t *testing.T) {
+	t.Chdir("../../..")
+	ft := newProseTest(t)
+	ft.newsletter("digest")
+	ft.subscriber("reader@example.com", db.SubscriberConfirmed)
+
+	publishAt := time.Now().Add(-time.Minute)
+	scheduled := &db.Post{
+		ID:        "post-2",
+		UserID:    ft.user.ID,
+		Filename:  "later.md",
+		Slug:      "later",
+		Title:     "Later",
+		Text:      "# Later",
+		Space:     "prose",
+		PublishAt: &publishAt,
+		UpdatedAt: &publishAt,
+		Data:      db.PostData{Scheduled: true},
+	}
+	ft.dbpool.Posts = append(ft.dbpool.Posts, scheduled)
+
+	if err := ft.nl.PostSaved(ft.user, nil, ft.post); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.nl.PostSaved(ft.user, nil, scheduled); err != nil {
+		t.Fatal(err)
+	}
+	if len(ft.dbpool.Issues) != 1 {
+		t.Fatalf("scheduled posts should wait for the scheduler, got %d", len(ft.dbpool.Issues))
+	}
+	scheduler := NewPublishScheduler(ft.fed.Cfg, ft.dbpool, nil, ft.nl, nil)
+	if err := scheduler.Run(context.Background(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(ft.dbpool.Issues) != 2 {
+		t.Fatalf("expected the scheduler to queue the post, got %d", len(ft.dbpool.Issues))
+	}
+
+	now := time.Now()
+	if err := ft.nl.Run(now); err != nil {
+		t.Fatal(err)
+	}
+	if sent := ft.mailer.reset(); len(sent) != 0 {
+		t.Fatalf("digest went out early, got %d", len(sent))
+	}
+
+	if err := ft.nl.Run(now.Add(newsletterDigestInterval)); err != nil {
+		t.Fatal(err)
+	}
+	sent := ft.mailer.reset()
+	if len(sent) != 1 {
+		t.Fatalf("expected one digest, got %d", len(sent))
+	}
+	if !strings.Contains(sent[0].Subject, "2 new posts") || !strings.Contains(sent[0].Text, "Hello") || !strings.Contains(sent[0].Text, "Later") {
+		t.Errorf("unexpected digest %+v", sent[0])
+	}
+}
+
+func TestNewsletterBounce(t *testing.T) {
+	t.Chdir("../../..")
+	ft := newProseTest(t)
+	ft.newsletter("post")
+	ft.subscriber("reader@example.com", db.SubscriberConfirmed)
+	ft.subscriber("gone@example.com", db.SubscriberConfirmed)
+	ft.mailer.Fail = func(email string) error {
+		if email == "gone@example.com" {
+			return &smtp.SMTPError{Code: 550, Message: "mailbox unavailable"}
+		}
+		return nil
+	}
+
+	if err := ft.nl.PostSaved(ft.user, nil, ft.post); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.nl.Run(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if sent := ft.mailer.reset(); len(sent) != 1 || sent[0].To != "reader@example.com" {
+		t.Fatalf("unexpected emails %+v", sent)
+	}
+	gone, _ := ft.dbpool.FindNewsletterSubscriber(ft.user.ID, "gone@example.com")
+	if gone.Status != db.SubscriberBounced {
+		t.Errorf("expected rejected address to bounce, got %+v", gone)
+	}
+
+	report := func(secret, body string) int {
+		req := httptest.NewRequest("POST", "http://prose.test/_newsletter/bounce", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+secret)
+		return ft.do(req).Code
+	}
+	soft := `{"email":"Reader@example.com"}`
+	if code := report("", soft); code != http.StatusNotFound {
+		t.Errorf("expected bounce reports to be disabled, got %d", code)
+	}
+	ft.nl.BounceSecret = "s3cret"
+	if code := report("nope", soft); code != http.StatusUnauthorized {
+		t.Errorf("expected unauthorized, got %d", code)
+	}
+	for i := range maxNewsletterBounces {
+		if code := report("s3cret", soft); code != http.StatusNoContent {
+			t.Fatalf("bounce report failed %d", code)
+		}
+		reader, _ := ft.dbpool.FindNewsletterSubscriber(ft.user.ID, "reader@example.com")
+		bounced := reader.Status == db.SubscriberBounced
+		if bounced != (i == maxNewsletterBounces-1) {
+			t.Fatalf("unexpected status after %d soft bounces: %+v", i+1, reader)
+		}
+	}
+}
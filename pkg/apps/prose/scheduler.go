@@ -0,0 +1,203 @@
+package prose
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/utils/pipe"
+)
+
+// PublishTopic is the pipe topic scheduled posts are announced on.
+const PublishTopic = "prose-publish"
+
+// PublishEvent is emitted on PublishTopic when a scheduled post goes live.
+type PublishEvent struct {
+	Type      string    `json:"type"`
+	Username  string    `json:"username"`
+	Title     string    `json:"title"`
+	Slug      string    `json:"slug"`
+	URL       string    `json:"url"`
+	RssURL    string    `json:"rss_url"`
+	PublishAt time.Time `json:"publish_at"`
+}
+
+// WebSubHubs are the hubs pinged when a blog feed changes, configured as a
+// comma separated list.
+func WebSubHubs() []string {
+	hubs := []string{}
+	for _, hub := range strings.Split(shared.GetEnv("PROSE_WEBSUB_HUBS", ""), ",") {
+		hub = strings.TrimSpace(hub)
+		if hub != "" {
+			hubs = append(hubs, hub)
+		}
+	}
+	return hubs
+}
+
+/*
+PublishScheduler announces posts that were uploaded with a publish date in
+the future once that date arrives.  Each post is announced once: a pipe
+event is emitted, the WebSub hubs are pinged with the rss feed of the blog,
+the post is federated to its followers and queued for the newsletter.
+*/
+type PublishScheduler struct {
+	Cfg        *shared.ConfigSite
+	Db         db.DB
+	Federation *Federation
+	Newsletter *Newsletter
+	Events     io.Writer
+	Hubs       []string
+	Client     *http.Client
+	Logger     *slog.Logger
+}
+
+func NewPublishScheduler(cfg *shared.ConfigSite, dbpool db.DB, fed *Federation, nl *Newsletter, events io.Writer) *PublishScheduler {
+	return &PublishScheduler{
+		Cfg:        cfg,
+		Db:         dbpool,
+		Federation: fed,
+		Newsletter: nl,
+		Events:     events,
+		Hubs:       WebSubHubs(),
+		Client:     &http.Client{Timeout: 10 * time.Second},
+		Logger:     cfg.Logger,
+	}
+}
+
+func CreatePubPublishEvents(ctx context.Context, logger *slog.Logger) *pipe.ReconnectReadWriteCloser {
+	info := shared.NewPicoPipeClient()
+	send := pipe.NewReconnectReadWriteCloser(
+		ctx,
+		logger,
+		info,
+		"pub to "+PublishTopic,
+		fmt.Sprintf("pub %s -b=false", PublishTopic),
+		100,
+		-1,
+	)
+	return send
+}
+
+func (s *PublishScheduler) Loop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Run(ctx, time.Now().UTC()); err != nil {
+				s.Logger.Error("publish scheduler", "err", err)
+			}
+		}
+	}
+}
+
+func (s *PublishScheduler) Run(ctx context.Context, now time.Time) error {
+	posts, err := s.Db.FindScheduledPosts(s.Cfg.Space, now)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		err := s.publish(ctx, post)
+		if err != nil {
+			s.Logger.Error("publish post", "id", post.ID, "user", post.Username, "err", err)
+		}
+	}
+	return nil
+}
+
+// publish marks the post as live before announcing it so a failing
+// announcement is not retried forever.  Every replica runs a scheduler,
+// only the one that claims the post announces it.
+func (s *PublishScheduler) publish(ctx context.Context, post *db.Post) error {
+	user, err := s.Db.FindUser(post.UserID)
+	if err != nil {
+		return err
+	}
+
+	prev := *post
+	claimed, err := s.Db.ClaimScheduledPost(post.ID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+	post.Data.Scheduled = false
+	s.Logger.Info("scheduled post is live", "id", post.ID, "user", user.Name, "slug", post.Slug)
+
+	curl := shared.NewCreateURL(s.Cfg)
+	event := &PublishEvent{
+		Type:     "post.published",
+		Username: user.Name,
+		Title:    shared.FilenameToTitle(post.Filename, post.Title),
+		Slug:     post.Slug,
+		URL:      s.Cfg.FullPostURL(curl, user.Name, post.Slug),
+		RssURL:   s.Cfg.RssBlogURL(curl, user.Name, ""),
+	}
+	if post.PublishAt != nil {
+		event.PublishAt = post.PublishAt.UTC()
+	}
+
+	var errs []error
+	if s.Events != nil {
+		errs = append(errs, s.emit(event))
+	}
+	for _, hub := range s.Hubs {
+		errs = append(errs, s.pingHub(ctx, hub, event.RssURL))
+	}
+	if s.Federation != nil {
+		errs = append(errs, s.Federation.PostSaved(user, &prev, post))
+	}
+	if s.Newsletter != nil {
+		errs = append(errs, s.Newsletter.PostSaved(user, &prev, post))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *PublishScheduler) emit(event *PublishEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = s.Events.Write(append(data, '\n'))
+	return err
+}
+
+// pingHub tells a WebSub hub that the feed at topic has new content.
+func (s *PublishScheduler) pingHub(ctx context.Context, hub, topic string) error {
+	form := url.Values{}
+	form.Set("hub.mode", "publish")
+	form.Set("hub.url", topic)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", hub, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: responded with status %d", hub, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package prose
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPublishScheduler(t *testing.T) {
+	ft := newProseTest(t)
+	ft.follow(t)
+
+	publishAt := time.Now().Add(-time.Minute)
+	ft.post.PublishAt = &publishAt
+	ft.post.Data.Scheduled = true
+
+	pings := make(chan url.Values, 10)
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		pings <- r.PostForm
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(hub.Close)
+
+	events := &bytes.Buffer{}
+	scheduler := NewPublishScheduler(ft.fed.Cfg, ft.dbpool, ft.fed, ft.nl, events)
+	scheduler.Hubs = []string{hub.URL}
+
+	err := scheduler.Run(context.Background(), publishAt.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events.Len() != 0 {
+		t.Fatalf("post was announced before its publish date: %s", events.String())
+	}
+	ft.remote.none()
+
+	err = scheduler.Run(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := &PublishEvent{}
+	if err := json.Unmarshal(events.Bytes(), event); err != nil {
+		t.Fatalf("event: %v: %q", err, events.String())
+	}
+	if event.Type != "post.published" || event.URL != ft.postURL || event.RssURL != "http://erock.prose.test/rss" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	select {
+	case form := <-pings:
+		if form.Get("hub.mode") != "publish" || form.Get("hub.url") != event.RssURL {
+			t.Fatalf("unexpected hub ping %v", form)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("hub was not pinged")
+	}
+
+	create := ft.remote.next()
+	if create.Type != "Create" {
+		t.Fatalf("expected Create, got %s", create.Type)
+	}
+
+	post, _ := ft.dbpool.FindPostWithSlug("hello", ft.user.ID, "prose")
+	if post.Data.Scheduled {
+		t.Fatal("post is still scheduled")
+	}
+
+	events.Reset()
+	err = scheduler.Run(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events.Len() != 0 {
+		t.Fatalf("post was announced twice: %s", events.String())
+	}
+	ft.remote.none()
+
+	// a replica that read the post before it was claimed skips it
+	stale := *post
+	stale.Data.Scheduled = true
+	if err := scheduler.publish(context.Background(), &stale); err != nil {
+		t.Fatal(err)
+	}
+	if events.Len() != 0 {
+		t.Fatalf("post was announced by a second scheduler: %s", events.String())
+	}
+	ft.remote.none()
+}
@@ -1,9 +1,12 @@
 package prose
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"slices"
 
@@ -15,11 +18,15 @@ import (
 )
 
 type MarkdownHooks struct {
-	Cfg  *shared.ConfigSite
-	Db   db.DB
-	Pipe *pipeUtil.ReconnectReadWriteCloser
+	Cfg        *shared.ConfigSite
+	Db         db.DB
+	Pipe       *pipeUtil.ReconnectReadWriteCloser
+	Federation *Federation
+	Newsletter *Newsletter
 }
 
+var _ filehandlers.ScpPostHooks = (*MarkdownHooks)(nil)
+
 func (p *MarkdownHooks) FileValidate(s *pssh.SSHServerConnSession, data *filehandlers.PostMetaData) (bool, error) {
 	if !shared.IsTextFile(data.Text) {
 		err := fmt.Errorf(
@@ -74,6 +81,8 @@ func (p *MarkdownHooks) metaLxt(data *filehandlers.PostMetaData) error {
 	data.Aliases = parsedText.Aliases
 	data.Tags = parsedText.Tags
 	data.Description = parsedText.Description
+	data.Data.Series = parsedText.Series
+	data.Data.Lang = parsedText.Lang
 
 	if parsedText.PublishAt != nil && !parsedText.PublishAt.IsZero() {
 		data.PublishAt = parsedText.PublishAt
@@ -98,6 +107,9 @@ func (p *MarkdownHooks) metaMd(data *filehandlers.PostMetaData) error {
 	data.Aliases = parsedText.Aliases
 	data.Tags = parsedText.Tags
 	data.Description = parsedText.Description
+	data.Data.Series = parsedText.Series
+	data.Data.Lang = parsedText.Lang
+	data.Data.Translations = parsedText.Translations
 
 	if parsedText.PublishAt != nil && !parsedText.PublishAt.IsZero() {
 		data.PublishAt = parsedText.PublishAt
@@ -122,8 +134,84 @@ func (p *MarkdownHooks) FileMeta(s *pssh.SSHServerConnSession, data *filehandler
 		}
 	}
 
+	// only drafts are hidden by the front-matter at this point
+	isDraft := data.Hidden
 	isHiddenFilename := slices.Contains(p.Cfg.HiddenPosts, data.Filename)
 	data.Hidden = data.Hidden || isHiddenFilename
 
+	if isDraft && !isHiddenFilename {
+		if data.Data.PreviewToken == "" {
+			token, err := newToken()
+			if err != nil {
+				return err
+			}
+			data.Data.PreviewToken = token
+		}
+	} else {
+		data.Data.PreviewToken = ""
+	}
+
+	data.Data.Scheduled = isScheduled(data)
+
 	return nil
 }
+
+// newToken creates the secret part of urls that must not be guessable.
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+/*
+isScheduled reports whether the publish scheduler still has to announce
+the post.  A post stays scheduled when it is uploaded again after its
+publish date but before the scheduler got to it, that way going live is
+announced exactly once.
+*/
+func isScheduled(data *filehandlers.PostMetaData) bool {
+	if data.Hidden || data.PublishAt == nil {
+		return false
+	}
+	if data.PublishAt.After(time.Now()) {
+		return true
+	}
+	return data.Cur != nil && data.Cur.Data.Scheduled
+}
+
+// PostSaved queues the post for the newsletter and federates it in the
+// background so uploads do not wait on remote servers.
+func (p *MarkdownHooks) PostSaved(s *pssh.SSHServerConnSession, user *db.User, prev *db.Post, post *db.Post) {
+	logger := pssh.GetLogger(s)
+	if p.Newsletter != nil {
+		err := p.Newsletter.PostSaved(user, prev, post)
+		if err != nil {
+			logger.Error("queue post for newsletter", "err", err.Error())
+		}
+	}
+	if p.Federation == nil {
+		return
+	}
+	go func() {
+		err := p.Federation.PostSaved(user, prev, post)
+		if err != nil {
+			logger.Error("federate post", "err", err.Error())
+		}
+	}()
+}
+
+func (p *MarkdownHooks) PostRemoved(s *pssh.SSHServerConnSession, user *db.User, post *db.Post) {
+	if p.Federation == nil {
+		return
+	}
+	logger := pssh.GetLogger(s)
+	go func() {
+		err := p.Federation.PostRemoved(user, post)
+		if err != nil {
+			logger.Error("federate post removal", "err", err.Error())
+		}
+	}()
+}
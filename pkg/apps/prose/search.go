@@ -0,0 +1,170 @@
+package prose
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/shared/router"
+)
+
+// long queries are truncated, they only make the search slower.
+const maxSearchQueryLen = 256
+
+type SearchResultData struct {
+	PostItemData
+	Snippet template.HTML
+}
+
+type SearchPageData struct {
+	Site       shared.SitePageData
+	PageTitle  string
+	URL        template.URL
+	HomeURL    template.URL
+	Username   string
+	Query      string
+	Results    []SearchResultData
+	NextPage   string
+	PrevPage   string
+	HasCSS     bool
+	WithStyles bool
+	CssURL     template.URL
+}
+
+// highlightSnippet escapes a search snippet and turns the match markers
+// into <mark> elements.
+func highlightSnippet(snippet string) template.HTML {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, db.SearchMarkStart, "<mark>")
+	escaped = strings.ReplaceAll(escaped, db.SearchMarkEnd, "</mark>")
+	return template.HTML(escaped)
+}
+
+func searchQuery(r *http.Request) (string, int) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(query) > maxSearchQueryLen {
+		query = query[:maxSearchQueryLen]
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	return query, max(page, 0)
+}
+
+func searchPageURL(base, query string, page int) string {
+	return fmt.Sprintf("%s?q=%s&page=%d", base, url.QueryEscape(query), page)
+}
+
+// renderSearch runs the search for userID, or every user when empty, and
+// renders the results into data.
+func renderSearch(w http.ResponseWriter, r *http.Request, userID string, data *SearchPageData) {
+	dbpool := router.GetDB(r)
+	logger := router.GetLogger(r)
+	cfg := router.GetCfg(r)
+	curl := shared.CreateURLFromRequest(cfg, r)
+
+	query, page := searchQuery(r)
+	data.Query = query
+
+	if query != "" {
+		pager, err := dbpool.FindPostsBySearch(&db.Pager{Num: 30, Page: page}, query, userID, cfg.Space)
+		if err != nil {
+			logger.Error("search posts", "err", err.Error(), "query", query)
+			http.Error(w, "could not search posts", http.StatusInternalServerError)
+			return
+		}
+
+		for _, result := range pager.Data {
+			post := result.Post
+			data.Results = append(data.Results, SearchResultData{
+				PostItemData: PostItemData{
+					URL:          template.URL(cfg.FullPostURL(curl, post.Username, post.Slug)),
+					BlogURL:      template.URL(cfg.FullBlogURL(curl, post.Username)),
+					Title:        shared.FilenameToTitle(post.Filename, post.Title),
+					Description:  post.Description,
+					Username:     post.Username,
+					PublishAt:    post.PublishAt.Format(time.DateOnly),
+					PublishAtISO: post.PublishAt.Format(time.RFC3339),
+				},
+				Snippet: highlightSnippet(result.Snippet),
+			})
+		}
+
+		if page < pager.Total-1 {
+			data.NextPage = searchPageURL(string(data.URL), query, page+1)
+		}
+		if page > 0 {
+			data.PrevPage = searchPageURL(string(data.URL), query, page-1)
+		}
+	}
+
+	ts, err := router.RenderTemplate(cfg, []string{
+		cfg.StaticPath("html/search.page.tmpl"),
+	})
+	if err != nil {
+		logger.Error("render template", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = ts.Execute(w, data)
+	if err != nil {
+		logger.Error("template execute", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := router.GetCfg(r)
+	renderSearch(w, r, "", &SearchPageData{
+		Site:      *cfg.GetSiteData(),
+		PageTitle: cfg.Domain,
+		URL:       "/search",
+		HomeURL:   "/read",
+	})
+}
+
+func blogSearchHandler(w http.ResponseWriter, r *http.Request) {
+	username := router.GetUsernameFromRequest(r)
+	dbpool := router.GetDB(r)
+	logger := router.GetLogger(r)
+	cfg := router.GetCfg(r)
+
+	user, err := dbpool.FindUserByName(username)
+	if err != nil {
+		logger.Info("blog not found", "user", username)
+		http.Error(w, "blog not found", http.StatusNotFound)
+		return
+	}
+
+	curl := shared.CreateURLFromRequest(cfg, r)
+	data := &SearchPageData{
+		Site:       *cfg.GetSiteData(),
+		PageTitle:  GetBlogName(username),
+		URL:        template.URL(cfg.FullPostURL(curl, username, "search")),
+		HomeURL:    template.URL(cfg.FullBlogURL(curl, username)),
+		Username:   username,
+		WithStyles: true,
+		CssURL:     template.URL(cfg.CssURL(username)),
+	}
+
+	readme, err := dbpool.FindPostWithFilename("_readme.md", user.ID, cfg.Space)
+	if err == nil {
+		parsedText, err := shared.ParseText(readme.Text)
+		if err == nil {
+			data.WithStyles = parsedText.WithStyles
+			if parsedText.Title != "" {
+				data.PageTitle = parsedText.Title
+			}
+		}
+	}
+	_, err = dbpool.FindPostWithFilename("_styles.css", user.ID, cfg.Space)
+	data.HasCSS = err == nil
+
+	renderSearch(w, r, user.ID, data)
+}
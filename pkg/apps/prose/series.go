@@ -0,0 +1,181 @@
+package prose
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/shared/router"
+)
+
+type SeriesNavData struct {
+	Name  string
+	URL   template.URL
+	Index int
+	Total int
+	Prev  *PostItemData
+	Next  *PostItemData
+}
+
+type SeriesPageData struct {
+	Site       shared.SitePageData
+	PageTitle  string
+	URL        template.URL
+	BlogURL    template.URL
+	BlogName   string
+	Username   string
+	Name       string
+	Posts      []PostItemData
+	HasCSS     bool
+	WithStyles bool
+	CssURL     template.URL
+}
+
+// canView reports whether a post may be served, drafts are only visible
+// through their secret preview url and theme files are never served.
+// Hidden posts without a token, like drafts saved before previews
+// existed, are not served at all.
+func canView(r *http.Request, post *db.Post) bool {
+	if strings.HasPrefix(post.Filename, themeDir) {
+		return false
+	}
+	token := post.Data.PreviewToken
+	if token == "" {
+		return !post.Hidden
+	}
+	preview := r.URL.Query().Get("preview")
+	return subtle.ConstantTimeCompare([]byte(preview), []byte(token)) == 1
+}
+
+func seriesURL(cfg *shared.ConfigSite, curl *shared.CreateURL, username, series string) string {
+	return cfg.FullPostURL(curl, username, "series") + "/" + url.PathEscape(series)
+}
+
+func seriesItem(cfg *shared.ConfigSite, curl *shared.CreateURL, post *db.Post) PostItemData {
+	return PostItemData{
+		URL:          template.URL(cfg.FullPostURL(curl, post.Username, post.Slug)),
+		BlogURL:      template.URL(cfg.FullBlogURL(curl, post.Username)),
+		Username:     post.Username,
+		Title:        shared.FilenameToTitle(post.Filename, post.Title),
+		Description:  post.Description,
+		PublishAt:    post.PublishAt.Format(time.DateOnly),
+		PublishAtISO: post.PublishAt.Format(time.RFC3339),
+	}
+}
+
+// seriesNav links a post to its neighbours in the series, it is nil when
+// the post is not part of a published series.
+func seriesNav(r *http.Request, post *db.Post) (*SeriesNavData, error) {
+	if post.Data.Series == "" {
+		return nil, nil
+	}
+
+	dbpool := router.GetDB(r)
+	cfg := router.GetCfg(r)
+	curl := shared.CreateURLFromRequest(cfg, r)
+
+	posts, err := dbpool.FindPostsBySeries(post.UserID, post.Data.Series, cfg.Space)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, p := range posts {
+		if p.ID != post.ID {
+			continue
+		}
+
+		nav := &SeriesNavData{
+			Name:  post.Data.Series,
+			URL:   template.URL(seriesURL(cfg, curl, post.Username, post.Data.Series)),
+			Index: idx + 1,
+			Total: len(posts),
+		}
+		if idx > 0 {
+			prev := seriesItem(cfg, curl, posts[idx-1])
+			nav.Prev = &prev
+		}
+		if idx < len(posts)-1 {
+			next := seriesItem(cfg, curl, posts[idx+1])
+			nav.Next = &next
+		}
+		return nav, nil
+	}
+
+	return nil, nil
+}
+
+func seriesHandler(w http.ResponseWriter, r *http.Request) {
+	username := router.GetUsernameFromRequest(r)
+	dbpool := router.GetDB(r)
+	logger := router.GetLogger(r)
+	cfg := router.GetCfg(r)
+
+	user, err := dbpool.FindUserByName(username)
+	if err != nil {
+		logger.Info("blog not found", "user", username)
+		http.Error(w, "blog not found", http.StatusNotFound)
+		return
+	}
+	logger = shared.LoggerWithUser(logger, user)
+
+	name, _ := url.PathUnescape(router.GetField(r, 0))
+	posts, err := dbpool.FindPostsBySeries(user.ID, name, cfg.Space)
+	if err != nil {
+		logger.Error("find series", "err", err.Error(), "series", name)
+		http.Error(w, "could not fetch series", http.StatusInternalServerError)
+		return
+	}
+	if len(posts) == 0 {
+		http.Error(w, "series not found", http.StatusNotFound)
+		return
+	}
+
+	curl := shared.CreateURLFromRequest(cfg, r)
+	data := &SeriesPageData{
+		Site:       *cfg.GetSiteData(),
+		PageTitle:  name,
+		URL:        template.URL(seriesURL(cfg, curl, username, name)),
+		BlogURL:    template.URL(cfg.FullBlogURL(curl, username)),
+		BlogName:   GetBlogName(username),
+		Username:   username,
+		Name:       name,
+		WithStyles: true,
+		CssURL:     template.URL(cfg.CssURL(username)),
+	}
+	for _, post := range posts {
+		data.Posts = append(data.Posts, seriesItem(cfg, curl, post))
+	}
+
+	readme, err := dbpool.FindPostWithFilename("_readme.md", user.ID, cfg.Space)
+	if err == nil {
+		parsedText, err := shared.ParseText(readme.Text)
+		if err == nil {
+			data.WithStyles = parsedText.WithStyles
+			if parsedText.Title != "" {
+				data.BlogName = parsedText.Title
+			}
+		}
+	}
+	_, err = dbpool.FindPostWithFilename("_styles.css", user.ID, cfg.Space)
+	data.HasCSS = err == nil
+
+	ts, err := router.RenderTemplate(cfg, []string{
+		cfg.StaticPath("html/series.page.tmpl"),
+	})
+	if err != nil {
+		logger.Error("render template", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = ts.Execute(w, data)
+	if err != nil {
+		logger.Error("template execute", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
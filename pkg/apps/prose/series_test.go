@@ -0,0 +1,110 @@
+package prose
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+)
+
+func TestDraftPreview(t *testing.T) {
+	t.Chdir("../../..")
+	ft := newProseTest(t)
+	ft.post.Hidden = true
+	ft.post.Data.PreviewToken = "secret"
+
+	for _, target := range []string{
+		ft.postURL,
+		ft.postURL + "?preview=wrong",
+		"http://erock.prose.test/raw/hello",
+	} {
+		rec := ft.do(httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: want 404, got %d", target, rec.Code)
+		}
+	}
+
+	for _, target := range []string{
+		ft.postURL + "?preview=secret",
+		"http://erock.prose.test/raw/hello?preview=secret",
+	} {
+		rec := ft.do(httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: want 200, got %d", target, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "from prose") {
+			t.Errorf("%s: draft was not rendered", target)
+		}
+	}
+
+	// drafts saved before previews existed have no token
+	ft.post.Data.PreviewToken = ""
+	for _, target := range []string{ft.postURL, ft.postURL + "?preview="} {
+		rec := ft.do(httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: want 404 for a draft without a token, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestSeries(t *testing.T) {
+	t.Chdir("../../..")
+	ft := newProseTest(t)
+
+	ft.dbpool.Posts = nil
+	for i, slug := range []string{"part-two", "part-one", "part-three"} {
+		publishAt := time.Now().Add(-time.Duration(10-i) * time.Hour)
+		if slug == "part-one" {
+			publishAt = publishAt.Add(-24 * time.Hour)
+		}
+		ft.dbpool.Posts = append(ft.dbpool.Posts, &db.Post{
+			ID:        slug,
+			UserID:    ft.user.ID,
+			Username:  ft.user.Name,
+			Filename:  slug + ".md",
+			Slug:      slug,
+			Title:     slug,
+			Text:      "# " + slug,
+			Space:     "prose",
+			PublishAt: &publishAt,
+			UpdatedAt: &publishAt,
+			Data:      db.PostData{Series: "go tour"},
+		})
+	}
+
+	rec := ft.do(httptest.NewRequest(http.MethodGet, "http://erock.prose.test/part-two", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("post: want 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"part 2 of 3",
+		`href="http://erock.prose.test/part-one" rel="prev"`,
+		`href="http://erock.prose.test/part-three" rel="next"`,
+		`href="http://erock.prose.test/series/go%20tour"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("post page is missing %q", want)
+		}
+	}
+
+	rec = ft.do(httptest.NewRequest(http.MethodGet, "http://erock.prose.test/series/go%20tour", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("series: want 200, got %d", rec.Code)
+	}
+	body = rec.Body.String()
+	one := strings.Index(body, "http://erock.prose.test/part-one")
+	two := strings.Index(body, "http://erock.prose.test/part-two")
+	three := strings.Index(body, "http://erock.prose.test/part-three")
+	if one < 0 || one > two || two > three {
+		t.Errorf("series index is not in reading order:\n%s", body)
+	}
+
+	rec = ft.do(httptest.NewRequest(http.MethodGet, "http://erock.prose.test/series/nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown series: want 404, got %d", rec.Code)
+	}
+}
@@ -39,8 +39,10 @@ func StartSshServer() {
 	}()
 
 	hooks := &MarkdownHooks{
-		Cfg: cfg,
-		Db:  dbh,
+		Cfg:        cfg,
+		Db:         dbh,
+		Federation: NewFederation(cfg, dbh),
+		Newsletter: NewNewsletter(cfg, dbh),
 	}
 
 	adapter := storage.GetStorageTypeFromEnv()
@@ -55,11 +57,14 @@ func StartSshServer() {
 		".txt":     filehandlers.NewScpPostHandler(dbh, cfg, hooks),
 		".css":     filehandlers.NewScpPostHandler(dbh, cfg, hooks),
 		".lxt":     filehandlers.NewScpPostHandler(dbh, cfg, hooks),
+		".html":    NewThemeHandler(dbh, cfg),
+		exportDir:  NewExportHandler(dbh, cfg, st),
 		"fallback": uploadimgs.NewUploadImgHandler(dbh, cfg, st),
 	}
 	handler := filehandlers.NewFileHandlerRouter(cfg, dbh, fileMap)
 
 	sshAuth := shared.NewSshAuthHandler(dbh, logger, "prose")
+	sshAuth.OrgContext = true
 
 	// Create a new SSH server
 	server, err := pssh.NewSSHServerWithConfig(
@@ -77,6 +82,9 @@ func StartSshServer() {
 			scp.Middleware(handler),
 			rsync.Middleware(handler),
 			auth.Middleware(handler),
+			Middleware(dbh, cfg),
+			StatsMiddleware(dbh, cfg),
+			ExportMiddleware(dbh, cfg, st),
 			pssh.PtyMdw(pssh.DeprecatedNotice(), 200*time.Millisecond),
 			pssh.LogMiddleware(handler, dbh),
 		},
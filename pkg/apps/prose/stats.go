@@ -0,0 +1,260 @@
+package prose
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"text/tabwriter"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/pssh"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/shared/router"
+)
+
+// readPixel is a transparent 1x1 gif.
+var readPixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00,
+	0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00,
+	0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// readThroughURL is the pixel at the end of a post, it is only rendered
+// when the author has analytics enabled.
+func readThroughURL(blogURL, slug string) string {
+	return fmt.Sprintf("%s/_read/%s", blogURL, slug)
+}
+
+/*
+readThroughHandler records that a reader reached the end of a post.  The
+pixel is lazy loaded at the bottom of the post so browsers only fetch it
+once the reader scrolls near the end.  The pixel is always served, failing
+to record a read is never shown to the reader.
+*/
+func readThroughHandler(w http.ResponseWriter, r *http.Request) {
+	username := router.GetUsernameFromRequest(r)
+	slug := router.GetField(r, 0)
+	dbpool := router.GetDB(r)
+	logger := router.GetLogger(r)
+	cfg := router.GetCfg(r)
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-store")
+	defer func() {
+		_, _ = w.Write(readPixel)
+	}()
+
+	user, err := dbpool.FindUserByName(username)
+	if err != nil {
+		return
+	}
+	post, err := dbpool.FindPostWithSlug(slug, user.ID, cfg.Space)
+	if err != nil {
+		return
+	}
+
+	visit, err := router.AnalyticsVisitFromRequest(r, dbpool, user.ID)
+	if err != nil {
+		return
+	}
+	visit.PostID = post.ID
+	visit.Namespace = cfg.Space
+	err = router.AnalyticsVisitFromVisit(visit, dbpool, cfg.Secret)
+	if err != nil {
+		logger.Info("could not record read-through", "err", err)
+		return
+	}
+	err = dbpool.InsertPostRead(visit)
+	if err != nil {
+		logger.Error("insert read-through", "err", err)
+	}
+}
+
+// StatsCmd implements the stats commands of the prose ssh server for User.
+type StatsCmd struct {
+	Cfg  *shared.ConfigSite
+	Db   db.DB
+	User *db.User
+	Out  io.Writer
+}
+
+func (c *StatsCmd) output(format string, args ...any) {
+	_, _ = fmt.Fprintf(c.Out, format+"\r\n", args...)
+}
+
+func (c *StatsCmd) notice() {
+	if !c.Db.HasFeatureByUser(c.User.ID, "analytics") {
+		c.output("analytics are disabled, enable them in the pico tui to collect new stats")
+		c.output("")
+	}
+}
+
+func lastDays(now time.Time) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return today.AddDate(0, 0, -29)
+}
+
+func lastMonths(now time.Time) time.Time {
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return month.AddDate(0, -11, 0)
+}
+
+// List prints the visitors and read-throughs of every post over the last
+// 30 days.
+func (c *StatsCmd) List(now time.Time) error {
+	c.notice()
+	posts, err := c.Db.FindVisitPostList(&db.SummaryOpts{
+		UserID: c.User.ID,
+		Origin: lastDays(now),
+	})
+	if err != nil {
+		return err
+	}
+	if len(posts) == 0 {
+		c.output("no post stats found for the last 30 days")
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(c.Out, 0, 0, 1, ' ', tabwriter.TabIndent)
+	_, _ = fmt.Fprintln(writer, "Post\tVisitors\tReads\tRead-through\tViews")
+	for _, post := range posts {
+		_, _ = fmt.Fprintf(
+			writer,
+			"%s\t%d\t%d\t%.1f%%\t%d\r\n",
+			post.Slug,
+			post.Visitors,
+			post.ReadThroughs,
+			post.ReadThroughRate()*100,
+			post.Views,
+		)
+	}
+	return writer.Flush()
+}
+
+// Post prints the visitor and read-through trends of the post with slug
+// over the last 30 days and 12 months along with its top referers.
+func (c *StatsCmd) Post(slug string, now time.Time) error {
+	post, err := c.Db.FindPostWithSlug(slug, c.User.ID, c.Cfg.Space)
+	if err != nil {
+		return fmt.Errorf("post %s not found", slug)
+	}
+
+	dayOrigin := lastDays(now)
+	days, err := c.Db.PostVisitSummary(&db.SummaryOpts{
+		UserID:   c.User.ID,
+		PostID:   post.ID,
+		Interval: "day",
+		Origin:   dayOrigin,
+	})
+	if err != nil {
+		return err
+	}
+	monthOrigin := lastMonths(now)
+	months, err := c.Db.PostVisitSummary(&db.SummaryOpts{
+		UserID:   c.User.ID,
+		PostID:   post.ID,
+		Interval: "month",
+		Origin:   monthOrigin,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.output("%s: %s", post.Slug, shared.FilenameToTitle(post.Filename, post.Title))
+	c.output("views: %d", months.Views)
+	c.output("")
+	c.notice()
+
+	trend := func(label string, stats *db.PostAnalytics, origin time.Time, interval string, n int) error {
+		visitors, reads := shared.IntervalSeries(stats.Intervals, origin, interval, n)
+		c.output("%s", label)
+		writer := tabwriter.NewWriter(c.Out, 0, 0, 1, ' ', tabwriter.TabIndent)
+		_, _ = fmt.Fprintf(writer, "visitors\t%d\t%s\r\n", stats.Visitors, shared.Sparkline(visitors))
+		_, _ = fmt.Fprintf(
+			writer,
+			"reads\t%d\t%s %.1f%%\r\n",
+			stats.ReadThroughs,
+			shared.Sparkline(reads),
+			stats.ReadThroughRate()*100,
+		)
+		err := writer.Flush()
+		c.output("")
+		return err
+	}
+	err = trend(
+		fmt.Sprintf("last 30 days (%s to %s)", dayOrigin.Format(time.DateOnly), now.Format(time.DateOnly)),
+		days, dayOrigin, "day", 30,
+	)
+	if err != nil {
+		return err
+	}
+	err = trend(
+		fmt.Sprintf("last 12 months (%s to %s)", monthOrigin.Format("2006-01"), now.Format("2006-01")),
+		months, monthOrigin, "month", 12,
+	)
+	if err != nil {
+		return err
+	}
+
+	c.output("top referers")
+	if len(months.TopReferers) == 0 {
+		c.output("none")
+		return nil
+	}
+	writer := tabwriter.NewWriter(c.Out, 0, 0, 1, ' ', tabwriter.TabIndent)
+	for _, ref := range months.TopReferers {
+		_, _ = fmt.Fprintf(writer, "%s\t%d\r\n", ref.Url, ref.Count)
+	}
+	return writer.Flush()
+}
+
+/*
+StatsMiddleware handles `stats`, it prints the analytics of every post or
+the breakdown of a single post:
+
+	ssh prose.sh stats
+	ssh prose.sh stats hello-world
+*/
+func StatsMiddleware(dbpool db.DB, cfg *shared.ConfigSite) pssh.SSHServerMiddleware {
+	return func(next pssh.SSHServerHandler) pssh.SSHServerHandler {
+		return func(sesh *pssh.SSHServerConnSession) error {
+			args := sesh.Command()
+			if len(args) == 0 || len(args) > 2 || args[0] != "stats" {
+				return next(sesh)
+			}
+
+			logger := pssh.GetLogger(sesh)
+			user := pssh.GetUser(sesh)
+			if user == nil {
+				err := fmt.Errorf("user not found")
+				_, _ = fmt.Fprintln(sesh.Stderr(), err)
+				return err
+			}
+			logger = shared.LoggerWithUser(logger, user)
+
+			cmd := &StatsCmd{
+				Cfg:  cfg,
+				Db:   dbpool,
+				User: user,
+				Out:  sesh,
+			}
+
+			var err error
+			if len(args) == 2 {
+				logger.Info("stats cmd", "post", args[1])
+				err = cmd.Post(args[1], time.Now())
+			} else {
+				logger.Info("stats cmd")
+				err = cmd.List(time.Now())
+			}
+
+			if err != nil {
+				logger.Error("stats cmd", "err", err.Error())
+				_, _ = fmt.Fprintln(sesh.Stderr(), err)
+			}
+			return err
+		}
+	}
+}
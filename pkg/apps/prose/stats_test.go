@@ -0,0 +1,124 @@
+package prose
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+)
+
+type statsDB struct {
+	*TestDB
+	stats *db.PostAnalytics
+	list  []*db.PostAnalytics
+}
+
+func (s *statsDB) PostVisitSummary(opts *db.SummaryOpts) (*db.PostAnalytics, error) {
+	return s.stats, nil
+}
+
+func (s *statsDB) FindVisitPostList(opts *db.SummaryOpts) ([]*db.PostAnalytics, error) {
+	return s.list, nil
+}
+
+func TestStatsCmd(t *testing.T) {
+	ft := newProseTest(t)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	first := lastDays(now)
+	last := first.AddDate(0, 0, 29)
+	dbpool := &statsDB{
+		TestDB: ft.dbpool,
+		stats: &db.PostAnalytics{
+			Slug:         "hello",
+			Views:        42,
+			Visitors:     12,
+			ReadThroughs: 3,
+			Intervals: []*db.VisitInterval{
+				{Interval: &first, Visitors: 4, ReadThroughs: 1},
+				{Interval: &last, Visitors: 8, ReadThroughs: 2},
+			},
+			TopReferers: []*db.VisitUrl{{Url: "lobste.rs", Count: 5}},
+		},
+		list: []*db.PostAnalytics{{Slug: "hello", Visitors: 12, ReadThroughs: 3, Views: 42}},
+	}
+
+	out := &bytes.Buffer{}
+	cmd := &StatsCmd{Cfg: ft.fed.Cfg, Db: dbpool, User: ft.user, Out: out}
+
+	if err := cmd.Post("nope", now); err == nil {
+		t.Fatal("expected missing post to fail")
+	}
+
+	if err := cmd.Post("hello", now); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"hello: Hello",
+		"views: 42",
+		"analytics are disabled",
+		"last 30 days (2026-09-20 to 2026-10-19)",
+		"▅" + strings.Repeat("▁", 28) + "█",
+		"25.0%",
+		"lobste.rs",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+
+	out.Reset()
+	ft.dbpool.Features = append(ft.dbpool.Features, ft.user.ID+":analytics")
+	if err := cmd.List(now); err != nil {
+		t.Fatal(err)
+	}
+	got = out.String()
+	if strings.Contains(got, "analytics are disabled") {
+		t.Errorf("did not expect notice, got %s", got)
+	}
+	if !strings.Contains(got, "hello") || !strings.Contains(got, "25.0%") {
+		t.Errorf("unexpected list %s", got)
+	}
+}
+
+func TestReadThroughHandler(t *testing.T) {
+	t.Chdir("../../..")
+	ft := newProseTest(t)
+
+	read := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0")
+		return ft.do(req)
+	}
+
+	rec := read("http://erock.prose.test/_read/hello")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/gif" {
+		t.Fatalf("expected pixel, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if len(ft.dbpool.Reads) != 0 {
+		t.Fatal("reads should not be recorded without analytics")
+	}
+	if strings.Contains(ft.do(httptest.NewRequest("GET", ft.postURL, nil)).Body.String(), "/_read/hello") {
+		t.Error("pixel should not be rendered without analytics")
+	}
+
+	ft.dbpool.Features = append(ft.dbpool.Features, ft.user.ID+":analytics")
+	body := ft.do(httptest.NewRequest("GET", ft.postURL, nil)).Body.String()
+	if !strings.Contains(body, `src="http://erock.prose.test/_read/hello"`) {
+		t.Errorf("expected read-through pixel, got %s", body)
+	}
+
+	read("http://erock.prose.test/_read/hello")
+	read("http://erock.prose.test/_read/nope")
+	if len(ft.dbpool.Reads) != 1 {
+		t.Fatalf("expected one read, got %d", len(ft.dbpool.Reads))
+	}
+	got := ft.dbpool.Reads[0]
+	if got.PostID != ft.post.ID || got.Host != "erock.prose.test" || got.IpAddress == "" || strings.HasPrefix(got.IpAddress, "192.0.2") {
+		t.Errorf("unexpected read %+v", got)
+	}
+}
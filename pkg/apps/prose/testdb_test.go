@@ -0,0 +1,591 @@
+package prose
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/picosh/pico/pkg/activitypub"
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/db/stub"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/shared/router"
+)
+
+// TestDB is an in-memory db.DB shared by the prose tests.
+type TestDB struct {
+	*stub.StubDB
+	mu        sync.Mutex
+	Users     []*db.User
+	Posts     []*db.Post
+	Keys      []*db.FederationKey
+	Followers []*db.Follower
+	Mentions  []*db.PostMention
+	Comments  []*db.PostComment
+	Features  []string
+	Reads     []*db.AnalyticsVisits
+
+	Subscribers []*db.NewsletterSubscriber
+	Issues      []*db.NewsletterPost
+}
+
+func NewTestDB(logger *slog.Logger) *TestDB {
+	return &TestDB{StubDB: stub.NewStubDB(logger)}
+}
+
+func (t *TestDB) FindUserByName(name string) (*db.User, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, user := range t.Users {
+		if user.Name == name {
+			return user, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (t *TestDB) FindPostWithSlug(slug, userID, space string) (*db.Post, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, post := range t.Posts {
+		if post.Slug == slug && post.UserID == userID && post.Space == space {
+			return post, nil
+		}
+	}
+	return nil, fmt.Errorf("post not found")
+}
+
+func (t *TestDB) FindPostWithFilename(filename, userID, space string) (*db.Post, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, post := range t.Posts {
+		if post.Filename == filename && post.UserID == userID && post.Space == space {
+			return post, nil
+		}
+	}
+	return nil, fmt.Errorf("post not found")
+}
+
+func (t *TestDB) FindUser(userID string) (*db.User, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, user := range t.Users {
+		if user.ID == userID {
+			return user, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (t *TestDB) FindPost(postID string) (*db.Post, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, post := range t.Posts {
+		if post.ID == postID {
+			return post, nil
+		}
+	}
+	return nil, fmt.Errorf("post not found")
+}
+
+func (t *TestDB) UpdatePost(post *db.Post) (*db.Post, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, p := range t.Posts {
+		if p.ID == post.ID {
+			t.Posts[i] = post
+			return post, nil
+		}
+	}
+	return nil, fmt.Errorf("post not found")
+}
+
+func (t *TestDB) FindScheduledPosts(space string, now time.Time) ([]*db.Post, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	posts := []*db.Post{}
+	for _, post := range t.Posts {
+		if post.Space == space && post.Data.Scheduled && !post.PublishAt.After(now) {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+func (t *TestDB) ClaimScheduledPost(postID string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, post := range t.Posts {
+		if post.ID == postID && post.Data.Scheduled {
+			post.Data.Scheduled = false
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (t *TestDB) FindPostsByUser(page *db.Pager, userID, space string) (*db.Paginate[*db.Post], error) {
+	return t.FindUserPostsByTag(page, "", userID, space)
+}
+
+func (t *TestDB) FindUserPostsByTag(page *db.Pager, tag, userID, space string) (*db.Paginate[*db.Post], error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	posts := []*db.Post{}
+	for _, post := range t.Posts {
+		if post.UserID != userID || post.Space != space || post.Hidden {
+			continue
+		}
+		if tag == "" || slices.Contains(post.Tags, tag) {
+			posts = append(posts, post)
+		}
+	}
+	return paginate(page, posts), nil
+}
+
+// paginate cuts a page out of posts, Total is the number of pages like it
+// is for the postgres db.
+func paginate(page *db.Pager, posts []*db.Post) *db.Paginate[*db.Post] {
+	if page == nil || page.Num <= 0 {
+		return &db.Paginate[*db.Post]{Data: posts, Total: 1}
+	}
+	total := (len(posts) + page.Num - 1) / page.Num
+	start := min(page.Page*page.Num, len(posts))
+	end := min(start+page.Num, len(posts))
+	return &db.Paginate[*db.Post]{Data: posts[start:end], Total: total}
+}
+
+func (t *TestDB) FindUserPostsByLang(page *db.Pager, lang, userID, space string) (*db.Paginate[*db.Post], error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	posts := []*db.Post{}
+	for _, post := range t.Posts {
+		if post.UserID != userID || post.Space != space || post.Hidden {
+			continue
+		}
+		if post.Data.Lang == "" || primaryLang(post.Data.Lang) == primaryLang(lang) {
+			posts = append(posts, post)
+		}
+	}
+	return &db.Paginate[*db.Post]{Data: posts, Total: len(posts)}, nil
+}
+
+func (t *TestDB) FindPostsBySeries(userID, series, space string) ([]*db.Post, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	posts := []*db.Post{}
+	for _, post := range t.Posts {
+		if post.UserID == userID && post.Space == space && post.Data.Series == series && !post.Hidden {
+			posts = append(posts, post)
+		}
+	}
+	slices.SortFunc(posts, func(a, b *db.Post) int {
+		return a.PublishAt.Compare(*b.PublishAt)
+	})
+	return posts, nil
+}
+
+func (t *TestDB) InsertPostComment(comment *db.PostComment) (*db.PostComment, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	comment.ID = fmt.Sprintf("comment-%d", len(t.Comments)+1)
+	comment.CreatedAt = &now
+	for _, user := range t.Users {
+		if user.ID == comment.UserID {
+			comment.Username = user.Name
+		}
+	}
+	for _, post := range t.Posts {
+		if post.ID == comment.PostID {
+			comment.PostSlug = post.Slug
+		}
+	}
+	t.Comments = append(t.Comments, comment)
+	return comment, nil
+}
+
+func (t *TestDB) FindPostComments(postID string) ([]*db.PostComment, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	comments := []*db.PostComment{}
+	for _, comment := range t.Comments {
+		if comment.PostID == postID {
+			comments = append(comments, comment)
+		}
+	}
+	return comments, nil
+}
+
+func (t *TestDB) ownsComment(ownerID, commentID string) (int, bool) {
+	for i, comment := range t.Comments {
+		if comment.ID != commentID {
+			continue
+		}
+		for _, post := range t.Posts {
+			if post.ID == comment.PostID && post.UserID == ownerID {
+				return i, true
+			}
+		}
+	}
+	return -1, false
+}
+
+func (t *TestDB) FindPostCommentsByOwner(ownerID, space string) ([]*db.PostComment, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	comments := []*db.PostComment{}
+	for _, comment := range t.Comments {
+		if _, ok := t.ownsComment(ownerID, comment.ID); ok {
+			comments = append(comments, comment)
+		}
+	}
+	return comments, nil
+}
+
+func (t *TestDB) ApprovePostComment(ownerID, commentID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i, ok := t.ownsComment(ownerID, commentID)
+	if !ok {
+		return fmt.Errorf("comment %s not found", commentID)
+	}
+	t.Comments[i].Approved = true
+	return nil
+}
+
+func (t *TestDB) RemovePostComment(ownerID, commentID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i, ok := t.ownsComment(ownerID, commentID)
+	if !ok {
+		return fmt.Errorf("comment %s not found", commentID)
+	}
+	t.Comments = slices.Delete(t.Comments, i, i+1)
+	return nil
+}
+
+func (t *TestDB) HasFeatureByUser(userID, feature string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Contains(t.Features, userID+":"+feature)
+}
+
+func (t *TestDB) InsertPostRead(read *db.AnalyticsVisits) error {
+	t.mu.Lock()
+	t.Reads = append(t.Reads, read)
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *TestDB) UpsertNewsletterSubscriber(sub *db.NewsletterSubscriber) (*db.NewsletterSubscriber, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for _, existing := range t.Subscribers {
+		if existing.UserID == sub.UserID && existing.Email == sub.Email {
+			existing.Token = sub.Token
+			existing.Status = sub.Status
+			existing.Bounces = sub.Bounces
+			existing.ConfirmedAt = sub.ConfirmedAt
+			existing.UpdatedAt = &now
+			updated := *existing
+			return &updated, nil
+		}
+	}
+	created := *sub
+	created.ID = fmt.Sprintf("sub-%d", len(t.Subscribers)+1)
+	created.CreatedAt = &now
+	created.UpdatedAt = &now
+	t.Subscribers = append(t.Subscribers, &created)
+	found := created
+	return &found, nil
+}
+
+func (t *TestDB) findSubscribers(match func(sub *db.NewsletterSubscriber) bool) []*db.NewsletterSubscriber {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	subs := []*db.NewsletterSubscriber{}
+	for _, sub := range t.Subscribers {
+		if match(sub) {
+			found := *sub
+			subs = append(subs, &found)
+		}
+	}
+	return subs
+}
+
+func (t *TestDB) FindNewsletterSubscriber(userID, email string) (*db.NewsletterSubscriber, error) {
+	subs := t.findSubscribers(func(sub *db.NewsletterSubscriber) bool {
+		return sub.UserID == userID && sub.Email == email
+	})
+	if len(subs) == 0 {
+		return nil, fmt.Errorf("subscriber not found")
+	}
+	return subs[0], nil
+}
+
+func (t *TestDB) FindNewsletterSubscriberByToken(token string) (*db.NewsletterSubscriber, error) {
+	subs := t.findSubscribers(func(sub *db.NewsletterSubscriber) bool {
+		return sub.Token == token
+	})
+	if len(subs) == 0 {
+		return nil, fmt.Errorf("subscriber not found")
+	}
+	return subs[0], nil
+}
+
+func (t *TestDB) FindNewsletterSubscribersByEmail(email string) ([]*db.NewsletterSubscriber, error) {
+	return t.findSubscribers(func(sub *db.NewsletterSubscriber) bool {
+		return sub.Email == email
+	}), nil
+}
+
+func (t *TestDB) FindNewsletterSubscribers(userID, status string) ([]*db.NewsletterSubscriber, error) {
+	return t.findSubscribers(func(sub *db.NewsletterSubscriber) bool {
+		return sub.UserID == userID && sub.Status == status
+	}), nil
+}
+
+func (t *TestDB) InsertNewsletterPost(userID, postID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, issue := range t.Issues {
+		if issue.PostID == postID {
+			return nil
+		}
+	}
+	now := time.Now()
+	t.Issues = append(t.Issues, &db.NewsletterPost{
+		ID:        fmt.Sprintf("issue-%d", len(t.Issues)+1),
+		UserID:    userID,
+		PostID:    postID,
+		CreatedAt: &now,
+	})
+	return nil
+}
+
+func (t *TestDB) FindPendingNewsletterPosts() ([]*db.NewsletterPost, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pending := []*db.NewsletterPost{}
+	for _, issue := range t.Issues {
+		if issue.SentAt == nil {
+			found := *issue
+			pending = append(pending, &found)
+		}
+	}
+	return pending, nil
+}
+
+func (t *TestDB) MarkNewsletterPostsSent(ids []string, sentAt time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, issue := range t.Issues {
+		if slices.Contains(ids, issue.ID) {
+			issue.SentAt = &sentAt
+		}
+	}
+	return nil
+}
+
+func (t *TestDB) FindFederationKey(userID string) (*db.FederationKey, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range t.Keys {
+		if key.UserID == userID {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("key not found")
+}
+
+func (t *TestDB) InsertFederationKey(userID, pubkey, privkey string) (*db.FederationKey, error) {
+	t.mu.Lock()
+	t.Keys = append(t.Keys, &db.FederationKey{UserID: userID, PublicKey: pubkey, PrivateKey: privkey})
+	t.mu.Unlock()
+	return t.FindFederationKey(userID)
+}
+
+func (t *TestDB) UpsertFollower(userID, actor, inbox string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, follower := range t.Followers {
+		if follower.UserID == userID && follower.Actor == actor {
+			follower.Inbox = inbox
+			return nil
+		}
+	}
+	t.Followers = append(t.Followers, &db.Follower{UserID: userID, Actor: actor, Inbox: inbox})
+	return nil
+}
+
+func (t *TestDB) RemoveFollower(userID, actor string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	followers := []*db.Follower{}
+	for _, follower := range t.Followers {
+		if follower.UserID != userID || follower.Actor != actor {
+			followers = append(followers, follower)
+		}
+	}
+	t.Followers = followers
+	return nil
+}
+
+func (t *TestDB) FindFollowersByUser(userID string) ([]*db.Follower, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	followers := []*db.Follower{}
+	for _, follower := range t.Followers {
+		if follower.UserID == userID {
+			followers = append(followers, follower)
+		}
+	}
+	return followers, nil
+}
+
+func (t *TestDB) UpsertPostMention(mention *db.PostMention) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, m := range t.Mentions {
+		if m.PostID == mention.PostID && m.Source == mention.Source {
+			t.Mentions[i] = mention
+			return nil
+		}
+	}
+	now := time.Now()
+	mention.CreatedAt = &now
+	t.Mentions = append(t.Mentions, mention)
+	return nil
+}
+
+func (t *TestDB) removeMentions(keep func(m *db.PostMention) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	mentions := []*db.PostMention{}
+	for _, m := range t.Mentions {
+		if keep(m) {
+			mentions = append(mentions, m)
+		}
+	}
+	t.Mentions = mentions
+}
+
+func (t *TestDB) RemovePostMention(postID, source string) error {
+	t.removeMentions(func(m *db.PostMention) bool {
+		return m.PostID != postID || m.Source != source
+	})
+	return nil
+}
+
+func (t *TestDB) RemovePostMentionsBySource(source string) error {
+	t.removeMentions(func(m *db.PostMention) bool {
+		return m.Source != source
+	})
+	return nil
+}
+
+func (t *TestDB) FindPostMentions(postID string) ([]*db.PostMention, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	mentions := []*db.PostMention{}
+	for _, m := range t.Mentions {
+		if m.PostID == postID {
+			mentions = append(mentions, m)
+		}
+	}
+	return mentions, nil
+}
+
+// proseTest serves a blog with one user and post backed by TestDB along
+// with a fake fediverse server and mailer.
+type proseTest struct {
+	dbpool  *TestDB
+	fed     *Federation
+	serve   http.HandlerFunc
+	user    *db.User
+	post    *db.Post
+	postURL string
+	remote  *fakeRemote
+	nl      *Newsletter
+	mailer  *fakeMailer
+}
+
+func newProseTest(t *testing.T) *proseTest {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &shared.ConfigSite{
+		Domain:   "prose.test",
+		Protocol: "http",
+		Space:    "prose",
+		Logger:   logger,
+	}
+	dbpool := NewTestDB(logger)
+	user := &db.User{ID: "user-1", Name: "erock"}
+	dbpool.Users = append(dbpool.Users, user)
+
+	publishAt := time.Now().Add(-time.Hour)
+	post := &db.Post{
+		ID:        "post-1",
+		UserID:    user.ID,
+		Filename:  "hello.md",
+		Slug:      "hello",
+		Title:     "Hello",
+		Text:      "# Hello\n\nfrom prose",
+		Space:     "prose",
+		PublishAt: &publishAt,
+		UpdatedAt: &publishAt,
+	}
+	dbpool.Posts = append(dbpool.Posts, post)
+
+	fed := NewFederation(cfg, dbpool)
+	remote := newFakeRemote(t)
+	// the fake fediverse runs on loopback which the public client refuses
+	fed.Client.HTTP = remote.srv.Client()
+	remote.fetchKey = func(keyID string) (*rsa.PublicKey, error) {
+		if keyID != fed.KeyID(user.Name) {
+			return nil, fmt.Errorf("unknown key %s", keyID)
+		}
+		actor, err := fed.Actor(user)
+		if err != nil {
+			return nil, err
+		}
+		return activitypub.ParsePublicKey(actor.PublicKey.PublicKeyPem)
+	}
+
+	mailer := &fakeMailer{}
+	nl := NewNewsletter(cfg, dbpool)
+	nl.Mailer = mailer
+
+	apiConfig := &router.ApiConfig{Cfg: cfg, Dbpool: dbpool}
+	serve := router.CreateServe(
+		createMainRoutes(nil, fed, nl),
+		createSubdomainRoutes(nil, fed, nl),
+		apiConfig,
+	)
+
+	return &proseTest{
+		dbpool:  dbpool,
+		fed:     fed,
+		serve:   serve,
+		user:    user,
+		post:    post,
+		postURL: "http://erock.prose.test/hello",
+		remote:  remote,
+		nl:      nl,
+		mailer:  mailer,
+	}
+}
+
+func (ft *proseTest) do(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	ft.serve(rec, req)
+	return rec
+}
@@ -0,0 +1,517 @@
+package prose
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template/parse"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/shared/router"
+	"golang.org/x/net/html"
+)
+
+const (
+	// themeDir is where users upload the template overrides of their blog.
+	themeDir = "_theme/"
+	// maxThemeSize is the most bytes a theme file can have.
+	maxThemeSize = 64 * 1024
+	// maxThemeCalls is how many templates a page can expand into, it keeps
+	// themes from nesting template calls until rendering never finishes.
+	maxThemeCalls = 1000
+	// maxThemeOutput is the largest page a theme can render.
+	maxThemeOutput = 10 * 1024 * 1024
+)
+
+// ThemeFiles are the pages a theme can override.  The tag page is the blog
+// index filtered by a tag and uses the blog override when there is no tag
+// override.
+var ThemeFiles = []string{"blog.html", "post.html", "tag.html"}
+
+// BundledThemes can be selected with the theme key of _readme.md.
+var BundledThemes = []string{"journal", "minimal"}
+
+// themeBlockedTags are the elements that would let a theme run code on the
+// blog, posts are sanitized so themes must not bring them back.
+var themeBlockedTags = []string{"script", "iframe", "object", "embed"}
+
+// themeCSP is sent with themed pages in case a theme finds a way to smuggle
+// a script past checkThemeHTML.
+const themeCSP = "script-src 'none'"
+
+/*
+ThemeFuncs are the only functions themes can call besides the builtins of
+html/template and the functions available to the built-in templates.
+Nothing in here can reach outside of the page data.
+*/
+var ThemeFuncs = template.FuncMap{
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"trim":      strings.TrimSpace,
+	"join":      strings.Join,
+	"contains":  strings.Contains,
+	"hasPrefix": strings.HasPrefix,
+	"hasSuffix": strings.HasSuffix,
+	"truncate":  truncate,
+}
+
+func truncate(n int, text string) string {
+	runes := []rune(text)
+	if n < 0 || len(runes) <= n {
+		return text
+	}
+	return string(runes[:n]) + "..."
+}
+
+var errThemeOutput = fmt.Errorf("theme rendered more than %d bytes", maxThemeOutput)
+
+type themeSource struct {
+	Name string
+	Text string
+	// Version changes whenever an uploaded theme file does, bundled theme
+	// files only change with a deploy and leave it empty.
+	Version string
+}
+
+// parsedTheme is the outcome of parsing a theme, a broken theme keeps its
+// error so it is not parsed again on every page view.
+type parsedTheme struct {
+	ts  *template.Template
+	err error
+}
+
+// themeCache holds parsed themes so a blog's theme is only parsed again
+// once one of its files changed.
+var themeCache = expirable.NewLRU[string, *parsedTheme](2048, nil, shared.CacheTimeout)
+
+// themeCacheKey identifies the theme of a page by the version of each of
+// its files.
+func themeCacheKey(userID, theme, page string, sources []themeSource) string {
+	key := []string{userID, theme, page}
+	for _, src := range sources {
+		key = append(key, src.Name+"@"+src.Version)
+	}
+	return strings.Join(key, "|")
+}
+
+// pageTemplates are the built-in templates of a page a theme overrides.
+func pageTemplates(cfg *shared.ConfigSite, page string) []string {
+	if page == "post.html" {
+		return []string{
+			cfg.StaticPath("html/list.partial.tmpl"),
+			cfg.StaticPath("html/post.page.tmpl"),
+		}
+	}
+	return []string{
+		cfg.StaticPath("html/newsletter.partial.tmpl"),
+		cfg.StaticPath("html/blog-default.partial.tmpl"),
+		cfg.StaticPath("html/blog-aside.partial.tmpl"),
+		cfg.StaticPath("html/blog.page.tmpl"),
+	}
+}
+
+// themeOverrides lists the theme files used for a page, later files take
+// precedence.
+func themeOverrides(page string) []string {
+	if page == "tag.html" {
+		return []string{"blog.html", "tag.html"}
+	}
+	return []string{page}
+}
+
+// themeSources loads the overrides of a page, the bundled theme selected in
+// _readme.md comes first so uploaded theme files can refine it.
+func themeSources(dbpool db.DB, cfg *shared.ConfigSite, userID, theme, page string) []themeSource {
+	sources := []themeSource{}
+	for _, name := range themeOverrides(page) {
+		if slices.Contains(BundledThemes, theme) {
+			fp := cfg.StaticPath(filepath.Join("html", "themes", theme, name))
+			text, err := os.ReadFile(fp)
+			if err == nil {
+				sources = append(sources, themeSource{Name: fp, Text: string(text)})
+			}
+		}
+
+		post, err := dbpool.FindPostWithFilename(themeDir+name, userID, cfg.Space)
+		if err == nil {
+			version := post.Shasum
+			if post.UpdatedAt != nil {
+				version = fmt.Sprintf("%d:%s", post.UpdatedAt.UnixNano(), post.Shasum)
+			}
+			sources = append(sources, themeSource{Name: post.Filename, Text: post.Text, Version: version})
+		}
+	}
+	return sources
+}
+
+// walkTheme calls fn for every node of a template, descending into the
+// branches of if, range and with actions.
+func walkTheme(node parse.Node, fn func(parse.Node) error) error {
+	if node == nil {
+		return nil
+	}
+	err := fn(node)
+	if err != nil {
+		return err
+	}
+
+	var branch *parse.BranchNode
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			err := walkTheme(child, fn)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.IfNode:
+		branch = &n.BranchNode
+	case *parse.RangeNode:
+		branch = &n.BranchNode
+	case *parse.WithNode:
+		branch = &n.BranchNode
+	default:
+		return nil
+	}
+
+	if branch.List != nil {
+		err := walkTheme(branch.List, fn)
+		if err != nil {
+			return err
+		}
+	}
+	if branch.ElseList != nil {
+		return walkTheme(branch.ElseList, fn)
+	}
+	return nil
+}
+
+// isBoundedRange reports whether a range loops over page data, ranging over
+// numbers or function results could loop for as long as a theme wants.
+func isBoundedRange(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode:
+		return true
+	case *parse.VariableNode:
+		return len(arg.Ident) > 1
+	}
+	return false
+}
+
+// checkThemeSource rejects the template constructs that are not allowed in
+// themes.
+func checkThemeSource(src themeSource) error {
+	tree := parse.New(src.Name)
+	tree.Mode = parse.SkipFuncCheck
+	trees := map[string]*parse.Tree{}
+	_, err := tree.Parse(src.Text, "", "", trees)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range trees {
+		err := walkTheme(t.Root, func(node parse.Node) error {
+			rng, ok := node.(*parse.RangeNode)
+			if !ok || isBoundedRange(rng.Pipe) {
+				return nil
+			}
+			location, _ := t.ErrorContext(node)
+			return fmt.Errorf("template: %s: range can only loop over page data, like .Posts", location)
+		})
+		if err != nil {
+			return err
+		}
+
+		err = checkThemeHTML(t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+checkThemeHTML rejects markup that runs code: script, iframe, object and
+embed elements, event handler attributes and javascript: urls.  Only the
+literal text of a template is checked, html/template already keeps page
+data from adding any of them.  The text around actions is joined so a tag
+cannot be split by an action to get past the check.
+*/
+func checkThemeHTML(t *parse.Tree) error {
+	text := &strings.Builder{}
+	_ = walkTheme(t.Root, func(node parse.Node) error {
+		if txt, ok := node.(*parse.TextNode); ok {
+			text.Write(txt.Text)
+		}
+		return nil
+	})
+
+	z := html.NewTokenizer(strings.NewReader(text.String()))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			tag := strings.ToLower(token.Data)
+			if slices.Contains(themeBlockedTags, tag) {
+				return fmt.Errorf("template: %s: <%s> elements are not allowed in themes", t.ParseName, tag)
+			}
+			for _, attr := range token.Attr {
+				key := strings.ToLower(attr.Key)
+				if strings.HasPrefix(key, "on") {
+					return fmt.Errorf("template: %s: <%s %s> event handlers are not allowed in themes", t.ParseName, tag, key)
+				}
+				value := strings.ToLower(strings.Join(strings.FieldsFunc(attr.Val, isURLSpace), ""))
+				if strings.HasPrefix(value, "javascript:") {
+					return fmt.Errorf("template: %s: <%s %s> javascript urls are not allowed in themes", t.ParseName, tag, key)
+				}
+			}
+		}
+	}
+}
+
+// isURLSpace reports whether browsers drop r when they parse a url.
+func isURLSpace(r rune) bool {
+	return r <= ' '
+}
+
+// checkThemeCalls makes sure the templates of a page expand into a bounded
+// number of template calls.
+func checkThemeCalls(ts *template.Template) error {
+	trees := map[string]*parse.Tree{}
+	for _, t := range ts.Templates() {
+		if t.Tree != nil {
+			trees[t.Name()] = t.Tree
+		}
+	}
+
+	calls := map[string]int{}
+	visiting := map[string]bool{}
+	var count func(name string) (int, error)
+	count = func(name string) (int, error) {
+		if n, ok := calls[name]; ok {
+			return n, nil
+		}
+		tree, ok := trees[name]
+		if !ok {
+			return 1, nil
+		}
+		if visiting[name] {
+			return 0, fmt.Errorf("template: %s: calls itself", name)
+		}
+
+		visiting[name] = true
+		total := 1
+		err := walkTheme(tree.Root, func(node parse.Node) error {
+			call, ok := node.(*parse.TemplateNode)
+			if !ok {
+				return nil
+			}
+			n, err := count(call.Name)
+			total += n
+			return err
+		})
+		visiting[name] = false
+		if err != nil {
+			return 0, err
+		}
+		if total > maxThemeCalls {
+			return 0, fmt.Errorf("template: %s: expands into more than %d templates", name, maxThemeCalls)
+		}
+		calls[name] = total
+		return total, nil
+	}
+
+	for name := range trees {
+		_, err := count(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseTheme adds the theme sources to the built-in templates of a page.
+func parseTheme(cfg *shared.ConfigSite, page string, sources []themeSource) (*template.Template, error) {
+	ts, err := router.RenderTemplate(cfg, pageTemplates(cfg, page))
+	if err != nil {
+		return nil, err
+	}
+	ts = ts.Funcs(ThemeFuncs)
+
+	for _, src := range sources {
+		err := checkThemeSource(src)
+		if err != nil {
+			return nil, err
+		}
+		_, err = ts.New(src.Name).Parse(src.Text)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = checkThemeCalls(ts)
+	if err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+type themeWriter struct {
+	bytes.Buffer
+}
+
+func (w *themeWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > maxThemeOutput {
+		return 0, errThemeOutput
+	}
+	return w.Buffer.Write(p)
+}
+
+func executeTheme(ts *template.Template, data any) ([]byte, error) {
+	out := &themeWriter{}
+	err := ts.Execute(out, data)
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// themeSample is page data for trying out a theme before it is saved.
+func themeSample(cfg *shared.ConfigSite, page string) any {
+	post := PostItemData{
+		URL:          "/sample",
+		BlogURL:      "/",
+		Username:     "sample",
+		Title:        "Sample post",
+		Description:  "a sample post",
+		PublishAt:    "2026-01-02",
+		PublishAtISO: "2026-01-02T00:00:00Z",
+	}
+
+	if page == "post.html" {
+		return PostPageData{
+			Site:        *cfg.GetSiteData(),
+			PageTitle:   post.Title,
+			URL:         post.URL,
+			BlogURL:     post.BlogURL,
+			BlogName:    "sample's blog",
+			Slug:        "sample",
+			Title:       post.Title,
+			Description: post.Description,
+			Username:    post.Username,
+			Contents:    "<p>sample</p>",
+			PublishAt:   post.PublishAt,
+			Tags:        []string{"sample"},
+			Mentions:    []MentionData{{Kind: db.MentionReply, AuthorName: "reader"}},
+			Series:      &SeriesNavData{Name: "sample", Index: 2, Total: 3, Prev: &post, Next: &post},
+			CommentsOn:  true,
+			Comments:    []CommentData{{Username: "reader", Text: "nice"}},
+		}
+	}
+
+	return BlogPageData{
+		Site:      *cfg.GetSiteData(),
+		PageTitle: "sample's blog",
+		URL:       "/",
+		Username:  post.Username,
+		Readme:    &ReadmeTxt{HasText: true, Contents: "<p>sample</p>"},
+		Header: &HeaderTxt{
+			Title:  "sample's blog",
+			Bio:    "a sample blog",
+			Nav:    []shared.Link{{URL: "/about", Text: "about"}},
+			Layout: "default",
+		},
+		Posts:     []PostItemData{post},
+		HasFilter: page == "tag.html",
+	}
+}
+
+// ValidateTheme parses a theme file and renders a sample page with it so
+// broken themes are rejected at upload time.
+func ValidateTheme(cfg *shared.ConfigSite, filename, text string) error {
+	page := strings.TrimPrefix(filename, themeDir)
+	if !slices.Contains(ThemeFiles, page) {
+		return fmt.Errorf(
+			"theme file must be one of %s%s",
+			themeDir,
+			strings.Join(ThemeFiles, ", "+themeDir),
+		)
+	}
+
+	ts, err := parseTheme(cfg, page, []themeSource{{Name: filename, Text: text}})
+	if err != nil {
+		return err
+	}
+	_, err = executeTheme(ts, themeSample(cfg, page))
+	return err
+}
+
+/*
+renderPage renders a page of a blog with its theme.  Themes are user code,
+so when one fails to render the built-in templates are used instead and
+the error is only logged.
+*/
+func renderPage(w http.ResponseWriter, r *http.Request, userID, theme, page string, data any) {
+	cfg := router.GetCfg(r)
+	dbpool := router.GetDB(r)
+	logger := router.GetLogger(r)
+
+	sources := themeSources(dbpool, cfg, userID, theme, page)
+	if len(sources) > 0 {
+		key := themeCacheKey(userID, theme, page, sources)
+		parsed, ok := themeCache.Get(key)
+		if !ok {
+			ts, err := parseTheme(cfg, page, sources)
+			parsed = &parsedTheme{ts: ts, err: err}
+			themeCache.Add(key, parsed)
+		}
+		err := parsed.err
+		if err == nil {
+			var out []byte
+			out, err = executeTheme(parsed.ts, data)
+			if err == nil {
+				w.Header().Set("Content-Security-Policy", themeCSP)
+				_, err = w.Write(out)
+				if err != nil {
+					logger.Error("write to response writer", "err", err.Error())
+				}
+				return
+			}
+		}
+		if errors.Is(err, errThemeOutput) {
+			logger.Info("theme", "err", err.Error())
+		} else {
+			logger.Info("theme failed, using built-in templates", "err", err.Error(), "page", page)
+		}
+	}
+
+	ts, err := router.RenderTemplate(cfg, pageTemplates(cfg, page))
+	if err != nil {
+		logger.Error("render template", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = ts.Execute(w, data)
+	if err != nil {
+		logger.Error("template execute", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,218 @@
+package prose
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/filehandlers"
+	"github.com/picosh/pico/pkg/pssh"
+	sendutils "github.com/picosh/pico/pkg/send/utils"
+	"github.com/picosh/pico/pkg/shared"
+)
+
+// ThemeHandler stores the template overrides uploaded to _theme/.
+type ThemeHandler struct {
+	DBPool db.DB
+	Cfg    *shared.ConfigSite
+}
+
+var _ filehandlers.ReadWriteHandler = (*ThemeHandler)(nil)
+
+func NewThemeHandler(dbpool db.DB, cfg *shared.ConfigSite) *ThemeHandler {
+	return &ThemeHandler{
+		DBPool: dbpool,
+		Cfg:    cfg,
+	}
+}
+
+// themeFilename turns an upload path into the filename of a theme file,
+// theme files keep their directory unlike other posts.
+func themeFilename(fpath string) string {
+	return strings.TrimPrefix(filepath.Clean("/"+fpath), "/")
+}
+
+func (h *ThemeHandler) user(s *pssh.SSHServerConnSession) (*db.User, error) {
+	user := pssh.GetUser(s)
+	if user == nil {
+		err := fmt.Errorf("could not get user from ctx")
+		pssh.GetLogger(s).Error("error getting user from ctx", "err", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+func (h *ThemeHandler) List(s *pssh.SSHServerConnSession, fpath string, isDir bool, recursive bool) ([]os.FileInfo, error) {
+	user, err := h.user(s)
+	if err != nil {
+		return nil, err
+	}
+
+	name := themeFilename(fpath)
+	fileList := []os.FileInfo{}
+	names := []string{}
+	if name+"/" == themeDir {
+		fileList = append(fileList, &sendutils.VirtualFile{
+			FName:  strings.TrimSuffix(themeDir, "/"),
+			FIsDir: true,
+		})
+		for _, page := range ThemeFiles {
+			names = append(names, themeDir+page)
+		}
+	} else if strings.HasPrefix(name, themeDir) {
+		names = append(names, name)
+	}
+
+	for _, filename := range names {
+		post, err := h.DBPool.FindPostWithFilename(filename, user.ID, h.Cfg.Space)
+		if err != nil {
+			continue
+		}
+		fileList = append(fileList, &sendutils.VirtualFile{
+			FName:    post.Filename,
+			FIsDir:   false,
+			FSize:    int64(post.FileSize),
+			FModTime: *post.UpdatedAt,
+		})
+	}
+	return fileList, nil
+}
+
+func (h *ThemeHandler) Read(s *pssh.SSHServerConnSession, entry *sendutils.FileEntry) (os.FileInfo, sendutils.ReadAndReaderAtCloser, error) {
+	user, err := h.user(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	post, err := h.DBPool.FindPostWithFilename(themeFilename(entry.Filepath), user.ID, h.Cfg.Space)
+	if err != nil {
+		return nil, nil, os.ErrNotExist
+	}
+
+	fileInfo := &sendutils.VirtualFile{
+		FName:    post.Filename,
+		FIsDir:   false,
+		FSize:    int64(post.FileSize),
+		FModTime: *post.UpdatedAt,
+	}
+	reader := sendutils.NopReadAndReaderAtCloser(strings.NewReader(post.Text))
+	return fileInfo, reader, nil
+}
+
+// Write validates a theme file by rendering a sample page with it, broken
+// themes are rejected with the template error instead of being saved.
+func (h *ThemeHandler) Write(s *pssh.SSHServerConnSession, entry *sendutils.FileEntry) (string, error) {
+	logger := pssh.GetLogger(s)
+	user, err := h.user(s)
+	if err != nil {
+		return "", err
+	}
+
+	filename := themeFilename(entry.Filepath)
+	logger = logger.With("filename", filename)
+
+	if !strings.HasPrefix(filename, themeDir) || !slices.Contains(ThemeFiles, strings.TrimPrefix(filename, themeDir)) {
+		return "", fmt.Errorf(
+			"ERROR: (%s) invalid file, html files must be one of %s%s, skipping",
+			filename,
+			themeDir,
+			strings.Join(ThemeFiles, ", "+themeDir),
+		)
+	}
+
+	text, err := io.ReadAll(io.LimitReader(entry.Reader, maxThemeSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(text) > maxThemeSize {
+		return "", fmt.Errorf(
+			"ERROR: file (%s) has exceeded maximum file size (%d bytes)",
+			filename,
+			maxThemeSize,
+		)
+	}
+	if !shared.IsTextFile(string(text)) {
+		return "", fmt.Errorf(
+			"ERROR: (%s) invalid file must be plain text (utf-8), skipping",
+			filename,
+		)
+	}
+
+	err = ValidateTheme(h.Cfg, filename, string(text))
+	if err != nil {
+		logger.Info("theme failed validation", "err", err.Error())
+		return "", fmt.Errorf("ERROR: (%s) invalid theme: %w", filename, err)
+	}
+
+	modTime := time.Now()
+	if entry.Mtime > 0 {
+		modTime = time.Unix(entry.Mtime, 0)
+	}
+
+	post, err := h.DBPool.FindPostWithFilename(filename, user.ID, h.Cfg.Space)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("unable to load theme, continuing", "err", err.Error())
+	}
+
+	next := db.Post{
+		Filename:  filename,
+		Slug:      shared.SanitizeFileExt(filename),
+		Title:     filename,
+		Text:      string(text),
+		Hidden:    true,
+		MimeType:  "text/html; charset=UTF-8",
+		FileSize:  binary.Size(text),
+		Shasum:    shared.Shasum(text),
+		PublishAt: &modTime,
+		UpdatedAt: &modTime,
+	}
+
+	if post == nil {
+		logger.Info("theme not found, adding record")
+		next.UserID = user.ID
+		next.Space = h.Cfg.Space
+		_, err = h.DBPool.InsertPost(&next)
+	} else {
+		logger.Info("theme found, updating record")
+		next.ID = post.ID
+		next.PublishAt = post.PublishAt
+		_, err = h.DBPool.UpdatePost(&next)
+	}
+	if err != nil {
+		logger.Error("theme could not be saved", "err", err.Error())
+		return "", fmt.Errorf("error for %s: %v", filename, err)
+	}
+
+	curl := shared.NewCreateURL(h.Cfg)
+	return h.Cfg.FullBlogURL(curl, user.Name), nil
+}
+
+func (h *ThemeHandler) Delete(s *pssh.SSHServerConnSession, entry *sendutils.FileEntry) error {
+	logger := pssh.GetLogger(s)
+	user, err := h.user(s)
+	if err != nil {
+		return err
+	}
+
+	filename := themeFilename(entry.Filepath)
+	post, err := h.DBPool.FindPostWithFilename(filename, user.ID, h.Cfg.Space)
+	if err != nil {
+		return os.ErrNotExist
+	}
+
+	logger.Info("removing theme", "filename", filename)
+	err = h.DBPool.RemovePosts([]string{post.ID})
+	if err != nil {
+		logger.Error("theme could not be removed", "err", err.Error())
+		return fmt.Errorf("error for %s: %v", filename, err)
+	}
+	return nil
+}
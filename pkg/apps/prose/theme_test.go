@@ -0,0 +1,264 @@
+package prose
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+)
+
+func TestValidateTheme(t *testing.T) {
+	t.Chdir("../../..")
+	ft := newProseTest(t)
+	cfg := ft.fed.Cfg
+
+	expand := `{{define "t0"}}x{{end}}`
+	for i := 1; i <= 12; i++ {
+		expand += fmt.Sprintf(`{{define "t%d"}}{{template "t%d" .}}{{template "t%d" .}}{{end}}`, i, i-1, i-1)
+	}
+	expand += `{{define "body"}}{{template "t12" .}}{{end}}`
+
+	tests := []struct {
+		name     string
+		filename string
+		text     string
+		err      string
+	}{
+		{
+			name:     "override",
+			filename: "_theme/blog.html",
+			text:     `{{define "body"}}{{range .Posts}}<a href="{{.URL}}">{{.Title | lower}}</a>{{end}}{{end}}`,
+		},
+		{
+			name:     "post",
+			filename: "_theme/post.html",
+			text:     `{{define "head"}}<style>body { color: red; }</style>{{end}}`,
+		},
+		{
+			name:     "unknown page",
+			filename: "_theme/index.html",
+			text:     `{{define "body"}}{{end}}`,
+			err:      "theme file must be one of _theme/blog.html",
+		},
+		{
+			name:     "syntax",
+			filename: "_theme/blog.html",
+			text:     "{{define \"body\"}}\n{{.Title}\n{{end}}",
+			err:      "_theme/blog.html:2",
+		},
+		{
+			name:     "unsafe function",
+			filename: "_theme/blog.html",
+			text:     `{{define "body"}}{{exec "ls"}}{{end}}`,
+			err:      `function "exec" not defined`,
+		},
+		{
+			name:     "unbounded range",
+			filename: "_theme/blog.html",
+			text:     `{{define "body"}}{{range 100000000}}x{{end}}{{end}}`,
+			err:      "range can only loop over page data",
+		},
+		{
+			name:     "recursion",
+			filename: "_theme/post.html",
+			text:     `{{define "body"}}{{template "body" .}}{{end}}`,
+			err:      "body: calls itself",
+		},
+		{
+			name:     "expansion",
+			filename: "_theme/tag.html",
+			text:     expand,
+			err:      "expands into more than 1000 templates",
+		},
+		{
+			name:     "script",
+			filename: "_theme/post.html",
+			text:     `{{define "head"}}<SCRIPT src="/x.js"></SCRIPT>{{end}}`,
+			err:      "<script> elements are not allowed",
+		},
+		{
+			name:     "split script",
+			filename: "_theme/post.html",
+			text:     `{{define "head"}}<scr{{if .Title}}{{end}}ipt>alert(1)</script>{{end}}`,
+			err:      "<script> elements are not allowed",
+		},
+		{
+			name:     "iframe",
+			filename: "_theme/blog.html",
+			text:     `{{define "body"}}<iframe src="https://example.com"></iframe>{{end}}`,
+			err:      "<iframe> elements are not allowed",
+		},
+		{
+			name:     "event handler",
+			filename: "_theme/blog.html",
+			text:     `{{define "body"}}<img src="/x.png" onerror="alert(1)">{{end}}`,
+			err:      "event handlers are not allowed",
+		},
+		{
+			name:     "javascript url",
+			filename: "_theme/blog.html",
+			text:     `{{define "body"}}<a href=" java&#x09;script:alert(1)">x</a>{{end}}`,
+			err:      "javascript urls are not allowed",
+		},
+		{
+			name:     "missing field",
+			filename: "_theme/post.html",
+			text:     `{{define "body"}}{{.Nope}}{{end}}`,
+			err:      "can't evaluate field Nope",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTheme(cfg, tt.filename, tt.text)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("want error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestThemeRendering(t *testing.T) {
+	t.Chdir("../../..")
+	ft := newProseTest(t)
+	ft.post.Tags = []string{"go"}
+
+	addFile := func(filename, text string) *db.Post {
+		now := time.Now()
+		post := &db.Post{
+			ID:        filename,
+			UserID:    ft.user.ID,
+			Username:  ft.user.Name,
+			Filename:  filename,
+			Slug:      strings.TrimSuffix(filename, ".html"),
+			Text:      text,
+			Space:     "prose",
+			Hidden:    true,
+			PublishAt: &now,
+			UpdatedAt: &now,
+		}
+		ft.dbpool.Posts = append(ft.dbpool.Posts, post)
+		return post
+	}
+	get := func(target string) string {
+		t.Helper()
+		rec := ft.do(httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: want 200, got %d", target, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	body := get("http://erock.prose.test/")
+	if !strings.Contains(body, `class="layout-default"`) {
+		t.Fatalf("blog without theme should use built-in templates:\n%s", body)
+	}
+
+	readme := addFile("_readme.md", "---\ntheme: minimal\n---\n")
+	readme.Hidden = false
+	body = get("http://erock.prose.test/")
+	if !strings.Contains(body, "theme-minimal") {
+		t.Errorf("bundled theme was not used:\n%s", body)
+	}
+
+	readme.Text = "---\ntheme: journal\n---\n"
+	body = get("http://erock.prose.test/hello")
+	if !strings.Contains(body, "Georgia") || !strings.Contains(body, "from prose") {
+		t.Errorf("bundled post theme was not used:\n%s", body)
+	}
+
+	addFile("_theme/blog.html", `{{define "body"}}<p class="custom">{{range .Posts}}{{.Title | upper}}{{end}}</p>{{end}}`)
+	body = get("http://erock.prose.test/")
+	if !strings.Contains(body, `<p class="custom">HELLO</p>`) {
+		t.Errorf("uploaded theme was not used:\n%s", body)
+	}
+	if !strings.Contains(body, "Georgia") {
+		t.Errorf("uploaded theme should refine the bundled theme:\n%s", body)
+	}
+
+	addFile("_theme/tag.html", `{{define "attrs"}}class="tagged"{{end}}`)
+	body = get("http://erock.prose.test/?tag=go")
+	if !strings.Contains(body, `class="tagged"`) || !strings.Contains(body, `<p class="custom">HELLO</p>`) {
+		t.Errorf("tag page should use the tag and blog overrides:\n%s", body)
+	}
+
+	addFile("_theme/post.html", `{{define "body"}}{{.Nope}}{{end}}`)
+	body = get("http://erock.prose.test/hello")
+	if !strings.Contains(body, "from prose") || strings.Contains(body, "can't evaluate") {
+		t.Errorf("broken theme should fall back to built-in templates:\n%s", body)
+	}
+
+	rec := ft.do(httptest.NewRequest(http.MethodGet, "http://erock.prose.test/_theme/blog", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("theme files must not be served as posts, got %d", rec.Code)
+	}
+}
+
+func TestThemeCache(t *testing.T) {
+	t.Chdir("../../..")
+	ft := newProseTest(t)
+
+	now := time.Now()
+	theme := &db.Post{
+		ID:        "theme-blog",
+		UserID:    ft.user.ID,
+		Filename:  "_theme/blog.html",
+		Slug:      "blog",
+		Text:      `{{define "body"}}<p>first</p>{{end}}`,
+		Space:     "prose",
+		Hidden:    true,
+		UpdatedAt: &now,
+	}
+	ft.dbpool.Posts = append(ft.dbpool.Posts, theme)
+
+	get := func() string {
+		t.Helper()
+		rec := ft.do(httptest.NewRequest(http.MethodGet, "http://erock.prose.test/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("want 200, got %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	if body := get(); !strings.Contains(body, "<p>first</p>") {
+		t.Fatalf("uploaded theme was not used:\n%s", body)
+	}
+	rec := ft.do(httptest.NewRequest(http.MethodGet, "http://erock.prose.test/", nil))
+	if csp := rec.Header().Get("Content-Security-Policy"); csp != themeCSP {
+		t.Errorf("expected themed pages to forbid scripts, got %q", csp)
+	}
+
+	// the parsed theme is reused until the file is updated
+	theme.Text = `{{define "body"}}<p>second</p>{{end}}`
+	if body := get(); !strings.Contains(body, "<p>first</p>") {
+		t.Errorf("expected the cached theme to be used:\n%s", body)
+	}
+
+	updated := now.Add(time.Second)
+	theme.UpdatedAt = &updated
+	if body := get(); !strings.Contains(body, "<p>second</p>") {
+		t.Errorf("expected the updated theme to be parsed again:\n%s", body)
+	}
+
+	// a broken theme is not parsed again until it is updated
+	broken := updated.Add(time.Second)
+	theme.UpdatedAt = &broken
+	theme.Text = `{{define "body"}}<script>alert(1)</script>{{end}}`
+	if body := get(); strings.Contains(body, "alert(1)") {
+		t.Fatalf("expected the built-in templates for a broken theme:\n%s", body)
+	}
+	theme.Text = `{{define "body"}}<p>third</p>{{end}}`
+	if body := get(); strings.Contains(body, "<p>third</p>") {
+		t.Errorf("expected the failed parse to be cached:\n%s", body)
+	}
+}
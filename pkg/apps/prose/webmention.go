@@ -0,0 +1,153 @@
+package prose
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/picosh/pico/pkg/activitypub"
+	"github.com/picosh/pico/pkg/db"
+	"github.com/picosh/pico/pkg/shared"
+	"github.com/picosh/pico/pkg/shared/router"
+	"golang.org/x/net/html"
+)
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+/*
+webmentionHandler accepts webmentions for the posts of a blog.  The source
+is verified in the background, as the spec recommends, so the sender only
+learns whether the target is one of our posts.
+*/
+func (f *Federation) webmentionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := f.findUser(w, r)
+	if !ok {
+		return
+	}
+
+	source := r.FormValue("source")
+	target := r.FormValue("target")
+	if !isHTTPURL(source) || !isHTTPURL(target) || source == target {
+		http.Error(w, "source and target must be different http urls", http.StatusBadRequest)
+		return
+	}
+
+	cfg := router.GetCfg(r)
+	curl := shared.CreateURLFromRequest(cfg, r)
+	post, err := f.findPostByURL(user, target, cfg.FullBlogURL(curl, user.Name))
+	if err != nil {
+		http.Error(w, "target is not a post of this blog", http.StatusBadRequest)
+		return
+	}
+
+	go func() {
+		err := f.VerifyWebmention(post, source, target)
+		if err != nil {
+			f.Logger.Error("verify webmention", "err", err.Error(), "source", source, "target", target)
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// webmentionPage is what we learn about the page sending a webmention.
+type webmentionPage struct {
+	Title   string
+	LinksTo bool
+}
+
+func parseWebmentionSource(body io.Reader, source *url.URL, target string) (*webmentionPage, error) {
+	doc, err := html.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &webmentionPage{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "title":
+				if page.Title == "" && n.FirstChild != nil {
+					page.Title = strings.TrimSpace(n.FirstChild.Data)
+				}
+			case "a", "link":
+				for _, attr := range n.Attr {
+					if attr.Key != "href" {
+						continue
+					}
+					href, err := source.Parse(attr.Val)
+					if err == nil && href.String() == target {
+						page.LinksTo = true
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return page, nil
+}
+
+// VerifyWebmention fetches the source of a webmention and stores it when it
+// links to the target, a source that is gone or no longer links to the
+// target removes the mention.
+func (f *Federation) VerifyWebmention(post *db.Post, source, target string) error {
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("User-Agent", f.Client.UserAgent)
+
+	resp, err := f.Client.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound {
+		return f.Db.RemovePostMention(post.ID, source)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("source responded with status %d", resp.StatusCode)
+	}
+
+	page, err := parseWebmentionSource(io.LimitReader(resp.Body, activitypub.MaxDocumentSize), sourceURL, target)
+	if err != nil {
+		return err
+	}
+	if !page.LinksTo {
+		return f.Db.RemovePostMention(post.ID, source)
+	}
+
+	return f.Db.UpsertPostMention(&db.PostMention{
+		PostID:     post.ID,
+		Kind:       db.MentionWebmention,
+		Source:     source,
+		AuthorName: sourceURL.Host,
+		AuthorURL:  fmt.Sprintf("%s://%s", sourceURL.Scheme, sourceURL.Host),
+		Content:    html.EscapeString(page.Title),
+	})
+}
@@ -0,0 +1,72 @@
+package prose
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+)
+
+func TestWebmention(t *testing.T) {
+	ft := newProseTest(t)
+	source := ft.remote.setPage("reply", fmt.Sprintf(
+		`<html><head><title>A reply</title></head><body><a href="%s">erock wrote</a></body></html>`,
+		ft.postURL,
+	))
+
+	send := func(source, target string) int {
+		form := url.Values{"source": {source}, "target": {target}}
+		req := httptest.NewRequest(http.MethodPost, "http://erock.prose.test/_webmention", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return ft.do(req).Code
+	}
+
+	if code := send(source, "http://erock.prose.test/missing"); code != http.StatusBadRequest {
+		t.Fatalf("unknown target: want 400, got %d", code)
+	}
+	if code := send("ftp://example.com", ft.postURL); code != http.StatusBadRequest {
+		t.Fatalf("invalid source: want 400, got %d", code)
+	}
+	if code := send(source, ft.postURL); code != http.StatusAccepted {
+		t.Fatalf("webmention: want 202, got %d", code)
+	}
+
+	// the source is verified in the background
+	var mentions []*db.PostMention
+	for range 50 {
+		mentions, _ = ft.dbpool.FindPostMentions(ft.post.ID)
+		if len(mentions) > 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(mentions) != 1 || mentions[0].Content != "A reply" || mentions[0].Kind != db.MentionWebmention {
+		t.Fatalf("expected webmention to be stored, got %+v", mentions)
+	}
+
+	ft.remote.setPage("reply", `<html><body>no longer linking</body></html>`)
+	if err := ft.fed.VerifyWebmention(ft.post, source, ft.postURL); err != nil {
+		t.Fatal(err)
+	}
+	if mentions, _ := ft.dbpool.FindPostMentions(ft.post.ID); len(mentions) != 0 {
+		t.Fatalf("expected webmention to be removed, got %+v", mentions)
+	}
+}
+
+func TestWebmentionPrivateSource(t *testing.T) {
+	ft := newProseTest(t)
+	source := ft.remote.setPage("reply", fmt.Sprintf(`<a href="%s">erock wrote</a>`, ft.postURL))
+
+	fed := NewFederation(ft.fed.Cfg, ft.dbpool)
+	if err := fed.VerifyWebmention(ft.post, source, ft.postURL); err == nil {
+		t.Fatal("expected a loopback source to be refused")
+	}
+	if mentions, _ := ft.dbpool.FindPostMentions(ft.post.ID); len(mentions) != 0 {
+		t.Fatalf("expected no webmention, got %+v", mentions)
+	}
+}
@@ -0,0 +1,27 @@
+package db
+
+// CertQuerier is the part of a sql connection needed to check certs.
+type CertQuerier interface {
+	Get(dest interface{}, query string, args ...interface{}) error
+}
+
+// IsSshCertRevoked reports whether a cert signed by caPubkey may no longer
+// be used.  For a CA pico manages, a serial that pico never issued counts
+// as revoked, certs of any other CA are never revoked.  Both sql backends
+// share it so pgs and the other services agree on revocations.
+func IsSshCertRevoked(q CertQuerier, caPubkey string, serial int64) (bool, error) {
+	var revoked bool
+	err := q.Get(
+		&revoked,
+		`SELECT EXISTS (
+			SELECT 1 FROM ssh_cert_authorities WHERE public_key = $1
+		) AND NOT EXISTS (
+			SELECT 1 FROM ssh_certs
+			INNER JOIN ssh_cert_authorities ON ssh_cert_authorities.id = ssh_certs.ca_id
+			WHERE ssh_cert_authorities.public_key = $1 AND ssh_certs.serial = $2 AND ssh_certs.revoked_at IS NULL
+		);`,
+		caPubkey,
+		serial,
+	)
+	return revoked, err
+}
@@ -7,6 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -14,6 +16,7 @@ var ErrNameTaken = errors.New("username has already been claimed")
 var ErrNameDenied = errors.New("username is on the denylist")
 var ErrNameInvalid = errors.New("username has invalid characters in it")
 var ErrPublicKeyTaken = errors.New("public key is already associated with another user")
+var ErrTokenScope = errors.New("token does not have the required scope")
 
 // sqlite uses string to BLOB type and postgres uses []uint8 for JSONB.
 func tcast(value any) ([]byte, error) {
@@ -57,6 +60,16 @@ type PostData struct {
 	ImgPath    string     `json:"img_path"`
 	LastDigest *time.Time `json:"last_digest"`
 	Attempts   int        `json:"attempts"`
+	// PreviewToken is set on drafts which are only visible with the token.
+	PreviewToken string `json:"preview_token,omitempty"`
+	// Series groups posts into an ordered series.
+	Series string `json:"series,omitempty"`
+	// Scheduled is set while a post waits for its publish date.
+	Scheduled bool `json:"scheduled,omitempty"`
+	// Lang is the language tag of the post, e.g. en or de-AT.
+	Lang string `json:"lang,omitempty"`
+	// Translations maps language tags to the slugs of translated posts.
+	Translations map[string]string `json:"translations,omitempty"`
 }
 
 // Make the Attrs struct implement the driver.Valuer interface. This method
@@ -165,11 +178,28 @@ type Paginate[T any] struct {
 	Total int
 }
 
+// SearchMarkStart and SearchMarkEnd wrap every match in a search snippet.
+// They come from the unicode private use area so they never clash with the
+// contents of a post and callers can escape the snippet before turning the
+// marks into html.
+const (
+	SearchMarkStart = "\ue000"
+	SearchMarkEnd   = "\ue001"
+)
+
+// SearchResult is a post matched by a full-text search.
+type SearchResult struct {
+	Post    *Post
+	Rank    float64
+	Snippet string
+}
+
 type VisitInterval struct {
 	Interval        *time.Time `json:"interval" db:"interval"`
 	Visitors        int        `json:"visitors" db:"visitors"`
 	MobileVisitors  int        `json:"mobile_visitors" db:"mobile_visitors"`
 	DesktopVisitors int        `json:"desktop_visitors" db:"desktop_visitors"`
+	ReadThroughs    int        `json:"read_throughs" db:"read_throughs"`
 }
 
 type VisitUrl struct {
@@ -183,6 +213,7 @@ type SummaryOpts struct {
 	Host     string
 	Path     string
 	UserID   string
+	PostID   string
 	Limit    int
 }
 
@@ -193,11 +224,32 @@ type SummaryVisits struct {
 	TopReferers  []*VisitUrl      `json:"top_referers"`
 }
 
+// PostAnalytics is the analytics breakdown of a single prose post.  Views
+// is the lifetime counter stored on the post, Visitors and ReadThroughs
+// are summed over the requested range.
 type PostAnalytics struct {
-	ID       string     `json:"id" db:"id"`
-	PostID   string     `json:"post_id" db:"post_id"`
-	Views    int        `json:"views" db:"views"`
-	UpdateAt *time.Time `json:"updated_at" db:"updated_at"`
+	ID           string           `json:"id" db:"id"`
+	PostID       string           `json:"post_id" db:"post_id"`
+	Slug         string           `json:"slug" db:"slug"`
+	Title        string           `json:"title" db:"title"`
+	Views        int              `json:"views" db:"views"`
+	Visitors     int              `json:"visitors" db:"visitors"`
+	ReadThroughs int              `json:"read_throughs" db:"read_throughs"`
+	UpdateAt     *time.Time       `json:"updated_at" db:"updated_at"`
+	Intervals    []*VisitInterval `json:"intervals"`
+	TopReferers  []*VisitUrl      `json:"top_referers"`
+}
+
+// ReadThroughRate is the share of visitors that reached the end of the post.
+func (p *PostAnalytics) ReadThroughRate() float64 {
+	if p.Visitors == 0 {
+		return 0
+	}
+	rate := float64(p.ReadThroughs) / float64(p.Visitors)
+	if rate > 1 {
+		return 1
+	}
+	return rate
 }
 
 type AnalyticsVisits struct {
@@ -250,12 +302,87 @@ type FeedItem struct {
 }
 
 type Token struct {
-	ID        string     `json:"id" db:"id"`
-	UserID    string     `json:"user_id" db:"user_id"`
-	Name      string     `json:"name" db:"name"`
-	Token     string     `json:"token" db:"token"`
-	CreatedAt *time.Time `json:"created_at" db:"created_at"`
-	ExpiresAt *time.Time `json:"expires_at" db:"expires_at"`
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Token      string     `json:"token" db:"token"`
+	Scopes     string     `json:"scopes" db:"scopes"`
+	CreatedAt  *time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+}
+
+const (
+	// TokenScopeFull is only held by tokens created without any scopes,
+	// which have full access to the account.
+	TokenScopeFull      = "full"
+	TokenScopePgsDeploy = "pgs:deploy"
+	TokenScopePipePub   = "pipe:pub"
+	// TokenScopePipeSub grants every topic, "pipe:sub:<topic>" a single one.
+	TokenScopePipeSub       = "pipe:sub"
+	TokenScopeAnalyticsRead = "analytics:read"
+	TokenScopeFeedsRead     = "feeds:read"
+)
+
+var TokenScopes = []string{
+	TokenScopePgsDeploy,
+	TokenScopePipePub,
+	TokenScopePipeSub,
+	TokenScopeAnalyticsRead,
+	TokenScopeFeedsRead,
+}
+
+// ScopeList returns the token scopes, an empty list means full access.
+func (t *Token) ScopeList() []string {
+	return ParseTokenScopes(t.Scopes)
+}
+
+// HasScope reports whether the token may be used for scope.  Unscoped
+// tokens have every scope.
+func (t *Token) HasScope(scope string) bool {
+	scopes := t.ScopeList()
+	if len(scopes) == 0 {
+		return true
+	}
+	if scope == TokenScopeFull {
+		return false
+	}
+
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+		// "pipe:sub" covers "pipe:sub:<topic>"
+		if strings.HasPrefix(scope, s+":") && s == TokenScopePipeSub {
+			return true
+		}
+	}
+	return false
+}
+
+func ParseTokenScopes(scopes string) []string {
+	list := []string{}
+	for _, scope := range strings.Split(scopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope != "" {
+			list = append(list, scope)
+		}
+	}
+	return list
+}
+
+func ValidateTokenScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if slices.Contains(TokenScopes, scope) {
+			continue
+		}
+		topic, found := strings.CutPrefix(scope, TokenScopePipeSub+":")
+		if found && topic != "" {
+			continue
+		}
+		return fmt.Errorf("invalid token scope %q, must be one of %v or pipe:sub:<topic>", scope, TokenScopes)
+	}
+	return nil
 }
 
 type FormEntry struct {
@@ -413,14 +540,16 @@ type TunsEventLog struct {
 }
 
 type PipeMonitor struct {
-	ID        string        `json:"id" db:"id"`
-	UserId    string        `json:"user_id" db:"user_id"`
-	Topic     string        `json:"topic" db:"topic"`
-	WindowDur time.Duration `json:"window_dur" db:"window_dur"`
-	WindowEnd *time.Time    `json:"window_end" db:"window_end"`
-	LastPing  *time.Time    `json:"last_ping" db:"last_ping"`
-	CreatedAt *time.Time    `json:"created_at" db:"created_at"`
-	UpdatedAt *time.Time    `json:"updated_at" db:"updated_at"`
+	ID          string        `json:"id" db:"id"`
+	UserId      string        `json:"user_id" db:"user_id"`
+	Topic       string        `json:"topic" db:"topic"`
+	WindowDur   time.Duration `json:"window_dur" db:"window_dur"`
+	WindowEnd   *time.Time    `json:"window_end" db:"window_end"`
+	LastPing    *time.Time    `json:"last_ping" db:"last_ping"`
+	AlertStatus string        `json:"alert_status" db:"alert_status"`
+	AlertedAt   *time.Time    `json:"alerted_at" db:"alerted_at"`
+	CreatedAt   *time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt   *time.Time    `json:"updated_at" db:"updated_at"`
 }
 
 type PipeMonitorHistory struct {
@@ -433,6 +562,183 @@ type PipeMonitorHistory struct {
 	UpdatedAt *time.Time    `json:"updated_at" db:"updated_at"`
 }
 
+const (
+	PipeMonitorUp   = "up"
+	PipeMonitorDown = "down"
+)
+
+const (
+	PipeTargetEmail   = "email"
+	PipeTargetWebhook = "webhook"
+	PipeTargetTopic   = "topic"
+)
+
+// PipeMonitorTarget is a destination notified when a monitor goes down or
+// recovers.  Email targets are only notified once the address confirmed
+// them with Token.
+type PipeMonitorTarget struct {
+	ID          string     `json:"id" db:"id"`
+	MonitorID   string     `json:"monitor_id" db:"monitor_id"`
+	Kind        string     `json:"kind" db:"kind"`
+	Target      string     `json:"target" db:"target"`
+	Secret      string     `json:"-" db:"secret"`
+	Token       string     `json:"-" db:"token"`
+	ConfirmedAt *time.Time `json:"confirmed_at" db:"confirmed_at"`
+	CreatedAt   *time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at" db:"updated_at"`
+}
+
+const (
+	OrgRoleOwner    = "owner"
+	OrgRoleDeployer = "deployer"
+	OrgRoleViewer   = "viewer"
+)
+
+// OrgMember grants a user access to an organization.  Organizations are
+// regular app_users without public keys so everything keyed by user id
+// (projects, posts, buckets) works for them as well.
+type OrgMember struct {
+	ID        string     `json:"id" db:"id"`
+	OrgID     string     `json:"org_id" db:"org_id"`
+	OrgName   string     `json:"org_name" db:"org_name"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	UserName  string     `json:"user_name" db:"user_name"`
+	Role      string     `json:"role" db:"role"`
+	CreatedAt *time.Time `json:"created_at" db:"created_at"`
+}
+
+// SshCertAuthority is the per-user keypair pico signs ssh certificates
+// with.  Its public key is registered as one of the user's keys so
+// certificates signed by it authenticate as the user.
+type SshCertAuthority struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	PublicKey  string     `json:"public_key" db:"public_key"`
+	PrivateKey string     `json:"-" db:"private_key"`
+	CreatedAt  *time.Time `json:"created_at" db:"created_at"`
+}
+
+// SshCert records a certificate issued by a user's SshCertAuthority.
+type SshCert struct {
+	ID          string     `json:"id" db:"id"`
+	CaID        string     `json:"ca_id" db:"ca_id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	Serial      int64      `json:"serial" db:"serial"`
+	KeyID       string     `json:"key_id" db:"key_id"`
+	Principals  string     `json:"principals" db:"principals"`
+	PublicKey   string     `json:"public_key" db:"public_key"`
+	ValidAfter  *time.Time `json:"valid_after" db:"valid_after"`
+	ValidBefore *time.Time `json:"valid_before" db:"valid_before"`
+	RevokedAt   *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt   *time.Time `json:"created_at" db:"created_at"`
+}
+
+// FederationKey is the keypair a prose blog signs its ActivityPub
+// requests with.
+type FederationKey struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	PublicKey  string     `json:"public_key" db:"public_key"`
+	PrivateKey string     `json:"-" db:"private_key"`
+	CreatedAt  *time.Time `json:"created_at" db:"created_at"`
+}
+
+// Follower is a remote ActivityPub actor following a prose blog.
+type Follower struct {
+	ID        string     `json:"id" db:"id"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	Actor     string     `json:"actor" db:"actor"`
+	Inbox     string     `json:"inbox" db:"inbox"`
+	CreatedAt *time.Time `json:"created_at" db:"created_at"`
+}
+
+const (
+	MentionWebmention = "webmention"
+	MentionReply      = "reply"
+)
+
+// PostMention is a webmention or fediverse reply received for a post,
+// Source is the url of the mentioning page or the id of the reply.
+type PostMention struct {
+	ID         string     `json:"id" db:"id"`
+	PostID     string     `json:"post_id" db:"post_id"`
+	Kind       string     `json:"kind" db:"kind"`
+	Source     string     `json:"source" db:"source"`
+	AuthorName string     `json:"author_name" db:"author_name"`
+	AuthorURL  string     `json:"author_url" db:"author_url"`
+	Content    string     `json:"content" db:"content"`
+	CreatedAt  *time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PostComment is a comment a pico user left on a post over ssh.  Comments
+// are only shown once the author of the post approves them.
+type PostComment struct {
+	ID        string     `json:"id" db:"id"`
+	PostID    string     `json:"post_id" db:"post_id"`
+	PostSlug  string     `json:"post_slug" db:"post_slug"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	Username  string     `json:"username" db:"username"`
+	Text      string     `json:"text" db:"text"`
+	Approved  bool       `json:"approved" db:"approved"`
+	CreatedAt *time.Time `json:"created_at" db:"created_at"`
+}
+
+const (
+	SubscriberPending      = "pending"
+	SubscriberConfirmed    = "confirmed"
+	SubscriberUnsubscribed = "unsubscribed"
+	SubscriberBounced      = "bounced"
+)
+
+// NewsletterSubscriber is an email address subscribed to the posts of a
+// prose blog.  Subscriptions stay pending until the link sent to the
+// address is opened, Token identifies the subscription in those links.
+type NewsletterSubscriber struct {
+	ID          string     `json:"id" db:"id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	Email       string     `json:"email" db:"email"`
+	Token       string     `json:"token" db:"token"`
+	Status      string     `json:"status" db:"status"`
+	Bounces     int        `json:"bounces" db:"bounces"`
+	ConfirmedAt *time.Time `json:"confirmed_at" db:"confirmed_at"`
+	CreatedAt   *time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// NewsletterPost is a published post queued for the subscribers of its
+// blog, SentAt is set once it was emailed.
+type NewsletterPost struct {
+	ID        string     `json:"id" db:"id"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	PostID    string     `json:"post_id" db:"post_id"`
+	CreatedAt *time.Time `json:"created_at" db:"created_at"`
+	SentAt    *time.Time `json:"sent_at" db:"sent_at"`
+}
+
+const (
+	PipeAclRead      = "read"
+	PipeAclWrite     = "write"
+	PipeAclReadWrite = "rw"
+)
+
+type PipeAcl struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	Topic      string     `json:"topic" db:"topic"`
+	Principal  string     `json:"principal" db:"principal"`
+	Permission string     `json:"permission" db:"permission"`
+	CreatedAt  *time.Time `json:"created_at" db:"created_at"`
+}
+
+// Allows reports whether the acl grants the requested permission.
+func (a *PipeAcl) Allows(perm string) bool {
+	if a.Permission == PipeAclReadWrite {
+		return true
+	}
+	return a.Permission == perm
+}
+
 type UptimeResult struct {
 	TotalDuration  time.Duration
 	UptimeDuration time.Duration
@@ -579,8 +885,10 @@ type DB interface {
 	FindUser(userID string) (*User, error)
 
 	FindUserByToken(token string) (*User, error)
+	FindUserByScopedToken(token, scope string) (*User, error)
 	FindTokensByUser(userID string) ([]*Token, error)
 	InsertToken(userID, name string) (string, error)
+	InsertScopedToken(userID, name string, scopes []string, expiresAt *time.Time) (string, error)
 	UpsertToken(userID, name string) (string, error)
 	RemoveToken(tokenID string) error
 
@@ -590,6 +898,12 @@ type DB interface {
 	FindAllPostsByUser(userID string, space string) ([]*Post, error)
 	FindUsersWithPost(space string) ([]*User, error)
 	FindExpiredPosts(space string) ([]*Post, error)
+	FindScheduledPosts(space string, now time.Time) ([]*Post, error)
+	ClaimScheduledPost(postID string) (bool, error)
+	FindPostsBySeries(userID, series, space string) ([]*Post, error)
+	FindPostsByLang(pager *Pager, lang, space string) (*Paginate[*Post], error)
+	FindUserPostsByLang(pager *Pager, lang, userID, space string) (*Paginate[*Post], error)
+	FindPostLangs(space string) ([]string, error)
 	FindPostWithFilename(filename string, userID string, space string) (*Post, error)
 	FindPostWithSlug(slug string, userID string, space string) (*Post, error)
 	FindPostsByFeed(pager *Pager, space string) (*Paginate[*Post], error)
@@ -601,12 +915,16 @@ type DB interface {
 	FindUserPostsByTag(pager *Pager, tag, userID, space string) (*Paginate[*Post], error)
 	FindPostsByTag(pager *Pager, tag, space string) (*Paginate[*Post], error)
 	FindPopularTags(space string) ([]string, error)
+	FindPostsBySearch(pager *Pager, query, userID, space string) (*Paginate[*SearchResult], error)
 	ReplaceAliasesByPost(aliases []string, postID string) error
 
 	InsertVisit(view *AnalyticsVisits) error
 	VisitSummary(opts *SummaryOpts) (*SummaryVisits, error)
 	FindVisitSiteList(opts *SummaryOpts) ([]*VisitUrl, error)
 	VisitUrlNotFound(opts *SummaryOpts) ([]*VisitUrl, error)
+	InsertPostRead(read *AnalyticsVisits) error
+	PostVisitSummary(opts *SummaryOpts) (*PostAnalytics, error)
+	FindVisitPostList(opts *SummaryOpts) ([]*PostAnalytics, error)
 
 	AddPicoPlusUser(username, email, paymentType, txId string) error
 	AddFeatureUser(username, name string) error
@@ -643,5 +961,57 @@ type DB interface {
 	InsertPipeMonitorHistory(monitorID string, windowDur time.Duration, windowEnd, lastPing *time.Time) error
 	FindPipeMonitorHistory(monitorID string, from, to time.Time) ([]*PipeMonitorHistory, error)
 
+	UpsertPipeMonitorTarget(monitorID, kind, target, secret, token string) error
+	ConfirmPipeMonitorTarget(token string) (*PipeMonitorTarget, error)
+	RemovePipeMonitorTarget(monitorID, kind, target string) error
+	FindPipeMonitorTargets(monitorID string) ([]*PipeMonitorTarget, error)
+	FindPipeMonitorsWithTargets() ([]*PipeMonitor, error)
+	UpdatePipeMonitorAlertStatus(monitorID, prev, status string, alertedAt *time.Time) (bool, error)
+
+	UpsertPipeAcl(userID, topic, principal, permission string) error
+	RemovePipeAcl(userID, topic, principal string) error
+	FindPipeAclsByTopic(userID, topic string) ([]*PipeAcl, error)
+	FindPipeAclsByUser(userID string) ([]*PipeAcl, error)
+
+	FindSshCertAuthority(userID string) (*SshCertAuthority, error)
+	InsertSshCertAuthority(userID, pubkey, privkey string) (*SshCertAuthority, error)
+	InsertSshCert(cert *SshCert) error
+	FindSshCertsByUser(userID string) ([]*SshCert, error)
+	RevokeSshCert(userID string, serial int64) error
+	IsSshCertRevoked(caPubkey string, serial int64) (bool, error)
+
+	InsertOrg(ownerID, name string) (*User, error)
+	FindOrgMember(orgID, userID string) (*OrgMember, error)
+	FindOrgMembers(orgID string) ([]*OrgMember, error)
+	FindOrgsByUser(userID string) ([]*OrgMember, error)
+	UpsertOrgMember(orgID, userID, role string) error
+	RemoveOrgMember(orgID, userID string) error
+
+	FindFederationKey(userID string) (*FederationKey, error)
+	InsertFederationKey(userID, pubkey, privkey string) (*FederationKey, error)
+	UpsertFollower(userID, actor, inbox string) error
+	RemoveFollower(userID, actor string) error
+	FindFollowersByUser(userID string) ([]*Follower, error)
+
+	UpsertPostMention(mention *PostMention) error
+	RemovePostMention(postID, source string) error
+	RemovePostMentionsBySource(source string) error
+	FindPostMentions(postID string) ([]*PostMention, error)
+
+	InsertPostComment(comment *PostComment) (*PostComment, error)
+	FindPostComments(postID string) ([]*PostComment, error)
+	FindPostCommentsByOwner(ownerID, space string) ([]*PostComment, error)
+	ApprovePostComment(ownerID, commentID string) error
+	RemovePostComment(ownerID, commentID string) error
+
+	UpsertNewsletterSubscriber(sub *NewsletterSubscriber) (*NewsletterSubscriber, error)
+	FindNewsletterSubscriber(userID, email string) (*NewsletterSubscriber, error)
+	FindNewsletterSubscriberByToken(token string) (*NewsletterSubscriber, error)
+	FindNewsletterSubscribersByEmail(email string) ([]*NewsletterSubscriber, error)
+	FindNewsletterSubscribers(userID, status string) ([]*NewsletterSubscriber, error)
+	InsertNewsletterPost(userID, postID string) error
+	FindPendingNewsletterPosts() ([]*NewsletterPost, error)
+	MarkNewsletterPostsSent(ids []string, sentAt time.Time) error
+
 	Close() error
 }
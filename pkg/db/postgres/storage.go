@@ -370,17 +370,17 @@ func (me *PsqlDB) findUserForNameAndKey(name string, key string) (*db.User, erro
 	return user, nil
 }
 
+// FindUserByToken only accepts tokens with full account access.
 func (me *PsqlDB) FindUserByToken(token string) (*db.User, error) {
-	user := &db.User{}
-	err := me.Db.Get(user, `
-	SELECT app_users.id, app_users.name, app_users.created_at
-	FROM app_users
-	LEFT JOIN tokens ON tokens.user_id = app_users.id
-	WHERE tokens.token = $1 AND tokens.expires_at > NOW()`, token)
+	return me.FindUserByScopedToken(token, db.TokenScopeFull)
+}
+
+func (me *PsqlDB) FindUserByScopedToken(token, scope string) (*db.User, error) {
+	tkn, err := db.FindScopedToken(me.Db, me.Logger, token, scope)
 	if err != nil {
 		return nil, err
 	}
-	return user, nil
+	return me.FindUser(tkn.UserID)
 }
 
 func (me *PsqlDB) FindPostWithFilename(filename string, persona_id string, space string) (*db.Post, error) {
@@ -694,6 +694,176 @@ func (me *PsqlDB) FindExpiredPosts(space string) ([]*db.Post, error) {
 	return posts, nil
 }
 
+// FindScheduledPosts returns the scheduled posts whose publish date has
+// arrived.
+func (me *PsqlDB) FindScheduledPosts(space string, now time.Time) ([]*db.Post, error) {
+	var posts []*db.Post
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM posts
+		LEFT JOIN app_users ON app_users.id = posts.user_id
+		WHERE
+			cur_space = $1 AND
+			data->>'scheduled' = 'true' AND
+			publish_at <= $2
+		ORDER BY publish_at ASC`, SelectPost)
+	err := me.Db.Select(&posts, query, space, now)
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// ClaimScheduledPost clears the scheduled flag of a post and reports
+// whether this call cleared it, so only one of the schedulers running on
+// every replica announces the post.
+func (me *PsqlDB) ClaimScheduledPost(postID string) (bool, error) {
+	res, err := me.Db.Exec(
+		`UPDATE posts SET data = data - 'scheduled' WHERE id = $1 AND data->>'scheduled' = 'true';`,
+		postID,
+	)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// FindPostsBySeries returns the published posts of a series in reading
+// order.
+func (me *PsqlDB) FindPostsBySeries(userID, series, space string) ([]*db.Post, error) {
+	var posts []*db.Post
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM posts
+		LEFT JOIN app_users ON app_users.id = posts.user_id
+		WHERE
+			hidden = FALSE AND
+			user_id = $1 AND
+			data->>'series' = $2 AND
+			publish_at::date <= CURRENT_DATE AND
+			cur_space = $3
+		ORDER BY publish_at ASC, slug ASC`, SelectPost)
+	err := me.Db.Select(&posts, query, userID, series, space)
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// langFilter matches posts written in a language, including regional
+// variants like de-AT for de, and posts that do not declare a language.
+const langFilter = `(
+		coalesce(data->>'lang', '') = '' OR
+		lower(data->>'lang') = %[1]s OR
+		lower(data->>'lang') LIKE %[1]s || '-%%'
+	)`
+
+// FindPostsByLang is the discovery feed limited to a single language.
+func (me *PsqlDB) FindPostsByLang(pager *db.Pager, lang, space string) (*db.Paginate[*db.Post], error) {
+	lang = strings.ToLower(lang)
+	query := fmt.Sprintf(`
+	SELECT *
+	FROM (
+	    SELECT DISTINCT ON (posts.user_id)
+	        %s
+	    FROM posts
+	    LEFT JOIN app_users ON app_users.id = posts.user_id
+	    WHERE
+	        hidden = FALSE
+	        AND publish_at::date <= CURRENT_DATE
+	        AND cur_space = $3
+	        AND %s
+	    ORDER BY posts.user_id, publish_at DESC
+	) AS latest_posts
+	ORDER BY publish_at DESC
+	LIMIT $1 OFFSET $2`, SelectPost, fmt.Sprintf(langFilter, "$4"))
+	var posts []*db.Post
+	err := me.Db.Select(&posts, query, pager.Num, pager.Num*pager.Page, space, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int
+	err = me.Db.QueryRow(
+		fmt.Sprintf(
+			`SELECT count(id) FROM posts WHERE hidden = FALSE AND cur_space = $1 AND %s`,
+			fmt.Sprintf(langFilter, "$2"),
+		),
+		space,
+		lang,
+	).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+
+	return &db.Paginate[*db.Post]{
+		Data:  posts,
+		Total: int(math.Ceil(float64(count) / float64(pager.Num))),
+	}, nil
+}
+
+// FindUserPostsByLang returns the posts of a blog written in a language.
+func (me *PsqlDB) FindUserPostsByLang(pager *db.Pager, lang, userID, space string) (*db.Paginate[*db.Post], error) {
+	lang = strings.ToLower(lang)
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM posts
+	LEFT JOIN app_users ON app_users.id = posts.user_id
+	WHERE
+		hidden = FALSE AND
+		user_id = $1 AND
+		publish_at::date <= CURRENT_DATE AND
+		cur_space = $2 AND
+		%s
+	ORDER BY publish_at DESC, slug DESC
+	LIMIT $4 OFFSET $5`, SelectPost, fmt.Sprintf(langFilter, "$3"))
+	var posts []*db.Post
+	err := me.Db.Select(&posts, query, userID, space, lang, pager.Num, pager.Num*pager.Page)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int
+	err = me.Db.QueryRow(
+		fmt.Sprintf(
+			`SELECT count(id) FROM posts WHERE hidden = FALSE AND user_id = $1 AND cur_space = $2 AND %s`,
+			fmt.Sprintf(langFilter, "$3"),
+		),
+		userID,
+		space,
+		lang,
+	).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+
+	return &db.Paginate[*db.Post]{
+		Data:  posts,
+		Total: int(math.Ceil(float64(count) / float64(pager.Num))),
+	}, nil
+}
+
+// FindPostLangs returns the languages published posts are written in.
+func (me *PsqlDB) FindPostLangs(space string) ([]string, error) {
+	langs := []string{}
+	err := me.Db.Select(&langs, `
+		SELECT DISTINCT lower(data->>'lang')
+		FROM posts
+		WHERE
+			hidden = FALSE AND
+			cur_space = $1 AND
+			coalesce(data->>'lang', '') != ''
+		ORDER BY 1`, space)
+	if err != nil {
+		return nil, err
+	}
+	return langs, nil
+}
+
 func (me *PsqlDB) Close() error {
 	me.Logger.Info("Closing db")
 	return me.Db.Close()
@@ -1456,6 +1626,282 @@ func (me *PsqlDB) FindVisitSiteList(opts *db.SummaryOpts) ([]*db.VisitUrl, error
 	return me.visitHost(opts)
 }
 
+func (me *PsqlDB) InsertPostRead(read *db.AnalyticsVisits) error {
+	_, err := me.Db.Exec(
+		`INSERT INTO analytics_post_reads (user_id, post_id, host, ip_address) VALUES ($1, $2, $3, $4);`,
+		read.UserID,
+		read.PostID,
+		read.Host,
+		read.IpAddress,
+	)
+	return err
+}
+
+// PostVisitSummary returns the visitors, read-throughs and top referers of
+// opts.PostID, which must belong to opts.UserID.
+func (me *PsqlDB) PostVisitSummary(opts *db.SummaryOpts) (*db.PostAnalytics, error) {
+	post := &db.PostAnalytics{}
+	err := me.Db.Get(
+		post,
+		`SELECT id AS post_id, slug, title, COALESCE(views, 0) AS views FROM posts WHERE id = $1 AND user_id = $2`,
+		opts.PostID,
+		opts.UserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		intervals, currentIntervals []*db.VisitInterval
+		refs, currentRefs           []*db.VisitUrl
+		errs                        [4]error
+	)
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+
+	go func() {
+		defer wg.Done()
+		intervals, errs[0] = me.postVisitFromSummary(opts)
+	}()
+	go func() {
+		defer wg.Done()
+		currentIntervals, errs[1] = me.postVisitFromRaw(opts)
+	}()
+	go func() {
+		defer wg.Done()
+		refs, errs[2] = me.postRefererFromSummary(opts)
+	}()
+	go func() {
+		defer wg.Done()
+		currentRefs, errs[3] = me.postRefererFromRaw(opts)
+	}()
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("query post visits: %w", err)
+		}
+	}
+
+	post.Intervals = mergeVisitIntervals(intervals, currentIntervals)
+	post.TopReferers = mergeTopReferers(refs, currentRefs)
+	for _, interval := range post.Intervals {
+		post.Visitors += interval.Visitors
+		post.ReadThroughs += interval.ReadThroughs
+	}
+	return post, nil
+}
+
+// postVisitFromSummary reads daily post visitors and read-throughs from
+// analytics_monthly_post_visits for historical data.
+func (me *PsqlDB) postVisitFromSummary(opts *db.SummaryOpts) ([]*db.VisitInterval, error) {
+	now := time.Now()
+	currentMonthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	previousMonthStart := currentMonthStart.AddDate(0, -1, 0)
+
+	// If origin is in the previous month or later, raw data covers it — no summary to fetch.
+	if !opts.Origin.Before(previousMonthStart) {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			date_trunc('%s', visit_date)::timestamptz as interval_start,
+			sum(unique_visits) as unique_visitors,
+			sum(mobile_visits) as mobile_visits,
+			sum(desktop_visits) as desktop_visits,
+			sum(read_throughs) as read_throughs
+		FROM analytics_monthly_post_visits
+		WHERE user_id = $1 AND post_id = $2 AND visit_date >= $3 AND visit_date < $4
+		GROUP BY interval_start
+		ORDER BY interval_start`, opts.Interval)
+
+	rows, err := me.Db.Queryx(query, opts.UserID, opts.PostID, opts.Origin, currentMonthStart)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var intervals []*db.VisitInterval
+	for rows.Next() {
+		interval := &db.VisitInterval{}
+		err := rows.Scan(
+			&interval.Interval,
+			&interval.Visitors,
+			&interval.MobileVisitors,
+			&interval.DesktopVisitors,
+			&interval.ReadThroughs,
+		)
+		if err != nil {
+			return nil, err
+		}
+		intervals = append(intervals, interval)
+	}
+	return intervals, rows.Err()
+}
+
+// postVisitFromRaw reads post visitors from analytics_visits and
+// read-throughs from analytics_post_reads for the previous and current months.
+func (me *PsqlDB) postVisitFromRaw(opts *db.SummaryOpts) ([]*db.VisitInterval, error) {
+	now := time.Now()
+	currentMonthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	previousMonthStart := currentMonthStart.AddDate(0, -1, 0)
+
+	effectiveStart := previousMonthStart
+	if opts.Origin.After(previousMonthStart) {
+		effectiveStart = opts.Origin
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			interval_start,
+			sum(unique_visitors),
+			sum(mobile_visitors),
+			sum(desktop_visitors),
+			sum(read_throughs)
+		FROM (
+			SELECT
+				date_trunc('%[1]s', created_at)::timestamptz as interval_start,
+				count(DISTINCT ip_address) as unique_visitors,
+				count(DISTINCT CASE WHEN %[2]s THEN ip_address END) as mobile_visitors,
+				count(DISTINCT CASE WHEN NOT (%[2]s) THEN ip_address END) as desktop_visitors,
+				0 as read_throughs
+			FROM analytics_visits
+			WHERE created_at >= $1 AND created_at < $2 AND post_id = $3 AND user_id = $4 AND status <> 404
+			GROUP BY interval_start
+			UNION ALL
+			SELECT
+				date_trunc('%[1]s', created_at)::timestamptz as interval_start,
+				0, 0, 0,
+				count(DISTINCT ip_address)
+			FROM analytics_post_reads
+			WHERE created_at >= $1 AND created_at < $2 AND post_id = $3 AND user_id = $4
+			GROUP BY interval_start
+		) AS post_visits
+		GROUP BY interval_start
+		ORDER BY interval_start`, opts.Interval, mobileUserAgentExpr)
+
+	rows, err := me.Db.Queryx(query, effectiveStart, currentMonthStart.AddDate(0, 1, 0), opts.PostID, opts.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var intervals []*db.VisitInterval
+	for rows.Next() {
+		interval := &db.VisitInterval{}
+		err := rows.Scan(
+			&interval.Interval,
+			&interval.Visitors,
+			&interval.MobileVisitors,
+			&interval.DesktopVisitors,
+			&interval.ReadThroughs,
+		)
+		if err != nil {
+			return nil, err
+		}
+		intervals = append(intervals, interval)
+	}
+	return intervals, rows.Err()
+}
+
+// postRefererFromSummary reads top post referers from analytics_monthly_post_referers.
+func (me *PsqlDB) postRefererFromSummary(opts *db.SummaryOpts) ([]*db.VisitUrl, error) {
+	now := time.Now()
+	currentMonthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	previousMonthStart := currentMonthStart.AddDate(0, -1, 0)
+
+	if !opts.Origin.Before(previousMonthStart) {
+		return nil, nil
+	}
+	originMonthStart := time.Date(opts.Origin.Year(), opts.Origin.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var results []*db.VisitUrl
+	err := me.Db.Select(&results, `
+		SELECT referer as url, sum(unique_visits) as count
+		FROM analytics_monthly_post_referers
+		WHERE user_id = $1 AND post_id = $2 AND month >= $3 AND month < $4
+		GROUP BY referer
+		ORDER BY count DESC
+		LIMIT 10`, opts.UserID, opts.PostID, originMonthStart, currentMonthStart)
+	return results, err
+}
+
+// postRefererFromRaw reads top post referers from analytics_visits for the previous and current months.
+func (me *PsqlDB) postRefererFromRaw(opts *db.SummaryOpts) ([]*db.VisitUrl, error) {
+	now := time.Now()
+	currentMonthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	previousMonthStart := currentMonthStart.AddDate(0, -1, 0)
+
+	effectiveStart := previousMonthStart
+	if opts.Origin.After(previousMonthStart) {
+		effectiveStart = opts.Origin
+	}
+
+	var results []*db.VisitUrl
+	err := me.Db.Select(&results, `
+		SELECT referer as url, count(DISTINCT ip_address) as count
+		FROM analytics_visits
+		WHERE created_at >= $1 AND created_at < $2 AND post_id = $3 AND user_id = $4 AND referer <> '' AND status <> 404
+		GROUP BY referer
+		ORDER BY count DESC
+		LIMIT 10`, effectiveStart, currentMonthStart.AddDate(0, 1, 0), opts.PostID, opts.UserID)
+	return results, err
+}
+
+// FindVisitPostList returns the visitors and read-throughs of every post of
+// opts.UserID since opts.Origin, most visited first.  Like the site summary,
+// visitors are unique per day.
+func (me *PsqlDB) FindVisitPostList(opts *db.SummaryOpts) ([]*db.PostAnalytics, error) {
+	now := time.Now()
+	currentMonthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	previousMonthStart := currentMonthStart.AddDate(0, -1, 0)
+
+	// summary tables cover everything before the previous month, raw data the rest
+	effectiveStart := previousMonthStart
+	if opts.Origin.After(previousMonthStart) {
+		effectiveStart = opts.Origin
+	}
+
+	var posts []*db.PostAnalytics
+	err := me.Db.Select(&posts, `
+		SELECT
+			posts.id AS post_id,
+			posts.slug,
+			posts.title,
+			COALESCE(posts.views, 0) AS views,
+			sum(post_visits.visitors) AS visitors,
+			sum(post_visits.read_throughs) AS read_throughs
+		FROM (
+			SELECT post_id, sum(unique_visits) AS visitors, sum(read_throughs) AS read_throughs
+			FROM analytics_monthly_post_visits
+			WHERE user_id = $1 AND visit_date >= $2 AND visit_date < $3
+			GROUP BY post_id
+			UNION ALL
+			SELECT post_id, count(DISTINCT created_at::date::text || ip_address), 0
+			FROM analytics_visits
+			WHERE user_id = $1 AND post_id IS NOT NULL AND created_at >= $4 AND created_at < $5 AND status <> 404
+			GROUP BY post_id
+			UNION ALL
+			SELECT post_id, 0, count(DISTINCT created_at::date::text || ip_address)
+			FROM analytics_post_reads
+			WHERE user_id = $1 AND created_at >= $4 AND created_at < $5
+			GROUP BY post_id
+		) AS post_visits
+		INNER JOIN posts ON posts.id = post_visits.post_id
+		GROUP BY posts.id
+		ORDER BY visitors DESC, posts.slug`,
+		opts.UserID,
+		opts.Origin,
+		previousMonthStart,
+		effectiveStart,
+		currentMonthStart.AddDate(0, 1, 0),
+	)
+	return posts, err
+}
+
 func (me *PsqlDB) FindUsers() ([]*db.User, error) {
 	var users []*db.User
 	err := me.Db.Select(&users, `SELECT id, COALESCE(name, '') as name, created_at FROM app_users ORDER BY name ASC`)
@@ -1655,22 +2101,116 @@ func (me *PsqlDB) FindPostsByTag(pager *db.Pager, tag, space string) (*db.Pagina
 	return me.postPager(rs, pager.Num, space, tag)
 }
 
-func (me *PsqlDB) FindPopularTags(space string) ([]string, error) {
-	tags := make([]string, 0)
-	query := `
-	SELECT name, count(post_id) as "tally"
-	FROM post_tags
-	LEFT JOIN posts ON posts.id = post_id
-	WHERE posts.cur_space = $1
-	GROUP BY name
-	ORDER BY tally DESC
-	LIMIT 5`
-	rs, err := me.Db.Queryx(query, space)
-	if err != nil {
-		return tags, err
-	}
-	defer func() { _ = rs.Close() }()
-	for rs.Next() {
+// searchVector is the weighted document matched by FindPostsBySearch, it
+// has to stay in sync with the posts_search_idx expression index.
+const searchVector = `(
+	setweight(to_tsvector('english'::regconfig, coalesce(title, '')), 'A') ||
+	setweight(to_tsvector('english'::regconfig, coalesce(description, '')), 'B') ||
+	setweight(to_tsvector('english'::regconfig, coalesce(text, '')), 'C'))`
+
+var searchHeadlineOpts = fmt.Sprintf(
+	`StartSel=%s, StopSel=%s, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" ... "`,
+	db.SearchMarkStart,
+	db.SearchMarkEnd,
+)
+
+func (me *PsqlDB) FindPostsBySearch(pager *db.Pager, query, userID, space string) (*db.Paginate[*db.SearchResult], error) {
+	where := fmt.Sprintf(`
+		%s @@ websearch_to_tsquery('english', $1) AND
+		hidden = FALSE AND
+		publish_at::date <= CURRENT_DATE AND
+		cur_space = $2`, searchVector)
+	args := []any{query, space}
+	if userID != "" {
+		where += " AND user_id = $3"
+		args = append(args, userID)
+	}
+
+	n := len(args)
+	sqlQuery := fmt.Sprintf(`
+	SELECT %s,
+		ts_rank(%s, websearch_to_tsquery('english', $1)) AS rank,
+		ts_headline('english', text, websearch_to_tsquery('english', $1), $%d) AS snippet
+	FROM posts
+	LEFT JOIN app_users ON app_users.id = posts.user_id
+	WHERE %s
+	ORDER BY rank DESC, publish_at DESC
+	LIMIT $%d OFFSET $%d`, SelectPost, searchVector, n+1, where, n+2, n+3)
+	rs, err := me.Db.Queryx(
+		sqlQuery,
+		append(slices.Clone(args), searchHeadlineOpts, pager.Num, pager.Num*pager.Page)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rs.Close() }()
+
+	results := []*db.SearchResult{}
+	for rs.Next() {
+		post := &db.Post{}
+		result := &db.SearchResult{Post: post}
+		err := rs.Scan(
+			&post.ID,
+			&post.UserID,
+			&post.Username,
+			&post.Filename,
+			&post.Slug,
+			&post.Title,
+			&post.Text,
+			&post.Description,
+			&post.CreatedAt,
+			&post.PublishAt,
+			&post.UpdatedAt,
+			&post.Hidden,
+			&post.FileSize,
+			&post.MimeType,
+			&post.Shasum,
+			&post.Data,
+			&post.ExpiresAt,
+			&post.Views,
+			&result.Rank,
+			&result.Snippet,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if rs.Err() != nil {
+		return nil, rs.Err()
+	}
+
+	var count int
+	err = me.Db.QueryRow(
+		fmt.Sprintf(`SELECT count(posts.id) FROM posts WHERE %s`, where),
+		args...,
+	).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+
+	return &db.Paginate[*db.SearchResult]{
+		Data:  results,
+		Total: int(math.Ceil(float64(count) / float64(pager.Num))),
+	}, nil
+}
+
+func (me *PsqlDB) FindPopularTags(space string) ([]string, error) {
+	tags := make([]string, 0)
+	query := `
+	SELECT name, count(post_id) as "tally"
+	FROM post_tags
+	LEFT JOIN posts ON posts.id = post_id
+	WHERE posts.cur_space = $1
+	GROUP BY name
+	ORDER BY tally DESC
+	LIMIT 5`
+	rs, err := me.Db.Queryx(query, space)
+	if err != nil {
+		return tags, err
+	}
+	defer func() { _ = rs.Close() }()
+	for rs.Next() {
 		name := ""
 		tally := 0
 		err := rs.Scan(&name, &tally)
@@ -1790,6 +2330,26 @@ func (me *PsqlDB) InsertToken(userID, name string) (string, error) {
 	return token, nil
 }
 
+func (me *PsqlDB) InsertScopedToken(userID, name string, scopes []string, expiresAt *time.Time) (string, error) {
+	err := db.ValidateTokenScopes(scopes)
+	if err != nil {
+		return "", err
+	}
+
+	var token string
+	err = me.Db.QueryRow(
+		`INSERT INTO tokens (user_id, name, scopes, expires_at) VALUES($1, $2, $3, COALESCE($4, '2100-01-01 00:00:00'::timestamp)) RETURNING token;`,
+		userID,
+		name,
+		strings.Join(scopes, ","),
+		expiresAt,
+	).Scan(&token)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
 func (me *PsqlDB) UpsertToken(userID, name string) (string, error) {
 	token, _ := me.findTokenByName(userID, name)
 	if token != "" {
@@ -1816,7 +2376,7 @@ func (me *PsqlDB) RemoveToken(tokenID string) error {
 
 func (me *PsqlDB) FindTokensByUser(userID string) ([]*db.Token, error) {
 	var tokens []*db.Token
-	err := me.Db.Select(&tokens, `SELECT * FROM tokens WHERE user_id = $1`, userID)
+	err := me.Db.Select(&tokens, `SELECT id, user_id, name, token, scopes, created_at, expires_at, last_used_at FROM tokens WHERE user_id = $1 ORDER BY created_at`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -2089,7 +2649,7 @@ func (me *PsqlDB) RemovePipeMonitor(userID, topic string) error {
 
 func (me *PsqlDB) FindPipeMonitorByTopic(userID, topic string) (*db.PipeMonitor, error) {
 	monitor := &db.PipeMonitor{}
-	err := me.Db.Get(monitor, `SELECT id, user_id, topic, (EXTRACT(EPOCH FROM window_dur) * 1000000000)::bigint as window_dur, window_end, last_ping, created_at, updated_at FROM pipe_monitors WHERE user_id = $1 AND topic = $2;`, userID, topic)
+	err := me.Db.Get(monitor, `SELECT id, user_id, topic, (EXTRACT(EPOCH FROM window_dur) * 1000000000)::bigint as window_dur, window_end, last_ping, alert_status, alerted_at, created_at, updated_at FROM pipe_monitors WHERE user_id = $1 AND topic = $2;`, userID, topic)
 	if err != nil {
 		return nil, err
 	}
@@ -2098,7 +2658,7 @@ func (me *PsqlDB) FindPipeMonitorByTopic(userID, topic string) (*db.PipeMonitor,
 
 func (me *PsqlDB) FindPipeMonitorsByUser(userID string) ([]*db.PipeMonitor, error) {
 	var monitors []*db.PipeMonitor
-	err := me.Db.Select(&monitors, `SELECT id, user_id, topic, (EXTRACT(EPOCH FROM window_dur) * 1000000000)::bigint as window_dur, window_end, last_ping, created_at, updated_at FROM pipe_monitors WHERE user_id = $1 ORDER BY topic;`, userID)
+	err := me.Db.Select(&monitors, `SELECT id, user_id, topic, (EXTRACT(EPOCH FROM window_dur) * 1000000000)::bigint as window_dur, window_end, last_ping, alert_status, alerted_at, created_at, updated_at FROM pipe_monitors WHERE user_id = $1 ORDER BY topic;`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -2126,3 +2686,649 @@ func (me *PsqlDB) FindPipeMonitorHistory(monitorID string, from, to time.Time) (
 	}
 	return history, nil
 }
+
+// UpsertPipeMonitorTarget confirms targets added without a token right
+// away, a token starts a new confirmation of the target.
+func (me *PsqlDB) UpsertPipeMonitorTarget(monitorID, kind, target, secret, token string) error {
+	_, err := me.Db.Exec(
+		`INSERT INTO pipe_monitor_targets (monitor_id, kind, target, secret, token, confirmed_at)
+		VALUES ($1, $2, $3, $4, $5, CASE WHEN $5 = '' THEN NOW() END)
+		ON CONFLICT (monitor_id, kind, target) DO UPDATE SET
+			secret = $4,
+			token = $5,
+			confirmed_at = CASE WHEN $5 = '' THEN NOW() END,
+			updated_at = NOW();`,
+		monitorID,
+		kind,
+		target,
+		secret,
+		token,
+	)
+	return err
+}
+
+func (me *PsqlDB) ConfirmPipeMonitorTarget(token string) (*db.PipeMonitorTarget, error) {
+	target := &db.PipeMonitorTarget{}
+	err := me.Db.Get(
+		target,
+		`UPDATE pipe_monitor_targets SET confirmed_at = COALESCE(confirmed_at, NOW()), updated_at = NOW()
+		WHERE token = $1 AND token <> ''
+		RETURNING id, monitor_id, kind, target, secret, token, confirmed_at, created_at, updated_at;`,
+		token,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+func (me *PsqlDB) RemovePipeMonitorTarget(monitorID, kind, target string) error {
+	_, err := me.Db.Exec(
+		`DELETE FROM pipe_monitor_targets WHERE monitor_id = $1 AND kind = $2 AND target = $3;`,
+		monitorID,
+		kind,
+		target,
+	)
+	return err
+}
+
+func (me *PsqlDB) FindPipeMonitorTargets(monitorID string) ([]*db.PipeMonitorTarget, error) {
+	var targets []*db.PipeMonitorTarget
+	err := me.Db.Select(&targets, `SELECT id, monitor_id, kind, target, secret, token, confirmed_at, created_at, updated_at FROM pipe_monitor_targets WHERE monitor_id = $1 ORDER BY kind, target;`, monitorID)
+	if err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+func (me *PsqlDB) FindPipeMonitorsWithTargets() ([]*db.PipeMonitor, error) {
+	var monitors []*db.PipeMonitor
+	err := me.Db.Select(
+		&monitors,
+		`SELECT id, user_id, topic, (EXTRACT(EPOCH FROM window_dur) * 1000000000)::bigint as window_dur, window_end, last_ping, alert_status, alerted_at, created_at, updated_at
+		FROM pipe_monitors
+		WHERE EXISTS (SELECT 1 FROM pipe_monitor_targets WHERE monitor_id = pipe_monitors.id)
+		ORDER BY user_id, topic;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return monitors, nil
+}
+
+// UpdatePipeMonitorAlertStatus is a compare-and-swap on the alert status
+// so only one of several alerters records, and sends, a transition.
+func (me *PsqlDB) UpdatePipeMonitorAlertStatus(monitorID, prev, status string, alertedAt *time.Time) (bool, error) {
+	res, err := me.Db.Exec(
+		`UPDATE pipe_monitors SET alert_status = $3, alerted_at = $4, updated_at = NOW() WHERE id = $1 AND alert_status = $2;`,
+		monitorID,
+		prev,
+		status,
+		alertedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (me *PsqlDB) UpsertPipeAcl(userID, topic, principal, permission string) error {
+	_, err := me.Db.Exec(
+		`INSERT INTO pipe_topic_acls (user_id, topic, principal, permission)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, topic, principal) DO UPDATE SET permission = $4, updated_at = NOW();`,
+		userID,
+		topic,
+		principal,
+		permission,
+	)
+	return err
+}
+
+func (me *PsqlDB) RemovePipeAcl(userID, topic, principal string) error {
+	_, err := me.Db.Exec(
+		`DELETE FROM pipe_topic_acls WHERE user_id = $1 AND topic = $2 AND principal = $3;`,
+		userID,
+		topic,
+		principal,
+	)
+	return err
+}
+
+func (me *PsqlDB) FindPipeAclsByTopic(userID, topic string) ([]*db.PipeAcl, error) {
+	var acls []*db.PipeAcl
+	err := me.Db.Select(&acls, `SELECT id, user_id, topic, principal, permission, created_at FROM pipe_topic_acls WHERE user_id = $1 AND topic = $2 ORDER BY principal;`, userID, topic)
+	if err != nil {
+		return nil, err
+	}
+	return acls, nil
+}
+
+func (me *PsqlDB) FindPipeAclsByUser(userID string) ([]*db.PipeAcl, error) {
+	var acls []*db.PipeAcl
+	err := me.Db.Select(&acls, `SELECT id, user_id, topic, principal, permission, created_at FROM pipe_topic_acls WHERE user_id = $1 ORDER BY topic, principal;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return acls, nil
+}
+
+func (me *PsqlDB) FindSshCertAuthority(userID string) (*db.SshCertAuthority, error) {
+	ca := &db.SshCertAuthority{}
+	err := me.Db.Get(ca, `SELECT id, user_id, public_key, private_key, created_at FROM ssh_cert_authorities WHERE user_id = $1;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+func (me *PsqlDB) InsertSshCertAuthority(userID, pubkey, privkey string) (*db.SshCertAuthority, error) {
+	tx, err := me.Db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.Exec(
+		`INSERT INTO ssh_cert_authorities (user_id, public_key, private_key) VALUES ($1, $2, $3);`,
+		userID,
+		pubkey,
+		privkey,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// certs signed by the CA authenticate as the user through its pubkey
+	err = me.insertPublicKeyWithTx(userID, pubkey, "pico-ca", tx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return me.FindSshCertAuthority(userID)
+}
+
+func (me *PsqlDB) InsertSshCert(cert *db.SshCert) error {
+	_, err := me.Db.Exec(
+		`INSERT INTO ssh_certs (ca_id, user_id, serial, key_id, principals, public_key, valid_after, valid_before)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`,
+		cert.CaID,
+		cert.UserID,
+		cert.Serial,
+		cert.KeyID,
+		cert.Principals,
+		cert.PublicKey,
+		cert.ValidAfter,
+		cert.ValidBefore,
+	)
+	return err
+}
+
+func (me *PsqlDB) FindSshCertsByUser(userID string) ([]*db.SshCert, error) {
+	var certs []*db.SshCert
+	err := me.Db.Select(
+		&certs,
+		`SELECT id, ca_id, user_id, serial, key_id, principals, public_key, valid_after, valid_before, revoked_at, created_at
+		FROM ssh_certs WHERE user_id = $1 ORDER BY created_at DESC;`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+func (me *PsqlDB) RevokeSshCert(userID string, serial int64) error {
+	res, err := me.Db.Exec(
+		`UPDATE ssh_certs SET revoked_at = NOW() WHERE user_id = $1 AND serial = $2 AND revoked_at IS NULL;`,
+		userID,
+		serial,
+	)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("no active cert found with serial %d", serial)
+	}
+	return nil
+}
+
+func (me *PsqlDB) IsSshCertRevoked(caPubkey string, serial int64) (bool, error) {
+	return db.IsSshCertRevoked(me.Db, caPubkey, serial)
+}
+
+func (me *PsqlDB) InsertOrg(ownerID, name string) (*db.User, error) {
+	lowerName := strings.ToLower(name)
+	valid, err := me.validateName(lowerName)
+	if !valid {
+		return nil, err
+	}
+
+	tx, err := me.Db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var id string
+	err = tx.QueryRow(`INSERT INTO app_users (name) VALUES($1) returning id`, lowerName).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO org_members (org_id, user_id, role) VALUES ($1, $2, $3);`,
+		id,
+		ownerID,
+		db.OrgRoleOwner,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return me.FindUser(id)
+}
+
+const sqlSelectOrgMembers = `SELECT org_members.id, org_id, orgs.name as org_name, user_id, members.name as user_name, role, org_members.created_at
+	FROM org_members
+	INNER JOIN app_users orgs ON orgs.id = org_members.org_id
+	INNER JOIN app_users members ON members.id = org_members.user_id`
+
+func (me *PsqlDB) FindOrgMember(orgID, userID string) (*db.OrgMember, error) {
+	member := &db.OrgMember{}
+	err := me.Db.Get(member, sqlSelectOrgMembers+` WHERE org_id = $1 AND user_id = $2;`, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func (me *PsqlDB) FindOrgMembers(orgID string) ([]*db.OrgMember, error) {
+	var members []*db.OrgMember
+	err := me.Db.Select(&members, sqlSelectOrgMembers+` WHERE org_id = $1 ORDER BY members.name;`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (me *PsqlDB) FindOrgsByUser(userID string) ([]*db.OrgMember, error) {
+	var orgs []*db.OrgMember
+	err := me.Db.Select(&orgs, sqlSelectOrgMembers+` WHERE user_id = $1 ORDER BY orgs.name;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+func (me *PsqlDB) UpsertOrgMember(orgID, userID, role string) error {
+	_, err := me.Db.Exec(
+		`INSERT INTO org_members (org_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (org_id, user_id) DO UPDATE SET role = $3, updated_at = NOW();`,
+		orgID,
+		userID,
+		role,
+	)
+	return err
+}
+
+func (me *PsqlDB) RemoveOrgMember(orgID, userID string) error {
+	_, err := me.Db.Exec(
+		`DELETE FROM org_members WHERE org_id = $1 AND user_id = $2;`,
+		orgID,
+		userID,
+	)
+	return err
+}
+
+func (me *PsqlDB) FindFederationKey(userID string) (*db.FederationKey, error) {
+	key := &db.FederationKey{}
+	err := me.Db.Get(key, `SELECT id, user_id, public_key, private_key, created_at FROM federation_keys WHERE user_id = $1;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (me *PsqlDB) InsertFederationKey(userID, pubkey, privkey string) (*db.FederationKey, error) {
+	// two requests racing to create the key both end up with the first one
+	_, err := me.Db.Exec(
+		`INSERT INTO federation_keys (user_id, public_key, private_key) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO NOTHING;`,
+		userID,
+		pubkey,
+		privkey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return me.FindFederationKey(userID)
+}
+
+func (me *PsqlDB) UpsertFollower(userID, actor, inbox string) error {
+	_, err := me.Db.Exec(
+		`INSERT INTO federation_followers (user_id, actor, inbox)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, actor) DO UPDATE SET inbox = $3;`,
+		userID,
+		actor,
+		inbox,
+	)
+	return err
+}
+
+func (me *PsqlDB) RemoveFollower(userID, actor string) error {
+	_, err := me.Db.Exec(
+		`DELETE FROM federation_followers WHERE user_id = $1 AND actor = $2;`,
+		userID,
+		actor,
+	)
+	return err
+}
+
+func (me *PsqlDB) FindFollowersByUser(userID string) ([]*db.Follower, error) {
+	var followers []*db.Follower
+	err := me.Db.Select(
+		&followers,
+		`SELECT id, user_id, actor, inbox, created_at FROM federation_followers WHERE user_id = $1 ORDER BY created_at;`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return followers, nil
+}
+
+func (me *PsqlDB) UpsertPostMention(mention *db.PostMention) error {
+	_, err := me.Db.Exec(
+		`INSERT INTO post_mentions (post_id, kind, source, author_name, author_url, content)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (post_id, source) DO UPDATE
+		SET kind = $2, author_name = $4, author_url = $5, content = $6, updated_at = NOW();`,
+		mention.PostID,
+		mention.Kind,
+		mention.Source,
+		mention.AuthorName,
+		mention.AuthorURL,
+		mention.Content,
+	)
+	return err
+}
+
+func (me *PsqlDB) RemovePostMention(postID, source string) error {
+	_, err := me.Db.Exec(`DELETE FROM post_mentions WHERE post_id = $1 AND source = $2;`, postID, source)
+	return err
+}
+
+func (me *PsqlDB) RemovePostMentionsBySource(source string) error {
+	_, err := me.Db.Exec(`DELETE FROM post_mentions WHERE source = $1;`, source)
+	return err
+}
+
+func (me *PsqlDB) FindPostMentions(postID string) ([]*db.PostMention, error) {
+	var mentions []*db.PostMention
+	err := me.Db.Select(
+		&mentions,
+		`SELECT id, post_id, kind, source, author_name, author_url, content, created_at, updated_at
+		FROM post_mentions WHERE post_id = $1 ORDER BY created_at;`,
+		postID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return mentions, nil
+}
+
+var selectPostComment = `
+	post_comments.id, post_comments.post_id, posts.slug AS post_slug,
+	post_comments.user_id, app_users.name AS username, post_comments.text,
+	post_comments.approved, post_comments.created_at`
+
+func (me *PsqlDB) InsertPostComment(comment *db.PostComment) (*db.PostComment, error) {
+	var id string
+	err := me.Db.QueryRow(
+		`INSERT INTO post_comments (post_id, user_id, text, approved)
+		VALUES ($1, $2, $3, $4) RETURNING id;`,
+		comment.PostID,
+		comment.UserID,
+		comment.Text,
+		comment.Approved,
+	).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	created := &db.PostComment{}
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM post_comments
+	INNER JOIN posts ON posts.id = post_comments.post_id
+	INNER JOIN app_users ON app_users.id = post_comments.user_id
+	WHERE post_comments.id = $1`, selectPostComment)
+	err = me.Db.Get(created, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (me *PsqlDB) FindPostComments(postID string) ([]*db.PostComment, error) {
+	var comments []*db.PostComment
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM post_comments
+	INNER JOIN posts ON posts.id = post_comments.post_id
+	INNER JOIN app_users ON app_users.id = post_comments.user_id
+	WHERE post_comments.post_id = $1
+	ORDER BY post_comments.created_at`, selectPostComment)
+	err := me.Db.Select(&comments, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// FindPostCommentsByOwner returns the comments on every post of ownerID,
+// newest first, for moderation.
+func (me *PsqlDB) FindPostCommentsByOwner(ownerID, space string) ([]*db.PostComment, error) {
+	var comments []*db.PostComment
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM post_comments
+	INNER JOIN posts ON posts.id = post_comments.post_id
+	INNER JOIN app_users ON app_users.id = post_comments.user_id
+	WHERE posts.user_id = $1 AND posts.cur_space = $2
+	ORDER BY post_comments.created_at DESC`, selectPostComment)
+	err := me.Db.Select(&comments, query, ownerID, space)
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (me *PsqlDB) ApprovePostComment(ownerID, commentID string) error {
+	res, err := me.Db.Exec(
+		`UPDATE post_comments SET approved = true
+		FROM posts
+		WHERE posts.id = post_comments.post_id AND posts.user_id = $1 AND post_comments.id = $2;`,
+		ownerID,
+		commentID,
+	)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("comment %s not found", commentID)
+	}
+	return nil
+}
+
+func (me *PsqlDB) RemovePostComment(ownerID, commentID string) error {
+	res, err := me.Db.Exec(
+		`DELETE FROM post_comments
+		USING posts
+		WHERE posts.id = post_comments.post_id AND posts.user_id = $1 AND post_comments.id = $2;`,
+		ownerID,
+		commentID,
+	)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("comment %s not found", commentID)
+	}
+	return nil
+}
+
+var selectNewsletterSubscriber = `
+	id, user_id, email, token, status, bounces, confirmed_at, created_at, updated_at`
+
+// UpsertNewsletterSubscriber creates the subscription of an email address
+// to the blog of sub.UserID or updates its token, status and bounces.
+func (me *PsqlDB) UpsertNewsletterSubscriber(sub *db.NewsletterSubscriber) (*db.NewsletterSubscriber, error) {
+	query := fmt.Sprintf(`
+	INSERT INTO newsletter_subscribers (user_id, email, token, status, bounces, confirmed_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (user_id, email) DO UPDATE SET
+		token = EXCLUDED.token,
+		status = EXCLUDED.status,
+		bounces = EXCLUDED.bounces,
+		confirmed_at = EXCLUDED.confirmed_at,
+		updated_at = NOW()
+	RETURNING %s;`, selectNewsletterSubscriber)
+	updated := &db.NewsletterSubscriber{}
+	err := me.Db.Get(
+		updated,
+		query,
+		sub.UserID,
+		sub.Email,
+		sub.Token,
+		sub.Status,
+		sub.Bounces,
+		sub.ConfirmedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (me *PsqlDB) FindNewsletterSubscriber(userID, email string) (*db.NewsletterSubscriber, error) {
+	sub := &db.NewsletterSubscriber{}
+	query := fmt.Sprintf(
+		`SELECT %s FROM newsletter_subscribers WHERE user_id = $1 AND email = $2;`,
+		selectNewsletterSubscriber,
+	)
+	err := me.Db.Get(sub, query, userID, email)
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (me *PsqlDB) FindNewsletterSubscriberByToken(token string) (*db.NewsletterSubscriber, error) {
+	sub := &db.NewsletterSubscriber{}
+	query := fmt.Sprintf(
+		`SELECT %s FROM newsletter_subscribers WHERE token = $1;`,
+		selectNewsletterSubscriber,
+	)
+	err := me.Db.Get(sub, query, token)
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// FindNewsletterSubscribersByEmail returns the subscriptions of an email
+// address to every blog, bounces are reported per address.
+func (me *PsqlDB) FindNewsletterSubscribersByEmail(email string) ([]*db.NewsletterSubscriber, error) {
+	var subs []*db.NewsletterSubscriber
+	query := fmt.Sprintf(
+		`SELECT %s FROM newsletter_subscribers WHERE email = $1 ORDER BY created_at;`,
+		selectNewsletterSubscriber,
+	)
+	err := me.Db.Select(&subs, query, email)
+	if err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+func (me *PsqlDB) FindNewsletterSubscribers(userID, status string) ([]*db.NewsletterSubscriber, error) {
+	var subs []*db.NewsletterSubscriber
+	query := fmt.Sprintf(
+		`SELECT %s FROM newsletter_subscribers WHERE user_id = $1 AND status = $2 ORDER BY created_at;`,
+		selectNewsletterSubscriber,
+	)
+	err := me.Db.Select(&subs, query, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// InsertNewsletterPost queues a post for the subscribers of its blog, a
+// post is only ever queued once.
+func (me *PsqlDB) InsertNewsletterPost(userID, postID string) error {
+	_, err := me.Db.Exec(
+		`INSERT INTO newsletter_posts (user_id, post_id) VALUES ($1, $2)
+		ON CONFLICT (post_id) DO NOTHING;`,
+		userID,
+		postID,
+	)
+	return err
+}
+
+func (me *PsqlDB) FindPendingNewsletterPosts() ([]*db.NewsletterPost, error) {
+	var posts []*db.NewsletterPost
+	err := me.Db.Select(
+		&posts,
+		`SELECT id, user_id, post_id, created_at, sent_at
+		FROM newsletter_posts WHERE sent_at IS NULL ORDER BY created_at;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (me *PsqlDB) MarkNewsletterPostsSent(ids []string, sentAt time.Time) error {
+	param := "{" + strings.Join(ids, ",") + "}"
+	_, err := me.Db.Exec(
+		`UPDATE newsletter_posts SET sent_at = $1 WHERE id = ANY($2::uuid[]);`,
+		sentAt,
+		param,
+	)
+	return err
+}
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -156,10 +158,11 @@ func setupTestSchema(db *sqlx.DB) error {
 func cleanupTestData(t *testing.T) {
 	t.Helper()
 	tables := []string{
-		"access_logs", "tuns_event_logs", "analytics_visits",
+		"access_logs", "tuns_event_logs", "analytics_visits", "analytics_post_reads",
+		"analytics_monthly_post_visits", "analytics_monthly_post_referers",
 		"feed_items", "post_aliases", "post_tags", "posts",
 		"projects", "feature_flags", "payment_history", "tokens",
-		"public_keys", "pipe_monitors", "app_users",
+		"public_keys", "newsletter_posts", "newsletter_subscribers", "post_comments", "post_mentions", "federation_followers", "federation_keys", "org_members", "ssh_certs", "ssh_cert_authorities", "pipe_monitor_targets", "pipe_monitors", "pipe_topic_acls", "app_users",
 	}
 	for _, table := range tables {
 		_, err := testDB.Db.Exec(fmt.Sprintf("DELETE FROM %s", table))
@@ -778,6 +781,137 @@ func TestFindPostsByFeed(t *testing.T) {
 	}
 }
 
+func TestFindPostsBySearch(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("searchowner", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI searchowner", "comment", "")
+	other, _ := testDB.RegisterUser("searchother", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI searchother", "comment", "")
+
+	now := time.Now()
+	future := now.Add(72 * time.Hour)
+	_ = mustInsertPost(t, &db.Post{UserID: user.ID, Filename: "body.md", Slug: "body", Title: "Notes", Text: "a few words about running a marathon", Space: "prose", PublishAt: &now})
+	_ = mustInsertPost(t, &db.Post{UserID: other.ID, Filename: "title.md", Slug: "title", Title: "Running", Text: "training log", Space: "prose", PublishAt: &now})
+	_ = mustInsertPost(t, &db.Post{UserID: user.ID, Filename: "hidden.md", Slug: "hidden", Title: "Running", Hidden: true, Space: "prose", PublishAt: &now})
+	_ = mustInsertPost(t, &db.Post{UserID: user.ID, Filename: "future.md", Slug: "future", Title: "Running", Space: "prose", PublishAt: &future})
+
+	pager := &db.Pager{Num: 10, Page: 0}
+	result, err := testDB.FindPostsBySearch(pager, "run", "", "prose")
+	if err != nil {
+		t.Fatalf("FindPostsBySearch failed: %v", err)
+	}
+	if len(result.Data) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(result.Data))
+	}
+	if result.Data[0].Post.Slug != "title" {
+		t.Errorf("expected title match to rank first, got %s", result.Data[0].Post.Slug)
+	}
+	if !strings.Contains(result.Data[1].Snippet, db.SearchMarkStart+"running"+db.SearchMarkEnd) {
+		t.Errorf("expected highlighted snippet, got %q", result.Data[1].Snippet)
+	}
+
+	result, err = testDB.FindPostsBySearch(pager, "run", user.ID, "prose")
+	if err != nil {
+		t.Fatalf("FindPostsBySearch failed: %v", err)
+	}
+	if len(result.Data) != 1 || result.Data[0].Post.Slug != "body" {
+		t.Errorf("expected only the user's post, got %d results", len(result.Data))
+	}
+}
+
+func TestFindScheduledPosts(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("scheduleowner", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI scheduleowner", "comment", "")
+
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(72 * time.Hour)
+	scheduled := db.PostData{Scheduled: true}
+	_ = mustInsertPost(t, &db.Post{UserID: user.ID, Filename: "due.md", Slug: "due", Space: "prose", PublishAt: &past, Data: scheduled})
+	_ = mustInsertPost(t, &db.Post{UserID: user.ID, Filename: "later.md", Slug: "later", Space: "prose", PublishAt: &future, Data: scheduled})
+	_ = mustInsertPost(t, &db.Post{UserID: user.ID, Filename: "live.md", Slug: "live", Space: "prose", PublishAt: &past})
+
+	posts, err := testDB.FindScheduledPosts("prose", now)
+	if err != nil {
+		t.Fatalf("FindScheduledPosts failed: %v", err)
+	}
+	if len(posts) != 1 || posts[0].Slug != "due" {
+		t.Fatalf("expected only the due post, got %d posts", len(posts))
+	}
+	if posts[0].Username != "scheduleowner" {
+		t.Errorf("expected username, got %q", posts[0].Username)
+	}
+}
+
+func TestFindPostsBySeries(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("seriesowner", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI seriesowner", "comment", "")
+
+	now := time.Now()
+	first := now.Add(-48 * time.Hour)
+	future := now.Add(72 * time.Hour)
+	series := db.PostData{Series: "go"}
+	_ = mustInsertPost(t, &db.Post{UserID: user.ID, Filename: "two.md", Slug: "two", Space: "prose", PublishAt: &now, Data: series})
+	_ = mustInsertPost(t, &db.Post{UserID: user.ID, Filename: "one.md", Slug: "one", Space: "prose", PublishAt: &first, Data: series})
+	_ = mustInsertPost(t, &db.Post{UserID: user.ID, Filename: "draft.md", Slug: "draft", Space: "prose", PublishAt: &now, Hidden: true, Data: series})
+	_ = mustInsertPost(t, &db.Post{UserID: user.ID, Filename: "three.md", Slug: "three", Space: "prose", PublishAt: &future, Data: series})
+	_ = mustInsertPost(t, &db.Post{UserID: user.ID, Filename: "other.md", Slug: "other", Space: "prose", PublishAt: &now})
+
+	posts, err := testDB.FindPostsBySeries(user.ID, "go", "prose")
+	if err != nil {
+		t.Fatalf("FindPostsBySeries failed: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("expected 2 posts, got %d", len(posts))
+	}
+	if posts[0].Slug != "one" || posts[1].Slug != "two" {
+		t.Errorf("expected posts in publish order, got %s, %s", posts[0].Slug, posts[1].Slug)
+	}
+}
+
+func TestFindPostsByLang(t *testing.T) {
+	cleanupTestData(t)
+
+	en, _ := testDB.RegisterUser("langen", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI langen", "comment", "")
+	de, _ := testDB.RegisterUser("langde", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI langde", "comment", "")
+	none, _ := testDB.RegisterUser("langnone", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI langnone", "comment", "")
+
+	now := time.Now()
+	_ = mustInsertPost(t, &db.Post{UserID: en.ID, Filename: "hello.md", Slug: "hello", Space: "prose", PublishAt: &now, Data: db.PostData{Lang: "en"}})
+	_ = mustInsertPost(t, &db.Post{UserID: de.ID, Filename: "hallo.md", Slug: "hallo", Space: "prose", PublishAt: &now, Data: db.PostData{Lang: "de-AT"}})
+	_ = mustInsertPost(t, &db.Post{UserID: none.ID, Filename: "plain.md", Slug: "plain", Space: "prose", PublishAt: &now})
+
+	pager, err := testDB.FindPostsByLang(&db.Pager{Num: 10, Page: 0}, "de", "prose")
+	if err != nil {
+		t.Fatalf("FindPostsByLang failed: %v", err)
+	}
+	slugs := []string{}
+	for _, post := range pager.Data {
+		slugs = append(slugs, post.Slug)
+	}
+	sort.Strings(slugs)
+	if strings.Join(slugs, ",") != "hallo,plain" {
+		t.Errorf("expected german and neutral posts, got %v", slugs)
+	}
+
+	userPager, err := testDB.FindUserPostsByLang(&db.Pager{Num: 10, Page: 0}, "de", en.ID, "prose")
+	if err != nil {
+		t.Fatalf("FindUserPostsByLang failed: %v", err)
+	}
+	if len(userPager.Data) != 0 {
+		t.Errorf("expected no german posts for english blog, got %d", len(userPager.Data))
+	}
+
+	langs, err := testDB.FindPostLangs("prose")
+	if err != nil {
+		t.Fatalf("FindPostLangs failed: %v", err)
+	}
+	if strings.Join(langs, ",") != "de-at,en" {
+		t.Errorf("expected de-at,en, got %v", langs)
+	}
+}
+
 // ============ Tags Tests ============
 
 func TestReplaceTagsByPost(t *testing.T) {
@@ -984,6 +1118,71 @@ func TestVisitUrlNotFound(t *testing.T) {
 	}
 }
 
+func TestPostVisitSummary(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("poststatsowner", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI poststatsowner", "comment", "")
+	post := mustInsertPost(t, &db.Post{
+		UserID:   user.ID,
+		Filename: "stats.md",
+		Slug:     "stats",
+		Title:    "Stats",
+		Text:     "# stats",
+		Space:    "prose",
+	})
+
+	for _, ip := range []string{"a", "b", "c"} {
+		_ = testDB.InsertVisit(&db.AnalyticsVisits{
+			UserID:    user.ID,
+			PostID:    post.ID,
+			Host:      "poststatsowner.prose.sh",
+			Path:      "/stats",
+			IpAddress: ip,
+			Referer:   "lobste.rs",
+			Status:    200,
+		})
+	}
+	err := testDB.InsertPostRead(&db.AnalyticsVisits{
+		UserID:    user.ID,
+		PostID:    post.ID,
+		Host:      "poststatsowner.prose.sh",
+		IpAddress: "a",
+	})
+	if err != nil {
+		t.Fatalf("InsertPostRead failed: %v", err)
+	}
+
+	opts := &db.SummaryOpts{
+		Interval: "day",
+		Origin:   time.Now().Add(-24 * time.Hour),
+		UserID:   user.ID,
+		PostID:   post.ID,
+	}
+	stats, err := testDB.PostVisitSummary(opts)
+	if err != nil {
+		t.Fatalf("PostVisitSummary failed: %v", err)
+	}
+	if stats.Slug != "stats" || stats.Visitors != 3 || stats.ReadThroughs != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if len(stats.TopReferers) != 1 || stats.TopReferers[0].Count != 3 {
+		t.Errorf("unexpected referers %+v", stats.TopReferers)
+	}
+
+	posts, err := testDB.FindVisitPostList(opts)
+	if err != nil {
+		t.Fatalf("FindVisitPostList failed: %v", err)
+	}
+	if len(posts) != 1 || posts[0].Visitors != 3 || posts[0].ReadThroughs != 1 {
+		t.Errorf("unexpected post list %+v", posts)
+	}
+
+	opts.UserID = "00000000-0000-0000-0000-000000000000"
+	if _, err := testDB.PostVisitSummary(opts); err == nil {
+		t.Error("expected post of another user to fail")
+	}
+}
+
 // ============ Features Tests ============
 
 func TestInsertFeature(t *testing.T) {
@@ -1640,3 +1839,600 @@ func TestFindPipeMonitorsByUser_Empty(t *testing.T) {
 		t.Errorf("expected 0 monitors for user with none, got %d", len(monitors))
 	}
 }
+
+func TestPipeMonitorTargets(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("pipetargetowner", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI pipetargetowner", "comment", "")
+
+	winEnd := time.Now().Add(time.Hour)
+	_ = testDB.UpsertPipeMonitor(user.ID, "pipetargetowner/cron", time.Hour, &winEnd)
+	monitor, err := testDB.FindPipeMonitorByTopic(user.ID, "pipetargetowner/cron")
+	if err != nil {
+		t.Fatalf("FindPipeMonitorByTopic failed: %v", err)
+	}
+
+	err = testDB.UpsertPipeMonitorTarget(monitor.ID, db.PipeTargetWebhook, "https://example.com", "one", "")
+	if err != nil {
+		t.Fatalf("UpsertPipeMonitorTarget failed: %v", err)
+	}
+	err = testDB.UpsertPipeMonitorTarget(monitor.ID, db.PipeTargetWebhook, "https://example.com", "two", "")
+	if err != nil {
+		t.Fatalf("second UpsertPipeMonitorTarget failed: %v", err)
+	}
+	_ = testDB.UpsertPipeMonitorTarget(monitor.ID, db.PipeTargetEmail, "me@example.com", "", "confirm-token")
+
+	targets, err := testDB.FindPipeMonitorTargets(monitor.ID)
+	if err != nil {
+		t.Fatalf("FindPipeMonitorTargets failed: %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(targets))
+	}
+	if targets[1].Kind != db.PipeTargetWebhook || targets[1].Secret != "two" {
+		t.Errorf("expected webhook secret to be replaced, got %s %s", targets[1].Kind, targets[1].Secret)
+	}
+	if targets[1].ConfirmedAt == nil {
+		t.Error("expected webhook target to be confirmed")
+	}
+	if targets[0].ConfirmedAt != nil {
+		t.Error("expected email target to wait for confirmation")
+	}
+
+	if _, err := testDB.ConfirmPipeMonitorTarget("nope"); err == nil {
+		t.Error("expected unknown token to fail")
+	}
+	confirmed, err := testDB.ConfirmPipeMonitorTarget("confirm-token")
+	if err != nil {
+		t.Fatalf("ConfirmPipeMonitorTarget failed: %v", err)
+	}
+	if confirmed.Target != "me@example.com" || confirmed.ConfirmedAt == nil {
+		t.Errorf("expected confirmed email target, got %+v", confirmed)
+	}
+
+	monitors, err := testDB.FindPipeMonitorsWithTargets()
+	if err != nil {
+		t.Fatalf("FindPipeMonitorsWithTargets failed: %v", err)
+	}
+	if len(monitors) != 1 || monitors[0].ID != monitor.ID {
+		t.Errorf("expected monitor with targets, got %v", monitors)
+	}
+
+	err = testDB.RemovePipeMonitorTarget(monitor.ID, db.PipeTargetEmail, "me@example.com")
+	if err != nil {
+		t.Fatalf("RemovePipeMonitorTarget failed: %v", err)
+	}
+	targets, _ = testDB.FindPipeMonitorTargets(monitor.ID)
+	if len(targets) != 1 {
+		t.Errorf("expected 1 target after removal, got %d", len(targets))
+	}
+}
+
+func TestPipeMonitorTargets_InvalidKind(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("pipetargetinvalid", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI pipetargetinvalid", "comment", "")
+
+	winEnd := time.Now().Add(time.Hour)
+	_ = testDB.UpsertPipeMonitor(user.ID, "pipetargetinvalid/cron", time.Hour, &winEnd)
+	monitor, _ := testDB.FindPipeMonitorByTopic(user.ID, "pipetargetinvalid/cron")
+
+	err := testDB.UpsertPipeMonitorTarget(monitor.ID, "sms", "555", "", "")
+	if err == nil {
+		t.Error("expected error for invalid target kind, got nil")
+	}
+}
+
+func TestUpdatePipeMonitorAlertStatus(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("pipealertowner", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI pipealertowner", "comment", "")
+
+	winEnd := time.Now().Add(time.Hour)
+	_ = testDB.UpsertPipeMonitor(user.ID, "pipealertowner/cron", time.Hour, &winEnd)
+	monitor, _ := testDB.FindPipeMonitorByTopic(user.ID, "pipealertowner/cron")
+	if monitor.AlertStatus != "" {
+		t.Errorf("expected empty alert status for new monitor, got %q", monitor.AlertStatus)
+	}
+
+	now := time.Now().UTC()
+	changed, err := testDB.UpdatePipeMonitorAlertStatus(monitor.ID, "", db.PipeMonitorDown, &now)
+	if err != nil {
+		t.Fatalf("UpdatePipeMonitorAlertStatus failed: %v", err)
+	}
+	if !changed {
+		t.Error("expected alert status to change")
+	}
+
+	// another alerter that read the old status loses the race
+	changed, err = testDB.UpdatePipeMonitorAlertStatus(monitor.ID, "", db.PipeMonitorDown, &now)
+	if err != nil {
+		t.Fatalf("UpdatePipeMonitorAlertStatus failed: %v", err)
+	}
+	if changed {
+		t.Error("expected stale alert status to be rejected")
+	}
+
+	monitor, _ = testDB.FindPipeMonitorByTopic(user.ID, "pipealertowner/cron")
+	if monitor.AlertStatus != db.PipeMonitorDown {
+		t.Errorf("expected alert status 'down', got %q", monitor.AlertStatus)
+	}
+	if monitor.AlertedAt == nil {
+		t.Error("expected alerted_at to be set")
+	}
+}
+
+// ============ Pipe Topic ACL Tests ============
+
+func TestUpsertPipeAcl(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("pipeaclowner", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI pipeaclowner", "comment", "")
+
+	err := testDB.UpsertPipeAcl(user.ID, "pipeaclowner/shared", "bob", db.PipeAclRead)
+	if err != nil {
+		t.Fatalf("UpsertPipeAcl failed: %v", err)
+	}
+
+	err = testDB.UpsertPipeAcl(user.ID, "pipeaclowner/shared", "bob", db.PipeAclReadWrite)
+	if err != nil {
+		t.Fatalf("second UpsertPipeAcl failed: %v", err)
+	}
+
+	acls, err := testDB.FindPipeAclsByTopic(user.ID, "pipeaclowner/shared")
+	if err != nil {
+		t.Fatalf("FindPipeAclsByTopic failed: %v", err)
+	}
+	if len(acls) != 1 {
+		t.Fatalf("expected 1 acl, got %d", len(acls))
+	}
+	if acls[0].Permission != db.PipeAclReadWrite {
+		t.Errorf("expected permission 'rw' after update, got '%s'", acls[0].Permission)
+	}
+}
+
+func TestUpsertPipeAcl_InvalidPermission(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("pipeaclinvalid", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI pipeaclinvalid", "comment", "")
+
+	err := testDB.UpsertPipeAcl(user.ID, "pipeaclinvalid/shared", "bob", "admin")
+	if err == nil {
+		t.Error("expected error for invalid permission, got nil")
+	}
+}
+
+func TestRemovePipeAcl(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("pipeaclremove", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI pipeaclremove", "comment", "")
+
+	_ = testDB.UpsertPipeAcl(user.ID, "pipeaclremove/shared", "bob", db.PipeAclRead)
+	_ = testDB.UpsertPipeAcl(user.ID, "pipeaclremove/shared", "carol", db.PipeAclWrite)
+
+	err := testDB.RemovePipeAcl(user.ID, "pipeaclremove/shared", "bob")
+	if err != nil {
+		t.Fatalf("RemovePipeAcl failed: %v", err)
+	}
+
+	acls, err := testDB.FindPipeAclsByTopic(user.ID, "pipeaclremove/shared")
+	if err != nil {
+		t.Fatalf("FindPipeAclsByTopic failed: %v", err)
+	}
+	if len(acls) != 1 || acls[0].Principal != "carol" {
+		t.Errorf("expected only carol to remain, got %v", acls)
+	}
+}
+
+func TestFindPipeAclsByUser(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("pipeacllist", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI pipeacllist", "comment", "")
+
+	_ = testDB.UpsertPipeAcl(user.ID, "pipeacllist/b", "bob", db.PipeAclRead)
+	_ = testDB.UpsertPipeAcl(user.ID, "pipeacllist/a", "carol", db.PipeAclWrite)
+	_ = testDB.UpsertPipeAcl(user.ID, "pipeacllist/a", "bob", db.PipeAclReadWrite)
+
+	acls, err := testDB.FindPipeAclsByUser(user.ID)
+	if err != nil {
+		t.Fatalf("FindPipeAclsByUser failed: %v", err)
+	}
+
+	if len(acls) != 3 {
+		t.Fatalf("expected 3 acls, got %d", len(acls))
+	}
+
+	// Should be ordered by topic, then principal
+	if acls[0].Topic != "pipeacllist/a" || acls[0].Principal != "bob" {
+		t.Errorf("expected first acl 'pipeacllist/a' bob, got %s %s", acls[0].Topic, acls[0].Principal)
+	}
+	if acls[2].Topic != "pipeacllist/b" {
+		t.Errorf("expected last acl topic 'pipeacllist/b', got %s", acls[2].Topic)
+	}
+}
+
+func TestSshCerts(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("certowner", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI certowner", "comment", "")
+
+	_, err := testDB.FindSshCertAuthority(user.ID)
+	if err == nil {
+		t.Fatal("expected no cert authority before one is created")
+	}
+
+	ca, err := testDB.InsertSshCertAuthority(user.ID, "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI certca", "private")
+	if err != nil {
+		t.Fatalf("InsertSshCertAuthority failed: %v", err)
+	}
+
+	caUser, err := testDB.FindUserByPubkey(ca.PublicKey)
+	if err != nil || caUser.ID != user.ID {
+		t.Fatalf("expected ca pubkey to belong to the user, got %v %v", caUser, err)
+	}
+
+	now := time.Now()
+	expires := now.Add(time.Hour)
+	err = testDB.InsertSshCert(&db.SshCert{
+		CaID:        ca.ID,
+		UserID:      user.ID,
+		Serial:      42,
+		KeyID:       "ci-deploy",
+		Principals:  "pgs",
+		PublicKey:   "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI ci",
+		ValidAfter:  &now,
+		ValidBefore: &expires,
+	})
+	if err != nil {
+		t.Fatalf("InsertSshCert failed: %v", err)
+	}
+
+	certs, err := testDB.FindSshCertsByUser(user.ID)
+	if err != nil {
+		t.Fatalf("FindSshCertsByUser failed: %v", err)
+	}
+	if len(certs) != 1 || certs[0].KeyID != "ci-deploy" || certs[0].RevokedAt != nil {
+		t.Fatalf("expected one active cert, got %v", certs)
+	}
+
+	revoked, err := testDB.IsSshCertRevoked(ca.PublicKey, 42)
+	if err != nil || revoked {
+		t.Fatalf("expected cert not to be revoked, got %v %v", revoked, err)
+	}
+
+	revoked, err = testDB.IsSshCertRevoked(ca.PublicKey, 43)
+	if err != nil || !revoked {
+		t.Errorf("expected cert pico never issued to be revoked, got %v %v", revoked, err)
+	}
+
+	revoked, err = testDB.IsSshCertRevoked("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI userca", 43)
+	if err != nil || revoked {
+		t.Errorf("expected cert from a user managed ca not to be revoked, got %v %v", revoked, err)
+	}
+
+	err = testDB.RevokeSshCert(user.ID, 42)
+	if err != nil {
+		t.Fatalf("RevokeSshCert failed: %v", err)
+	}
+	if err := testDB.RevokeSshCert(user.ID, 42); err == nil {
+		t.Error("expected error revoking an already revoked cert")
+	}
+
+	revoked, err = testDB.IsSshCertRevoked(ca.PublicKey, 42)
+	if err != nil || !revoked {
+		t.Errorf("expected cert to be revoked, got %v %v", revoked, err)
+	}
+}
+
+func TestOrgMembers(t *testing.T) {
+	cleanupTestData(t)
+
+	owner, _ := testDB.RegisterUser("orgowner", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI orgowner", "comment", "")
+	deployer, _ := testDB.RegisterUser("orgdeployer", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI orgdeployer", "comment", "")
+
+	org, err := testDB.InsertOrg(owner.ID, "AcmeOrg")
+	if err != nil {
+		t.Fatalf("InsertOrg failed: %v", err)
+	}
+	if org.Name != "acmeorg" {
+		t.Errorf("expected org name to be lowercased, got %s", org.Name)
+	}
+
+	if _, err := testDB.InsertOrg(owner.ID, "orgdeployer"); err == nil {
+		t.Error("expected error creating an org with a taken name")
+	}
+
+	member, err := testDB.FindOrgMember(org.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("FindOrgMember failed: %v", err)
+	}
+	if member.Role != db.OrgRoleOwner || member.OrgName != "acmeorg" || member.UserName != "orgowner" {
+		t.Errorf("unexpected owner membership %+v", member)
+	}
+
+	err = testDB.UpsertOrgMember(org.ID, deployer.ID, db.OrgRoleViewer)
+	if err != nil {
+		t.Fatalf("UpsertOrgMember failed: %v", err)
+	}
+	err = testDB.UpsertOrgMember(org.ID, deployer.ID, db.OrgRoleDeployer)
+	if err != nil {
+		t.Fatalf("second UpsertOrgMember failed: %v", err)
+	}
+	if err := testDB.UpsertOrgMember(org.ID, deployer.ID, "admin"); err == nil {
+		t.Error("expected error for invalid role")
+	}
+
+	members, err := testDB.FindOrgMembers(org.ID)
+	if err != nil {
+		t.Fatalf("FindOrgMembers failed: %v", err)
+	}
+	if len(members) != 2 || members[0].UserName != "orgdeployer" || members[0].Role != db.OrgRoleDeployer {
+		t.Fatalf("expected deployer and owner, got %+v", members)
+	}
+
+	orgs, err := testDB.FindOrgsByUser(deployer.ID)
+	if err != nil {
+		t.Fatalf("FindOrgsByUser failed: %v", err)
+	}
+	if len(orgs) != 1 || orgs[0].OrgID != org.ID {
+		t.Errorf("expected deployer to belong to acmeorg, got %+v", orgs)
+	}
+
+	err = testDB.RemoveOrgMember(org.ID, deployer.ID)
+	if err != nil {
+		t.Fatalf("RemoveOrgMember failed: %v", err)
+	}
+	if _, err := testDB.FindOrgMember(org.ID, deployer.ID); err == nil {
+		t.Error("expected deployer to be removed")
+	}
+}
+
+func TestFederation(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("fedblog", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI fedblog", "comment", "")
+
+	if _, err := testDB.FindFederationKey(user.ID); err == nil {
+		t.Error("expected no federation key yet")
+	}
+	key, err := testDB.InsertFederationKey(user.ID, "pub-1", "priv-1")
+	if err != nil {
+		t.Fatalf("InsertFederationKey failed: %v", err)
+	}
+	key, err = testDB.InsertFederationKey(user.ID, "pub-2", "priv-2")
+	if err != nil {
+		t.Fatalf("second InsertFederationKey failed: %v", err)
+	}
+	if key.PublicKey != "pub-1" || key.PrivateKey != "priv-1" {
+		t.Errorf("expected the first key to be kept, got %+v", key)
+	}
+
+	actor := "https://remote.test/users/alice"
+	if err := testDB.UpsertFollower(user.ID, actor, "https://remote.test/users/alice/inbox"); err != nil {
+		t.Fatalf("UpsertFollower failed: %v", err)
+	}
+	if err := testDB.UpsertFollower(user.ID, actor, "https://remote.test/inbox"); err != nil {
+		t.Fatalf("second UpsertFollower failed: %v", err)
+	}
+	followers, err := testDB.FindFollowersByUser(user.ID)
+	if err != nil {
+		t.Fatalf("FindFollowersByUser failed: %v", err)
+	}
+	if len(followers) != 1 || followers[0].Inbox != "https://remote.test/inbox" {
+		t.Fatalf("expected one follower with the shared inbox, got %+v", followers)
+	}
+	if err := testDB.RemoveFollower(user.ID, actor); err != nil {
+		t.Fatalf("RemoveFollower failed: %v", err)
+	}
+	if followers, _ := testDB.FindFollowersByUser(user.ID); len(followers) != 0 {
+		t.Errorf("expected follower to be removed, got %+v", followers)
+	}
+
+	post := mustInsertPost(t, &db.Post{UserID: user.ID, Filename: "hello.md", Slug: "hello", Title: "Hello", Space: "prose"})
+	mention := &db.PostMention{
+		PostID:     post.ID,
+		Kind:       db.MentionReply,
+		Source:     "https://remote.test/notes/1",
+		AuthorName: "Alice",
+		Content:    "<p>nice</p>",
+	}
+	if err := testDB.UpsertPostMention(mention); err != nil {
+		t.Fatalf("UpsertPostMention failed: %v", err)
+	}
+	mention.Content = "<p>nice post</p>"
+	if err := testDB.UpsertPostMention(mention); err != nil {
+		t.Fatalf("second UpsertPostMention failed: %v", err)
+	}
+	webmention := &db.PostMention{PostID: post.ID, Kind: db.MentionWebmention, Source: "https://example.com/reply"}
+	if err := testDB.UpsertPostMention(webmention); err != nil {
+		t.Fatalf("UpsertPostMention failed: %v", err)
+	}
+
+	mentions, err := testDB.FindPostMentions(post.ID)
+	if err != nil {
+		t.Fatalf("FindPostMentions failed: %v", err)
+	}
+	if len(mentions) != 2 || mentions[0].Content != "<p>nice post</p>" {
+		t.Fatalf("expected updated reply and webmention, got %+v", mentions)
+	}
+
+	if err := testDB.RemovePostMentionsBySource(mention.Source); err != nil {
+		t.Fatalf("RemovePostMentionsBySource failed: %v", err)
+	}
+	if err := testDB.RemovePostMention(post.ID, webmention.Source); err != nil {
+		t.Fatalf("RemovePostMention failed: %v", err)
+	}
+	if mentions, _ := testDB.FindPostMentions(post.ID); len(mentions) != 0 {
+		t.Errorf("expected mentions to be removed, got %+v", mentions)
+	}
+}
+
+func TestFindUserByScopedToken(t *testing.T) {
+	cleanupTestData(t)
+
+	user, _ := testDB.RegisterUser("scopedtokenowner", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI scopedtokenowner", "comment", "")
+	expiresAt := time.Now().Add(time.Hour)
+	token, err := testDB.InsertScopedToken(user.ID, "deploy", []string{db.TokenScopePgsDeploy}, &expiresAt)
+	if err != nil {
+		t.Fatalf("InsertScopedToken failed: %v", err)
+	}
+
+	found, err := testDB.FindUserByScopedToken(token, db.TokenScopePgsDeploy)
+	if err != nil {
+		t.Fatalf("FindUserByScopedToken failed: %v", err)
+	}
+	if found.ID != user.ID {
+		t.Errorf("expected user %s, got %s", user.ID, found.ID)
+	}
+
+	_, err = testDB.FindUserByScopedToken(token, db.TokenScopeFeedsRead)
+	if !errors.Is(err, db.ErrTokenScope) {
+		t.Errorf("expected scope error, got %v", err)
+	}
+	if _, err := testDB.FindUserByToken(token); err == nil {
+		t.Error("expected scoped token to be rejected for full access")
+	}
+
+	tokens, _ := testDB.FindTokensByUser(user.ID)
+	if len(tokens) != 1 || tokens[0].LastUsedAt == nil || tokens[0].Scopes != db.TokenScopePgsDeploy {
+		t.Errorf("expected token usage to be recorded, got %+v", tokens)
+	}
+
+	if _, err := testDB.InsertScopedToken(user.ID, "bad", []string{"root"}, nil); err == nil {
+		t.Error("expected error for invalid scope")
+	}
+}
+
+func TestPostComments(t *testing.T) {
+	cleanupTestData(t)
+
+	author, _ := testDB.RegisterUser("commentauthor", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI commentauthor", "comment", "")
+	reader, _ := testDB.RegisterUser("commentreader", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI commentreader", "comment", "")
+	post := mustInsertPost(t, &db.Post{UserID: author.ID, Filename: "hello.md", Slug: "hello", Title: "Hello", Space: "prose"})
+
+	comment, err := testDB.InsertPostComment(&db.PostComment{PostID: post.ID, UserID: reader.ID, Text: "nice post"})
+	if err != nil {
+		t.Fatalf("InsertPostComment failed: %v", err)
+	}
+	if comment.Username != "commentreader" || comment.PostSlug != "hello" || comment.Approved {
+		t.Errorf("unexpected comment %+v", comment)
+	}
+
+	comments, err := testDB.FindPostCommentsByOwner(author.ID, "prose")
+	if err != nil {
+		t.Fatalf("FindPostCommentsByOwner failed: %v", err)
+	}
+	if len(comments) != 1 || comments[0].ID != comment.ID {
+		t.Fatalf("expected the comment for the author, got %+v", comments)
+	}
+	if comments, _ := testDB.FindPostCommentsByOwner(reader.ID, "prose"); len(comments) != 0 {
+		t.Errorf("expected no comments on the reader's posts, got %d", len(comments))
+	}
+
+	if err := testDB.ApprovePostComment(reader.ID, comment.ID); err == nil {
+		t.Error("expected only the author to approve the comment")
+	}
+	if err := testDB.ApprovePostComment(author.ID, comment.ID); err != nil {
+		t.Fatalf("ApprovePostComment failed: %v", err)
+	}
+	comments, err = testDB.FindPostComments(post.ID)
+	if err != nil {
+		t.Fatalf("FindPostComments failed: %v", err)
+	}
+	if len(comments) != 1 || !comments[0].Approved {
+		t.Fatalf("expected an approved comment, got %+v", comments)
+	}
+
+	if err := testDB.RemovePostComment(reader.ID, comment.ID); err == nil {
+		t.Error("expected only the author to remove the comment")
+	}
+	if err := testDB.RemovePostComment(author.ID, comment.ID); err != nil {
+		t.Fatalf("RemovePostComment failed: %v", err)
+	}
+	if comments, _ := testDB.FindPostComments(post.ID); len(comments) != 0 {
+		t.Errorf("expected comment to be removed, got %d", len(comments))
+	}
+}
+
+func TestNewsletter(t *testing.T) {
+	cleanupTestData(t)
+
+	author, _ := testDB.RegisterUser("newsauthor", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI newsauthor", "news", "")
+	other, _ := testDB.RegisterUser("newsother", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI newsother", "news", "")
+
+	sub, err := testDB.UpsertNewsletterSubscriber(&db.NewsletterSubscriber{
+		UserID: author.ID,
+		Email:  "reader@example.com",
+		Token:  "token-1",
+		Status: db.SubscriberPending,
+	})
+	if err != nil {
+		t.Fatalf("UpsertNewsletterSubscriber failed: %v", err)
+	}
+	if sub.ID == "" || sub.Status != db.SubscriberPending || sub.ConfirmedAt != nil {
+		t.Errorf("unexpected subscriber %+v", sub)
+	}
+
+	now := time.Now().UTC()
+	sub.Status = db.SubscriberConfirmed
+	sub.ConfirmedAt = &now
+	updated, err := testDB.UpsertNewsletterSubscriber(sub)
+	if err != nil {
+		t.Fatalf("UpsertNewsletterSubscriber failed: %v", err)
+	}
+	if updated.ID != sub.ID || updated.Status != db.SubscriberConfirmed || updated.ConfirmedAt == nil {
+		t.Errorf("expected subscriber to be confirmed, got %+v", updated)
+	}
+
+	_, err = testDB.UpsertNewsletterSubscriber(&db.NewsletterSubscriber{
+		UserID: other.ID,
+		Email:  "reader@example.com",
+		Token:  "token-2",
+		Status: db.SubscriberPending,
+	})
+	if err != nil {
+		t.Fatalf("UpsertNewsletterSubscriber failed: %v", err)
+	}
+
+	found, err := testDB.FindNewsletterSubscriberByToken("token-1")
+	if err != nil || found.ID != sub.ID {
+		t.Errorf("FindNewsletterSubscriberByToken got %+v, %v", found, err)
+	}
+	if _, err := testDB.FindNewsletterSubscriber(author.ID, "nope@example.com"); err == nil {
+		t.Error("expected missing subscriber to fail")
+	}
+	if subs, _ := testDB.FindNewsletterSubscribersByEmail("reader@example.com"); len(subs) != 2 {
+		t.Errorf("expected two subscriptions, got %d", len(subs))
+	}
+	confirmed, err := testDB.FindNewsletterSubscribers(author.ID, db.SubscriberConfirmed)
+	if err != nil {
+		t.Fatalf("FindNewsletterSubscribers failed: %v", err)
+	}
+	if len(confirmed) != 1 || confirmed[0].Email != "reader@example.com" {
+		t.Errorf("unexpected confirmed subscribers %+v", confirmed)
+	}
+
+	post := mustInsertPost(t, &db.Post{UserID: author.ID, Filename: "hello.md", Slug: "hello", Title: "Hello", Space: "prose"})
+	for range 2 {
+		if err := testDB.InsertNewsletterPost(author.ID, post.ID); err != nil {
+			t.Fatalf("InsertNewsletterPost failed: %v", err)
+		}
+	}
+	pending, err := testDB.FindPendingNewsletterPosts()
+	if err != nil {
+		t.Fatalf("FindPendingNewsletterPosts failed: %v", err)
+	}
+	if len(pending) != 1 || pending[0].PostID != post.ID {
+		t.Fatalf("expected the post to be queued once, got %+v", pending)
+	}
+
+	if err := testDB.MarkNewsletterPostsSent([]string{pending[0].ID}, now); err != nil {
+		t.Fatalf("MarkNewsletterPostsSent failed: %v", err)
+	}
+	if err := testDB.InsertNewsletterPost(author.ID, post.ID); err != nil {
+		t.Fatalf("InsertNewsletterPost failed: %v", err)
+	}
+	if pending, _ := testDB.FindPendingNewsletterPosts(); len(pending) != 0 {
+		t.Errorf("expected a sent post not to be queued again, got %d", len(pending))
+	}
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// weights for matches in the title, description and text of a post, they
+// mirror the defaults of postgres' ts_rank for the A, B and C labels.
+const (
+	searchWeightTitle       = 1.0
+	searchWeightDescription = 0.4
+	searchWeightText        = 0.2
+)
+
+// searchSnippetWords is how many words of a post surround the first match.
+const searchSnippetWords = 30
+
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// searchTerms splits a query the way websearch_to_tsquery does for the
+// simple cases: every word has to match unless it is prefixed with "-",
+// in which case it must not.
+func searchTerms(query string) (include []string, exclude []string) {
+	for _, field := range strings.Fields(query) {
+		excluded := strings.HasPrefix(field, "-")
+		for _, word := range searchWords(field) {
+			if excluded {
+				exclude = append(exclude, word)
+			} else {
+				include = append(include, word)
+			}
+		}
+	}
+	return include, exclude
+}
+
+// matchesTerm is a crude replacement for stemming, "run" finds "running".
+func matchesTerm(word string, terms []string) bool {
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func countTerm(words []string, term string) int {
+	count := 0
+	for _, word := range words {
+		if strings.HasPrefix(word, term) {
+			count++
+		}
+	}
+	return count
+}
+
+// searchSnippet returns the words around the first match in text with
+// every match wrapped in SearchMarkStart and SearchMarkEnd.
+func searchSnippet(text string, terms []string) string {
+	words := strings.Fields(text)
+	first := 0
+	for idx, word := range words {
+		if matchesTerm(strings.Join(searchWords(word), ""), terms) {
+			first = idx
+			break
+		}
+	}
+	start := max(0, first-searchSnippetWords/3)
+	end := min(len(words), start+searchSnippetWords)
+
+	snippet := make([]string, 0, end-start)
+	for _, word := range words[start:end] {
+		if matchesTerm(strings.Join(searchWords(word), ""), terms) {
+			word = SearchMarkStart + word + SearchMarkEnd
+		}
+		snippet = append(snippet, word)
+	}
+	return strings.Join(snippet, " ")
+}
+
+// SearchPosts is an in-memory version of the full-text search in postgres
+// for the stub and test databases.  Matches in the title rank higher than
+// matches in the description or text, and hidden or future posts are never
+// returned.  An empty userID searches the posts of every user.
+func SearchPosts(posts []*Post, pager *Pager, query, userID, space string, now time.Time) *Paginate[*SearchResult] {
+	include, exclude := searchTerms(query)
+	today := now.Format(time.DateOnly)
+
+	results := []*SearchResult{}
+	for _, post := range posts {
+		if post.Hidden || post.Space != space {
+			continue
+		}
+		if userID != "" && post.UserID != userID {
+			continue
+		}
+		if post.PublishAt != nil && post.PublishAt.Format(time.DateOnly) > today {
+			continue
+		}
+		if len(include) == 0 {
+			continue
+		}
+
+		title := searchWords(post.Title)
+		description := searchWords(post.Description)
+		text := searchWords(post.Text)
+		all := slices.Concat(title, description, text)
+
+		excluded := slices.ContainsFunc(exclude, func(term string) bool {
+			return countTerm(all, term) > 0
+		})
+		if excluded {
+			continue
+		}
+
+		rank := 0.0
+		matched := true
+		for _, term := range include {
+			if countTerm(all, term) == 0 {
+				matched = false
+				break
+			}
+			rank += searchWeightTitle * float64(countTerm(title, term))
+			rank += searchWeightDescription * float64(countTerm(description, term))
+			rank += searchWeightText * float64(countTerm(text, term))
+		}
+		if !matched {
+			continue
+		}
+
+		results = append(results, &SearchResult{
+			Post:    post,
+			Rank:    rank,
+			Snippet: searchSnippet(post.Text, include),
+		})
+	}
+
+	slices.SortStableFunc(results, func(a, b *SearchResult) int {
+		if a.Rank != b.Rank {
+			if a.Rank > b.Rank {
+				return -1
+			}
+			return 1
+		}
+		if a.Post.PublishAt == nil || b.Post.PublishAt == nil {
+			return 0
+		}
+		return b.Post.PublishAt.Compare(*a.Post.PublishAt)
+	})
+
+	total := len(results)
+	start := min(total, pager.Num*pager.Page)
+	end := min(total, start+pager.Num)
+	return &Paginate[*SearchResult]{
+		Data:  results[start:end],
+		Total: int(math.Ceil(float64(total) / float64(pager.Num))),
+	}
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchPosts(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+	tomorrow := now.Add(24 * time.Hour)
+
+	posts := []*Post{
+		{ID: "text", UserID: "a", Space: "prose", Title: "Notes", Text: "a few words about running a marathon", PublishAt: &now},
+		{ID: "title", UserID: "b", Space: "prose", Title: "Running", Text: "training log", PublishAt: &yesterday},
+		{ID: "hidden", UserID: "a", Space: "prose", Title: "Running", Hidden: true, PublishAt: &now},
+		{ID: "future", UserID: "a", Space: "prose", Title: "Running", PublishAt: &tomorrow},
+		{ID: "space", UserID: "a", Space: "pastes", Title: "Running", PublishAt: &now},
+		{ID: "excluded", UserID: "a", Space: "prose", Title: "Running shoes", PublishAt: &now},
+	}
+
+	res := SearchPosts(posts, &Pager{Num: 10}, "run -shoes", "", "prose", now)
+	ids := []string{}
+	for _, r := range res.Data {
+		ids = append(ids, r.Post.ID)
+	}
+	if strings.Join(ids, ",") != "title,text" {
+		t.Fatalf("unexpected results %v", ids)
+	}
+
+	snippet := res.Data[1].Snippet
+	if !strings.Contains(snippet, SearchMarkStart+"running"+SearchMarkEnd) {
+		t.Errorf("match not highlighted in %q", snippet)
+	}
+
+	res = SearchPosts(posts, &Pager{Num: 10}, "run", "a", "prose", now)
+	if len(res.Data) != 2 || res.Data[0].Post.ID != "excluded" {
+		t.Errorf("expected only posts of user a, got %d", len(res.Data))
+	}
+
+	res = SearchPosts(posts, &Pager{Num: 1, Page: 1}, "run -shoes", "", "prose", now)
+	if res.Total != 2 || len(res.Data) != 1 || res.Data[0].Post.ID != "text" {
+		t.Errorf("unexpected second page %+v", res)
+	}
+}
@@ -10,6 +10,8 @@ import (
 
 type StubDB struct {
 	Logger *slog.Logger
+	// Posts are searched by FindPostsBySearch
+	Posts []*db.Post
 }
 
 var _ db.DB = (*StubDB)(nil)
@@ -108,6 +110,30 @@ func (me *StubDB) FindExpiredPosts(space string) ([]*db.Post, error) {
 	return []*db.Post{}, errNotImpl
 }
 
+func (me *StubDB) FindScheduledPosts(space string, now time.Time) ([]*db.Post, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) ClaimScheduledPost(postID string) (bool, error) {
+	return false, errNotImpl
+}
+
+func (me *StubDB) FindPostsBySeries(userID, series, space string) ([]*db.Post, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) FindPostsByLang(pager *db.Pager, lang, space string) (*db.Paginate[*db.Post], error) {
+	return &db.Paginate[*db.Post]{}, errNotImpl
+}
+
+func (me *StubDB) FindUserPostsByLang(pager *db.Pager, lang, userID, space string) (*db.Paginate[*db.Post], error) {
+	return &db.Paginate[*db.Post]{}, errNotImpl
+}
+
+func (me *StubDB) FindPostLangs(space string) ([]string, error) {
+	return nil, errNotImpl
+}
+
 func (me *StubDB) Close() error {
 	return errNotImpl
 }
@@ -124,6 +150,18 @@ func (me *StubDB) FindVisitSiteList(opts *db.SummaryOpts) ([]*db.VisitUrl, error
 	return []*db.VisitUrl{}, errNotImpl
 }
 
+func (me *StubDB) InsertPostRead(read *db.AnalyticsVisits) error {
+	return errNotImpl
+}
+
+func (me *StubDB) PostVisitSummary(opts *db.SummaryOpts) (*db.PostAnalytics, error) {
+	return &db.PostAnalytics{}, errNotImpl
+}
+
+func (me *StubDB) FindVisitPostList(opts *db.SummaryOpts) ([]*db.PostAnalytics, error) {
+	return []*db.PostAnalytics{}, errNotImpl
+}
+
 func (me *StubDB) FindUsers() ([]*db.User, error) {
 	return []*db.User{}, errNotImpl
 }
@@ -148,6 +186,10 @@ func (me *StubDB) FindPopularTags(space string) ([]string, error) {
 	return []string{}, errNotImpl
 }
 
+func (me *StubDB) FindPostsBySearch(pager *db.Pager, query, userID, space string) (*db.Paginate[*db.SearchResult], error) {
+	return db.SearchPosts(me.Posts, pager, query, userID, space, time.Now()), nil
+}
+
 func (me *StubDB) FindFeature(userID string, feature string) (*db.FeatureFlag, error) {
 	return nil, errNotImpl
 }
@@ -275,3 +317,187 @@ func (me *StubDB) InsertPipeMonitorHistory(monitorID string, windowDur time.Dura
 func (me *StubDB) FindPipeMonitorHistory(monitorID string, from, to time.Time) ([]*db.PipeMonitorHistory, error) {
 	return nil, errNotImpl
 }
+
+func (me *StubDB) UpsertPipeMonitorTarget(monitorID, kind, target, secret, token string) error {
+	return errNotImpl
+}
+
+func (me *StubDB) ConfirmPipeMonitorTarget(token string) (*db.PipeMonitorTarget, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) RemovePipeMonitorTarget(monitorID, kind, target string) error {
+	return errNotImpl
+}
+
+func (me *StubDB) FindPipeMonitorTargets(monitorID string) ([]*db.PipeMonitorTarget, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) FindPipeMonitorsWithTargets() ([]*db.PipeMonitor, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) UpdatePipeMonitorAlertStatus(monitorID, prev, status string, alertedAt *time.Time) (bool, error) {
+	return false, errNotImpl
+}
+
+func (me *StubDB) UpsertPipeAcl(userID, topic, principal, permission string) error {
+	return errNotImpl
+}
+
+func (me *StubDB) RemovePipeAcl(userID, topic, principal string) error {
+	return errNotImpl
+}
+
+func (me *StubDB) FindPipeAclsByTopic(userID, topic string) ([]*db.PipeAcl, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) FindPipeAclsByUser(userID string) ([]*db.PipeAcl, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) FindSshCertAuthority(userID string) (*db.SshCertAuthority, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) InsertSshCertAuthority(userID, pubkey, privkey string) (*db.SshCertAuthority, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) InsertSshCert(cert *db.SshCert) error {
+	return errNotImpl
+}
+
+func (me *StubDB) FindSshCertsByUser(userID string) ([]*db.SshCert, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) RevokeSshCert(userID string, serial int64) error {
+	return errNotImpl
+}
+
+func (me *StubDB) IsSshCertRevoked(caPubkey string, serial int64) (bool, error) {
+	return false, errNotImpl
+}
+
+func (me *StubDB) InsertOrg(ownerID, name string) (*db.User, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) FindOrgMember(orgID, userID string) (*db.OrgMember, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) FindOrgMembers(orgID string) ([]*db.OrgMember, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) FindOrgsByUser(userID string) ([]*db.OrgMember, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) UpsertOrgMember(orgID, userID, role string) error {
+	return errNotImpl
+}
+
+func (me *StubDB) RemoveOrgMember(orgID, userID string) error {
+	return errNotImpl
+}
+
+func (me *StubDB) FindUserByScopedToken(token, scope string) (*db.User, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) InsertScopedToken(userID, name string, scopes []string, expiresAt *time.Time) (string, error) {
+	return "", errNotImpl
+}
+
+func (me *StubDB) FindFederationKey(userID string) (*db.FederationKey, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) InsertFederationKey(userID, pubkey, privkey string) (*db.FederationKey, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) UpsertFollower(userID, actor, inbox string) error {
+	return errNotImpl
+}
+
+func (me *StubDB) RemoveFollower(userID, actor string) error {
+	return errNotImpl
+}
+
+func (me *StubDB) FindFollowersByUser(userID string) ([]*db.Follower, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) UpsertPostMention(mention *db.PostMention) error {
+	return errNotImpl
+}
+
+func (me *StubDB) RemovePostMention(postID, source string) error {
+	return errNotImpl
+}
+
+func (me *StubDB) RemovePostMentionsBySource(source string) error {
+	return errNotImpl
+}
+
+func (me *StubDB) FindPostMentions(postID string) ([]*db.PostMention, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) InsertPostComment(comment *db.PostComment) (*db.PostComment, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) FindPostComments(postID string) ([]*db.PostComment, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) FindPostCommentsByOwner(ownerID, space string) ([]*db.PostComment, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) ApprovePostComment(ownerID, commentID string) error {
+	return errNotImpl
+}
+
+func (me *StubDB) RemovePostComment(ownerID, commentID string) error {
+	return errNotImpl
+}
+
+func (me *StubDB) UpsertNewsletterSubscriber(sub *db.NewsletterSubscriber) (*db.NewsletterSubscriber, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) FindNewsletterSubscriber(userID, email string) (*db.NewsletterSubscriber, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) FindNewsletterSubscriberByToken(token string) (*db.NewsletterSubscriber, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) FindNewsletterSubscribersByEmail(email string) ([]*db.NewsletterSubscriber, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) FindNewsletterSubscribers(userID, status string) ([]*db.NewsletterSubscriber, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) InsertNewsletterPost(userID, postID string) error {
+	return errNotImpl
+}
+
+func (me *StubDB) FindPendingNewsletterPosts() ([]*db.NewsletterPost, error) {
+	return nil, errNotImpl
+}
+
+func (me *StubDB) MarkNewsletterPostsSent(ids []string, sentAt time.Time) error {
+	return errNotImpl
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// TokenQuerier is the part of a sql connection needed to look up tokens.
+type TokenQuerier interface {
+	Get(dest interface{}, query string, args ...interface{}) error
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Authorize returns an error unless the token has not expired and holds
+// scope.
+func (t *Token) Authorize(scope string) error {
+	if t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("token has expired")
+	}
+	if !t.HasScope(scope) {
+		return fmt.Errorf("%w: %s", ErrTokenScope, scope)
+	}
+	return nil
+}
+
+// FindScopedToken returns the token when it is authorized for scope and
+// records that it was used.  Every FindUserByScopedToken backed by sql
+// shares it so tokens are checked the same way across services.
+func FindScopedToken(q TokenQuerier, logger *slog.Logger, token, scope string) (*Token, error) {
+	tkn := &Token{}
+	err := q.Get(tkn, `
+	SELECT id, user_id, name, token, scopes, created_at, expires_at, last_used_at
+	FROM tokens
+	WHERE token = $1 AND expires_at > CURRENT_TIMESTAMP`, token)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tkn.Authorize(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = q.Exec(`UPDATE tokens SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`, tkn.ID)
+	if err != nil {
+		logger.Error("could not record token usage", "err", err, "tokenId", tkn.ID)
+	}
+
+	return tkn, nil
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenHasScope(t *testing.T) {
+	tests := []struct {
+		scopes string
+		scope  string
+		want   bool
+	}{
+		{scopes: "", scope: TokenScopeFull, want: true},
+		{scopes: "", scope: TokenScopePgsDeploy, want: true},
+		{scopes: "pgs:deploy", scope: TokenScopeFull, want: false},
+		{scopes: "pgs:deploy", scope: TokenScopePgsDeploy, want: true},
+		{scopes: "pgs:deploy", scope: TokenScopeFeedsRead, want: false},
+		{scopes: "feeds:read, pipe:sub", scope: "pipe:sub:alerts", want: true},
+		{scopes: "pipe:sub:alerts", scope: "pipe:sub:alerts", want: true},
+		{scopes: "pipe:sub:alerts", scope: "pipe:sub:other", want: false},
+		{scopes: "pipe:pub", scope: "pipe:sub:alerts", want: false},
+	}
+
+	for _, tt := range tests {
+		token := &Token{Scopes: tt.scopes}
+		if got := token.HasScope(tt.scope); got != tt.want {
+			t.Errorf("%q HasScope(%q) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTokenScopes(t *testing.T) {
+	if err := ValidateTokenScopes([]string{"pgs:deploy", "pipe:sub:alerts"}); err != nil {
+		t.Errorf("expected valid scopes, got %v", err)
+	}
+	for _, scope := range []string{"pgs", "pipe:sub:", "full"} {
+		if err := ValidateTokenScopes([]string{scope}); err == nil {
+			t.Errorf("expected %q to be invalid", scope)
+		}
+	}
+}
+
+func TestTokenAuthorize(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		token   *Token
+		scope   string
+		wantErr bool
+	}{
+		{name: "scoped", token: &Token{Scopes: "pgs:deploy", ExpiresAt: &future}, scope: TokenScopePgsDeploy},
+		{name: "missing scope", token: &Token{Scopes: "pgs:deploy", ExpiresAt: &future}, scope: TokenScopeAnalyticsRead, wantErr: true},
+		{name: "expired", token: &Token{ExpiresAt: &past}, scope: TokenScopeFull, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.token.Authorize(tt.scope)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Authorize(%q) = %v, wantErr %v", tt.scope, err, tt.wantErr)
+			}
+		})
+	}
+
+	err := (&Token{Scopes: "pgs:deploy"}).Authorize(TokenScopeFeedsRead)
+	if !errors.Is(err, ErrTokenScope) {
+		t.Errorf("expected ErrTokenScope, got %v", err)
+	}
+}
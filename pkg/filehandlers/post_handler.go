@@ -30,6 +30,14 @@ type ScpFileHooks interface {
 	FileMeta(s *pssh.SSHServerConnSession, data *PostMetaData) error
 }
 
+// ScpPostHooks can optionally be implemented by ScpFileHooks to act on a
+// post after it has been saved or removed.  Prev is the post before the
+// upload and nil for new posts.
+type ScpPostHooks interface {
+	PostSaved(s *pssh.SSHServerConnSession, user *db.User, prev *db.Post, post *db.Post)
+	PostRemoved(s *pssh.SSHServerConnSession, user *db.User, post *db.Post)
+}
+
 type ScpUploadHandler struct {
 	DBPool db.DB
 	Cfg    *shared.ConfigSite
@@ -217,8 +225,7 @@ func (h *ScpUploadHandler) Write(s *pssh.SSHServerConnSession, entry *sendutils.
 	} else {
 		if metadata.Text == post.Text && modTime.Equal(*post.UpdatedAt) {
 			logger.Info("file found, but text is identical, skipping")
-			curl := shared.NewCreateURL(h.Cfg)
-			return h.Cfg.FullPostURL(curl, user.Name, metadata.Slug), nil
+			return h.postURL(user, metadata.Post), nil
 		}
 
 		logger.Info("file found, updating record")
@@ -238,7 +245,7 @@ func (h *ScpUploadHandler) Write(s *pssh.SSHServerConnSession, entry *sendutils.
 			ExpiresAt:   metadata.ExpiresAt,
 			UpdatedAt:   &modTime,
 		}
-		_, err = h.DBPool.UpdatePost(&updatePost)
+		post, err = h.DBPool.UpdatePost(&updatePost)
 		if err != nil {
 			logger.Error("post could not be updated", "err", err.Error())
 			return "", fmt.Errorf("error for %s: %v", filename, err)
@@ -265,8 +272,22 @@ func (h *ScpUploadHandler) Write(s *pssh.SSHServerConnSession, entry *sendutils.
 		}
 	}
 
+	if hooks, ok := h.Hooks.(ScpPostHooks); ok {
+		hooks.PostSaved(s, user, metadata.Cur, post)
+	}
+
+	return h.postURL(user, metadata.Post), nil
+}
+
+// postURL is the url reported back after an upload, drafts link to their
+// secret preview.
+func (h *ScpUploadHandler) postURL(user *db.User, post *db.Post) string {
 	curl := shared.NewCreateURL(h.Cfg)
-	return h.Cfg.FullPostURL(curl, user.Name, metadata.Slug), nil
+	postURL := h.Cfg.FullPostURL(curl, user.Name, post.Slug)
+	if post.Data.PreviewToken != "" {
+		postURL += "?preview=" + post.Data.PreviewToken
+	}
+	return postURL
 }
 
 func (h *ScpUploadHandler) Delete(s *pssh.SSHServerConnSession, entry *sendutils.FileEntry) error {
@@ -300,5 +321,9 @@ func (h *ScpUploadHandler) Delete(s *pssh.SSHServerConnSession, entry *sendutils
 		logger.Error("post could not remove", "err", err.Error())
 		return fmt.Errorf("error for %s: %v", filename, err)
 	}
+
+	if hooks, ok := h.Hooks.(ScpPostHooks); ok {
+		hooks.PostRemoved(s, user, post)
+	}
 	return nil
 }
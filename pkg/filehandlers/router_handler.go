@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/picosh/pico/pkg/db"
 	"github.com/picosh/pico/pkg/pssh"
@@ -40,7 +41,16 @@ func NewFileHandlerRouter(cfg *shared.ConfigSite, dbpool db.DB, mapper map[strin
 	}
 }
 
+// findHandler picks the handler of a file by its extension, keys ending
+// with a slash match every file inside of that directory instead.
 func (r *FileHandlerRouter) findHandler(fp string) (ReadWriteHandler, error) {
+	clean := strings.TrimPrefix(filepath.Clean("/"+fp), "/")
+	for key, handler := range r.FileMap {
+		if strings.HasSuffix(key, "/") && strings.HasPrefix(clean+"/", key) {
+			return handler, nil
+		}
+	}
+
 	fext := filepath.Ext(fp)
 	handler, ok := r.FileMap[fext]
 	if !ok {
@@ -54,6 +64,10 @@ func (r *FileHandlerRouter) findHandler(fp string) (ReadWriteHandler, error) {
 }
 
 func (r *FileHandlerRouter) Write(s *pssh.SSHServerConnSession, entry *utils.FileEntry) (string, error) {
+	if !pssh.CanWrite(s) {
+		return "", pssh.ErrOrgViewer
+	}
+
 	if entry.Mode.IsDir() {
 		return "", os.ErrInvalid
 	}
@@ -66,6 +80,10 @@ func (r *FileHandlerRouter) Write(s *pssh.SSHServerConnSession, entry *utils.Fil
 }
 
 func (r *FileHandlerRouter) Delete(s *pssh.SSHServerConnSession, entry *utils.FileEntry) error {
+	if !pssh.CanWrite(s) {
+		return pssh.ErrOrgViewer
+	}
+
 	handler, err := r.findHandler(entry.Filepath)
 	if err != nil {
 		return err
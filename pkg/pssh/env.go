@@ -0,0 +1,60 @@
+package pssh
+
+import (
+	"path"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultEnvAllowlist is used when a server doesn't configure which env
+// vars clients are allowed to set with "ssh -o SetEnv".
+var DefaultEnvAllowlist = []string{"PICO_*"}
+
+type Signal string
+
+// Signal names as sent in an ssh "signal" request, without the "SIG"
+// prefix (RFC 4254 section 6.9).
+const (
+	SIGINT  Signal = "INT"
+	SIGTERM Signal = "TERM"
+)
+
+// signalBuffer is how many signals are queued for a session before new
+// ones are dropped.
+const signalBuffer = 8
+
+func envAllowed(allowlist []string, name string) bool {
+	for _, pattern := range allowlist {
+		matched, err := path.Match(pattern, name)
+		if err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func parseEnvRequest(payload []byte) (string, string, bool) {
+	var env = struct {
+		Name  string
+		Value string
+	}{}
+	err := ssh.Unmarshal(payload, &env)
+	if err != nil || env.Name == "" {
+		return "", "", false
+	}
+	return env.Name, env.Value, true
+}
+
+func parseSignalRequest(payload []byte) (Signal, bool) {
+	var sig = struct{ Signal string }{}
+	err := ssh.Unmarshal(payload, &sig)
+	if err != nil {
+		return "", false
+	}
+
+	switch Signal(sig.Signal) {
+	case SIGINT, SIGTERM:
+		return Signal(sig.Signal), true
+	}
+	return "", false
+}
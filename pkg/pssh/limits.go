@@ -0,0 +1,296 @@
+package pssh
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrConnRejected = errors.New("connection rejected")
+
+// Reasons reported by the pssh_connections_rejected_total counter.
+const (
+	RejectBanned        = "banned"
+	RejectConnsPerIP    = "conns_per_ip"
+	RejectConnsPerUser  = "conns_per_user"
+	RejectSessionRate   = "session_rate"
+	RejectAuthFailure   = "auth_failure"
+	RejectHandshake     = "handshake"
+	RejectIdleTimeout   = "idle_timeout"
+	defaultSessionBurst = 1
+)
+
+/*
+ConnLimits protects an SSHServer from abusive clients.
+
+A zero value for any field disables that limit.  Every rejected
+connection, rate limited session and connection that failed to
+authenticate counts as a strike against the remote IP, rejected keys of
+a connection that still authenticates do not.  Once an IP collects
+BanThreshold strikes within BanDuration it is banned for BanDuration.
+*/
+type ConnLimits struct {
+	MaxConnsPerIP     int
+	MaxConnsPerUser   int
+	HandshakeTimeout  time.Duration
+	IdleTimeout       time.Duration
+	SessionsPerSecond float64
+	SessionBurst      int
+	BanThreshold      int
+	BanDuration       time.Duration
+	// AuthFailureDelay is slept before a connection that failed to
+	// authenticate is closed.
+	AuthFailureDelay time.Duration
+}
+
+/*
+NewConnLimitsFromEnv reads ConnLimits from SSH_* env vars, e.g.
+SSH_MAX_CONNS_PER_IP=10 or SSH_IDLE_TIMEOUT=1h.  It returns nil when none
+of them are set.
+*/
+func NewConnLimitsFromEnv() *ConnLimits {
+	limits := &ConnLimits{}
+	found := false
+
+	envInt := func(key string, dst *int) {
+		if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+			*dst = v
+			found = true
+		}
+	}
+	envDur := func(key string, dst *time.Duration) {
+		if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+			*dst = v
+			found = true
+		}
+	}
+
+	envInt("SSH_MAX_CONNS_PER_IP", &limits.MaxConnsPerIP)
+	envInt("SSH_MAX_CONNS_PER_USER", &limits.MaxConnsPerUser)
+	envDur("SSH_HANDSHAKE_TIMEOUT", &limits.HandshakeTimeout)
+	envDur("SSH_IDLE_TIMEOUT", &limits.IdleTimeout)
+	if v, err := strconv.ParseFloat(os.Getenv("SSH_SESSIONS_PER_SEC"), 64); err == nil {
+		limits.SessionsPerSecond = v
+		found = true
+	}
+	envInt("SSH_SESSION_BURST", &limits.SessionBurst)
+	envInt("SSH_BAN_THRESHOLD", &limits.BanThreshold)
+	envDur("SSH_BAN_DURATION", &limits.BanDuration)
+	envDur("SSH_AUTH_FAILURE_DELAY", &limits.AuthFailureDelay)
+
+	if !found {
+		return nil
+	}
+	return limits
+}
+
+type sessionBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// connTracker keeps the per IP, user and key state needed to enforce
+// ConnLimits.
+type connTracker struct {
+	mu      sync.Mutex
+	ips     map[string]int
+	users   map[string]int
+	buckets map[string]*sessionBucket
+	strikes map[string][]time.Time
+	bans    map[string]time.Time
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{
+		ips:     map[string]int{},
+		users:   map[string]int{},
+		buckets: map[string]*sessionBucket{},
+		strikes: map[string][]time.Time{},
+		bans:    map[string]time.Time{},
+	}
+}
+
+func (t *connTracker) banned(ip string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	until, ok := t.bans[ip]
+	if !ok {
+		return false
+	}
+	if now.After(until) {
+		delete(t.bans, ip)
+		return false
+	}
+	return true
+}
+
+// strike records a violation for ip and reports whether it is now banned.
+func (t *connTracker) strike(limits *ConnLimits, ip string, now time.Time) bool {
+	if limits.BanThreshold <= 0 || limits.BanDuration <= 0 {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := now.Add(-limits.BanDuration)
+	recent := []time.Time{now}
+	for _, s := range t.strikes[ip] {
+		if s.After(cutoff) {
+			recent = append(recent, s)
+		}
+	}
+
+	if len(recent) >= limits.BanThreshold {
+		delete(t.strikes, ip)
+		t.bans[ip] = now.Add(limits.BanDuration)
+		return true
+	}
+
+	t.strikes[ip] = recent
+	return false
+}
+
+func acquire(counts map[string]int, key string, max int) bool {
+	if max > 0 && counts[key] >= max {
+		return false
+	}
+	counts[key]++
+	return true
+}
+
+func release(counts map[string]int, key string) {
+	counts[key]--
+	if counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+func (t *connTracker) acquireIP(limits *ConnLimits, ip string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return acquire(t.ips, ip, limits.MaxConnsPerIP)
+}
+
+func (t *connTracker) releaseIP(ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	release(t.ips, ip)
+}
+
+func (t *connTracker) acquireUser(limits *ConnLimits, user string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return acquire(t.users, user, limits.MaxConnsPerUser)
+}
+
+func (t *connTracker) releaseUser(user string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	release(t.users, user)
+}
+
+// allowSession takes a token from key's bucket, refilling at
+// SessionsPerSecond up to SessionBurst tokens.
+func (t *connTracker) allowSession(limits *ConnLimits, key string, now time.Time) bool {
+	if limits.SessionsPerSecond <= 0 {
+		return true
+	}
+
+	burst := float64(limits.SessionBurst)
+	if burst <= 0 {
+		burst = defaultSessionBurst
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bucket, ok := t.buckets[key]
+	if !ok {
+		bucket = &sessionBucket{tokens: burst, last: now}
+		t.buckets[key] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * limits.SessionsPerSecond
+	if bucket.tokens > burst {
+		bucket.tokens = burst
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// cleanup drops expired bans and strikes and full session buckets.
+func (t *connTracker) cleanup(limits *ConnLimits, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for ip, until := range t.bans {
+		if now.After(until) {
+			delete(t.bans, ip)
+		}
+	}
+
+	for ip, strikes := range t.strikes {
+		if len(strikes) == 0 || now.Sub(strikes[0]) > limits.BanDuration {
+			delete(t.strikes, ip)
+		}
+	}
+
+	burst := float64(max(limits.SessionBurst, defaultSessionBurst))
+	for key, bucket := range t.buckets {
+		refilled := bucket.tokens + now.Sub(bucket.last).Seconds()*limits.SessionsPerSecond
+		if refilled >= burst {
+			delete(t.buckets, key)
+		}
+	}
+}
+
+// idleConn closes the connection when no data is read or written for
+// timeout.  The timeout is only enforced once set, so the handshake
+// deadline isn't pushed back by handshake traffic.
+type idleConn struct {
+	net.Conn
+	timeout atomic.Int64
+	onIdle  func()
+}
+
+func (c *idleConn) extend() {
+	if timeout := c.timeout.Load(); timeout > 0 {
+		_ = c.Conn.SetDeadline(time.Now().Add(time.Duration(timeout)))
+	}
+}
+
+func (c *idleConn) Read(p []byte) (int, error) {
+	c.extend()
+	n, err := c.Conn.Read(p)
+	if errors.Is(err, os.ErrDeadlineExceeded) && c.timeout.Load() > 0 && c.onIdle != nil {
+		c.onIdle()
+	}
+	return n, err
+}
+
+func (c *idleConn) Write(p []byte) (int, error) {
+	c.extend()
+	return c.Conn.Write(p)
+}
+
+func remoteIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,275 @@
+package pssh_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/picosh/pico/pkg/pssh"
+	"golang.org/x/crypto/ssh"
+)
+
+func newLimitedServer(t *testing.T, limits *pssh.ConnLimits, authErr error) (*pssh.SSHServer, UserSSH) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	user := GenerateKey()
+	server := pssh.NewSSHServer(ctx, slog.Default(), &pssh.SSHServerConfig{
+		Limits: limits,
+		ServerConfig: &ssh.ServerConfig{
+			PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+				if authErr != nil {
+					return nil, authErr
+				}
+				return &ssh.Permissions{
+					Extensions: map[string]string{"pubkey": string(key.Marshal())},
+				}, nil
+			},
+		},
+	})
+	server.Config.AddHostKey(user.signer)
+
+	return server, user
+}
+
+// connPipe joins two net.Pipe connections with copiers so both ends can
+// send their ssh version banner at the same time without deadlocking.
+func connPipe() (net.Conn, net.Conn) {
+	serverConn, a := net.Pipe()
+	b, clientConn := net.Pipe()
+
+	proxy := func(dst, src net.Conn) {
+		_, _ = io.Copy(dst, src)
+		_ = dst.Close()
+		_ = src.Close()
+	}
+	go proxy(a, b)
+	go proxy(b, a)
+
+	return serverConn, clientConn
+}
+
+// dialPipe connects a client to server over net.Pipe and returns the ssh
+// client along with the result of HandleConn.
+func dialPipe(server *pssh.SSHServer, user UserSSH) (*ssh.Client, <-chan error, error) {
+	serverConn, clientConn := connPipe()
+
+	handled := make(chan error, 1)
+	go func() {
+		handled <- server.HandleConn(serverConn)
+	}()
+
+	config := &ssh.ClientConfig{
+		User:            "user",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(user.signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	conn, chans, reqs, err := ssh.NewClientConn(clientConn, "pipe", config)
+	if err != nil {
+		_ = clientConn.Close()
+		return nil, handled, err
+	}
+
+	return ssh.NewClient(conn, chans, reqs), handled, nil
+}
+
+func waitHandled(t *testing.T, handled <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-handled:
+		return err
+	case <-time.After(3 * time.Second):
+		t.Fatal("HandleConn did not return")
+		return nil
+	}
+}
+
+func TestConnLimits_MaxConnsPerIP(t *testing.T) {
+	server, user := newLimitedServer(t, &pssh.ConnLimits{MaxConnsPerIP: 1}, nil)
+
+	client, _, err := dialPipe(server, user)
+	if err != nil {
+		t.Fatalf("first connection should succeed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	_, handled, err := dialPipe(server, user)
+	if err == nil {
+		t.Fatal("second connection from the same ip should be rejected")
+	}
+	if err := waitHandled(t, handled); !errors.Is(err, pssh.ErrConnRejected) {
+		t.Errorf("expected ErrConnRejected, got %v", err)
+	}
+}
+
+func TestConnLimits_MaxConnsPerUser(t *testing.T) {
+	server, user := newLimitedServer(t, &pssh.ConnLimits{MaxConnsPerUser: 1}, nil)
+
+	client, _, err := dialPipe(server, user)
+	if err != nil {
+		t.Fatalf("first connection should succeed: %v", err)
+	}
+
+	second, handled, err := dialPipe(server, user)
+	if err == nil {
+		// the handshake finishes before the user is known so the
+		// connection is closed right after it
+		_, err = second.NewSession()
+	}
+	if err == nil {
+		t.Error("second connection for the same user should be closed")
+	}
+	if err := waitHandled(t, handled); !errors.Is(err, pssh.ErrConnRejected) {
+		t.Errorf("expected ErrConnRejected, got %v", err)
+	}
+
+	_ = client.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	third, _, err := dialPipe(server, user)
+	if err != nil {
+		t.Fatalf("connection should succeed once the first one closed: %v", err)
+	}
+	_ = third.Close()
+}
+
+func TestConnLimits_HandshakeTimeout(t *testing.T) {
+	server, _ := newLimitedServer(t, &pssh.ConnLimits{HandshakeTimeout: 50 * time.Millisecond}, nil)
+
+	serverConn, clientConn := connPipe()
+	defer func() { _ = clientConn.Close() }()
+
+	handled := make(chan error, 1)
+	go func() {
+		handled <- server.HandleConn(serverConn)
+	}()
+
+	// drain the server version banner but never answer
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := clientConn.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := waitHandled(t, handled); err == nil {
+		t.Error("expected handshake to time out")
+	}
+}
+
+func TestConnLimits_IdleTimeout(t *testing.T) {
+	server, user := newLimitedServer(t, &pssh.ConnLimits{IdleTimeout: 100 * time.Millisecond}, nil)
+
+	client, handled, err := dialPipe(server, user)
+	if err != nil {
+		t.Fatalf("connection should succeed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	waitHandled(t, handled)
+}
+
+func TestConnLimits_SessionRate(t *testing.T) {
+	server, user := newLimitedServer(t, &pssh.ConnLimits{SessionsPerSecond: 0.01, SessionBurst: 1}, nil)
+
+	client, _, err := dialPipe(server, user)
+	if err != nil {
+		t.Fatalf("connection should succeed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("first session should succeed: %v", err)
+	}
+	_ = session.Close()
+
+	_, err = client.NewSession()
+	var openErr *ssh.OpenChannelError
+	if !errors.As(err, &openErr) || openErr.Reason != ssh.ResourceShortage {
+		t.Errorf("expected resource shortage for second session, got %v", err)
+	}
+}
+
+func TestConnLimits_BanAfterAuthFailures(t *testing.T) {
+	server, user := newLimitedServer(t, &pssh.ConnLimits{
+		BanThreshold: 2,
+		BanDuration:  time.Minute,
+	}, fmt.Errorf("denied"))
+
+	for i := 0; i < 2; i++ {
+		_, handled, err := dialPipe(server, user)
+		if err == nil {
+			t.Fatal("authentication should fail")
+		}
+		waitHandled(t, handled)
+	}
+
+	_, handled, err := dialPipe(server, user)
+	if err == nil {
+		t.Fatal("banned ip should not connect")
+	}
+	if err := waitHandled(t, handled); !errors.Is(err, pssh.ErrConnRejected) {
+		t.Errorf("expected banned connection to be rejected, got %v", err)
+	}
+}
+
+func TestConnLimits_RejectedKeysBeforeSuccessAreNotStrikes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	user := GenerateKey()
+	others := []UserSSH{GenerateKey(), GenerateKey(), GenerateKey()}
+	server := pssh.NewSSHServer(ctx, slog.Default(), &pssh.SSHServerConfig{
+		Limits: &pssh.ConnLimits{
+			BanThreshold: 1,
+			BanDuration:  time.Minute,
+		},
+		ServerConfig: &ssh.ServerConfig{
+			PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+				if string(key.Marshal()) != string(user.signer.PublicKey().Marshal()) {
+					return nil, fmt.Errorf("denied")
+				}
+				return &ssh.Permissions{
+					Extensions: map[string]string{"pubkey": string(key.Marshal())},
+				}, nil
+			},
+		},
+	})
+	server.Config.AddHostKey(user.signer)
+
+	// an agent offering several keys before the one that is accepted
+	signers := []ssh.Signer{}
+	for _, other := range others {
+		signers = append(signers, other.signer)
+	}
+	signers = append(signers, user.signer)
+
+	for range 2 {
+		serverConn, clientConn := connPipe()
+		handled := make(chan error, 1)
+		go func() {
+			handled <- server.HandleConn(serverConn)
+		}()
+
+		conn, chans, reqs, err := ssh.NewClientConn(clientConn, "pipe", &ssh.ClientConfig{
+			User:            "user",
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		})
+		if err != nil {
+			t.Fatalf("expected the agent to authenticate, got %v", err)
+		}
+		_ = ssh.NewClient(conn, chans, reqs).Close()
+		waitHandled(t, handled)
+	}
+}
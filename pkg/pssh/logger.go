@@ -1,12 +1,21 @@
 package pssh
 
 import (
+	"errors"
 	"log/slog"
 	"time"
 
 	"github.com/picosh/pico/pkg/db"
 )
 
+// ErrOrgViewer is returned when a member with the viewer role tries to
+// modify content owned by an org.
+var ErrOrgViewer = errors.New("org viewers cannot modify content")
+
+// ErrOrgOwner is returned when a member without the owner role tries to
+// manage an org's account, e.g. its keys, tokens or certs.
+var ErrOrgOwner = errors.New("only org owners can manage the account")
+
 type ctxLoggerKey struct{}
 type ctxUserKey struct{}
 
@@ -31,12 +40,14 @@ func LogMiddleware(getLogger GetLoggerInterface, database FindUserInterface) SSH
 				user := GetUser(s)
 				if user == nil {
 					_, impersonated := s.Permissions().Extensions["imp_id"]
+					// the user id points at the org when in an org context
+					_, inOrg := s.Permissions().Extensions["org_role"]
 
 					var user *db.User
 					var err error
 					var found bool
 
-					if !impersonated {
+					if !impersonated && !inOrg {
 						pubKey, ok := s.Permissions().Extensions["pubkey"]
 						if ok {
 							user, err = database.FindUserByPubkey(pubKey)
@@ -60,6 +71,12 @@ func LogMiddleware(getLogger GetLoggerInterface, database FindUserInterface) SSH
 								"ip", s.RemoteAddr().String(),
 								"identity", identity,
 							)
+							if inOrg {
+								logger = logger.With(
+									"memberId", s.Permissions().Extensions["member_id"],
+									"orgRole", GetOrgRole(s),
+								)
+							}
 
 							SetUser(s, user)
 						} else {
@@ -155,3 +172,34 @@ func SetUser(s *SSHServerConnSession, user *db.User) {
 
 	s.SetValue(ctxUserKey{}, user)
 }
+
+// GetOrgRole returns the member's role when the session was opened in an
+// org context and an empty string otherwise.
+func GetOrgRole(s *SSHServerConnSession) string {
+	if s == nil || s.SSHServerConn == nil || s.Conn == nil || s.Permissions() == nil {
+		return ""
+	}
+	return s.Permissions().Extensions["org_role"]
+}
+
+// GetOrgMemberID returns the id of the member behind a session opened in
+// an org context and an empty string otherwise.
+func GetOrgMemberID(s *SSHServerConnSession) string {
+	if s == nil || s.SSHServerConn == nil || s.Conn == nil || s.Permissions() == nil {
+		return ""
+	}
+	return s.Permissions().Extensions["member_id"]
+}
+
+// CanWrite reports whether the session is allowed to modify content,
+// which org viewers are not.
+func CanWrite(s *SSHServerConnSession) bool {
+	return GetOrgRole(s) != db.OrgRoleViewer
+}
+
+// CanManage reports whether the session is allowed to manage the account
+// itself, which only org owners are.
+func CanManage(s *SSHServerConnSession) bool {
+	role := GetOrgRole(s)
+	return role == "" || role == db.OrgRoleOwner
+}
@@ -16,6 +16,7 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"strings"
 	"sync"
 	"time"
 	"unicode/utf8"
@@ -60,6 +61,8 @@ type SSHServerConnSession struct {
 
 	pty   *Pty
 	winch chan Window
+	env   []string
+	sigs  chan Signal
 
 	mu sync.Mutex
 }
@@ -222,6 +225,67 @@ func (s *SSHServerConnSession) Write(p []byte) (n int, err error) {
 	return written, err
 }
 
+// Environ returns the env vars accepted from the client as "KEY=value"
+// pairs.  Only names matching the server's env allowlist are kept.
+func (s *SSHServerConnSession) Environ() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.env...)
+}
+
+// Getenv returns the value of an accepted client env var.
+func (s *SSHServerConnSession) Getenv(key string) string {
+	for _, kv := range s.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if name == key {
+			return value
+		}
+	}
+	return ""
+}
+
+func (s *SSHServerConnSession) setenv(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := key + "="
+	for i, kv := range s.env {
+		if strings.HasPrefix(kv, prefix) {
+			s.env[i] = prefix + value
+			return
+		}
+	}
+	s.env = append(s.env, prefix+value)
+}
+
+// Signals delivers SIGINT and SIGTERM requests sent by the client.
+func (s *SSHServerConnSession) Signals() <-chan Signal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sigs == nil {
+		s.sigs = make(chan Signal, signalBuffer)
+	}
+	return s.sigs
+}
+
+func (s *SSHServerConnSession) signal(sig Signal) bool {
+	s.mu.Lock()
+	if s.sigs == nil {
+		s.sigs = make(chan Signal, signalBuffer)
+	}
+	sigs := s.sigs
+	s.mu.Unlock()
+
+	select {
+	case sigs <- sig:
+		return true
+	default:
+		return false
+	}
+}
+
 var _ context.Context = &SSHServerConnSession{}
 
 func (sc *SSHServerConn) Handle(chans <-chan ssh.NewChannel, reqs <-chan *ssh.Request) error {
@@ -306,6 +370,11 @@ type SSHServerConfig struct {
 	Middleware          []SSHServerMiddleware
 	SubsystemMiddleware []SSHServerMiddleware
 	ChannelMiddleware   map[string]SSHServerChannelMiddleware
+	// EnvAllowlist holds path.Match patterns for the env var names clients
+	// may set.  Defaults to DefaultEnvAllowlist.
+	EnvAllowlist []string
+	// Limits protects the server from abusive clients, nil disables them.
+	Limits *ConnLimits
 }
 
 type SSHServer struct {
@@ -316,9 +385,12 @@ type SSHServer struct {
 	Listener   net.Listener
 	Conns      *syncmap.Map[string, *SSHServerConn]
 
-	SessionsCreated  *prometheus.CounterVec
-	SessionsFinished *prometheus.CounterVec
-	SessionsDuration *prometheus.CounterVec
+	SessionsCreated     *prometheus.CounterVec
+	SessionsFinished    *prometheus.CounterVec
+	SessionsDuration    *prometheus.CounterVec
+	ConnectionsRejected *prometheus.CounterVec
+
+	tracker *connTracker
 
 	Mu sync.Mutex
 }
@@ -349,6 +421,14 @@ func (s *SSHServer) ListenAndServe() error {
 			},
 		}, []string{"command"})
 
+		s.ConnectionsRejected = promauto.With(prometheus.DefaultRegisterer).NewCounterVec(prometheus.CounterOpts{
+			Name: "pssh_connections_rejected_total",
+			Help: "The total number of connections and sessions rejected by limits",
+			ConstLabels: prometheus.Labels{
+				"app": s.Config.App,
+			},
+		}, []string{"reason"})
+
 		go func() {
 			mux := http.NewServeMux()
 			mux.Handle("/metrics", promhttp.Handler())
@@ -393,6 +473,21 @@ func (s *SSHServer) ListenAndServe() error {
 		_ = s.Close()
 	}()
 
+	if limits := s.Config.Limits; limits != nil {
+		go func() {
+			ticker := time.NewTicker(time.Minute)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-s.Ctx.Done():
+					return
+				case now := <-ticker.C:
+					s.tracker.cleanup(limits, now)
+				}
+			}
+		}()
+	}
+
 	var retErr error
 
 	for {
@@ -425,11 +520,71 @@ func (s *SSHServer) HandleConn(conn net.Conn) error {
 		_ = conn.Close()
 	}()
 
-	sshConn, chans, reqs, err := ssh.NewServerConn(conn, s.Config.ServerConfig)
+	limits := s.Config.Limits
+	serverConfig := s.Config.ServerConfig
+	authFailures := new(int)
+	ip := remoteIP(conn.RemoteAddr())
+
+	if limits != nil {
+		if s.tracker.banned(ip, time.Now()) {
+			s.reject(RejectBanned)
+			return fmt.Errorf("%w: %s is banned", ErrConnRejected, ip)
+		}
+
+		if !s.tracker.acquireIP(limits, ip) {
+			s.rejectAndStrike(RejectConnsPerIP, ip)
+			return fmt.Errorf("%w: too many connections from %s", ErrConnRejected, ip)
+		}
+		defer s.tracker.releaseIP(ip)
+
+		if limits.HandshakeTimeout > 0 {
+			_ = conn.SetDeadline(time.Now().Add(limits.HandshakeTimeout))
+		}
+
+		if limits.IdleTimeout > 0 {
+			conn = &idleConn{
+				Conn:   conn,
+				onIdle: func() { s.reject(RejectIdleTimeout) },
+			}
+		}
+
+		serverConfig, authFailures = s.limitAuth()
+	}
+
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
 	if err != nil {
+		if limits != nil {
+			switch {
+			case errors.Is(err, os.ErrDeadlineExceeded):
+				s.rejectAndStrike(RejectHandshake, ip)
+			case *authFailures > 0:
+				// a single strike for the whole attempt, clients commonly
+				// offer several keys before one is accepted
+				s.rejectAndStrike(RejectAuthFailure, ip)
+				if limits.AuthFailureDelay > 0 {
+					time.Sleep(limits.AuthFailureDelay)
+				}
+			}
+		}
 		return err
 	}
 
+	if limits != nil {
+		_ = conn.SetDeadline(time.Time{})
+		if idle, ok := conn.(*idleConn); ok {
+			idle.timeout.Store(int64(limits.IdleTimeout))
+			idle.extend()
+		}
+
+		user := connUser(sshConn)
+		if !s.tracker.acquireUser(limits, user) {
+			s.rejectAndStrike(RejectConnsPerUser, ip)
+			_ = sshConn.Close()
+			return fmt.Errorf("%w: too many connections for %s", ErrConnRejected, user)
+		}
+		defer s.tracker.releaseUser(user)
+	}
+
 	newLogger := s.Logger.With(
 		"remoteAddr", conn.RemoteAddr().String(),
 		"sshUser", sshConn.User(),
@@ -455,6 +610,66 @@ func (s *SSHServer) HandleConn(conn net.Conn) error {
 	return err
 }
 
+// limitAuth returns a copy of the server config that counts the failed
+// authentication attempts of a connection.
+func (s *SSHServer) limitAuth() (*ssh.ServerConfig, *int) {
+	cfg := *s.Config.ServerConfig
+	next := cfg.AuthLogCallback
+	failures := 0
+
+	cfg.AuthLogCallback = func(conn ssh.ConnMetadata, method string, err error) {
+		if next != nil {
+			next(conn, method, err)
+		}
+
+		if err != nil && method != "none" {
+			failures++
+		}
+	}
+
+	return &cfg, &failures
+}
+
+// allowSession reports whether the connection may open another session
+// under the session rate limit.
+func (s *SSHServer) allowSession(sc *SSHServerConn) bool {
+	limits := s.Config.Limits
+	if limits == nil {
+		return true
+	}
+
+	if s.tracker.allowSession(limits, connUser(sc.Conn), time.Now()) {
+		return true
+	}
+
+	s.rejectAndStrike(RejectSessionRate, remoteIP(sc.Conn.RemoteAddr()))
+	return false
+}
+
+func (s *SSHServer) reject(reason string) {
+	if s.ConnectionsRejected != nil {
+		s.ConnectionsRejected.WithLabelValues(reason).Inc()
+	}
+}
+
+func (s *SSHServer) rejectAndStrike(reason, ip string) {
+	s.reject(reason)
+	if s.tracker.strike(s.Config.Limits, ip, time.Now()) {
+		s.Logger.Info("banning remote ip", "ip", ip, "reason", reason)
+	}
+}
+
+// connUser identifies the user of a connection by their public key,
+// falling back to the remote IP.
+func connUser(conn *ssh.ServerConn) string {
+	if conn.Permissions != nil {
+		if key, ok := conn.Permissions.Extensions["pubkey"]; ok && key != "" {
+			return key
+		}
+	}
+	return remoteIP(conn.RemoteAddr())
+}
+
 func (s *SSHServer) Close() error {
 	s.CancelFunc()
 	return s.Listener.Close()
@@ -479,8 +694,16 @@ func NewSSHServer(ctx context.Context, logger *slog.Logger, config *SSHServerCon
 		config.ChannelMiddleware = map[string]SSHServerChannelMiddleware{}
 	}
 
+	if config.EnvAllowlist == nil {
+		config.EnvAllowlist = DefaultEnvAllowlist
+	}
+
 	if _, ok := config.ChannelMiddleware["session"]; !ok {
 		config.ChannelMiddleware["session"] = func(newChan ssh.NewChannel, sc *SSHServerConn) error {
+			if !sc.SSHServer.allowSession(sc) {
+				return newChan.Reject(ssh.ResourceShortage, "too many sessions, try again later")
+			}
+
 			channel, requests, err := newChan.Accept()
 			if err != nil {
 				sc.Logger.Error("accept session channel", "err", err)
@@ -505,6 +728,37 @@ func NewSSHServer(ctx context.Context, logger *slog.Logger, config *SSHServerCon
 						return nil
 					}
 
+					// env and signal requests are handled in order so env vars
+					// are set before the shell or exec request runs
+					switch req.Type {
+					case "env":
+						name, value, ok := parseEnvRequest(req.Payload)
+						ok = ok && envAllowed(sc.SSHServer.Config.EnvAllowlist, name)
+						if ok {
+							sesh.setenv(name, value)
+						} else {
+							sc.Logger.Info("env var rejected", "name", name)
+						}
+
+						err := req.Reply(ok, nil)
+						if err != nil {
+							sc.Logger.Error("env reply", "err", err)
+						}
+						continue
+					case "signal":
+						sig, ok := parseSignalRequest(req.Payload)
+						if ok {
+							ok = sesh.signal(sig)
+						}
+						sc.Logger.Info("signal received", "signal", sig, "delivered", ok)
+
+						err := req.Reply(ok, nil)
+						if err != nil {
+							sc.Logger.Error("signal reply", "err", err)
+						}
+						continue
+					}
+
 					go func() {
 						sc.Logger.Info("new session request", "type", req.Type, "wantReply", req.WantReply, "payload", req.Payload)
 						switch req.Type {
@@ -690,6 +944,7 @@ func NewSSHServer(ctx context.Context, logger *slog.Logger, config *SSHServerCon
 		Logger:     logger,
 		Config:     config,
 		Conns:      syncmap.New[string, *SSHServerConn](),
+		tracker:    newConnTracker(),
 	}
 
 	return server
@@ -714,6 +969,7 @@ func NewSSHServerWithConfig(
 		Middleware:          middleware,
 		SubsystemMiddleware: subsystemMiddleware,
 		ChannelMiddleware:   channelMiddleware,
+		Limits:              NewConnLimitsFromEnv(),
 	})
 
 	if promPort != "" {
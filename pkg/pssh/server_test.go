@@ -652,3 +652,114 @@ func GenerateKey() UserSSH {
 		signer:   userSigner,
 	}
 }
+
+func TestSSHServerEnvAndSignals(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := slog.Default()
+	user := GenerateKey()
+
+	envChan := make(chan []string, 1)
+	projectChan := make(chan string, 1)
+	sigChan := make(chan pssh.Signal, 1)
+
+	server := pssh.NewSSHServer(ctx, logger, &pssh.SSHServerConfig{
+		ListenAddr: "127.0.0.1:0",
+		Middleware: []pssh.SSHServerMiddleware{
+			func(next pssh.SSHServerHandler) pssh.SSHServerHandler {
+				return func(sesh *pssh.SSHServerConnSession) error {
+					envChan <- sesh.Environ()
+					projectChan <- sesh.Getenv("PICO_PROJECT")
+					_, _ = sesh.Write([]byte("ready\n"))
+					select {
+					case sig := <-sesh.Signals():
+						sigChan <- sig
+					case <-time.After(2 * time.Second):
+					}
+					return next(sesh)
+				}
+			},
+		},
+		ServerConfig: &ssh.ServerConfig{
+			NoClientAuth: true,
+			NoClientAuthCallback: func(ssh.ConnMetadata) (*ssh.Permissions, error) {
+				return &ssh.Permissions{Extensions: map[string]string{}}, nil
+			},
+		},
+	})
+	server.Config.AddHostKey(user.signer)
+
+	go func() {
+		_ = server.ListenAndServe()
+	}()
+
+	var actualAddr string
+	for i := 0; i < 50; i++ {
+		server.Mu.Lock()
+		listener := server.Listener
+		server.Mu.Unlock()
+		if listener != nil {
+			actualAddr = listener.Addr().String()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if actualAddr == "" {
+		t.Fatal("server listener not ready")
+	}
+
+	client, err := ssh.Dial("tcp", actualAddr, &ssh.ClientConfig{
+		User:            "user",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(user.signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("session: %v", err)
+	}
+	defer func() { _ = session.Close() }()
+
+	if err := session.Setenv("PICO_PROJECT", "blog"); err != nil {
+		t.Errorf("expected PICO_PROJECT to be accepted: %v", err)
+	}
+	if err := session.Setenv("LD_PRELOAD", "evil.so"); err == nil {
+		t.Error("expected LD_PRELOAD to be rejected")
+	}
+
+	stdout, _ := session.StdoutPipe()
+	if err := session.Start("cmd"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	buf := make([]byte, 6)
+	if _, err := io.ReadFull(stdout, buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if err := session.Signal(ssh.SIGINT); err != nil {
+		t.Fatalf("signal: %v", err)
+	}
+
+	env := <-envChan
+	if !slices.Equal(env, []string{"PICO_PROJECT=blog"}) {
+		t.Errorf("unexpected environ %v", env)
+	}
+	if project := <-projectChan; project != "blog" {
+		t.Errorf("expected PICO_PROJECT=blog, got %q", project)
+	}
+
+	select {
+	case sig := <-sigChan:
+		if sig != pssh.SIGINT {
+			t.Errorf("expected SIGINT, got %s", sig)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("signal was not delivered")
+	}
+}
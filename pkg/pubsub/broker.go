@@ -14,6 +14,10 @@ import (
 	"github.com/antoniomika/syncmap"
 )
 
+// MaxFrameSize is the largest read of a publisher's input, each read is
+// dispatched to subscribers as a single message.
+const MaxFrameSize = 32 * 1024
+
 // HasWildcard checks if a topic string contains the wildcard character (*).
 func HasWildcard(topic string) bool {
 	return strings.Contains(topic, "*")
@@ -150,11 +154,32 @@ func (b *BaseBroker) Connect(client *Client, channels []*Channel) (error, error)
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			// a publisher's message is all of its input, a bidirectional
+			// client sends every frame as a message of its own
+			message := 0
 			for {
-				data := make([]byte, 32*1024)
+				data := make([]byte, MaxFrameSize)
 				n, err := client.ReadWriter.Read(data)
 
 				data = data[:n]
+				message += n
+				if client.Direction == ChannelDirectionInputOutput {
+					message = n
+				}
+
+				if n > 0 && client.Limiter != nil {
+					topics := []string{}
+					for _, channel := range client.GetChannels() {
+						topics = append(topics, channel.Topic)
+					}
+
+					limitErr := client.Limiter.Allow(topics, n, message)
+					if limitErr != nil {
+						inputErr = limitErr
+						client.Cleanup()
+						return
+					}
+				}
 
 				channelMessage := ChannelMessage{
 					Data:      data,
@@ -193,8 +218,10 @@ func (b *BaseBroker) Connect(client *Client, channels []*Channel) (error, error)
 					sendwg.Add(1)
 					go func() {
 						defer sendwg.Done()
+						msg := channelMessage
+						msg.Topic = channel.Topic
 						select {
-						case channel.Data <- channelMessage:
+						case channel.Data <- msg:
 						case <-client.Done:
 						case <-channel.Done:
 						}
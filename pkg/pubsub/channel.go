@@ -33,6 +33,7 @@ const (
 type ChannelMessage struct {
 	Data      []byte
 	ClientID  string
+	Topic     string
 	Direction ChannelDirection
 	Action    ChannelAction
 }
@@ -37,6 +37,8 @@ type Client struct {
 	Replay     bool
 	BlockWrite bool
 	KeepAlive  bool
+	Limiter    Limiter
+	federated  bool
 	once       sync.Once
 	onceData   sync.Once
 }
@@ -0,0 +1,392 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FederationMessageKind string
+
+const (
+	FederationMessageMembership FederationMessageKind = "membership"
+	FederationMessageData       FederationMessageKind = "data"
+)
+
+/*
+FederationMessage is the envelope exchanged between federated brokers.
+
+Membership messages advertise the topics (including wildcard patterns) the
+origin broker currently has local subscribers for.  Data messages carry a
+single published payload for a concrete topic.
+*/
+type FederationMessage struct {
+	Kind   FederationMessageKind `json:"kind"`
+	Origin string                `json:"origin"`
+	ID     string                `json:"id,omitempty"`
+	Topic  string                `json:"topic,omitempty"`
+	Topics []string              `json:"topics,omitempty"`
+	Data   []byte                `json:"data,omitempty"`
+}
+
+// FederationTransport delivers messages to a peer broker.  Peers are
+// addressed by the same string they use as their federation ID.
+type FederationTransport interface {
+	Send(ctx context.Context, peer string, msg *FederationMessage) error
+}
+
+type federationPeer struct {
+	client   *Client
+	topics   []string
+	lastSeen time.Time
+}
+
+/*
+Federation is a Multicast broker that bridges topics across several
+broker instances.
+
+Each broker periodically gossips the set of topics its local subscribers
+are interested in.  When a peer advertises interest in a topic, the
+broker attaches a keep-alive subscriber on behalf of that peer to the
+matching local channels, so dispatchers, blocking publishers and channel
+listings treat remote subscribers like local ones.  Anything dispatched
+to that subscriber is forwarded to the peer.
+
+Forwarded messages are only ever delivered to local clients on the
+receiving broker and are never forwarded again, which prevents loops in a
+full mesh.  Each message carries a unique ID and duplicates are dropped,
+giving at-most-once delivery per broker.
+*/
+type Federation struct {
+	*Multicast
+	ID        string
+	Peers     []string
+	Transport FederationTransport
+	Interval  time.Duration
+	// Timeout bounds every send to a peer so a slow or unreachable peer
+	// does not hold up gossip or forwarded messages.
+	Timeout time.Duration
+
+	base     *BaseBroker
+	mu       sync.Mutex
+	interest map[string]int
+	peers    map[string]*federationPeer
+	seen     map[string]time.Time
+	notify   chan struct{}
+}
+
+func NewFederation(logger *slog.Logger, id string, peers []string, transport FederationTransport) *Federation {
+	cast := NewMulticast(logger.With(slog.String("federation", id)))
+	return &Federation{
+		Multicast: cast,
+		ID:        id,
+		Peers:     slices.DeleteFunc(slices.Clone(peers), func(p string) bool { return p == id }),
+		Transport: transport,
+		Interval:  5 * time.Second,
+		Timeout:   5 * time.Second,
+		base:      cast.Broker.(*BaseBroker),
+		interest:  map[string]int{},
+		peers:     map[string]*federationPeer{},
+		seen:      map[string]time.Time{},
+		notify:    make(chan struct{}, 1),
+	}
+}
+
+func federationClientID(peer string) string {
+	return fmt.Sprintf("federation-%s", peer)
+}
+
+// Start gossips local topic membership to peers until ctx is cancelled.
+func (f *Federation) Start(ctx context.Context) {
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+
+	f.announce(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.mu.Lock()
+			for peer := range f.peers {
+				f.removePeer(peer)
+			}
+			f.mu.Unlock()
+			return
+		case <-f.notify:
+			f.announce(ctx)
+		case <-ticker.C:
+			f.announce(ctx)
+			f.expire()
+		}
+	}
+}
+
+func (f *Federation) trigger() {
+	select {
+	case f.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (f *Federation) localTopics() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	topics := make([]string, 0, len(f.interest))
+	for topic := range f.interest {
+		topics = append(topics, topic)
+	}
+	slices.Sort(topics)
+	return topics
+}
+
+func (f *Federation) send(ctx context.Context, peer string, msg *FederationMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, f.Timeout)
+	defer cancel()
+	return f.Transport.Send(ctx, peer, msg)
+}
+
+// announce sends local topic membership to every peer concurrently.
+func (f *Federation) announce(ctx context.Context) {
+	msg := &FederationMessage{
+		Kind:   FederationMessageMembership,
+		Origin: f.ID,
+		Topics: f.localTopics(),
+	}
+
+	var wg sync.WaitGroup
+	for _, peer := range f.Peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := f.send(ctx, peer, msg)
+			if err != nil {
+				f.Logger.Error("could not announce membership", "peer", peer, "err", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// expire detaches peers we have not heard from in a while and prunes the
+// duplicate detection cache.
+func (f *Federation) expire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	for id, peer := range f.peers {
+		if now.Sub(peer.lastSeen) > 3*f.Interval {
+			f.Logger.Info("federation peer expired", "peer", id)
+			f.removePeer(id)
+		}
+	}
+
+	for key, at := range f.seen {
+		if now.Sub(at) > 10*f.Interval {
+			delete(f.seen, key)
+		}
+	}
+}
+
+func (f *Federation) addInterest(channels []*Channel) {
+	f.mu.Lock()
+	for _, channel := range channels {
+		f.interest[channel.Topic]++
+	}
+	f.mu.Unlock()
+	f.trigger()
+}
+
+func (f *Federation) removeInterest(channels []*Channel) {
+	f.mu.Lock()
+	for _, channel := range channels {
+		f.interest[channel.Topic]--
+		if f.interest[channel.Topic] <= 0 {
+			delete(f.interest, channel.Topic)
+		}
+	}
+	f.mu.Unlock()
+	f.trigger()
+}
+
+func (f *Federation) Pipe(ctx context.Context, ID string, rw io.ReadWriter, channels []*Channel, replay bool) (error, error) {
+	f.addInterest(channels)
+	defer f.removeInterest(channels)
+	return f.Multicast.Pipe(ctx, ID, rw, channels, replay)
+}
+
+func (f *Federation) Sub(ctx context.Context, ID string, rw io.ReadWriter, channels []*Channel, keepAlive bool) error {
+	f.addInterest(channels)
+	defer f.removeInterest(channels)
+	return f.Multicast.Sub(ctx, ID, rw, channels, keepAlive)
+}
+
+// Receive handles a message sent by a peer broker.
+func (f *Federation) Receive(ctx context.Context, msg *FederationMessage) error {
+	if msg.Origin == "" || msg.Origin == f.ID {
+		return nil
+	}
+
+	switch msg.Kind {
+	case FederationMessageMembership:
+		f.mu.Lock()
+		f.updatePeer(msg.Origin, msg.Topics)
+		f.mu.Unlock()
+		return nil
+	case FederationMessageData:
+		key := msg.Origin + "/" + msg.ID
+		f.mu.Lock()
+		if _, ok := f.seen[key]; ok {
+			f.mu.Unlock()
+			return nil
+		}
+		f.seen[key] = time.Now()
+		f.mu.Unlock()
+
+		f.deliver(msg)
+		return nil
+	default:
+		return fmt.Errorf("unknown federation message kind: %s", msg.Kind)
+	}
+}
+
+// deliver dispatches a forwarded message to local clients only.
+func (f *Federation) deliver(msg *FederationMessage) {
+	var (
+		dispatcher MessageDispatcher
+		done       chan struct{}
+	)
+
+	subscribers := []*Client{}
+	for topic, channel := range f.GetChannels() {
+		if !MatchTopic(topic, msg.Topic) {
+			continue
+		}
+
+		if topic == msg.Topic {
+			dispatcher = channel.GetDispatcher()
+			done = channel.Done
+		}
+
+		for _, client := range channel.GetClients() {
+			if client.Direction == ChannelDirectionInput || client.federated {
+				continue
+			}
+			if slices.Contains(subscribers, client) {
+				continue
+			}
+			subscribers = append(subscribers, client)
+		}
+	}
+
+	if len(subscribers) == 0 {
+		return
+	}
+
+	if dispatcher == nil {
+		dispatcher = &MulticastDispatcher{}
+	}
+	if done == nil {
+		done = make(chan struct{})
+	}
+
+	_ = dispatcher.Dispatch(ChannelMessage{
+		Data:      msg.Data,
+		ClientID:  federationClientID(msg.Origin),
+		Topic:     msg.Topic,
+		Direction: ChannelDirectionInput,
+	}, subscribers, done)
+}
+
+// updatePeer reconciles the channels a peer's subscriber is attached to
+// with the topics it advertised.  Callers must hold f.mu.
+func (f *Federation) updatePeer(id string, topics []string) {
+	peer, ok := f.peers[id]
+	if !ok {
+		client := NewClient(federationClientID(id), nil, ChannelDirectionOutput, false, false, true)
+		client.federated = true
+		peer = &federationPeer{client: client}
+		f.peers[id] = peer
+		go f.forward(id, client)
+	}
+	peer.topics = topics
+	peer.lastSeen = time.Now()
+
+	for _, topic := range topics {
+		channel := NewChannel(topic)
+		channel.SetDispatcher(&MulticastDispatcher{})
+		dataChannel := f.base.ensureChannel(channel)
+		if _, ok := dataChannel.Clients.Load(peer.client.ID); !ok {
+			dataChannel.Clients.Store(peer.client.ID, peer.client)
+			peer.client.Channels.Store(dataChannel.Topic, dataChannel)
+		}
+	}
+
+	for _, channel := range f.GetChannels() {
+		wanted := slices.ContainsFunc(topics, func(topic string) bool {
+			return MatchTopic(topic, channel.Topic)
+		})
+
+		_, attached := channel.Clients.Load(peer.client.ID)
+		switch {
+		case wanted && !attached:
+			channel.Clients.Store(peer.client.ID, peer.client)
+			peer.client.Channels.Store(channel.Topic, channel)
+		case !wanted && attached:
+			channel.Clients.Delete(peer.client.ID)
+			peer.client.Channels.Delete(channel.Topic)
+		}
+	}
+
+	f.base.Cleanup()
+}
+
+// removePeer detaches a peer from every channel.  Callers must hold f.mu.
+func (f *Federation) removePeer(id string) {
+	peer, ok := f.peers[id]
+	if !ok {
+		return
+	}
+	delete(f.peers, id)
+
+	for _, channel := range peer.client.GetChannels() {
+		channel.Clients.Delete(peer.client.ID)
+		peer.client.Channels.Delete(channel.Topic)
+	}
+	peer.client.Cleanup()
+
+	f.base.Cleanup()
+}
+
+// forward sends everything dispatched to a peer's subscriber to that peer.
+func (f *Federation) forward(peer string, client *Client) {
+	for {
+		select {
+		case <-client.Done:
+			return
+		case data, ok := <-client.Data:
+			if !ok {
+				return
+			}
+
+			err := f.send(context.Background(), peer, &FederationMessage{
+				Kind:   FederationMessageData,
+				Origin: f.ID,
+				ID:     uuid.NewString(),
+				Topic:  data.Topic,
+				Data:   data.Data,
+			})
+			if err != nil {
+				f.Logger.Error("could not forward message", "peer", peer, "topic", data.Topic, "err", err)
+			}
+		}
+	}
+}
+
+var _ PubSub = (*Federation)(nil)
@@ -0,0 +1,106 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FederationPath is the route peers post federation messages to.
+const FederationPath = "/federation"
+
+// maxFederationBody bounds a posted federation message: a base64 encoded
+// frame of at most MaxFrameSize plus room for the rest of the envelope.
+const maxFederationBody = MaxFrameSize*4/3 + 64*1024
+
+/*
+HTTPFederationTransport posts federation messages as JSON to peers.
+
+Peers are addressed by their base URL, e.g. "http://pipe-2:3001", which
+must also be the ID the peer uses for itself.
+*/
+type HTTPFederationTransport struct {
+	Client *http.Client
+	Secret string
+}
+
+func NewHTTPFederationTransport(secret string) *HTTPFederationTransport {
+	return &HTTPFederationTransport{
+		Client: &http.Client{Timeout: 10 * time.Second},
+		Secret: secret,
+	}
+}
+
+func (t *HTTPFederationTransport) Send(ctx context.Context, peer string, msg *FederationMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(peer, "/") + FederationPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.Secret)
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("peer %s responded with status %d", peer, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// FederationHandler accepts federation messages posted by peers.  Peers
+// must authenticate with the shared secret, without one every request is
+// refused since anyone reaching the handler could publish to any topic.
+func FederationHandler(federation *Federation, secret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if secret == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var msg FederationMessage
+		body := http.MaxBytesReader(w, r.Body, maxFederationBody)
+		err := json.NewDecoder(body).Decode(&msg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = federation.Receive(r.Context(), &msg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+var _ FederationTransport = (*HTTPFederationTransport)(nil)
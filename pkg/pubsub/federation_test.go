@@ -0,0 +1,262 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryTransport struct {
+	mu    sync.Mutex
+	nodes map[string]*Federation
+}
+
+func (m *memoryTransport) Send(ctx context.Context, peer string, msg *FederationMessage) error {
+	m.mu.Lock()
+	node, ok := m.nodes[peer]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown peer: %s", peer)
+	}
+	return node.Receive(ctx, msg)
+}
+
+func newTestFederation(t *testing.T, ids ...string) []*Federation {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	transport := &memoryTransport{nodes: map[string]*Federation{}}
+	nodes := []*Federation{}
+	for _, id := range ids {
+		node := NewFederation(slog.Default(), id, ids, transport)
+		node.Interval = 50 * time.Millisecond
+		transport.nodes[id] = node
+		nodes = append(nodes, node)
+	}
+
+	for _, node := range nodes {
+		go node.Start(ctx)
+	}
+
+	return nodes
+}
+
+func waitFor(t *testing.T, msg string, fn func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if fn() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", msg)
+}
+
+func hasPeerSub(node *Federation, topic, peer string) bool {
+	for name, channel := range node.GetChannels() {
+		if name != topic {
+			continue
+		}
+		if _, ok := channel.Clients.Load(federationClientID(peer)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFederationForwardsToPeer(t *testing.T) {
+	nodes := newTestFederation(t, "a", "b")
+	a, b := nodes[0], nodes[1]
+	expected := "some test data"
+	actual := new(Buffer)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = b.Sub(ctx, "sub", actual, []*Channel{NewChannel("test-channel")}, false)
+	}()
+
+	waitFor(t, "membership", func() bool { return hasPeerSub(a, "test-channel", "b") })
+
+	err := a.Pub(context.TODO(), "pub", &Buffer{b: *bytes.NewBufferString(expected)}, []*Channel{NewChannel("test-channel")}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "message", func() bool { return actual.String() == expected })
+}
+
+func TestFederationWildcard(t *testing.T) {
+	nodes := newTestFederation(t, "a", "b")
+	a, b := nodes[0], nodes[1]
+	expected := "wildcard data"
+	actual := new(Buffer)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = b.Sub(ctx, "sub", actual, []*Channel{NewChannel("events/*")}, false)
+	}()
+
+	waitFor(t, "membership", func() bool { return hasPeerSub(a, "events/*", "b") })
+
+	err := a.Pub(context.TODO(), "pub", &Buffer{b: *bytes.NewBufferString(expected)}, []*Channel{NewChannel("events/login")}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "message", func() bool { return actual.String() == expected })
+}
+
+func TestFederationNoLoops(t *testing.T) {
+	nodes := newTestFederation(t, "a", "b", "c")
+	expected := "mesh data"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bufs := []*Buffer{new(Buffer), new(Buffer), new(Buffer)}
+	for i, node := range nodes {
+		go func() {
+			_ = node.Sub(ctx, "sub", bufs[i], []*Channel{NewChannel("mesh")}, true)
+		}()
+	}
+
+	waitFor(t, "membership", func() bool {
+		return hasPeerSub(nodes[0], "mesh", "b") &&
+			hasPeerSub(nodes[0], "mesh", "c") &&
+			hasPeerSub(nodes[1], "mesh", "a") &&
+			hasPeerSub(nodes[2], "mesh", "a")
+	})
+
+	err := nodes[0].Pub(context.TODO(), "pub", &Buffer{b: *bytes.NewBufferString(expected)}, []*Channel{NewChannel("mesh")}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "messages", func() bool {
+		for _, buf := range bufs {
+			if buf.String() != expected {
+				return false
+			}
+		}
+		return true
+	})
+
+	// give any looping messages a chance to show up
+	time.Sleep(200 * time.Millisecond)
+	for i, buf := range bufs {
+		if buf.String() != expected {
+			t.Fatalf("node %d: expected %q, got %q", i, expected, buf.String())
+		}
+	}
+}
+
+func TestFederationDropsDuplicates(t *testing.T) {
+	nodes := newTestFederation(t, "a", "b")
+	b := nodes[1]
+	actual := new(Buffer)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = b.Sub(ctx, "sub", actual, []*Channel{NewChannel("dupes")}, false)
+	}()
+
+	waitFor(t, "subscriber", func() bool {
+		for range b.GetSubs() {
+			return true
+		}
+		return false
+	})
+
+	msg := &FederationMessage{
+		Kind:   FederationMessageData,
+		Origin: "a",
+		ID:     "same-id",
+		Topic:  "dupes",
+		Data:   []byte("once"),
+	}
+	for range 3 {
+		if err := b.Receive(context.TODO(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor(t, "message", func() bool { return actual.String() != "" })
+	time.Sleep(50 * time.Millisecond)
+	if strings.Count(actual.String(), "once") != 1 {
+		t.Fatalf("expected a single delivery, got %q", actual.String())
+	}
+}
+
+func TestFederationExpiresPeers(t *testing.T) {
+	nodes := newTestFederation(t, "a", "b")
+	a, b := nodes[0], nodes[1]
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		_ = b.Sub(ctx, "sub", new(Buffer), []*Channel{NewChannel("leaving")}, false)
+	}()
+
+	waitFor(t, "membership", func() bool { return hasPeerSub(a, "leaving", "b") })
+
+	cancel()
+
+	waitFor(t, "peer removal", func() bool { return !hasPeerSub(a, "leaving", "b") })
+}
+
+func TestFederationHandlerRequiresSecret(t *testing.T) {
+	node := NewFederation(slog.Default(), "a", nil, &memoryTransport{})
+	body := `{"kind":"data","origin":"b","id":"1","topic":"t","data":"aGk="}`
+
+	post := func(secret, token string) int {
+		req := httptest.NewRequest(http.MethodPost, FederationPath, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		FederationHandler(node, secret).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post("", ""); code != http.StatusNotFound {
+		t.Errorf("expected federation without a secret to be refused, got %d", code)
+	}
+	if code := post("secret", "nope"); code != http.StatusUnauthorized {
+		t.Errorf("expected wrong secret to be rejected, got %d", code)
+	}
+	if code := post("secret", "secret"); code != http.StatusNoContent {
+		t.Errorf("expected message to be accepted, got %d", code)
+	}
+}
+
+func TestFederationHandlerBodyLimit(t *testing.T) {
+	node := NewFederation(slog.Default(), "a", nil, &memoryTransport{})
+	data := base64.StdEncoding.EncodeToString(make([]byte, maxFederationBody))
+	body := `{"kind":"data","origin":"b","id":"1","topic":"t","data":"` + data + `"}`
+
+	req := httptest.NewRequest(http.MethodPost, FederationPath, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	FederationHandler(node, "secret").ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected oversized message to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,219 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/antoniomika/syncmap"
+)
+
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// LimitError describes which limit a publisher ran into.
+type LimitError struct {
+	Scope string
+	Limit string
+	Value float64
+}
+
+func (e *LimitError) Error() string {
+	value := strconv.FormatFloat(e.Value, 'f', -1, 64)
+	return fmt.Sprintf("%s: %s %s limit of %s reached", ErrLimitExceeded, e.Scope, e.Limit, value)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// Limiter is consulted by a publishing client before a frame is sent to
+// its channels.  size is the length of the frame and message the bytes
+// published so far in the message the frame belongs to, the frame
+// included.  Returning an error stops the publisher.
+type Limiter interface {
+	Allow(topics []string, size, message int) error
+}
+
+type limiterCtxKey struct{}
+
+// WithLimiter attaches a Limiter to the context passed to Pub or Pipe.
+func WithLimiter(ctx context.Context, limiter Limiter) context.Context {
+	return context.WithValue(ctx, limiterCtxKey{}, limiter)
+}
+
+func limiterFromContext(ctx context.Context) Limiter {
+	limiter, _ := ctx.Value(limiterCtxKey{}).(Limiter)
+	return limiter
+}
+
+/*
+RateLimits configures the throughput allowed for a publisher or topic.
+
+Limits are applied per frame, a single read of the publisher's input that
+is dispatched to subscribers as one message, so MessagesPerSecond counts
+frames and MaxFrameSize caps each frame.  Frames are never larger than
+MaxFrameSize of the broker.  MaxMessageSize caps the whole message, every
+frame a publisher sends until its input ends, or a single frame for
+bidirectional clients.  A zero value for any field means that dimension is
+unlimited.
+*/
+type RateLimits struct {
+	MessagesPerSecond float64
+	BytesPerSecond    float64
+	MaxFrameSize      int
+	MaxMessageSize    int
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: rate, last: time.Now()}
+}
+
+// take removes n tokens from the bucket, refilling at rate tokens per
+// second with a burst of one second's worth.  Requests larger than the
+// burst are allowed once the bucket is full and leave it in debt, so they
+// are paid off before anything else is taken.
+func (b *tokenBucket) take(n float64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens < min(n, b.rate) {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+type limitBuckets struct {
+	msgs  *tokenBucket
+	bytes *tokenBucket
+}
+
+func (l *limitBuckets) idleSince() time.Time {
+	last := time.Time{}
+	for _, bucket := range []*tokenBucket{l.msgs, l.bytes} {
+		if bucket == nil {
+			continue
+		}
+		bucket.mu.Lock()
+		if bucket.last.After(last) {
+			last = bucket.last
+		}
+		bucket.mu.Unlock()
+	}
+	return last
+}
+
+func (l *limitBuckets) allow(scope string, limits RateLimits, size int) error {
+	if l.msgs != nil && !l.msgs.take(1) {
+		return &LimitError{Scope: scope, Limit: "messages per second", Value: limits.MessagesPerSecond}
+	}
+	if l.bytes != nil && !l.bytes.take(float64(size)) {
+		return &LimitError{Scope: scope, Limit: "bytes per second", Value: limits.BytesPerSecond}
+	}
+	return nil
+}
+
+/*
+RateLimiter tracks shared token buckets per user and per topic.  Every
+publisher connection gets its own Limiter from For, but buckets with the
+same key are shared so limits apply across concurrent connections.
+*/
+type RateLimiter struct {
+	buckets *syncmap.Map[string, *limitBuckets]
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		buckets: syncmap.New[string, *limitBuckets](),
+	}
+}
+
+func (r *RateLimiter) load(key string, limits RateLimits) *limitBuckets {
+	key = fmt.Sprintf("%s:%g:%g", key, limits.MessagesPerSecond, limits.BytesPerSecond)
+	if buckets, ok := r.buckets.Load(key); ok {
+		return buckets
+	}
+
+	buckets := &limitBuckets{}
+	if limits.MessagesPerSecond > 0 {
+		buckets.msgs = newTokenBucket(limits.MessagesPerSecond)
+	}
+	if limits.BytesPerSecond > 0 {
+		buckets.bytes = newTokenBucket(limits.BytesPerSecond)
+	}
+	existing, _ := r.buckets.LoadOrStore(key, buckets)
+	return existing
+}
+
+// Cleanup removes buckets that have not been used within idle.
+func (r *RateLimiter) Cleanup(idle time.Duration) {
+	toRemove := []string{}
+	for key, buckets := range r.buckets.Range {
+		if time.Since(buckets.idleSince()) > idle {
+			toRemove = append(toRemove, key)
+		}
+	}
+
+	for _, key := range toRemove {
+		r.buckets.Delete(key)
+	}
+}
+
+// For returns a Limiter for a single publisher connection.
+func (r *RateLimiter) For(user string, userLimits RateLimits, topicLimits RateLimits) Limiter {
+	return &connLimiter{
+		limiter:     r,
+		user:        user,
+		userLimits:  userLimits,
+		topicLimits: topicLimits,
+	}
+}
+
+type connLimiter struct {
+	limiter     *RateLimiter
+	user        string
+	userLimits  RateLimits
+	topicLimits RateLimits
+}
+
+func (c *connLimiter) Allow(topics []string, size, message int) error {
+	if c.userLimits.MaxFrameSize > 0 && size > c.userLimits.MaxFrameSize {
+		return &LimitError{Scope: "user", Limit: "frame size", Value: float64(c.userLimits.MaxFrameSize)}
+	}
+	if c.topicLimits.MaxFrameSize > 0 && size > c.topicLimits.MaxFrameSize {
+		return &LimitError{Scope: "topic", Limit: "frame size", Value: float64(c.topicLimits.MaxFrameSize)}
+	}
+	if c.userLimits.MaxMessageSize > 0 && message > c.userLimits.MaxMessageSize {
+		return &LimitError{Scope: "user", Limit: "message size", Value: float64(c.userLimits.MaxMessageSize)}
+	}
+	if c.topicLimits.MaxMessageSize > 0 && message > c.topicLimits.MaxMessageSize {
+		return &LimitError{Scope: "topic", Limit: "message size", Value: float64(c.topicLimits.MaxMessageSize)}
+	}
+
+	userBuckets := c.limiter.load("user:"+c.user, c.userLimits)
+	if err := userBuckets.allow("user", c.userLimits, size); err != nil {
+		return err
+	}
+
+	for _, topic := range topics {
+		topicBuckets := c.limiter.load("topic:"+topic, c.topicLimits)
+		if err := topicBuckets.allow("topic", c.topicLimits, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
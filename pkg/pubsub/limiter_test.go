@@ -0,0 +1,128 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRateLimiterMessagesPerSecond(t *testing.T) {
+	limiter := NewRateLimiter().For("user", RateLimits{MessagesPerSecond: 2}, RateLimits{})
+
+	for i := range 2 {
+		if err := limiter.Allow([]string{"topic"}, 1, 1); err != nil {
+			t.Fatalf("message %d: unexpected error: %v", i, err)
+		}
+	}
+
+	err := limiter.Allow([]string{"topic"}, 1, 1)
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "user messages per second limit of 2") {
+		t.Fatalf("unexpected error message: %s", err)
+	}
+}
+
+func TestRateLimiterSharedAcrossConnections(t *testing.T) {
+	rl := NewRateLimiter()
+	topicLimits := RateLimits{BytesPerSecond: 10}
+	first := rl.For("alice", RateLimits{}, topicLimits)
+	second := rl.For("bob", RateLimits{}, topicLimits)
+
+	if err := first.Allow([]string{"shared"}, 8, 8); err != nil {
+		t.Fatal(err)
+	}
+
+	err := second.Allow([]string{"shared"}, 8, 8)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Scope != "topic" {
+		t.Fatalf("expected topic limit error, got %v", err)
+	}
+
+	if err := second.Allow([]string{"other"}, 8, 8); err != nil {
+		t.Fatalf("other topics should not be affected: %v", err)
+	}
+}
+
+func TestRateLimiterMaxFrameSize(t *testing.T) {
+	limiter := NewRateLimiter().For("user", RateLimits{MaxFrameSize: 10}, RateLimits{})
+
+	// the cap applies to each frame, not the connection
+	for range 3 {
+		if err := limiter.Allow([]string{"topic"}, 6, 6); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := limiter.Allow([]string{"topic"}, 11, 11)
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+}
+
+func TestRateLimiterMaxMessageSize(t *testing.T) {
+	limiter := NewRateLimiter().For("user", RateLimits{}, RateLimits{MaxMessageSize: 10})
+
+	if err := limiter.Allow([]string{"topic"}, 6, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	err := limiter.Allow([]string{"topic"}, 6, 12)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "message size" {
+		t.Fatalf("expected message size limit error, got %v", err)
+	}
+}
+
+func TestRateLimiterCountsFramesOnce(t *testing.T) {
+	limiter := NewRateLimiter().For("user", RateLimits{MessagesPerSecond: 2}, RateLimits{})
+
+	for i := range 2 {
+		if err := limiter.Allow([]string{"a", "b", "c"}, 1, 1); err != nil {
+			t.Fatalf("frame %d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestRateLimiterFramesLargerThanBurst(t *testing.T) {
+	limiter := NewRateLimiter().For("user", RateLimits{BytesPerSecond: 10}, RateLimits{})
+
+	if err := limiter.Allow([]string{"topic"}, 25, 25); err != nil {
+		t.Fatalf("expected a frame larger than the burst to pass on a full bucket: %v", err)
+	}
+
+	err := limiter.Allow([]string{"topic"}, 1, 1)
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected the debt to be paid off first, got %v", err)
+	}
+}
+
+func TestMulticastPubLimited(t *testing.T) {
+	cast := NewMulticast(slog.Default())
+	channel := NewChannel("limited")
+	limiter := NewRateLimiter().For("user", RateLimits{MaxFrameSize: 4}, RateLimits{})
+	ctx := WithLimiter(context.TODO(), limiter)
+
+	err := cast.Pub(ctx, "pub", &Buffer{b: *bytes.NewBufferString("too much data")}, []*Channel{channel}, false)
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+}
+
+func TestMulticastPubMessageLimited(t *testing.T) {
+	cast := NewMulticast(slog.Default())
+	channel := NewChannel("limited")
+	limiter := NewRateLimiter().For("user", RateLimits{MaxMessageSize: 2 * MaxFrameSize}, RateLimits{})
+	ctx := WithLimiter(context.TODO(), limiter)
+
+	// every frame fits, the message streamed across them does not
+	data := bytes.Repeat([]byte("x"), 3*MaxFrameSize)
+	err := cast.Pub(ctx, "pub", &Buffer{b: *bytes.NewBuffer(data)}, []*Channel{channel}, false)
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+}
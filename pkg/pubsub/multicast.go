@@ -60,6 +60,7 @@ func (p *Multicast) GetSubs() iter.Seq2[string, *Client] {
 
 func (p *Multicast) connect(ctx context.Context, ID string, rw io.ReadWriter, channels []*Channel, direction ChannelDirection, blockWrite bool, replay, keepAlive bool, dispatcher MessageDispatcher) (error, error) {
 	client := NewClient(ID, rw, direction, blockWrite, replay, keepAlive)
+	client.Limiter = limiterFromContext(ctx)
 
 	// Set dispatcher on all channels (only if not already set)
 	for _, ch := range channels {
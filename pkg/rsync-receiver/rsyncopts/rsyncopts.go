@@ -386,6 +386,9 @@ func (o *Options) CompressChoice() string     { return o.compress_choice }
 func (o *Options) CompressLevel() int         { return o.do_compression_level }
 func (o *Options) IgnoreTimes() bool          { return o.ignore_times == 1 }
 func (o *Options) SizeOnly() bool             { return o.size_only == 1 }
+func (o *Options) ItemizeChanges() bool {
+	return o.itemize_changes != 0 || strings.Contains(o.stdout_format, "%i")
+}
 
 func (o *Options) daemonTable() []poptOption {
 	return []poptOption{
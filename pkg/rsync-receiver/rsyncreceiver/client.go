@@ -51,22 +51,30 @@ func ClientRun(logger *slog.Logger, opts *rsyncopts.Options, conn io.ReadWriter,
 		}
 	}()
 
+	compression, err := Compression(opts)
+	if err != nil {
+		return err
+	}
+
 	rt := &Transfer{
 		Opts: &TransferOpts{
-			DryRun: opts.DryRun(),
-
-			DeleteMode:       opts.DeleteMode(),
-			PreserveGid:      opts.PreserveGid(),
-			PreserveUid:      opts.PreserveUid(),
-			PreserveLinks:    opts.PreserveLinks(),
-			PreservePerms:    opts.PreservePerms(),
-			PreserveDevices:  opts.PreserveDevices(),
-			PreserveSpecials: opts.PreserveSpecials(),
-			PreserveTimes:    opts.PreserveMTimes(),
-			IgnoreTimes:      opts.IgnoreTimes(),
-			SizeOnly:         opts.SizeOnly(),
-			AlwaysChecksum:   opts.AlwaysChecksum(),
-			// TODO: PreserveHardlinks: opts.PreserveHardlinks,
+			Verbose: opts.Verbose(),
+			DryRun:  opts.DryRun(),
+			Itemize: opts.ItemizeChanges(),
+
+			DeleteMode:        opts.DeleteMode(),
+			PreserveGid:       opts.PreserveGid(),
+			PreserveUid:       opts.PreserveUid(),
+			PreserveLinks:     opts.PreserveLinks(),
+			PreservePerms:     opts.PreservePerms(),
+			PreserveDevices:   opts.PreserveDevices(),
+			PreserveSpecials:  opts.PreserveSpecials(),
+			PreserveTimes:     opts.PreserveMTimes(),
+			IgnoreTimes:       opts.IgnoreTimes(),
+			SizeOnly:          opts.SizeOnly(),
+			AlwaysChecksum:    opts.AlwaysChecksum(),
+			Compression:       compression,
+			PreserveHardlinks: opts.PreserveHardLinks(),
 		},
 		Dest: "/",
 		// TODO: what is Env used for and can we get rid of it?
@@ -75,6 +83,7 @@ func ClientRun(logger *slog.Logger, opts *rsyncopts.Options, conn io.ReadWriter,
 			Stderr: os.Stderr,
 			Stdin:  os.Stdin,
 		},
+		Info: &rsyncwire.InfoWriter{Writer: mpx},
 		Conn: c,
 		Seed: sessionChecksumSeed,
 
@@ -15,7 +15,15 @@ func (rt *Transfer) deleteFiles(fileList []*utils.ReceiverFile) error {
 		return nil
 	}
 
-	return rt.Files.Remove(fileList)
+	deleted, err := rt.Files.Remove(fileList, rt.Opts.DryRun)
+	for _, name := range deleted {
+		if rt.Opts.Itemize {
+			rt.logInfo("*deleting   %s\n", name)
+		} else {
+			rt.logInfo("deleting %s\n", name)
+		}
+	}
+	return err
 }
 
 // rsync/main.c:do_recv.
@@ -49,6 +57,10 @@ func (rt *Transfer) Do(c *rsyncwire.Conn, fileList []*utils.ReceiverFile, noRepo
 		return nil, err
 	}
 
+	if rt.Opts.PreserveHardlinks {
+		rt.doHardLinks(fileList)
+	}
+
 	var stats *rsyncstats.TransferStats
 	if !noReport {
 		var err error
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/picosh/pico/pkg/rsync-receiver/rsync"
+	"github.com/picosh/pico/pkg/rsync-receiver/rsyncchecksum"
 	"github.com/picosh/pico/pkg/rsync-receiver/utils"
 )
 
@@ -137,6 +138,30 @@ func (rt *Transfer) receiveFileEntry(flags uint16, last *utils.ReceiverFile) (*u
 		f.LinkTarget = string(b)
 	}
 
+	if rt.Opts.PreserveHardlinks && mode == rsync.S_IFREG {
+		// before protocol 28 the device and inode are sent for every
+		// regular file, there is no XMIT_SAME_DEV flag yet
+		dev, err := rt.Conn.ReadInt64()
+		if err != nil {
+			return nil, err
+		}
+		inode, err := rt.Conn.ReadInt64()
+		if err != nil {
+			return nil, err
+		}
+		f.Dev = dev
+		f.Inode = inode
+	}
+
+	if rt.Opts.AlwaysChecksum {
+		// before protocol 28 the checksum is sent for every file,
+		// non-regular files get a useless set of nulls
+		f.Checksum = make([]byte, rsyncchecksum.Size)
+		if _, err := io.ReadFull(rt.Conn.Reader, f.Checksum); err != nil {
+			return nil, err
+		}
+	}
+
 	return f, nil
 }
 
@@ -169,6 +194,10 @@ func (rt *Transfer) ReceiveFileList() ([]*utils.ReceiverFile, error) {
 
 	utils.SortFileList(fileList)
 
+	if rt.Opts.PreserveHardlinks {
+		initHardLinks(fileList)
+	}
+
 	if rt.Opts.PreserveUid || rt.Opts.PreserveGid {
 		// receive the uid/gid list
 		users, groups, err := rt.RecvIdList()
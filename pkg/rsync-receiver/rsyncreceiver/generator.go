@@ -1,10 +1,12 @@
 package rsyncreceiver
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 
+	"github.com/mmcloughlin/md4"
 	"github.com/picosh/pico/pkg/rsync-receiver/rsync"
 	"github.com/picosh/pico/pkg/rsync-receiver/rsyncchecksum"
 	"github.com/picosh/pico/pkg/rsync-receiver/rsynccommon"
@@ -39,12 +41,27 @@ func (rt *Transfer) GenerateFiles(fileList []*utils.ReceiverFile) error {
 }
 
 // rsync/generator.c:skip_file.
-func (rt *Transfer) skipFile(f *utils.ReceiverFile, st os.FileInfo) (bool, error) {
-	if rt.Opts.AlwaysChecksum || rt.Opts.IgnoreTimes {
+func (rt *Transfer) skipFile(f *utils.ReceiverFile, st os.FileInfo, in utils.ReaderAtCloser) (bool, error) {
+	sizeMatch := st.Size() == f.Length
+	if rt.Opts.AlwaysChecksum {
+		if !sizeMatch || f.Checksum == nil {
+			return false, nil
+		}
+		// stored objects only carry an md5 etag while protocol 27 uses
+		// md4 so we have to hash the contents, reading through a section
+		// keeps the offset intact for generating the block sums
+		h := md4.New()
+		if _, err := io.Copy(h, io.NewSectionReader(in, 0, st.Size())); err != nil {
+			rt.Logger.Error("failed to checksum file, sending it again", "file", f, "err", err)
+			return false, nil
+		}
+		return bytes.Equal(h.Sum(nil), f.Checksum), nil
+	}
+
+	if rt.Opts.IgnoreTimes {
 		return false, nil
 	}
 
-	sizeMatch := st.Size() == f.Length
 	if rt.Opts.SizeOnly {
 		return sizeMatch, nil
 	}
@@ -53,6 +70,36 @@ func (rt *Transfer) skipFile(f *utils.ReceiverFile, st os.FileInfo) (bool, error
 	return sizeMatch && timeMatch, nil
 }
 
+// itemize reports a file the sender is about to transfer in the format of
+// `rsync --itemize-changes`, st is nil for new files.  Only the checksum,
+// size, and time attributes are tracked by our storage.
+//
+// rsync/log.c:log_formatted.
+func (rt *Transfer) itemize(f *utils.ReceiverFile, st os.FileInfo) {
+	if !rt.Opts.Itemize {
+		return
+	}
+	if st == nil {
+		rt.logInfo("<f+++++++++ %s\n", f.Name)
+		return
+	}
+
+	sizeMatch := st.Size() == f.Length
+	c, s, t := '.', '.', '.'
+	if rt.Opts.AlwaysChecksum && sizeMatch {
+		c = 'c'
+	}
+	if !sizeMatch {
+		s = 's'
+	}
+	if !rt.Opts.PreserveTimes {
+		t = 'T'
+	} else if !st.ModTime().Equal(f.ModTime) {
+		t = 't'
+	}
+	rt.logInfo("<f%c%c%c...... %s\n", c, s, t, f.Name)
+}
+
 // rsync/generator.c:recv_generator.
 func (rt *Transfer) recvGenerator(idx int, f *utils.ReceiverFile) error {
 	if rt.listOnly() {
@@ -67,9 +114,34 @@ func (rt *Transfer) recvGenerator(idx int, f *utils.ReceiverFile) error {
 	}
 	rt.Logger.Debug("recv_generator", "file", f)
 
+	if f.HardLink {
+		// the contents arrive with the first file of the group, the link
+		// itself is created by doHardLinks once the transfer is done
+		if rt.Opts.Itemize {
+			rt.logInfo("hf+++++++++ %s => %s\n", f.Name, f.LinkTarget)
+		}
+		return nil
+	}
+
+	if rt.Opts.PreserveLinks && f.FileMode()&os.ModeSymlink != 0 {
+		if rt.Opts.Itemize {
+			rt.logInfo("cL+++++++++ %s -> %s\n", f.Name, f.LinkTarget)
+		}
+		if rt.Opts.DryRun {
+			return nil
+		}
+		if _, err := rt.Files.Put(f); err != nil {
+			// like rsync, a link that can't be created doesn't abort
+			// the rest of the transfer
+			rt.Logger.Error("failed to create symlink", "file", f, "err", err)
+			rt.logInfo("symlink %s -> %s failed: %v\n", f.Name, f.LinkTarget, err)
+		}
+		return nil
+	}
+
 	if !f.FileMode().IsRegular() {
-		// None of the Preserve* options is enabled, so just skip over
-		// non-regular files.
+		// Devices and specials have no counterpart in our storage, so
+		// just skip over them.
 		return nil
 	}
 
@@ -91,12 +163,13 @@ func (rt *Transfer) recvGenerator(idx int, f *utils.ReceiverFile) error {
 	st, in, err := rt.Files.Read(&utils.SenderFile{WPath: f.Name})
 	if err != nil {
 		rt.Logger.Error("failed to open file", "st", st, "file", f, "err", err)
+		rt.itemize(f, nil)
 		return requestFullFile()
 	}
 
 	defer func() { _ = in.Close() }()
 
-	skip, err := rt.skipFile(f, st)
+	skip, err := rt.skipFile(f, st, in)
 	if err != nil {
 		return err
 	}
@@ -105,6 +178,7 @@ func (rt *Transfer) recvGenerator(idx int, f *utils.ReceiverFile) error {
 		rt.Logger.Debug("skipping", "file", f)
 		return nil
 	}
+	rt.itemize(f, st)
 
 	if rt.Opts.DryRun {
 		if err := rt.Conn.WriteInt32(int32(idx)); err != nil {
@@ -0,0 +1,123 @@
+package rsyncreceiver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/picosh/pico/pkg/rsync-receiver/rsync"
+	"github.com/picosh/pico/pkg/rsync-receiver/rsyncwire"
+	"github.com/picosh/pico/pkg/rsync-receiver/utils"
+)
+
+type memInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (m *memInfo) Name() string       { return m.name }
+func (m *memInfo) Size() int64        { return m.size }
+func (m *memInfo) Mode() os.FileMode  { return 0644 }
+func (m *memInfo) ModTime() time.Time { return m.modTime }
+func (m *memInfo) IsDir() bool        { return false }
+func (m *memInfo) Sys() any           { return nil }
+
+type memFile struct {
+	*bytes.Reader
+}
+
+func (f *memFile) Close() error { return nil }
+
+// memFS is a read-only file system, writes fail the test.
+type memFS struct {
+	t     *testing.T
+	files map[string]*memInfo
+}
+
+func (m *memFS) Put(f *utils.ReceiverFile) (int64, error) {
+	m.t.Errorf("unexpected write of %s", f.Name)
+	return 0, nil
+}
+
+func (m *memFS) List(string) ([]os.FileInfo, error) { return nil, nil }
+
+func (m *memFS) Read(f *utils.SenderFile) (os.FileInfo, utils.ReaderAtCloser, error) {
+	info, ok := m.files[f.WPath]
+	if !ok {
+		return nil, nil, os.ErrNotExist
+	}
+	return info, &memFile{bytes.NewReader(make([]byte, info.size))}, nil
+}
+
+func (m *memFS) Remove([]*utils.ReceiverFile, bool) ([]string, error) {
+	m.t.Error("unexpected remove")
+	return nil, nil
+}
+
+func TestGenerateFilesDryRunItemize(t *testing.T) {
+	mtime := time.Unix(1700000000, 0)
+	fs := &memFS{
+		t: t,
+		files: map[string]*memInfo{
+			"changed.html": {name: "changed.html", size: 10, modTime: mtime},
+			"touched.html": {name: "touched.html", size: 20, modTime: mtime.Add(-time.Hour)},
+			"same.html":    {name: "same.html", size: 30, modTime: mtime},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	info := &bytes.Buffer{}
+	rt := &Transfer{
+		Opts: &TransferOpts{
+			DryRun:        true,
+			Itemize:       true,
+			PreserveTimes: true,
+		},
+		Dest:   "/",
+		Info:   info,
+		Conn:   &rsyncwire.Conn{Writer: out},
+		Files:  fs,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	fileList := []*utils.ReceiverFile{
+		{Name: "changed.html", Length: 15, ModTime: mtime, Mode: rsync.S_IFREG | 0644},
+		{Name: "new.html", Length: 5, ModTime: mtime, Mode: rsync.S_IFREG | 0644},
+		{Name: "same.html", Length: 30, ModTime: mtime, Mode: rsync.S_IFREG | 0644},
+		{Name: "touched.html", Length: 20, ModTime: mtime, Mode: rsync.S_IFREG | 0644},
+	}
+	if err := rt.GenerateFiles(fileList); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "<f.s....... changed.html\n" +
+		"<f+++++++++ new.html\n" +
+		"<f..t...... touched.html\n"
+	if info.String() != want {
+		t.Errorf("unexpected itemized changes:\nwant:\n%s\ngot:\n%s", want, info.String())
+	}
+
+	// a dry-run only sends the indexes, never any block sums
+	var sent []int32
+	for out.Len() > 0 {
+		var idx int32
+		if err := binary.Read(out, binary.LittleEndian, &idx); err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, idx)
+	}
+	wantIdx := []int32{0, 1, 3, -1, -1}
+	if len(sent) != len(wantIdx) {
+		t.Fatalf("want indexes %v, got %v", wantIdx, sent)
+	}
+	for i := range sent {
+		if sent[i] != wantIdx[i] {
+			t.Fatalf("want indexes %v, got %v", wantIdx, sent)
+		}
+	}
+}
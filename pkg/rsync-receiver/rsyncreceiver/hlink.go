@@ -0,0 +1,42 @@
+package rsyncreceiver
+
+import "github.com/picosh/pico/pkg/rsync-receiver/utils"
+
+// initHardLinks points every regular file that shares its device and inode
+// with an earlier file in the sorted list at that first file.  Only the
+// first file is transferred, the others are linked to it afterwards.
+//
+// rsync/hlink.c:init_hard_links.
+func initHardLinks(fileList []*utils.ReceiverFile) {
+	type idev struct{ dev, inode int64 }
+	first := make(map[idev]*utils.ReceiverFile)
+	for _, f := range fileList {
+		if !f.FileMode().IsRegular() {
+			continue
+		}
+		key := idev{f.Dev, f.Inode}
+		head, ok := first[key]
+		if !ok {
+			first[key] = f
+			continue
+		}
+		f.LinkTarget = head.Name
+		f.HardLink = true
+	}
+}
+
+// rsync/hlink.c:do_hard_links.
+func (rt *Transfer) doHardLinks(fileList []*utils.ReceiverFile) {
+	if rt.Opts.DryRun {
+		return
+	}
+	for _, f := range fileList {
+		if !f.HardLink {
+			continue
+		}
+		if _, err := rt.Files.Put(f); err != nil {
+			rt.Logger.Error("failed to create hard link", "file", f, "err", err)
+			rt.logInfo("link %s => %s failed: %v\n", f.Name, f.LinkTarget, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package rsyncreceiver
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/picosh/pico/pkg/rsync-receiver/rsync"
+	"github.com/picosh/pico/pkg/rsync-receiver/rsyncwire"
+	"github.com/picosh/pico/pkg/rsync-receiver/utils"
+)
+
+// linkFS records the links it is asked to create.
+type linkFS struct {
+	memFS
+	links []string
+}
+
+func (l *linkFS) Put(f *utils.ReceiverFile) (int64, error) {
+	if f.LinkTarget == "" {
+		l.t.Errorf("unexpected write of %s", f.Name)
+		return 0, nil
+	}
+	sep := " -> "
+	if f.HardLink {
+		sep = " => "
+	}
+	l.links = append(l.links, f.Name+sep+f.LinkTarget)
+	return 0, nil
+}
+
+func TestGenerateFilesLinks(t *testing.T) {
+	fs := &linkFS{memFS: memFS{t: t, files: map[string]*memInfo{}}}
+	out := &bytes.Buffer{}
+	rt := &Transfer{
+		Opts: &TransferOpts{
+			PreserveLinks:     true,
+			PreserveHardlinks: true,
+		},
+		Dest:   "/",
+		Conn:   &rsyncwire.Conn{Writer: out},
+		Files:  fs,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	fileList := []*utils.ReceiverFile{
+		{Name: "a.html", Length: 5, Mode: rsync.S_IFREG | 0644, Dev: 1, Inode: 10},
+		{Name: "b.html", Length: 5, Mode: rsync.S_IFREG | 0644, Dev: 1, Inode: 10},
+		{Name: "c.html", Length: 5, Mode: rsync.S_IFREG | 0644, Dev: 1, Inode: 11},
+		{Name: "latest", Mode: rsync.S_IFLNK | 0777, LinkTarget: "v2"},
+	}
+	initHardLinks(fileList)
+	if err := rt.GenerateFiles(fileList); err != nil {
+		t.Fatal(err)
+	}
+	// the symlink is created right away, hard links wait for the transfer
+	if len(fs.links) != 1 || fs.links[0] != "latest -> v2" {
+		t.Fatalf("unexpected links %v", fs.links)
+	}
+	rt.doHardLinks(fileList)
+	if len(fs.links) != 2 || fs.links[1] != "b.html => a.html" {
+		t.Fatalf("unexpected links %v", fs.links)
+	}
+
+	// b.html is never requested from the sender
+	want := []int32{0, 2, -1, -1}
+	var sent []int32
+	c := &rsyncwire.Conn{Reader: out}
+	for out.Len() > 0 {
+		idx, err := c.ReadInt32()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, idx)
+		if idx >= 0 {
+			var sh rsync.SumHead
+			if err := sh.ReadFrom(c); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(sent) != len(want) {
+		t.Fatalf("want indexes %v, got %v", want, sent)
+	}
+	for i := range sent {
+		if sent[i] != want[i] {
+			t.Fatalf("want indexes %v, got %v", want, sent)
+		}
+	}
+}
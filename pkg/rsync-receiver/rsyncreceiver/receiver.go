@@ -15,6 +15,8 @@ import (
 
 // rsync/receiver.c:recv_files.
 func (rt *Transfer) RecvFiles(fileList []*utils.ReceiverFile) error {
+	defer rt.closeTokens()
+
 	phase := 0
 	for {
 		idx, err := rt.Conn.ReadInt32()
@@ -40,8 +42,8 @@ func (rt *Transfer) RecvFiles(fileList []*utils.ReceiverFile) error {
 }
 
 func (rt *Transfer) recvFile1(f *utils.ReceiverFile) error {
+	// the sender does not send any data on a dry-run
 	if rt.Opts.DryRun {
-		fmt.Println(f.Name)
 		return nil
 	}
 
@@ -137,6 +139,7 @@ func (rt *Transfer) receiveData(f *utils.ReceiverFile, localFile utils.ReaderAtC
 		if _, err := localFile.ReadAt(data, offset2); err != nil {
 			return err
 		}
+		rt.seeToken(data)
 
 		if _, err := h.Write(data); err != nil {
 			return err
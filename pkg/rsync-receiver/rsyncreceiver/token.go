@@ -1,9 +1,117 @@
 package rsyncreceiver
 
-import "io"
+import (
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/picosh/pico/pkg/rsync-receiver/rsyncopts"
+)
+
+// Compression algorithms for the token stream, see rsync/compat.c.
+const (
+	CompressNone  = ""
+	CompressZlib  = "zlib"
+	CompressZlibx = "zlibx"
+	CompressZstd  = "zstd"
+)
+
+// Compression returns the token stream compression requested by the client.
+// Without a --compress-choice older clients always use zlib.
+func Compression(opts *rsyncopts.Options) (string, error) {
+	choice := opts.CompressChoice()
+	if !opts.Compress() && choice == "" {
+		return CompressNone, nil
+	}
+	switch choice {
+	case "", "zlib":
+		return CompressZlib, nil
+	case "zlibx", "zstd":
+		return choice, nil
+	case "none":
+		return CompressNone, nil
+	}
+	return "", fmt.Errorf("compression %q is currently unsupported, use zlib, zlibx, or zstd", choice)
+}
+
+// rsync/token.c flag bytes for compressed token streams.
+const (
+	tokenEndFlag     = 0x00 // that's all folks
+	tokenLong        = 0x20 // followed by 32-bit token number
+	tokenRunLong     = 0x21 // ditto with 16-bit run count
+	tokenDeflated    = 0x40 // + 6-bit high len, then low len byte
+	tokenRel         = 0x80 // + 6-bit relative token number
+	tokenRunRel      = 0xc0 // ditto with 16-bit run count
+	inflateChunkSize = 32 * 1024
+	// zstd blocks never decode to more than 128kb so a buffer this size
+	// always drains the decoder, see zstdInput.
+	zstdChunkSize = 128 * 1024
+)
+
+// deflate keeps a 32kb window of history.
+const deflateWindowSize = 32 * 1024
+
+type recvState int
+
+const (
+	rInit recvState = iota
+	rIdle
+	rRunning
+	rInflating
+)
+
+// tokenState is the receiving side of rsync/token.c for compressed streams.
+type tokenState struct {
+	state     recvState
+	token     int32
+	run       int32
+	savedFlag int
+
+	dbuf []byte
+
+	// zlib and zlibx
+	deflateIn *deflateInput
+	inflater  io.ReadCloser
+	history   []byte
+
+	// zstd uses a single stream for the whole session
+	zstdIn  *zstdInput
+	zstdDec *zstd.Decoder
+}
 
 // rsync/token.c:recvToken.
 func (rt *Transfer) recvToken() (token int32, data []byte, _ error) {
+	switch rt.Opts.Compression {
+	case CompressZlib, CompressZlibx:
+		return rt.recvDeflatedToken()
+	case CompressZstd:
+		return rt.recvZstdToken()
+	}
+	return rt.simpleRecvToken()
+}
+
+// rsync/token.c:see_token.
+func (rt *Transfer) seeToken(data []byte) {
+	if rt.Opts.Compression != CompressZlib {
+		return
+	}
+	rt.seeDeflateToken(data)
+}
+
+// closeTokens releases the decompressors at the end of a transfer.
+func (rt *Transfer) closeTokens() {
+	if rt.tokens.inflater != nil {
+		_ = rt.tokens.inflater.Close()
+	}
+	if rt.tokens.zstdDec != nil {
+		rt.tokens.zstdDec.Close()
+	}
+}
+
+// rsync/token.c:simple_recv_token.
+func (rt *Transfer) simpleRecvToken() (token int32, data []byte, _ error) {
 	var err error
 	token, err = rt.Conn.ReadInt32()
 	if err != nil {
@@ -18,3 +126,305 @@ func (rt *Transfer) recvToken() (token int32, data []byte, _ error) {
 	}
 	return token, data, nil
 }
+
+// readDataCount reads the length of a DEFLATED_DATA chunk.
+func (rt *Transfer) readDataCount(flag byte) (int, error) {
+	low, err := rt.Conn.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	return int(flag&0x3f)<<8 + int(low), nil
+}
+
+// readRelToken decodes a (run of) matched block token(s).
+func (rt *Transfer) readRelToken(flag byte) (int32, error) {
+	tok := &rt.tokens
+	if flag&tokenRel != 0 {
+		tok.token += int32(flag & 0x3f)
+		flag >>= 6
+	} else {
+		t, err := rt.Conn.ReadInt32()
+		if err != nil {
+			return 0, err
+		}
+		tok.token = t
+	}
+	if flag&1 != 0 {
+		var run [2]byte
+		if _, err := io.ReadFull(rt.Conn.Reader, run[:]); err != nil {
+			return 0, err
+		}
+		tok.run = int32(run[0]) + int32(run[1])<<8
+		tok.state = rRunning
+	}
+	return -1 - tok.token, nil
+}
+
+// deflateInput feeds a single run of DEFLATED_DATA chunks to the inflater.
+// The sender strips the 0, 0, ff, ff trailer of each sync flush so we put it
+// back once the run ends and then report EOF.
+type deflateInput struct {
+	rt   *Transfer
+	buf  []byte
+	done bool
+	// flag that ended the run
+	flag int
+}
+
+func (d *deflateInput) fill() error {
+	for len(d.buf) == 0 {
+		if d.done {
+			return io.EOF
+		}
+		flag, err := d.rt.Conn.ReadByte()
+		if err != nil {
+			return err
+		}
+		if flag&0xc0 != tokenDeflated {
+			d.flag = int(flag)
+			d.buf = []byte{0, 0, 0xff, 0xff}
+			d.done = true
+			return nil
+		}
+		n, err := d.rt.readDataCount(flag)
+		if err != nil {
+			return err
+		}
+		d.buf = make([]byte, n)
+		if _, err := io.ReadFull(d.rt.Conn.Reader, d.buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *deflateInput) Read(p []byte) (int, error) {
+	if err := d.fill(); err != nil {
+		return 0, err
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *deflateInput) ReadByte() (byte, error) {
+	if err := d.fill(); err != nil {
+		return 0, err
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b, nil
+}
+
+func (tok *tokenState) appendHistory(data []byte) {
+	tok.history = append(tok.history, data...)
+	if over := len(tok.history) - deflateWindowSize; over > 0 {
+		tok.history = append(tok.history[:0], tok.history[over:]...)
+	}
+}
+
+// startInflate begins decoding a run of DEFLATED_DATA.  Every run starts on
+// a byte boundary after the previous sync flush so a fresh inflater seeded
+// with our history picks up exactly where the last one stopped.
+func (rt *Transfer) startInflate(flag byte) error {
+	tok := &rt.tokens
+	n, err := rt.readDataCount(flag)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(rt.Conn.Reader, buf); err != nil {
+		return err
+	}
+	tok.deflateIn = &deflateInput{rt: rt, buf: buf}
+	if tok.inflater == nil {
+		tok.inflater = flate.NewReaderDict(tok.deflateIn, tok.history)
+	} else if err := tok.inflater.(flate.Resetter).Reset(tok.deflateIn, tok.history); err != nil {
+		return err
+	}
+	tok.state = rInflating
+	return nil
+}
+
+// rsync/token.c:recv_deflated_token.
+func (rt *Transfer) recvDeflatedToken() (int32, []byte, error) {
+	tok := &rt.tokens
+	for {
+		switch tok.state {
+		case rInit:
+			tok.history = tok.history[:0]
+			tok.state = rIdle
+			tok.token = 0
+			tok.savedFlag = -1
+			if tok.dbuf == nil {
+				tok.dbuf = make([]byte, inflateChunkSize)
+			}
+
+		case rIdle:
+			var flag byte
+			if tok.savedFlag >= 0 {
+				flag = byte(tok.savedFlag)
+				tok.savedFlag = -1
+			} else {
+				b, err := rt.Conn.ReadByte()
+				if err != nil {
+					return 0, nil, err
+				}
+				flag = b
+			}
+			if flag&0xc0 == tokenDeflated {
+				if err := rt.startInflate(flag); err != nil {
+					return 0, nil, err
+				}
+				continue
+			}
+			if flag == tokenEndFlag {
+				tok.state = rInit
+				return 0, nil, nil
+			}
+			token, err := rt.readRelToken(flag)
+			return token, nil, err
+
+		case rInflating:
+			n, err := tok.inflater.Read(tok.dbuf)
+			if n > 0 {
+				data := make([]byte, n)
+				copy(data, tok.dbuf[:n])
+				tok.appendHistory(data)
+				return int32(n), data, nil
+			}
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, io.ErrUnexpectedEOF) || !tok.deflateIn.done || len(tok.deflateIn.buf) > 0 {
+				return 0, nil, fmt.Errorf("inflate: %w", err)
+			}
+			// the run ended at a sync flush, the flag that ended it
+			// is the next token
+			tok.savedFlag = tok.deflateIn.flag
+			tok.state = rIdle
+
+		case rRunning:
+			tok.token++
+			tok.run--
+			if tok.run == 0 {
+				tok.state = rIdle
+			}
+			return -1 - tok.token, nil, nil
+		}
+	}
+}
+
+// rsync/token.c:see_deflate_token.  The sender runs matched blocks through
+// its compressor so we have to add them to our history as well.
+func (rt *Transfer) seeDeflateToken(data []byte) {
+	tok := &rt.tokens
+	// rsync fed the matched data in 64kb stored blocks but before protocol
+	// 31 it never advanced past the first block.
+	const maxStoredBlock = 0xffff
+	for remaining := len(data); remaining > 0; {
+		n := min(remaining, maxStoredBlock)
+		tok.appendHistory(data[:n])
+		remaining -= n
+	}
+}
+
+// zstdInput feeds DEFLATED_DATA chunks to the zstd decoder.  The sender
+// flushes its stream before every token so the decoder only ever asks for
+// more input than we have in the middle of a block, which means the next
+// message on the wire has to be more data.
+type zstdInput struct {
+	rt  *Transfer
+	buf []byte
+}
+
+func (z *zstdInput) Read(p []byte) (int, error) {
+	if len(z.buf) == 0 {
+		flag, err := z.rt.Conn.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if flag&0xc0 != tokenDeflated {
+			return 0, fmt.Errorf("zstd: unexpected token flag 0x%x in the middle of a block", flag)
+		}
+		n, err := z.rt.readDataCount(flag)
+		if err != nil {
+			return 0, err
+		}
+		z.buf = make([]byte, n)
+		if _, err := io.ReadFull(z.rt.Conn.Reader, z.buf); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, z.buf)
+	z.buf = z.buf[n:]
+	return n, nil
+}
+
+// rsync/token.c:recv_zstd_token.
+func (rt *Transfer) recvZstdToken() (int32, []byte, error) {
+	tok := &rt.tokens
+	if tok.zstdDec == nil {
+		tok.zstdIn = &zstdInput{rt: rt}
+		dec, err := zstd.NewReader(tok.zstdIn, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return 0, nil, err
+		}
+		tok.zstdDec = dec
+		tok.dbuf = make([]byte, zstdChunkSize)
+	}
+
+	for {
+		switch tok.state {
+		case rInit:
+			tok.state = rIdle
+			tok.token = 0
+
+		case rIdle:
+			flag, err := rt.Conn.ReadByte()
+			if err != nil {
+				return 0, nil, err
+			}
+			if flag&0xc0 == tokenDeflated {
+				n, err := rt.readDataCount(flag)
+				if err != nil {
+					return 0, nil, err
+				}
+				tok.zstdIn.buf = make([]byte, n)
+				if _, err := io.ReadFull(rt.Conn.Reader, tok.zstdIn.buf); err != nil {
+					return 0, nil, err
+				}
+				tok.state = rInflating
+				continue
+			}
+			if flag == tokenEndFlag {
+				tok.state = rInit
+				return 0, nil, nil
+			}
+			token, err := rt.readRelToken(flag)
+			return token, nil, err
+
+		case rInflating:
+			n, err := tok.zstdDec.Read(tok.dbuf)
+			if err != nil {
+				return 0, nil, fmt.Errorf("zstd: %w", err)
+			}
+			if len(tok.zstdIn.buf) == 0 {
+				tok.state = rIdle
+			}
+			if n > 0 {
+				data := make([]byte, n)
+				copy(data, tok.dbuf[:n])
+				return int32(n), data, nil
+			}
+
+		case rRunning:
+			tok.token++
+			tok.run--
+			if tok.run == 0 {
+				tok.state = rIdle
+			}
+			return -1 - tok.token, nil, nil
+		}
+	}
+}
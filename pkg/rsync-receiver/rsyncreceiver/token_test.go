@@ -0,0 +1,218 @@
+package rsyncreceiver
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/picosh/pico/pkg/rsync-receiver/rsyncwire"
+)
+
+// tokenOp is either literal data or a matched block.
+type tokenOp struct {
+	data  []byte
+	block int32
+}
+
+// tokenEncoder mirrors the sending side of rsync/token.c closely enough to
+// exercise the receiver.
+type tokenEncoder struct {
+	t           *testing.T
+	compression string
+	out         bytes.Buffer
+	lastToken   int32
+	history     []byte
+	zstdBuf     bytes.Buffer
+	zstdEnc     *zstd.Encoder
+}
+
+func (e *tokenEncoder) writeData(compressed []byte) {
+	for len(compressed) > 0 {
+		n := min(len(compressed), 16383)
+		e.out.WriteByte(tokenDeflated | byte(n>>8))
+		e.out.WriteByte(byte(n))
+		e.out.Write(compressed[:n])
+		compressed = compressed[n:]
+	}
+}
+
+func (e *tokenEncoder) literal(data []byte) {
+	switch e.compression {
+	case CompressZstd:
+		if _, err := e.zstdEnc.Write(data); err != nil {
+			e.t.Fatal(err)
+		}
+		if err := e.zstdEnc.Flush(); err != nil {
+			e.t.Fatal(err)
+		}
+		e.writeData(e.zstdBuf.Bytes())
+		e.zstdBuf.Reset()
+	default:
+		var buf bytes.Buffer
+		fw, err := flate.NewWriterDict(&buf, flate.DefaultCompression, e.history)
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		_, _ = fw.Write(data)
+		_ = fw.Flush()
+		compressed := buf.Bytes()
+		if !bytes.HasSuffix(compressed, []byte{0, 0, 0xff, 0xff}) {
+			e.t.Fatal("missing sync flush trailer")
+		}
+		e.writeData(compressed[:len(compressed)-4])
+		e.see(data)
+	}
+}
+
+func (e *tokenEncoder) see(data []byte) {
+	e.history = append(e.history, data...)
+	if over := len(e.history) - deflateWindowSize; over > 0 {
+		e.history = e.history[over:]
+	}
+}
+
+func (e *tokenEncoder) token(block int32, run int, blockData []byte) {
+	diff := block - e.lastToken
+	flag := byte(tokenLong)
+	if run > 0 {
+		flag = tokenRunLong
+	}
+	if diff >= 0 && diff <= 63 {
+		flag = tokenRel | byte(diff)
+		if run > 0 {
+			flag = tokenRunRel | byte(diff)
+		}
+	}
+	e.out.WriteByte(flag)
+	if flag&tokenRel == 0 {
+		_ = binary.Write(&e.out, binary.LittleEndian, block)
+	}
+	if run > 0 {
+		e.out.WriteByte(byte(run))
+		e.out.WriteByte(byte(run >> 8))
+	}
+	e.lastToken = block + int32(run)
+	if e.compression == CompressZlib {
+		for i := 0; i <= run; i++ {
+			e.see(blockData)
+		}
+	}
+}
+
+func (e *tokenEncoder) end() {
+	e.out.WriteByte(tokenEndFlag)
+	e.lastToken = 0
+	e.history = nil
+}
+
+func TestRecvCompressedTokens(t *testing.T) {
+	rnd := rand.New(rand.NewSource(27))
+	noise := make([]byte, 100*1024)
+	_, _ = rnd.Read(noise)
+
+	block := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 20))
+	blocks := [][]byte{block, block, block, block}
+
+	files := [][]tokenOp{
+		{
+			{data: []byte("<html>" + string(block[:100]) + "</html>")},
+			{block: 0},
+			{block: 1},
+			{data: append([]byte("changed "), block[:200]...)},
+			{block: 3},
+		},
+		{
+			{data: noise},
+			{block: 2},
+			{data: []byte("tail")},
+		},
+	}
+
+	for _, compression := range []string{CompressZlib, CompressZlibx, CompressZstd} {
+		t.Run(compression, func(t *testing.T) {
+			enc := &tokenEncoder{t: t, compression: compression}
+			if compression == CompressZstd {
+				zenc, err := zstd.NewWriter(&enc.zstdBuf, zstd.WithEncoderConcurrency(1))
+				if err != nil {
+					t.Fatal(err)
+				}
+				enc.zstdEnc = zenc
+			}
+
+			for _, ops := range files {
+				for i := 0; i < len(ops); i++ {
+					op := ops[i]
+					if op.data != nil {
+						enc.literal(op.data)
+						continue
+					}
+					// collapse consecutive blocks into a run
+					run := 0
+					for i+1 < len(ops) && ops[i+1].data == nil && ops[i+1].block == op.block+int32(run)+1 {
+						run++
+						i++
+					}
+					enc.token(op.block, run, blocks[op.block])
+				}
+				enc.end()
+			}
+
+			rt := &Transfer{
+				Opts:   &TransferOpts{Compression: compression},
+				Conn:   &rsyncwire.Conn{Reader: &enc.out},
+				Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			defer rt.closeTokens()
+
+			for idx, ops := range files {
+				want := []string{}
+				for _, op := range ops {
+					if op.data != nil {
+						want = append(want, string(op.data))
+					} else {
+						want = append(want, fmt.Sprintf("block %d", op.block))
+					}
+				}
+
+				got := []string{}
+				var lit []byte
+				for {
+					token, data, err := rt.recvToken()
+					if err != nil {
+						t.Fatalf("file %d: %v", idx, err)
+					}
+					if token > 0 {
+						lit = append(lit, data...)
+						continue
+					}
+					if lit != nil {
+						got = append(got, string(lit))
+						lit = nil
+					}
+					if token == 0 {
+						break
+					}
+					blk := -(token + 1)
+					got = append(got, fmt.Sprintf("block %d", blk))
+					rt.seeToken(blocks[blk])
+				}
+
+				if !slices.Equal(got, want) {
+					t.Errorf("file %d: token stream mismatch, got %d ops want %d", idx, len(got), len(want))
+				}
+			}
+
+			if enc.out.Len() != 0 {
+				t.Errorf("%d bytes left unread", enc.out.Len())
+			}
+		})
+	}
+}
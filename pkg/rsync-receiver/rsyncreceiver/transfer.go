@@ -1,6 +1,7 @@
 package rsyncreceiver
 
 import (
+	"fmt"
 	"io"
 	"log/slog"
 
@@ -18,6 +19,7 @@ type Osenv struct {
 type TransferOpts struct {
 	Verbose bool
 	DryRun  bool
+	Itemize bool
 
 	DeleteMode        bool
 	PreserveGid       bool
@@ -31,6 +33,8 @@ type TransferOpts struct {
 	IgnoreTimes       bool
 	SizeOnly          bool
 	AlwaysChecksum    bool
+	// Compression of the token stream, one of the Compress* constants
+	Compression string
 }
 
 type Transfer struct {
@@ -39,11 +43,14 @@ type Transfer struct {
 	Opts *TransferOpts
 	Dest string
 	Env  Osenv
+	// Info receives messages meant for the client, e.g. itemized changes
+	Info io.Writer
 
 	// state
 	Conn     *rsyncwire.Conn
 	Seed     int32
 	IOErrors int32
+	tokens   tokenState
 
 	Files utils.FS
 
@@ -51,3 +58,10 @@ type Transfer struct {
 }
 
 func (rt *Transfer) listOnly() bool { return rt.Dest == "" }
+
+func (rt *Transfer) logInfo(format string, args ...any) {
+	if rt.Info == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(rt.Info, format, args...)
+}
@@ -34,6 +34,7 @@ func (st *Transfer) SendFileList(opts *rsyncopts.Options, paths []string, excl *
 
 	uidMap := make(map[int32]string)
 	gidMap := make(map[int32]string)
+	var inode int64
 
 	// TODO: flush in between to keep the pipes filled when traversal takes long
 
@@ -184,6 +185,15 @@ func (st *Transfer) SendFileList(opts *rsyncopts.Options, paths []string, excl *
 				fec.WriteString(target)
 			}
 
+			if opts.PreserveHardLinks() && info.Mode().IsRegular() {
+				// 13.  if a regular file and -H, the device and inode
+				// (long integers). Stored objects are never hard linked
+				// so every file gets a unique inode.
+				inode++
+				fec.WriteInt64(0)
+				fec.WriteInt64(inode)
+			}
+
 			if opts.AlwaysChecksum() {
 				var emptyChecksum [rsyncchecksum.Size]byte
 				checksum := emptyChecksum[:]
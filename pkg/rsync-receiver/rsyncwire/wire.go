@@ -34,6 +34,15 @@ func (w *MultiplexWriter) WriteMsg(tag uint8, p []byte) (n int, err error) {
 	return w.Writer.Write(p)
 }
 
+// InfoWriter sends writes as MSG_INFO, which the client prints to stdout.
+type InfoWriter struct {
+	Writer *MultiplexWriter
+}
+
+func (w *InfoWriter) Write(p []byte) (n int, err error) {
+	return w.Writer.WriteMsg(MsgInfo, p)
+}
+
 type MultiplexReader struct {
 	Reader io.Reader
 }
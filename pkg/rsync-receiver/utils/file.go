@@ -25,7 +25,17 @@ type ReceiverFile struct {
 	Gid        int32
 	LinkTarget string
 	Rdev       int32
-	Reader     io.Reader
+	// Dev and Inode identify the file on the sender, only sent when the
+	// client runs with --hard-links.
+	Dev   int64
+	Inode int64
+	// HardLink marks LinkTarget as the name of another file in the
+	// transfer that shares the same contents instead of a symlink target.
+	HardLink bool
+	// Checksum is the md4 sum of the file's contents, only sent by the
+	// sender when the client runs with --checksum.
+	Checksum []byte
+	Reader   io.Reader
 }
 
 // FileMode converts from the Linux permission bits to Go’s permission bits.
@@ -16,5 +16,7 @@ type FS interface {
 	Put(*ReceiverFile) (int64, error)
 	List(string) ([]os.FileInfo, error)
 	Read(*SenderFile) (os.FileInfo, ReaderAtCloser, error)
-	Remove([]*ReceiverFile) error
+	// Remove deletes every file missing from the list being received and
+	// returns their names, a dry-run only reports what would be deleted.
+	Remove(willReceive []*ReceiverFile, dryRun bool) ([]string, error)
 }
@@ -123,6 +123,14 @@ func (h *handler) Put(file *rsyncutils.ReceiverFile) (int64, error) {
 	}
 	fileEntry.Reader = file.Reader
 
+	if file.HardLink {
+		fileEntry.HardLink = true
+		fileEntry.LinkTarget = path.Join("/", h.root, file.LinkTarget)
+	} else if file.LinkTarget != "" {
+		fileEntry.Mode = fs.ModeSymlink | 0777
+		fileEntry.LinkTarget = file.LinkTarget
+	}
+
 	msg, err := h.writeHandler.Write(h.session, fileEntry)
 	if err != nil {
 		errMsg := fmt.Sprintf("%s\r\n", err.Error())
@@ -135,10 +143,10 @@ func (h *handler) Put(file *rsyncutils.ReceiverFile) (int64, error) {
 	return 0, err
 }
 
-func (h *handler) Remove(willReceive []*rsyncutils.ReceiverFile) error {
+func (h *handler) Remove(willReceive []*rsyncutils.ReceiverFile, dryRun bool) ([]string, error) {
 	entries, err := h.writeHandler.List(h.session, path.Join("/", h.root), true, true)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var toDelete []string
@@ -161,15 +169,46 @@ func (h *handler) Remove(willReceive []*rsyncutils.ReceiverFile) error {
 		return depthB - depthA
 	})
 
+	if dryRun {
+		return toDelete, nil
+	}
+
 	var errs []error
+	var deleted []string
 
 	for _, file := range toDelete {
-		errs = append(errs, h.writeHandler.Delete(h.session, &utils.FileEntry{Filepath: path.Join("/", h.root, file)}))
-		_, err = fmt.Fprintf(h.session.Stderr(), "deleting %s\r\n", file)
-		errs = append(errs, err)
+		err := h.writeHandler.Delete(h.session, &utils.FileEntry{Filepath: path.Join("/", h.root, file)})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		deleted = append(deleted, file)
+	}
+
+	return deleted, errors.Join(errs...)
+}
+
+func parseArguments(cmd []string) (*rsyncopts.Context, error) {
+	flgs := make([]string, len(cmd)-1)
+	for idx, f := range cmd[1:] {
+		// openrsync sends "delete-before" when the client provided "delete"
+		flgs[idx] = strings.ReplaceAll(f, "delete-before", "delete")
 	}
+	return rsyncopts.ParseArguments(flgs, true)
+}
 
-	return errors.Join(errs...)
+// IsDryRun reports whether the session is running `rsync --dry-run` so
+// handlers can avoid creating anything before the receiver even starts.
+func IsDryRun(session *pssh.SSHServerConnSession) bool {
+	cmd := session.Command()
+	if len(cmd) == 0 || cmd[0] != "rsync" {
+		return false
+	}
+	optsCtx, err := parseArguments(cmd)
+	if err != nil {
+		return false
+	}
+	return optsCtx.Options.DryRun()
 }
 
 func Middleware(writeHandler utils.CopyFromClientHandler) pssh.SSHServerMiddleware {
@@ -192,27 +231,18 @@ func Middleware(writeHandler utils.CopyFromClientHandler) pssh.SSHServerMiddlewa
 				}
 			}()
 
-			cmdFlags := session.Command()
-			flgs := cmdFlags[1:]
-			for idx, f := range flgs {
-				// openrsync sends "delete-before" when the client provided "delete"
-				flgs[idx] = strings.ReplaceAll(f, "delete-before", "delete")
-			}
-
-			optsCtx, err := rsyncopts.ParseArguments(cmdFlags[1:], true)
+			optsCtx, err := parseArguments(cmd)
 			if err != nil {
 				_, _ = fmt.Fprintf(session.Stderr(), "ERROR: error parsing rsync arguments: %s\r\n", err.Error())
 				return err
 			}
 
-			if optsCtx.Options.Compress() {
-				err := fmt.Errorf("compression is currently unsupported")
-				_, _ = fmt.Fprintf(session.Stderr(), "error: %s\r\n", err.Error())
-				return err
+			isSender := slices.Contains(cmd, "--sender")
+			compression, err := rsyncreceiver.Compression(optsCtx.Options)
+			if err == nil && isSender && compression != rsyncreceiver.CompressNone {
+				err = fmt.Errorf("compression is currently only supported for uploads")
 			}
-
-			if optsCtx.Options.AlwaysChecksum() {
-				err := fmt.Errorf("checksum is currently unsupported")
+			if err != nil {
 				_, _ = fmt.Fprintf(session.Stderr(), "error: %s\r\n", err.Error())
 				return err
 			}
@@ -236,14 +266,12 @@ func Middleware(writeHandler utils.CopyFromClientHandler) pssh.SSHServerMiddlewa
 				ignoreTimes:  !optsCtx.Options.PreserveMTimes(),
 			}
 
-			for _, arg := range cmd {
-				if arg == "--sender" {
-					err := rsyncsender.ClientRun(logger, optsCtx.Options, session, fileHandler, []string{fileHandler.root}, true)
-					if err != nil {
-						logger.Error("error running rsync sender", "err", err)
-					}
-					return err
+			if isSender {
+				err := rsyncsender.ClientRun(logger, optsCtx.Options, session, fileHandler, []string{fileHandler.root}, true)
+				if err != nil {
+					logger.Error("error running rsync sender", "err", err)
 				}
+				return err
 			}
 
 			err = rsyncreceiver.ClientRun(logger, optsCtx.Options, session, fileHandler, []string{fileHandler.root}, true)
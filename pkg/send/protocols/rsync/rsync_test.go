@@ -128,7 +128,7 @@ func TestRemove_DeletesChildrenBeforeParents(t *testing.T) {
 		root:         "test",
 	}
 
-	err := h.Remove([]*rsyncutils.ReceiverFile{})
+	_, err := h.Remove([]*rsyncutils.ReceiverFile{}, false)
 	if err != nil {
 		t.Fatalf("Remove() returned error: %v", err)
 	}
@@ -185,7 +185,7 @@ func TestRemove_IgnoresPicoKeepDir(t *testing.T) {
 		root:         "test",
 	}
 
-	err := h.Remove([]*rsyncutils.ReceiverFile{})
+	_, err := h.Remove([]*rsyncutils.ReceiverFile{}, false)
 	if err != nil {
 		t.Fatalf("Remove() returned error: %v", err)
 	}
@@ -222,7 +222,7 @@ func TestRemove_OnlyDeletesFilesNotInWillReceive(t *testing.T) {
 		{Name: "c.txt"},
 	}
 
-	err := h.Remove(willReceive)
+	_, err := h.Remove(willReceive, false)
 	if err != nil {
 		t.Fatalf("Remove() returned error: %v", err)
 	}
@@ -235,3 +235,33 @@ func TestRemove_OnlyDeletesFilesNotInWillReceive(t *testing.T) {
 		t.Errorf("expected to delete /test/b.txt, got %s", mockHandler.deleteCalls[0])
 	}
 }
+
+func TestRemove_DryRunOnlyReports(t *testing.T) {
+	session, _ := newMockSession()
+	mockHandler := &mockWriteHandler{
+		entries: []os.FileInfo{
+			&mockFileInfo{name: "a.txt", size: 100},
+			&mockFileInfo{name: "dir/b.txt", size: 100},
+			&mockFileInfo{name: "c.txt", size: 100},
+		},
+	}
+
+	h := &handler{
+		session:      session,
+		writeHandler: mockHandler,
+		root:         "test",
+	}
+
+	deleted, err := h.Remove([]*rsyncutils.ReceiverFile{{Name: "a.txt"}}, true)
+	if err != nil {
+		t.Fatalf("Remove() returned error: %v", err)
+	}
+
+	if len(mockHandler.deleteCalls) != 0 {
+		t.Errorf("expected no delete calls on a dry-run, got %v", mockHandler.deleteCalls)
+	}
+
+	if len(deleted) != 2 || deleted[0] != "dir/b.txt" || deleted[1] != "c.txt" {
+		t.Errorf("expected dir/b.txt and c.txt to be reported, got %v", deleted)
+	}
+}
@@ -52,6 +52,22 @@ func (f *handler) Filecmd(r *sftp.Request) error {
 
 		_, err := f.writeHandler.Write(f.session, entry)
 
+		return err
+	case "Symlink", "Link":
+		// the request has the link in Target and what it points to in
+		// Filepath, see sftp.Request
+		entry := toFileEntry(r)
+
+		entry.Filepath = r.Target
+		entry.LinkTarget = r.Filepath
+		if r.Method == "Link" {
+			entry.HardLink = true
+		} else {
+			entry.Mode = os.ModeSymlink | 0777
+		}
+
+		_, err := f.writeHandler.Write(f.session, entry)
+
 		return err
 	case "Setstat":
 		return nil
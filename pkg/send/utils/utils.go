@@ -26,6 +26,11 @@ type FileEntry struct {
 	Atime    int64
 	Mtime    int64
 	Metadata map[string]string
+	// LinkTarget turns the entry into a link without contents.  For
+	// symlinks it is the raw target relative to the link, for hard links
+	// (HardLink set) it is the absolute path of an existing file.
+	LinkTarget string
+	HardLink   bool
 }
 
 // Write a file to the given writer.
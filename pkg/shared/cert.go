@@ -0,0 +1,174 @@
+package shared
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// CertPrincipals are the service spaces a pico issued cert can be scoped
+// to.  "admin" grants access to every service.
+var CertPrincipals = []string{"admin", "pico", "pgs", "prose", "pastes", "pipe", "feeds", "tuns"}
+
+const (
+	DefaultCertTTL = time.Hour
+	MaxCertTTL     = 30 * 24 * time.Hour
+)
+
+type CertOpts struct {
+	KeyID      string
+	Principals []string
+	TTL        time.Duration
+	Serial     uint64
+	Now        time.Time
+}
+
+// NewCertAuthority generates an ed25519 CA keypair and returns the public
+// key in authorized_keys format along with the PEM encoded private key.
+func NewCertAuthority() (string, string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", "", err
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "pico-ca")
+	if err != nil {
+		return "", "", err
+	}
+
+	return KeyForKeyText(sshPub), string(pem.EncodeToMemory(block)), nil
+}
+
+// ErrCertSecretMissing is returned when a CA private key needs to be sealed
+// or opened without a secret configured.
+var ErrCertSecretMissing = errors.New("PICO_CERT_CA_SECRET is required for ssh certs")
+
+func certAEAD(secret string) (cipher.AEAD, error) {
+	if secret == "" {
+		return nil, ErrCertSecretMissing
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SealCertAuthority encrypts a PEM encoded CA private key with secret so it
+// is never stored in plaintext.
+func SealCertAuthority(secret, privkey string) (string, error) {
+	aead, err := certAEAD(secret)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(privkey), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenCertAuthority decrypts a CA private key sealed by SealCertAuthority
+// and returns its signer.
+func OpenCertAuthority(secret, sealed string) (ssh.Signer, error) {
+	aead, err := certAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("cert authority is not sealed: %w", err)
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("cert authority is not sealed")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	privkey, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open cert authority: %w", err)
+	}
+	return ssh.ParsePrivateKey(privkey)
+}
+
+// NewCertSerial returns a random serial that fits in a postgres bigint.
+func NewCertSerial() (uint64, error) {
+	buf := make([]byte, 8)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf) >> 1, nil
+}
+
+// SignUserCert signs a user certificate for pubkey with the CA signer.
+func SignUserCert(ca ssh.Signer, pubkey ssh.PublicKey, opts *CertOpts) (*ssh.Certificate, error) {
+	if _, ok := pubkey.(*ssh.Certificate); ok {
+		return nil, fmt.Errorf("cannot sign a certificate, provide a public key")
+	}
+	if opts.KeyID == "" {
+		return nil, fmt.Errorf("must provide a key id")
+	}
+	if len(opts.Principals) == 0 {
+		return nil, fmt.Errorf("must provide at least one principal")
+	}
+	for _, princ := range opts.Principals {
+		if !slices.Contains(CertPrincipals, princ) {
+			return nil, fmt.Errorf("invalid principal %q, must be one of %v", princ, CertPrincipals)
+		}
+	}
+
+	ttl := opts.TTL
+	if ttl == 0 {
+		ttl = DefaultCertTTL
+	}
+	if ttl < 0 || ttl > MaxCertTTL {
+		return nil, fmt.Errorf("ttl must be between 0 and %s", MaxCertTTL)
+	}
+
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	cert := &ssh.Certificate{
+		Key:             pubkey,
+		Serial:          opts.Serial,
+		CertType:        ssh.UserCert,
+		KeyId:           opts.KeyID,
+		ValidPrincipals: opts.Principals,
+		// allow for some clock skew between pico and the client
+		ValidAfter:  uint64(now.Add(-time.Minute).Unix()),
+		ValidBefore: uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-port-forwarding": "",
+				"permit-pty":             "",
+			},
+		},
+	}
+
+	err := cert.SignCert(rand.Reader, ca)
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
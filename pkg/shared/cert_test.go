@@ -0,0 +1,179 @@
+package shared
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type testKRL struct {
+	revoked map[int64]bool
+}
+
+func (k *testKRL) IsSshCertRevoked(caPubkey string, serial int64) (bool, error) {
+	return k.revoked[serial], nil
+}
+
+func newTestCA(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, privkey, err := NewCertAuthority()
+	if err != nil {
+		t.Fatalf("NewCertAuthority failed: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey([]byte(privkey))
+	if err != nil {
+		t.Fatalf("failed to parse ca: %v", err)
+	}
+	return signer
+}
+
+func newTestPubkey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubkey, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pubkey
+}
+
+func TestSignUserCert(t *testing.T) {
+	ca := newTestCA(t)
+	pubkey := newTestPubkey(t)
+
+	cert, err := SignUserCert(ca, pubkey, &CertOpts{
+		KeyID:      "ci-deploy",
+		Principals: []string{"pgs"},
+		TTL:        time.Hour,
+		Serial:     7,
+	})
+	if err != nil {
+		t.Fatalf("SignUserCert failed: %v", err)
+	}
+
+	krl := &testKRL{revoked: map[int64]bool{}}
+	authed, err := PubkeyCertVerify(cert, "pgs", krl)
+	if err != nil {
+		t.Fatalf("expected cert to verify for pgs: %v", err)
+	}
+	if authed.Pubkey != KeyForKeyText(ca.PublicKey()) {
+		t.Errorf("expected cert to authenticate as the ca pubkey, got %s", authed.Pubkey)
+	}
+	if authed.Identity != "ci-deploy" {
+		t.Errorf("expected identity ci-deploy, got %s", authed.Identity)
+	}
+
+	if _, err := PubkeyCertVerify(cert, "prose", krl); err == nil {
+		t.Error("expected cert to be rejected for prose")
+	}
+
+	krl.revoked[7] = true
+	if _, err := PubkeyCertVerify(cert, "pgs", krl); err == nil {
+		t.Error("expected revoked cert to be rejected")
+	}
+}
+
+func TestSignUserCert_Invalid(t *testing.T) {
+	ca := newTestCA(t)
+	pubkey := newTestPubkey(t)
+
+	tests := []struct {
+		name string
+		opts *CertOpts
+	}{
+		{name: "missing key id", opts: &CertOpts{Principals: []string{"pgs"}}},
+		{name: "missing principal", opts: &CertOpts{KeyID: "ci"}},
+		{name: "unknown principal", opts: &CertOpts{KeyID: "ci", Principals: []string{"root"}}},
+		{name: "ttl too long", opts: &CertOpts{KeyID: "ci", Principals: []string{"pgs"}, TTL: MaxCertTTL + time.Hour}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := SignUserCert(ca, pubkey, tt.opts); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestPubkeyCertVerify_ForgedSignatureKey(t *testing.T) {
+	attacker := newTestCA(t)
+	victim := newTestCA(t)
+	pubkey := newTestPubkey(t)
+
+	cert, err := SignUserCert(attacker, pubkey, &CertOpts{
+		KeyID:      "forged",
+		Principals: []string{"admin"},
+		Serial:     7,
+	})
+	if err != nil {
+		t.Fatalf("SignUserCert failed: %v", err)
+	}
+	cert.SignatureKey = victim.PublicKey()
+
+	krl := &testKRL{revoked: map[int64]bool{}}
+	if _, err := PubkeyCertVerify(cert, "pgs", krl); err == nil {
+		t.Error("expected cert not signed by its signature key to be rejected")
+	}
+}
+
+func TestPubkeyCertVerify_SerialTooLarge(t *testing.T) {
+	ca := newTestCA(t)
+	pubkey := newTestPubkey(t)
+
+	cert, err := SignUserCert(ca, pubkey, &CertOpts{
+		KeyID:      "ci",
+		Principals: []string{"pgs"},
+		Serial:     math.MaxInt64 + 1,
+	})
+	if err != nil {
+		t.Fatalf("SignUserCert failed: %v", err)
+	}
+
+	krl := &testKRL{revoked: map[int64]bool{}}
+	if _, err := PubkeyCertVerify(cert, "pgs", krl); err == nil {
+		t.Error("expected cert with a serial pico cannot issue to be rejected")
+	}
+}
+
+func TestSealCertAuthority(t *testing.T) {
+	pubkey, privkey, err := NewCertAuthority()
+	if err != nil {
+		t.Fatalf("NewCertAuthority failed: %v", err)
+	}
+
+	if _, err := SealCertAuthority("", privkey); err == nil {
+		t.Error("expected sealing without a secret to fail")
+	}
+
+	sealed, err := SealCertAuthority("secret", privkey)
+	if err != nil {
+		t.Fatalf("SealCertAuthority failed: %v", err)
+	}
+	if strings.Contains(sealed, "PRIVATE KEY") {
+		t.Fatal("expected sealed cert authority not to contain the private key")
+	}
+
+	signer, err := OpenCertAuthority("secret", sealed)
+	if err != nil {
+		t.Fatalf("OpenCertAuthority failed: %v", err)
+	}
+	if KeyForKeyText(signer.PublicKey()) != pubkey {
+		t.Errorf("expected opened signer to match the ca pubkey")
+	}
+
+	if _, err := OpenCertAuthority("wrong", sealed); err == nil {
+		t.Error("expected opening with the wrong secret to fail")
+	}
+	if _, err := OpenCertAuthority("secret", privkey); err == nil {
+		t.Error("expected opening a plaintext key to fail")
+	}
+}
@@ -52,6 +52,9 @@ type ListMetaData struct {
 	ImageCard   string
 	Layout      string
 	PublishAt   *time.Time
+	Series      string
+	Lang        string
+	Comments    *bool
 	Tags        []string
 	Title       string
 
@@ -122,6 +125,15 @@ func TokenToMetaField(meta *ListMetaData, token *SplitToken) error {
 		meta.Image = token.Value
 	case "image_card":
 		meta.ImageCard = token.Value
+	case "series":
+		meta.Series = token.Value
+	case "lang":
+		if langTag.MatchString(token.Value) {
+			meta.Lang = token.Value
+		}
+	case "comments":
+		comments := token.Value == "true"
+		meta.Comments = &comments
 	case "draft":
 		if token.Value == "true" {
 			meta.Hidden = true
@@ -0,0 +1,151 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	htmlTemplate "html/template"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/emersion/go-sasl"
+	"github.com/emersion/go-smtp"
+)
+
+// MailSender delivers emails, Mailer sends them through the smtp relay and
+// tests swap in a fake transport.
+type MailSender interface {
+	Send(email, subject, text, html string, headers map[string]string) error
+}
+
+var _ MailSender = (*Mailer)(nil)
+
+// Mailer sends multipart emails through the pico smtp relay.
+type Mailer struct {
+	Host string
+	From string
+	Auth sasl.Client
+}
+
+func NewMailer() *Mailer {
+	host := os.Getenv("PICO_SMTP_HOST")
+	smtPass := os.Getenv("PICO_SMTP_PASS")
+	emailLogin := os.Getenv("PICO_SMTP_USER")
+
+	return &Mailer{
+		Host: host,
+		From: "hello@pico.sh",
+		Auth: sasl.NewPlainClient("", emailLogin, smtPass),
+	}
+}
+
+// SanitizeHeader keeps user provided values, like a topic in a subject,
+// from adding headers or ending the header block of an email.
+func SanitizeHeader(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == 0 {
+			return ' '
+		}
+		return r
+	}, value)
+}
+
+// Send delivers a text and html email to a single recipient.  Any headers
+// provided are added alongside the standard ones.
+func (m *Mailer) Send(email, subject, text, html string, headers map[string]string) error {
+	if email == "" {
+		return fmt.Errorf("no email address provided")
+	}
+
+	allHeaders := map[string]string{
+		"From":         m.From,
+		"Subject":      subject,
+		"To":           email,
+		"MIME-Version": "1.0",
+		"Content-Type": `multipart/alternative; boundary="boundary123"`,
+	}
+	for k, v := range headers {
+		allHeaders[k] = v
+	}
+
+	var content strings.Builder
+	for k, v := range allHeaders {
+		fmt.Fprintf(&content, "%s: %s\r\n", k, SanitizeHeader(v))
+	}
+	content.WriteString("\r\n")
+	content.WriteString("\r\n--boundary123\r\n")
+	content.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	content.WriteString("\r\n" + text + "\r\n")
+	content.WriteString("--boundary123\r\n")
+	content.WriteString("Content-Type: text/html; charset=\"utf-8\"\r\n")
+	content.WriteString("\r\n" + html + "\r\n")
+	content.WriteString("--boundary123--")
+
+	return smtp.SendMail(
+		m.Host,
+		m.Auth,
+		m.From,
+		[]string{email},
+		strings.NewReader(content.String()),
+	)
+}
+
+// MsgBody is the text and html version of an email.
+type MsgBody struct {
+	Html string
+	Text string
+}
+
+// RenderText executes the plain text email template at fp with data.
+func RenderText(fp string, data any) (string, error) {
+	ts, err := template.ParseFiles(fp)
+	if err != nil {
+		return "", err
+	}
+
+	w := new(strings.Builder)
+	err = ts.Execute(w, data)
+	if err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
+
+// RenderHtml executes the html email template at fp with data.
+func RenderHtml(fp string, data any) (string, error) {
+	ts, err := htmlTemplate.ParseFiles(fp)
+	if err != nil {
+		return "", err
+	}
+
+	w := new(strings.Builder)
+	err = ts.Execute(w, data)
+	if err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
+
+// SendListEmail sends an email that recipients can unsubscribe from.  The
+// unsubscribe url is advertised in the headers so mail clients can offer a
+// one-click unsubscribe (RFC 8058), which POSTs to unsubURL.
+func SendListEmail(mailer MailSender, email, subject, unsubURL string, msg *MsgBody, headers map[string]string) error {
+	allHeaders := map[string]string{
+		"List-Unsubscribe":      "<" + unsubURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	for k, v := range headers {
+		allHeaders[k] = v
+	}
+	return mailer.Send(email, subject, msg.Text, msg.Html, allHeaders)
+}
+
+// IsPermanentMailError reports whether the smtp relay rejected an email for
+// good, e.g. because the mailbox does not exist.
+func IsPermanentMailError(err error) bool {
+	var smtpErr *smtp.SMTPError
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 500 && smtpErr.Code < 600
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package shared
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var admonitionTitles = map[string]string{
+	"NOTE":      "Note",
+	"TIP":       "Tip",
+	"IMPORTANT": "Important",
+	"WARNING":   "Warning",
+	"CAUTION":   "Caution",
+}
+
+// admonitionTransformer turns blockquotes starting with a github style
+// marker like [!NOTE] into admonitions.
+type admonitionTransformer struct{}
+
+func (t *admonitionTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	if !extensionEnabled(pc, "admonitions") {
+		return
+	}
+	source := reader.Source()
+
+	quotes := []*ast.Blockquote{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if quote, ok := n.(*ast.Blockquote); ok && entering {
+			quotes = append(quotes, quote)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, quote := range quotes {
+		para, ok := quote.FirstChild().(*ast.Paragraph)
+		if !ok || para.Lines().Len() == 0 {
+			continue
+		}
+		first := para.Lines().At(0)
+		marker := string(bytes.TrimSpace(first.Value(source)))
+		if !strings.HasPrefix(marker, "[!") || !strings.HasSuffix(marker, "]") {
+			continue
+		}
+		kind := strings.ToUpper(marker[2 : len(marker)-1])
+		title, ok := admonitionTitles[kind]
+		if !ok {
+			continue
+		}
+
+		// drop the inline nodes of the marker line, the rest of the
+		// paragraph is the body of the admonition
+		for child := para.FirstChild(); child != nil; {
+			next := child.NextSibling()
+			txt, ok := child.(*ast.Text)
+			if !ok || txt.Segment.Start >= first.Stop {
+				break
+			}
+			para.RemoveChild(para, child)
+			child = next
+		}
+		if !para.HasChildren() {
+			quote.RemoveChild(quote, para)
+		}
+
+		heading := ast.NewParagraph()
+		heading.SetAttributeString("class", []byte("admonition-title"))
+		heading.AppendChild(heading, ast.NewString([]byte(title)))
+		quote.InsertBefore(quote, quote.FirstChild(), heading)
+		quote.SetAttributeString("class", []byte("admonition "+strings.ToLower(kind)))
+	}
+}
+
+// AdmonitionExtension renders > [!NOTE] style blockquotes as admonitions
+// when the front-matter sets `admonitions: true`.
+var AdmonitionExtension goldmark.Extender = &admonitionExtension{}
+
+type admonitionExtension struct{}
+
+func (e *admonitionExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(util.Prioritized(&admonitionTransformer{}, 100)),
+	)
+}
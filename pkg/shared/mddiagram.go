@@ -0,0 +1,577 @@
+package shared
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// maxDiagramNodes keeps layout cheap for hostile input.
+const maxDiagramNodes = 200
+
+const (
+	diagramNodeHeight = 40
+	diagramRankGap    = 60
+	diagramNodeGap    = 30
+	diagramPadding    = 20
+)
+
+type diagramNode struct {
+	ID    string
+	Label string
+	// Shape is one of rect, round, diamond or circle.
+	Shape string
+	rank  int
+	order int
+	x     float64
+	y     float64
+	w     float64
+	h     float64
+}
+
+type diagramEdge struct {
+	From     string
+	To       string
+	Label    string
+	Directed bool
+	Dashed   bool
+}
+
+// diagram is a directed graph parsed from a mermaid flowchart or a graphviz
+// dot file.
+type diagram struct {
+	// Horizontal lays ranks out left to right instead of top to bottom.
+	Horizontal bool
+	Nodes      []*diagramNode
+	Edges      []*diagramEdge
+	index      map[string]*diagramNode
+}
+
+func newDiagram() *diagram {
+	return &diagram{index: map[string]*diagramNode{}}
+}
+
+func (d *diagram) node(id, label, shape string) (*diagramNode, error) {
+	n, ok := d.index[id]
+	if !ok {
+		if len(d.Nodes) >= maxDiagramNodes {
+			return nil, fmt.Errorf("diagram has more than %d nodes", maxDiagramNodes)
+		}
+		n = &diagramNode{ID: id, Label: id, Shape: "rect"}
+		d.index[id] = n
+		d.Nodes = append(d.Nodes, n)
+	}
+	if label != "" {
+		n.Label = label
+	}
+	if shape != "" {
+		n.Shape = shape
+	}
+	return n, nil
+}
+
+var mermaidHeader = regexp.MustCompile(`^(?:graph|flowchart)(?:\s+(TD|TB|BT|LR|RL))?$`)
+var mermaidNode = regexp.MustCompile(`^([A-Za-z0-9_]+)\s*(\(\(.*?\)\)|\(\[.*?\]\)|\[.*?\]|\(.*?\)|\{.*?\})?`)
+var mermaidEdge = regexp.MustCompile(`^(-->|---|-\.->|-\.-|==>|===)\s*(?:\|([^|]*)\|)?`)
+
+// parseMermaid understands the mermaid flowchart syntax: nodes with
+// [rect], (round), {diamond} and ((circle)) shapes and chains of edges
+// with optional |labels|.
+func parseMermaid(src string) (*diagram, error) {
+	stmts := []string{}
+	for _, line := range strings.Split(src, "\n") {
+		for _, stmt := range strings.Split(line, ";") {
+			stmt = strings.TrimSpace(stmt)
+			if stmt != "" && !strings.HasPrefix(stmt, "%%") {
+				stmts = append(stmts, stmt)
+			}
+		}
+	}
+	if len(stmts) == 0 {
+		return nil, fmt.Errorf("empty mermaid diagram")
+	}
+	match := mermaidHeader.FindStringSubmatch(stmts[0])
+	if match == nil {
+		return nil, fmt.Errorf("unsupported mermaid diagram: %s", stmts[0])
+	}
+
+	d := newDiagram()
+	d.Horizontal = match[1] == "LR" || match[1] == "RL"
+	for _, stmt := range stmts[1:] {
+		if err := d.mermaidStatement(stmt); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func (d *diagram) mermaidStatement(stmt string) error {
+	var prev *diagramNode
+	var edge *diagramEdge
+	for {
+		stmt = strings.TrimSpace(stmt)
+		match := mermaidNode.FindStringSubmatch(stmt)
+		if match == nil {
+			return fmt.Errorf("expected node in %q", stmt)
+		}
+		label, shape := mermaidShape(match[2])
+		n, err := d.node(match[1], label, shape)
+		if err != nil {
+			return err
+		}
+		if edge != nil {
+			edge.From = prev.ID
+			edge.To = n.ID
+			d.Edges = append(d.Edges, edge)
+		}
+		prev = n
+
+		stmt = strings.TrimSpace(stmt[len(match[0]):])
+		if stmt == "" {
+			return nil
+		}
+		arrow := mermaidEdge.FindStringSubmatch(stmt)
+		if arrow == nil {
+			return fmt.Errorf("expected edge in %q", stmt)
+		}
+		edge = &diagramEdge{
+			Label:    strings.TrimSpace(arrow[2]),
+			Directed: strings.HasSuffix(arrow[1], ">"),
+			Dashed:   strings.Contains(arrow[1], "."),
+		}
+		stmt = stmt[len(arrow[0]):]
+	}
+}
+
+func mermaidShape(raw string) (string, string) {
+	unquote := func(s string) string {
+		return strings.Trim(strings.TrimSpace(s), `"`)
+	}
+	switch {
+	case raw == "":
+		return "", ""
+	case strings.HasPrefix(raw, "(("):
+		return unquote(raw[2 : len(raw)-2]), "circle"
+	case strings.HasPrefix(raw, "(["):
+		return unquote(raw[2 : len(raw)-2]), "round"
+	case strings.HasPrefix(raw, "("):
+		return unquote(raw[1 : len(raw)-1]), "round"
+	case strings.HasPrefix(raw, "{"):
+		return unquote(raw[1 : len(raw)-1]), "diamond"
+	}
+	return unquote(raw[1 : len(raw)-1]), "rect"
+}
+
+var dotHeader = regexp.MustCompile(`^(?:strict\s+)?(digraph|graph)\s*("[^"]*"|[A-Za-z0-9_]*)\s*\{`)
+var dotAttr = regexp.MustCompile(`([A-Za-z]+)\s*=\s*("(?:[^"\\]|\\.)*"|[^,\s\]]+)`)
+var dotID = regexp.MustCompile(`^("(?:[^"\\]|\\.)*"|[A-Za-z0-9_.]+)`)
+
+// parseDot understands the subset of the graphviz dot language needed for
+// simple graphs: node and edge statements with label and shape attributes
+// and the rankdir graph attribute.  Subgraphs are not supported.
+func parseDot(src string) (*diagram, error) {
+	src = strings.TrimSpace(src)
+	match := dotHeader.FindStringSubmatch(src)
+	if match == nil || !strings.HasSuffix(src, "}") {
+		return nil, fmt.Errorf("expected graph or digraph")
+	}
+	directed := match[1] == "digraph"
+	body := src[len(match[0]) : len(src)-1]
+
+	d := newDiagram()
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "//") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, stmt := range strings.Split(line, ";") {
+			stmt = strings.TrimSpace(stmt)
+			if stmt == "" {
+				continue
+			}
+			if err := d.dotStatement(stmt, directed); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+func (d *diagram) dotStatement(stmt string, directed bool) error {
+	attrs := map[string]string{}
+	if i := strings.Index(stmt, "["); i >= 0 {
+		if !strings.HasSuffix(stmt, "]") {
+			return fmt.Errorf("expected ']' in %q", stmt)
+		}
+		for _, m := range dotAttr.FindAllStringSubmatch(stmt[i+1:len(stmt)-1], -1) {
+			attrs[strings.ToLower(m[1])] = dotUnquote(m[2])
+		}
+		stmt = strings.TrimSpace(stmt[:i])
+	}
+
+	if strings.HasPrefix(stmt, "rankdir") {
+		for _, m := range dotAttr.FindAllStringSubmatch(stmt, -1) {
+			d.Horizontal = dotUnquote(m[2]) == "LR" || dotUnquote(m[2]) == "RL"
+		}
+		return nil
+	}
+	switch stmt {
+	case "graph", "node", "edge":
+		// default attributes are not supported
+		return nil
+	}
+	if strings.Contains(stmt, "=") {
+		return nil
+	}
+
+	op := "--"
+	if directed {
+		op = "->"
+	}
+	ids := strings.Split(stmt, op)
+	var prev *diagramNode
+	for i, raw := range ids {
+		raw = strings.TrimSpace(raw)
+		id := dotID.FindString(raw)
+		if id == "" || id != raw {
+			return fmt.Errorf("invalid node id %q", raw)
+		}
+		label, shape := "", ""
+		if len(ids) == 1 {
+			label = attrs["label"]
+			shape = dotShape(attrs["shape"])
+		}
+		n, err := d.node(dotUnquote(id), label, shape)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			d.Edges = append(d.Edges, &diagramEdge{
+				From:     prev.ID,
+				To:       n.ID,
+				Label:    attrs["label"],
+				Directed: directed,
+				Dashed:   attrs["style"] == "dashed" || attrs["style"] == "dotted",
+			})
+		}
+		prev = n
+	}
+	return nil
+}
+
+func dotUnquote(s string) string {
+	if strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) && len(s) >= 2 {
+		s = strings.ReplaceAll(s[1:len(s)-1], `\"`, `"`)
+	}
+	return s
+}
+
+func dotShape(shape string) string {
+	switch shape {
+	case "":
+		return ""
+	case "diamond":
+		return "diamond"
+	case "circle", "doublecircle", "ellipse", "oval", "point":
+		return "circle"
+	}
+	return "rect"
+}
+
+// layout assigns each node a rank by its longest path from a root and
+// spreads the nodes of a rank out next to each other.
+func (d *diagram) layout() (float64, float64) {
+	out := map[string][]string{}
+	for _, e := range d.Edges {
+		if e.From != e.To {
+			out[e.From] = append(out[e.From], e.To)
+		}
+	}
+
+	// drop back edges so cycles do not push ranks out forever
+	state := map[string]int{}
+	forward := map[string][]string{}
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = 1
+		for _, to := range out[id] {
+			switch state[to] {
+			case 0:
+				forward[id] = append(forward[id], to)
+				visit(to)
+			case 2:
+				forward[id] = append(forward[id], to)
+			}
+		}
+		state[id] = 2
+	}
+	for _, n := range d.Nodes {
+		if state[n.ID] == 0 {
+			visit(n.ID)
+		}
+	}
+
+	// the nodes are at most len(d.Nodes) ranks deep so this settles
+	for range d.Nodes {
+		changed := false
+		for _, n := range d.Nodes {
+			for _, to := range forward[n.ID] {
+				if next := d.index[to]; next.rank < n.rank+1 {
+					next.rank = n.rank + 1
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	ranks := [][]*diagramNode{}
+	for _, n := range d.Nodes {
+		for len(ranks) <= n.rank {
+			ranks = append(ranks, []*diagramNode{})
+		}
+		n.order = len(ranks[n.rank])
+		ranks[n.rank] = append(ranks[n.rank], n)
+
+		n.w = math.Max(80, float64(len([]rune(n.Label)))*8+24)
+		n.h = diagramNodeHeight
+		if n.Shape == "diamond" || n.Shape == "circle" {
+			n.w = math.Max(n.w, 60) + 20
+			n.h = diagramNodeHeight + 20
+		}
+	}
+
+	// rank extent is measured along the axis the ranks are spread on
+	across := func(n *diagramNode) float64 {
+		if d.Horizontal {
+			return n.h
+		}
+		return n.w
+	}
+	along := func(n *diagramNode) float64 {
+		if d.Horizontal {
+			return n.w
+		}
+		return n.h
+	}
+
+	widest := 0.0
+	for _, rank := range ranks {
+		size := 0.0
+		for _, n := range rank {
+			size += across(n) + diagramNodeGap
+		}
+		widest = math.Max(widest, size-diagramNodeGap)
+	}
+
+	pos := float64(diagramPadding)
+	for _, rank := range ranks {
+		size, depth := 0.0, 0.0
+		for _, n := range rank {
+			size += across(n) + diagramNodeGap
+			depth = math.Max(depth, along(n))
+		}
+		offset := diagramPadding + (widest-(size-diagramNodeGap))/2
+		for _, n := range rank {
+			a := offset + across(n)/2
+			b := pos + depth/2
+			offset += across(n) + diagramNodeGap
+			if d.Horizontal {
+				n.x, n.y = b, a
+			} else {
+				n.x, n.y = a, b
+			}
+		}
+		pos += depth + diagramRankGap
+	}
+	pos = pos - diagramRankGap + diagramPadding
+	widest += 2 * diagramPadding
+
+	if d.Horizontal {
+		return pos, widest
+	}
+	return widest, pos
+}
+
+// boundary returns where the line from the center of n towards (x, y)
+// leaves the node.
+func (n *diagramNode) boundary(x, y float64) (float64, float64) {
+	dx, dy := x-n.x, y-n.y
+	if dx == 0 && dy == 0 {
+		return n.x, n.y
+	}
+	hw, hh := n.w/2, n.h/2
+	var t float64
+	switch n.Shape {
+	case "diamond":
+		t = 1 / (math.Abs(dx)/hw + math.Abs(dy)/hh)
+	case "circle":
+		t = 1 / math.Sqrt((dx*dx)/(hw*hw)+(dy*dy)/(hh*hh))
+	default:
+		t = math.Min(hw/math.Abs(dx), hh/math.Abs(dy))
+	}
+	return n.x + dx*t, n.y + dy*t
+}
+
+// SVG lays the diagram out and draws it as a standalone svg element.
+func (d *diagram) SVG() string {
+	width, height := d.layout()
+
+	var sb strings.Builder
+	fmt.Fprintf(
+		&sb,
+		`<svg xmlns="http://www.w3.org/2000/svg" class="diagram" viewBox="0 0 %.0f %.0f" width="%.0f" height="%.0f" font-family="sans-serif" font-size="14">`,
+		width, height, width, height,
+	)
+
+	for _, e := range d.Edges {
+		from, to := d.index[e.From], d.index[e.To]
+		if from == to {
+			continue
+		}
+		x1, y1 := from.boundary(to.x, to.y)
+		x2, y2 := to.boundary(from.x, from.y)
+		dash := ""
+		if e.Dashed {
+			dash = ` stroke-dasharray="4 3"`
+		}
+		fmt.Fprintf(&sb, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="currentColor"%s></line>`, x1, y1, x2, y2, dash)
+		if e.Directed {
+			angle := math.Atan2(y2-y1, x2-x1)
+			ax, ay := x2-10*math.Cos(angle-0.4), y2-10*math.Sin(angle-0.4)
+			bx, by := x2-10*math.Cos(angle+0.4), y2-10*math.Sin(angle+0.4)
+			fmt.Fprintf(&sb, `<polygon points="%.1f,%.1f %.1f,%.1f %.1f,%.1f" fill="currentColor"></polygon>`, x2, y2, ax, ay, bx, by)
+		}
+		if e.Label != "" {
+			fmt.Fprintf(
+				&sb,
+				`<text x="%.1f" y="%.1f" text-anchor="middle" font-size="12" fill="currentColor">%s</text>`,
+				(x1+x2)/2, (y1+y2)/2-4, html.EscapeString(e.Label),
+			)
+		}
+	}
+
+	for _, n := range d.Nodes {
+		switch n.Shape {
+		case "diamond":
+			fmt.Fprintf(
+				&sb,
+				`<polygon points="%.1f,%.1f %.1f,%.1f %.1f,%.1f %.1f,%.1f" fill="none" stroke="currentColor"></polygon>`,
+				n.x, n.y-n.h/2, n.x+n.w/2, n.y, n.x, n.y+n.h/2, n.x-n.w/2, n.y,
+			)
+		case "circle":
+			fmt.Fprintf(&sb, `<ellipse cx="%.1f" cy="%.1f" rx="%.1f" ry="%.1f" fill="none" stroke="currentColor"></ellipse>`, n.x, n.y, n.w/2, n.h/2)
+		default:
+			radius := 0.0
+			if n.Shape == "round" {
+				radius = n.h / 2
+			}
+			fmt.Fprintf(
+				&sb,
+				`<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="%.1f" fill="none" stroke="currentColor"></rect>`,
+				n.x-n.w/2, n.y-n.h/2, n.w, n.h, radius,
+			)
+		}
+		fmt.Fprintf(
+			&sb,
+			`<text x="%.1f" y="%.1f" text-anchor="middle" dominant-baseline="central" fill="currentColor">%s</text>`,
+			n.x, n.y, html.EscapeString(n.Label),
+		)
+	}
+
+	sb.WriteString("</svg>")
+	return sb.String()
+}
+
+var KindDiagramBlock = ast.NewNodeKind("DiagramBlock")
+
+// DiagramBlock is a fenced mermaid or graphviz code block.
+type DiagramBlock struct {
+	ast.BaseBlock
+	Lang   string
+	Source string
+}
+
+func (n *DiagramBlock) Kind() ast.NodeKind {
+	return KindDiagramBlock
+}
+
+func (n *DiagramBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Lang": n.Lang}, nil)
+}
+
+type diagramTransformer struct{}
+
+func (t *diagramTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	if !extensionEnabled(pc, "diagrams") {
+		return
+	}
+	replaceCodeBlocks(doc, reader.Source(), func(lang string, code []byte) ast.Node {
+		switch lang {
+		case "mermaid", "dot", "graphviz":
+			return &DiagramBlock{Lang: lang, Source: string(code)}
+		}
+		return nil
+	})
+}
+
+type diagramRenderer struct{}
+
+func (r *diagramRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindDiagramBlock, func(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkSkipChildren, nil
+		}
+		node := n.(*DiagramBlock)
+
+		var graph *diagram
+		var err error
+		if node.Lang == "mermaid" {
+			graph, err = parseMermaid(node.Source)
+		} else {
+			graph, err = parseDot(node.Source)
+		}
+		if err != nil {
+			// leave the source for a client-side renderer to pick up
+			_, _ = fmt.Fprintf(
+				w,
+				`<pre class="%s" title="%s">%s</pre>`+"\n",
+				html.EscapeString(node.Lang),
+				html.EscapeString(err.Error()),
+				html.EscapeString(node.Source),
+			)
+			return ast.WalkSkipChildren, nil
+		}
+
+		_, _ = w.WriteString(`<div class="diagram">`)
+		_, _ = w.WriteString(graph.SVG())
+		_, _ = w.WriteString("</div>\n")
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// DiagramExtension renders ```mermaid flowcharts and ```dot graphs as
+// inline svg when the front-matter sets `diagrams: true`.
+var DiagramExtension goldmark.Extender = &diagramExtension{}
+
+type diagramExtension struct{}
+
+func (e *diagramExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(util.Prioritized(&diagramTransformer{}, 100)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&diagramRenderer{}, 100)),
+	)
+}
@@ -0,0 +1,176 @@
+package shared
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// embedShortcode matches a paragraph holding only {{< kind target >}}.
+var embedShortcode = regexp.MustCompile(`^\{\{<\s*(paste|pgs)\s+(\S+)\s*>\}\}$`)
+
+// embedPaste is user/paste-name on pastes.
+var embedPaste = regexp.MustCompile(`^([a-zA-Z0-9_-]+)/([a-zA-Z0-9_.-]+)$`)
+
+// embedPgs is user-project/path/to/asset on pgs.
+var embedPgs = regexp.MustCompile(`^([a-zA-Z0-9_-]+)/([a-zA-Z0-9_./-]+)$`)
+
+// EmbedURL returns the url a shortcode points at.  Targets are restricted to
+// plain names so an embed can only ever point at pastes or pgs.
+func EmbedURL(kind, target string) (string, error) {
+	if strings.Contains(target, "..") {
+		return "", fmt.Errorf("invalid %s embed: %s", kind, target)
+	}
+	switch kind {
+	case "paste":
+		match := embedPaste.FindStringSubmatch(target)
+		if match == nil {
+			return "", fmt.Errorf("invalid paste embed, expected user/name: %s", target)
+		}
+		domain := GetEnv("PASTES_DOMAIN", "pastes.sh")
+		return fmt.Sprintf("https://%s/%s/%s", domain, match[1], match[2]), nil
+	case "pgs":
+		match := embedPgs.FindStringSubmatch(target)
+		if match == nil {
+			return "", fmt.Errorf("invalid pgs embed, expected user-project/path: %s", target)
+		}
+		domain := GetEnv("PGS_DOMAIN", "pgs.sh")
+		return fmt.Sprintf("https://%s.%s/%s", strings.ToLower(match[1]), domain, match[2]), nil
+	}
+	return "", fmt.Errorf("unknown embed: %s", kind)
+}
+
+// embedPolicy lets iframes through the sanitizer when they point at pastes.
+func embedPolicy() *regexp.Regexp {
+	domain := GetEnv("PASTES_DOMAIN", "pastes.sh")
+	return regexp.MustCompile(`^https://` + regexp.QuoteMeta(domain) + `/[a-zA-Z0-9_-]+/[a-zA-Z0-9_.-]+$`)
+}
+
+// embedAssetPolicy lets videos through the sanitizer when they are on pgs.
+func embedAssetPolicy() *regexp.Regexp {
+	domain := GetEnv("PGS_DOMAIN", "pgs.sh")
+	return regexp.MustCompile(`^https://[a-z0-9_-]+\.` + regexp.QuoteMeta(domain) + `/[a-zA-Z0-9_./-]+$`)
+}
+
+var KindEmbed = ast.NewNodeKind("Embed")
+
+// Embed is a shortcode for a paste or a pgs asset.
+type Embed struct {
+	ast.BaseBlock
+	EmbedKind string
+	Target    string
+}
+
+func (n *Embed) Kind() ast.NodeKind {
+	return KindEmbed
+}
+
+func (n *Embed) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"EmbedKind": n.EmbedKind, "Target": n.Target}, nil)
+}
+
+type embedTransformer struct{}
+
+func (t *embedTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	if !extensionEnabled(pc, "embeds") {
+		return
+	}
+	source := reader.Source()
+
+	paras := []*ast.Paragraph{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if para, ok := n.(*ast.Paragraph); ok && entering {
+			paras = append(paras, para)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, para := range paras {
+		if para.Lines().Len() != 1 {
+			continue
+		}
+		segment := para.Lines().At(0)
+		line := bytes.TrimSpace(segment.Value(source))
+		match := embedShortcode.FindSubmatch(line)
+		if match == nil {
+			continue
+		}
+		parent := para.Parent()
+		parent.ReplaceChild(parent, para, &Embed{
+			EmbedKind: string(match[1]),
+			Target:    string(match[2]),
+		})
+	}
+}
+
+var embedVideos = []string{".mp4", ".webm", ".ogv", ".mov"}
+var embedImages = []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg", ".avif"}
+
+type embedRenderer struct{}
+
+func (r *embedRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindEmbed, func(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkSkipChildren, nil
+		}
+		node := n.(*Embed)
+		url, err := EmbedURL(node.EmbedKind, node.Target)
+		if err != nil {
+			_, _ = fmt.Fprintf(w, `<p class="embed-error">%s</p>`+"\n", html.EscapeString(err.Error()))
+			return ast.WalkSkipChildren, nil
+		}
+		url = html.EscapeString(url)
+		name := html.EscapeString(node.Target)
+
+		if node.EmbedKind == "paste" {
+			_, _ = fmt.Fprintf(
+				w,
+				`<iframe class="embed embed-paste" src="%s" title="%s" loading="lazy"></iframe>`+"\n",
+				url, name,
+			)
+			return ast.WalkSkipChildren, nil
+		}
+
+		ext := strings.ToLower(filepath.Ext(node.Target))
+		for _, video := range embedVideos {
+			if ext == video {
+				_, _ = fmt.Fprintf(w, `<video class="embed embed-pgs" src="%s" controls preload="metadata"></video>`+"\n", url)
+				return ast.WalkSkipChildren, nil
+			}
+		}
+		for _, image := range embedImages {
+			if ext == image {
+				_, _ = fmt.Fprintf(w, `<img class="embed embed-pgs" src="%s" alt="%s" loading="lazy" />`+"\n", url, name)
+				return ast.WalkSkipChildren, nil
+			}
+		}
+		_, _ = fmt.Fprintf(w, `<p class="embed embed-pgs"><a href="%s">%s</a></p>`+"\n", url, name)
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// EmbedExtension renders {{< paste user/name >}} and
+// {{< pgs user-project/path >}} shortcodes when the front-matter sets
+// `embeds: true`.
+var EmbedExtension goldmark.Extender = &embedExtension{}
+
+type embedExtension struct{}
+
+func (e *embedExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(util.Prioritized(&embedTransformer{}, 100)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&embedRenderer{}, 100)),
+	)
+}
@@ -0,0 +1,771 @@
+package shared
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// maxTexDepth limits how deeply TeX groups can be nested.
+const maxTexDepth = 64
+
+var texIdentifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ", "sigma": "σ",
+	"varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ", "varphi": "φ",
+	"chi": "χ", "psi": "ψ", "omega": "ω", "Gamma": "Γ", "Delta": "Δ",
+	"Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π", "Sigma": "Σ",
+	"Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	"infty": "∞", "partial": "∂", "nabla": "∇", "ell": "ℓ", "hbar": "ℏ",
+	"emptyset": "∅", "varnothing": "∅", "aleph": "ℵ", "Re": "ℜ", "Im": "ℑ",
+	"imath": "ı", "jmath": "ȷ", "wp": "℘", "top": "⊤", "bot": "⊥",
+}
+
+var texOperators = map[string]string{
+	"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "⋅", "ast": "∗",
+	"star": "⋆", "circ": "∘", "bullet": "∙", "oplus": "⊕", "ominus": "⊖",
+	"otimes": "⊗", "odot": "⊙", "cup": "∪", "cap": "∩", "setminus": "∖",
+	"wedge": "∧", "land": "∧", "vee": "∨", "lor": "∨", "neg": "¬", "lnot": "¬",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅",
+	"propto": "∝", "ll": "≪", "gg": "≫", "prec": "≺", "succ": "≻",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "supset": "⊃",
+	"subseteq": "⊆", "supseteq": "⊇", "mid": "∣", "parallel": "∥",
+	"perp": "⊥", "forall": "∀", "exists": "∃", "nexists": "∄",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←",
+	"leftrightarrow": "↔", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"Leftrightarrow": "⇔", "implies": "⟹", "iff": "⟺", "mapsto": "↦",
+	"uparrow": "↑", "downarrow": "↓", "longrightarrow": "⟶",
+	"longleftarrow": "⟵", "ldots": "…", "cdots": "⋯", "vdots": "⋮",
+	"ddots": "⋱", "dots": "…", "langle": "⟨", "rangle": "⟩",
+	"lceil": "⌈", "rceil": "⌉", "lfloor": "⌊", "rfloor": "⌋",
+	"vert": "|", "Vert": "‖", "|": "‖", "angle": "∠", "triangle": "△",
+	"therefore": "∴", "because": "∵", "prime": "′", "degree": "°",
+	"sum": "∑", "prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬",
+	"iiint": "∭", "oint": "∮", "bigcup": "⋃", "bigcap": "⋂",
+	"bigoplus": "⨁", "bigotimes": "⨂", "bigvee": "⋁", "bigwedge": "⋀",
+	"{": "{", "}": "}", "$": "$", "%": "%", "&": "&", "#": "#", "_": "_",
+}
+
+// texLimits are the operators that put their scripts above and below them
+// in display mode.
+var texLimits = []string{
+	"∑", "∏", "∐", "⋃", "⋂", "⨁", "⨂", "⋁", "⋀",
+	"lim", "limsup", "liminf", "max", "min", "sup", "inf", "det", "gcd", "Pr",
+}
+
+var texFunctions = []string{
+	"sin", "cos", "tan", "cot", "sec", "csc", "arcsin", "arccos", "arctan",
+	"sinh", "cosh", "tanh", "coth", "log", "ln", "lg", "exp", "lim", "limsup",
+	"liminf", "max", "min", "sup", "inf", "det", "gcd", "deg", "dim", "ker",
+	"hom", "arg", "Pr", "mod",
+}
+
+var texSpaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em",
+	"!": "-0.1667em", " ": "0.25em", "quad": "1em", "qquad": "2em",
+}
+
+var texAccents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "overline": "¯", "vec": "→",
+	"overrightarrow": "→", "tilde": "~", "widetilde": "~", "dot": "˙",
+	"ddot": "¨", "check": "ˇ", "breve": "˘", "acute": "´", "grave": "`",
+}
+
+var texVariants = map[string]string{
+	"mathrm": "normal", "textrm": "normal", "operatorname": "normal",
+	"mathbf": "bold", "textbf": "bold", "boldsymbol": "bold-italic",
+	"mathit": "italic", "textit": "italic", "mathbb": "double-struck",
+	"mathcal": "script", "mathscr": "script", "mathfrak": "fraktur",
+	"mathsf": "sans-serif", "mathtt": "monospace",
+}
+
+var texMatrices = map[string][2]string{
+	"matrix": {"", ""}, "pmatrix": {"(", ")"}, "bmatrix": {"[", "]"},
+	"Bmatrix": {"{", "}"}, "vmatrix": {"|", "|"}, "Vmatrix": {"‖", "‖"},
+	"cases": {"{", ""}, "aligned": {"", ""}, "align": {"", ""},
+	"align*": {"", ""}, "gathered": {"", ""}, "array": {"", ""},
+}
+
+type texParser struct {
+	src     []rune
+	pos     int
+	depth   int
+	display bool
+}
+
+// texStop tells expr where the current expression ends.
+type texStop struct {
+	char  rune
+	right bool
+	table bool
+}
+
+func (p *texParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *texParser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *texParser) skipSpace() {
+	for !p.eof() && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// peekCommand returns the name of the command at the current position
+// without consuming it.
+func (p *texParser) peekCommand() string {
+	if p.peek() != '\\' || p.pos+1 >= len(p.src) {
+		return ""
+	}
+	end := p.pos + 1
+	for end < len(p.src) && unicode.IsLetter(p.src[end]) {
+		end++
+	}
+	if end == p.pos+1 {
+		return string(p.src[end])
+	}
+	return string(p.src[p.pos+1 : end])
+}
+
+func (p *texParser) command() string {
+	name := p.peekCommand()
+	p.pos += 1 + len([]rune(name))
+	return name
+}
+
+func mrow(nodes []string) string {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	return "<mrow>" + strings.Join(nodes, "") + "</mrow>"
+}
+
+func mi(text, variant string) string {
+	if variant != "" {
+		return fmt.Sprintf(`<mi mathvariant="%s">%s</mi>`, variant, html.EscapeString(text))
+	}
+	return "<mi>" + html.EscapeString(text) + "</mi>"
+}
+
+func mo(text string) string {
+	return "<mo>" + html.EscapeString(text) + "</mo>"
+}
+
+func (p *texParser) expr(stop texStop) ([]string, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxTexDepth {
+		return nil, fmt.Errorf("expression is nested too deeply")
+	}
+
+	nodes := []string{}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			if stop.char != 0 {
+				return nil, fmt.Errorf("expected '%c'", stop.char)
+			}
+			if stop.right {
+				return nil, fmt.Errorf(`expected \right`)
+			}
+			return nodes, nil
+		}
+
+		c := p.peek()
+		cmd := p.peekCommand()
+		if stop.char != 0 && c == stop.char {
+			p.pos++
+			return nodes, nil
+		}
+		if stop.right && cmd == "right" {
+			return nodes, nil
+		}
+		if stop.table && (c == '&' || cmd == "\\" || cmd == "end") {
+			return nodes, nil
+		}
+		if c == '}' || c == '&' {
+			return nil, fmt.Errorf("unexpected '%c'", c)
+		}
+
+		atom, err := p.atom()
+		if err != nil {
+			return nil, err
+		}
+		atom, err = p.scripts(atom)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, atom)
+	}
+}
+
+// arg reads the argument of a command, either a group or a single token.
+func (p *texParser) arg() (string, error) {
+	p.skipSpace()
+	if p.eof() {
+		return "", fmt.Errorf("missing argument")
+	}
+	if p.peek() == '{' {
+		p.pos++
+		nodes, err := p.expr(texStop{char: '}'})
+		if err != nil {
+			return "", err
+		}
+		return mrow(nodes), nil
+	}
+	c := p.peek()
+	if unicode.IsDigit(c) {
+		p.pos++
+		return "<mn>" + string(c) + "</mn>", nil
+	}
+	return p.atom()
+}
+
+// rawGroup reads a {group} without parsing it, for text and names.
+func (p *texParser) rawGroup() (string, error) {
+	p.skipSpace()
+	if p.peek() != '{' {
+		return "", fmt.Errorf("expected '{'")
+	}
+	start := p.pos + 1
+	level := 0
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '{':
+			level++
+		case '}':
+			level--
+			if level == 0 {
+				p.pos++
+				return string(p.src[start : p.pos-1]), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("expected '}'")
+}
+
+func (p *texParser) scripts(base string) (string, error) {
+	var sub, sup string
+	for {
+		p.skipSpace()
+		c := p.peek()
+		switch {
+		case c == '\'':
+			p.pos++
+			sup += mo("′")
+			continue
+		case c == '^' && sup == "":
+			p.pos++
+			arg, err := p.arg()
+			if err != nil {
+				return "", err
+			}
+			sup = arg
+			continue
+		case c == '_' && sub == "":
+			p.pos++
+			arg, err := p.arg()
+			if err != nil {
+				return "", err
+			}
+			sub = arg
+			continue
+		case c == '^' || c == '_':
+			return "", fmt.Errorf("double %c", c)
+		}
+		break
+	}
+
+	limits := false
+	if p.display {
+		for _, op := range texLimits {
+			if strings.Contains(base, ">"+op+"<") {
+				limits = true
+			}
+		}
+	}
+
+	switch {
+	case sub != "" && sup != "" && limits:
+		return "<munderover>" + base + sub + sup + "</munderover>", nil
+	case sub != "" && sup != "":
+		return "<msubsup>" + base + sub + sup + "</msubsup>", nil
+	case sub != "" && limits:
+		return "<munder>" + base + sub + "</munder>", nil
+	case sub != "":
+		return "<msub>" + base + sub + "</msub>", nil
+	case sup != "" && limits:
+		return "<mover>" + base + sup + "</mover>", nil
+	case sup != "":
+		return "<msup>" + base + sup + "</msup>", nil
+	}
+	return base, nil
+}
+
+// delimiter reads the delimiter following \left and \right.
+func (p *texParser) delimiter() (string, error) {
+	p.skipSpace()
+	if p.eof() {
+		return "", fmt.Errorf("missing delimiter")
+	}
+	if p.peek() == '\\' {
+		name := p.command()
+		if op, ok := texOperators[name]; ok {
+			return op, nil
+		}
+		return "", fmt.Errorf(`invalid delimiter \%s`, name)
+	}
+	c := p.peek()
+	p.pos++
+	if c == '.' {
+		return "", nil
+	}
+	if !strings.ContainsRune("()[]|/<>", c) {
+		return "", fmt.Errorf("invalid delimiter '%c'", c)
+	}
+	return string(c), nil
+}
+
+func fence(delim string) string {
+	if delim == "" {
+		return ""
+	}
+	return `<mo fence="true" stretchy="true">` + html.EscapeString(delim) + "</mo>"
+}
+
+func (p *texParser) environment() (string, error) {
+	name, err := p.rawGroup()
+	if err != nil {
+		return "", err
+	}
+	delims, ok := texMatrices[name]
+	if !ok {
+		return "", fmt.Errorf("unknown environment %s", name)
+	}
+	if name == "array" {
+		// column alignment is not supported, the spec is ignored
+		if _, err := p.rawGroup(); err != nil {
+			return "", err
+		}
+	}
+
+	rows := []string{}
+	cells := []string{}
+	for {
+		nodes, err := p.expr(texStop{table: true})
+		if err != nil {
+			return "", err
+		}
+		cells = append(cells, "<mtd>"+mrow(nodes)+"</mtd>")
+
+		if p.eof() {
+			return "", fmt.Errorf(`expected \end{%s}`, name)
+		}
+		if p.peek() == '&' {
+			p.pos++
+			continue
+		}
+
+		cmd := p.command()
+		rows = append(rows, "<mtr>"+strings.Join(cells, "")+"</mtr>")
+		cells = []string{}
+		if cmd == "end" {
+			end, err := p.rawGroup()
+			if err != nil {
+				return "", err
+			}
+			if end != name {
+				return "", fmt.Errorf(`\begin{%s} ended by \end{%s}`, name, end)
+			}
+			break
+		}
+	}
+
+	table := "<mtable>" + strings.Join(rows, "") + "</mtable>"
+	return "<mrow>" + fence(delims[0]) + table + fence(delims[1]) + "</mrow>", nil
+}
+
+func (p *texParser) macro() (string, error) {
+	name := p.command()
+	if name == "" {
+		return "", fmt.Errorf(`unexpected '\' at the end`)
+	}
+
+	if id, ok := texIdentifiers[name]; ok {
+		return mi(id, ""), nil
+	}
+	if op, ok := texOperators[name]; ok {
+		return mo(op), nil
+	}
+	if width, ok := texSpaces[name]; ok {
+		return fmt.Sprintf(`<mspace width="%s"></mspace>`, width), nil
+	}
+	for _, fn := range texFunctions {
+		if fn == name {
+			return "<mi>" + name + "</mi>", nil
+		}
+	}
+	if accent, ok := texAccents[name]; ok {
+		arg, err := p.arg()
+		if err != nil {
+			return "", err
+		}
+		return `<mover accent="true">` + arg + mo(accent) + "</mover>", nil
+	}
+	if variant, ok := texVariants[name]; ok {
+		raw, err := p.rawGroup()
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(name, "text") {
+			return fmt.Sprintf(`<mtext mathvariant="%s">%s</mtext>`, variant, html.EscapeString(raw)), nil
+		}
+		sub := &texParser{src: []rune(raw), depth: p.depth, display: p.display}
+		nodes, err := sub.expr(texStop{})
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(`<mstyle mathvariant="%s">%s</mstyle>`, variant, mrow(nodes)), nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num, err := p.arg()
+		if err != nil {
+			return "", err
+		}
+		den, err := p.arg()
+		if err != nil {
+			return "", err
+		}
+		return "<mfrac>" + num + den + "</mfrac>", nil
+	case "binom":
+		top, err := p.arg()
+		if err != nil {
+			return "", err
+		}
+		bottom, err := p.arg()
+		if err != nil {
+			return "", err
+		}
+		return `<mrow><mo>(</mo><mfrac linethickness="0">` + top + bottom + `</mfrac><mo>)</mo></mrow>`, nil
+	case "sqrt":
+		p.skipSpace()
+		index := ""
+		if p.peek() == '[' {
+			p.pos++
+			nodes, err := p.expr(texStop{char: ']'})
+			if err != nil {
+				return "", err
+			}
+			index = mrow(nodes)
+		}
+		radicand, err := p.arg()
+		if err != nil {
+			return "", err
+		}
+		if index != "" {
+			return "<mroot>" + radicand + index + "</mroot>", nil
+		}
+		return "<msqrt>" + radicand + "</msqrt>", nil
+	case "text", "mbox":
+		raw, err := p.rawGroup()
+		if err != nil {
+			return "", err
+		}
+		return "<mtext>" + html.EscapeString(raw) + "</mtext>", nil
+	case "underline":
+		arg, err := p.arg()
+		if err != nil {
+			return "", err
+		}
+		return `<munder accentunder="true">` + arg + mo("_") + "</munder>", nil
+	case "left":
+		open, err := p.delimiter()
+		if err != nil {
+			return "", err
+		}
+		nodes, err := p.expr(texStop{right: true})
+		if err != nil {
+			return "", err
+		}
+		p.command()
+		closing, err := p.delimiter()
+		if err != nil {
+			return "", err
+		}
+		return "<mrow>" + fence(open) + strings.Join(nodes, "") + fence(closing) + "</mrow>", nil
+	case "begin":
+		return p.environment()
+	case "\\":
+		return `<mspace linebreak="newline"></mspace>`, nil
+	}
+
+	return "", fmt.Errorf(`undefined control sequence \%s`, name)
+}
+
+func (p *texParser) atom() (string, error) {
+	c := p.peek()
+	switch {
+	case c == '\\':
+		return p.macro()
+	case c == '{':
+		p.pos++
+		nodes, err := p.expr(texStop{char: '}'})
+		if err != nil {
+			return "", err
+		}
+		return mrow(nodes), nil
+	case c == '^' || c == '_':
+		return "<mrow></mrow>", nil
+	case unicode.IsDigit(c) || c == '.':
+		start := p.pos
+		for !p.eof() && (unicode.IsDigit(p.peek()) || p.peek() == '.') {
+			p.pos++
+		}
+		return "<mn>" + string(p.src[start:p.pos]) + "</mn>", nil
+	case unicode.IsLetter(c):
+		p.pos++
+		return mi(string(c), ""), nil
+	case c == '~':
+		p.pos++
+		return `<mspace width="0.3333em"></mspace>`, nil
+	case c == '-':
+		p.pos++
+		return mo("−"), nil
+	case c == '*':
+		p.pos++
+		return mo("∗"), nil
+	case c == '#' || c == '%' || c == '$':
+		return "", fmt.Errorf("unexpected '%c'", c)
+	}
+	p.pos++
+	return mo(string(c)), nil
+}
+
+/*
+TexToMathML converts TeX math to MathML.  It understands the subset of TeX
+that is commonly used with KaTeX: fractions, roots, scripts, greek letters,
+operators, accents, fonts, \left \right delimiters and matrix environments.
+The TeX source is kept as an annotation the same way KaTeX does.
+*/
+func TexToMathML(tex string, display bool) (string, error) {
+	p := &texParser{src: []rune(tex), display: display}
+	nodes, err := p.expr(texStop{})
+	if err != nil {
+		return "", err
+	}
+
+	mode := "inline"
+	if display {
+		mode = "block"
+	}
+	return fmt.Sprintf(
+		`<math xmlns="http://www.w3.org/1998/Math/MathML" display="%s"><semantics><mrow>%s</mrow><annotation encoding="application/x-tex">%s</annotation></semantics></math>`,
+		mode,
+		strings.Join(nodes, ""),
+		html.EscapeString(tex),
+	), nil
+}
+
+func renderMath(w util.BufWriter, tex string, display bool) {
+	out, err := TexToMathML(tex, display)
+	if err != nil {
+		_, _ = fmt.Fprintf(
+			w,
+			`<code class="math-error" title="%s">%s</code>`,
+			html.EscapeString(err.Error()),
+			html.EscapeString(tex),
+		)
+		return
+	}
+	_, _ = w.WriteString(out)
+}
+
+var KindMath = ast.NewNodeKind("Math")
+
+// Math is TeX written between dollar signs, $$ is displayed as a block.
+type Math struct {
+	ast.BaseInline
+	TeX     string
+	Display bool
+}
+
+func (n *Math) Kind() ast.NodeKind {
+	return KindMath
+}
+
+func (n *Math) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"TeX": n.TeX}, nil)
+}
+
+var KindMathBlock = ast.NewNodeKind("MathBlock")
+
+// MathBlock is a fenced code block with the math language.
+type MathBlock struct {
+	ast.BaseBlock
+	TeX string
+}
+
+func (n *MathBlock) Kind() ast.NodeKind {
+	return KindMathBlock
+}
+
+func (n *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"TeX": n.TeX}, nil)
+}
+
+type mathParser struct{}
+
+func (s *mathParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (s *mathParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	if !extensionEnabled(pc, "math") {
+		return nil
+	}
+	line, _ := block.PeekLine()
+	if len(line) > 1 && line[1] == '$' {
+		return s.parseDisplay(block)
+	}
+
+	// $ only opens math when followed by a non-space and closes when
+	// preceded by one, so prices like $5 and $10 are left alone.
+	if len(line) < 3 || unicode.IsSpace(rune(line[1])) {
+		return nil
+	}
+	for i := 2; i < len(line); i++ {
+		if line[i] != '$' || line[i-1] == '\\' {
+			continue
+		}
+		if unicode.IsSpace(rune(line[i-1])) {
+			return nil
+		}
+		if i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9' {
+			return nil
+		}
+		block.Advance(i + 1)
+		return &Math{TeX: string(line[1:i])}
+	}
+	return nil
+}
+
+func (s *mathParser) parseDisplay(block text.Reader) ast.Node {
+	l, pos := block.Position()
+	block.Advance(2)
+	tex := []byte{}
+	for {
+		line, _ := block.PeekLine()
+		if line == nil {
+			block.SetPosition(l, pos)
+			return nil
+		}
+		for i := 0; i+1 < len(line); i++ {
+			if line[i] == '$' && line[i+1] == '$' {
+				tex = append(tex, line[:i]...)
+				block.Advance(i + 2)
+				return &Math{TeX: strings.TrimSpace(string(tex)), Display: true}
+			}
+		}
+		tex = append(tex, line...)
+		block.AdvanceLine()
+	}
+}
+
+// mathTransformer turns ```math code blocks into display math.
+type mathTransformer struct{}
+
+func (t *mathTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	if !extensionEnabled(pc, "math") {
+		return
+	}
+	source := reader.Source()
+	replaceCodeBlocks(doc, source, func(lang string, code []byte) ast.Node {
+		if lang != "math" {
+			return nil
+		}
+		return &MathBlock{TeX: strings.TrimSpace(string(code))}
+	})
+}
+
+// replaceCodeBlocks swaps fenced code blocks for the node returned by fn,
+// blocks are kept when fn returns nil.
+func replaceCodeBlocks(doc *ast.Document, source []byte, fn func(lang string, code []byte) ast.Node) {
+	blocks := []*ast.FencedCodeBlock{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if block, ok := n.(*ast.FencedCodeBlock); ok && entering {
+			blocks = append(blocks, block)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, block := range blocks {
+		lang := strings.ToLower(string(block.Language(source)))
+		code := []byte{}
+		lines := block.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			segment := lines.At(i)
+			code = append(code, segment.Value(source)...)
+		}
+		node := fn(lang, code)
+		if node == nil {
+			continue
+		}
+		parent := block.Parent()
+		parent.ReplaceChild(parent, block, node)
+	}
+}
+
+type mathRenderer struct{}
+
+func (r *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMath, func(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			node := n.(*Math)
+			renderMath(w, node.TeX, node.Display)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	reg.Register(KindMathBlock, func(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			_, _ = w.WriteString(`<div class="math">`)
+			renderMath(w, n.(*MathBlock).TeX, true)
+			_, _ = w.WriteString("</div>\n")
+		}
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// MathExtension renders $inline$, $$display$$ and ```math blocks as MathML
+// when the front-matter sets `math: true`.
+var MathExtension goldmark.Extender = &mathExtension{}
+
+type mathExtension struct{}
+
+func (e *mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithInlineParsers(util.Prioritized(&mathParser{}, 150)),
+		parser.WithASTTransformers(util.Prioritized(&mathTransformer{}, 100)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&mathRenderer{}, 100)),
+	)
+}
@@ -3,6 +3,7 @@ package shared
 import (
 	"bytes"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
@@ -42,6 +43,24 @@ type MetaData struct {
 	Hidden      bool
 	WithStyles  bool
 	Domain      string
+	Mentions    bool
+	Series      string
+	// Comments is nil when the front-matter does not set it so posts can
+	// fall back to the setting of the blog.
+	Comments *bool
+	Theme    string
+	// Newsletter is how new posts are emailed to subscribers of the blog,
+	// "post" or "digest", empty when the blog has no newsletter.
+	Newsletter string
+	// Lang is the language tag of the post, e.g. en or de-AT.
+	Lang string
+	// Translations maps language tags to the slugs of translated posts.
+	Translations map[string]string
+	// Markdown extensions that posts opt into through the front-matter.
+	Math        bool
+	Diagrams    bool
+	Admonitions bool
+	Embeds      bool
 }
 
 type ParsedText struct {
@@ -53,6 +72,46 @@ func HtmlPolicy() *bluemonday.Policy {
 	policy := bluemonday.UGCPolicy()
 	policy.AllowStyling()
 	policy.AllowAttrs("rel").OnElements("a")
+
+	// math
+	policy.AllowNoAttrs().OnElements(
+		"math", "semantics", "annotation", "mrow", "mi", "mo", "mn", "mtext",
+		"mspace", "msub", "msup", "msubsup", "munder", "mover", "munderover",
+		"mfrac", "msqrt", "mroot", "mstyle", "mtable", "mtr", "mtd",
+	)
+	policy.AllowAttrs("xmlns", "display").OnElements("math")
+	policy.AllowAttrs("encoding").OnElements("annotation")
+	policy.AllowAttrs("mathvariant").OnElements("mi", "mtext", "mstyle")
+	policy.AllowAttrs("fence", "stretchy").OnElements("mo")
+	policy.AllowAttrs("accent").OnElements("mover")
+	policy.AllowAttrs("accentunder").OnElements("munder")
+	policy.AllowAttrs("linethickness").OnElements("mfrac")
+	policy.AllowAttrs("width", "linebreak").OnElements("mspace")
+	policy.AllowAttrs("title").OnElements("code", "pre")
+
+	// diagrams
+	policy.AllowNoAttrs().OnElements("svg", "rect", "ellipse", "polygon", "line", "text")
+	policy.AllowAttrs(
+		"xmlns", "viewbox", "width", "height", "font-family", "font-size",
+	).OnElements("svg")
+	policy.AllowAttrs("x", "y", "width", "height", "rx").OnElements("rect")
+	policy.AllowAttrs("cx", "cy", "rx", "ry").OnElements("ellipse")
+	policy.AllowAttrs("points").OnElements("polygon")
+	policy.AllowAttrs("x1", "y1", "x2", "y2", "stroke-dasharray").OnElements("line")
+	policy.AllowAttrs(
+		"x", "y", "text-anchor", "dominant-baseline", "font-size",
+	).OnElements("text")
+	policy.AllowAttrs("fill", "stroke").OnElements(
+		"rect", "ellipse", "polygon", "line", "text",
+	)
+
+	// embeds
+	policy.AllowAttrs("src").Matching(embedPolicy()).OnElements("iframe")
+	policy.AllowAttrs("title", "loading").OnElements("iframe")
+	policy.RequireSandboxOnIFrame()
+	policy.AllowAttrs("src").Matching(embedAssetPolicy()).OnElements("video")
+	policy.AllowAttrs("controls", "preload").OnElements("video")
+	policy.AllowAttrs("loading").OnElements("img")
 	return policy
 }
 
@@ -82,6 +141,13 @@ func toBool(obj interface{}, fallback bool) (bool, error) {
 	}
 }
 
+// extensionEnabled reports whether the front-matter turned on a markdown
+// extension, e.g. `math: true`.
+func extensionEnabled(pc parser.Context, name string) bool {
+	enabled, err := toBool(meta.Get(pc)[name], false)
+	return err == nil && enabled
+}
+
 // The toc frontmatter can take a boolean or an integer.
 //
 // A value of -1 or false means "do not generate a toc".
@@ -198,6 +264,60 @@ func toTags(obj interface{}) ([]string, error) {
 	return arr, nil
 }
 
+var langTag = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// IsLangTag reports whether lang looks like a BCP 47 language tag.
+func IsLangTag(lang string) bool {
+	return langTag.MatchString(lang)
+}
+
+func toLang(obj interface{}) (string, error) {
+	lang, err := toString(obj)
+	if err != nil {
+		return "", err
+	}
+	lang = strings.TrimSpace(lang)
+	if lang == "" {
+		return "", nil
+	}
+	if !langTag.MatchString(lang) {
+		return "", fmt.Errorf("invalid language tag: %s", lang)
+	}
+	return lang, nil
+}
+
+// The translations frontmatter maps language tags to post slugs:
+//
+//	translations:
+//	  de: hallo-welt
+func toTranslations(obj interface{}) (map[string]string, error) {
+	translations := map[string]string{}
+	if obj == nil {
+		return translations, nil
+	}
+
+	raw, ok := obj.(map[interface{}]interface{})
+	if !ok {
+		return translations, fmt.Errorf("unsupported type for `translations` variable: %T", obj)
+	}
+	for k, v := range raw {
+		lang, err := toLang(fmt.Sprintf("%v", k))
+		if err != nil {
+			return translations, err
+		}
+		slug, err := toString(v)
+		if err != nil {
+			return translations, err
+		}
+		slug = strings.TrimPrefix(strings.TrimSpace(slug), "/")
+		if lang == "" || slug == "" {
+			continue
+		}
+		translations[lang] = slug
+	}
+	return translations, nil
+}
+
 func CreateGoldmark(extenders ...goldmark.Extender) goldmark.Markdown {
 	return goldmark.New(
 		goldmark.WithExtensions(
@@ -237,6 +357,10 @@ func ParseText(text string) (*ParsedText, error) {
 			Position: anchor.After,
 			Texter:   anchor.Text("#"),
 		},
+		MathExtension,
+		DiagramExtension,
+		AdmonitionExtension,
+		EmbedExtension,
 	}
 	md := CreateGoldmark(extenders...)
 	context := parser.NewContext()
@@ -313,6 +437,69 @@ func ParseText(text string) (*ParsedText, error) {
 	}
 	parsed.WithStyles = withStyles
 
+	mentions, err := toBool(metaData["mentions"], false)
+	if err != nil {
+		return &parsed, fmt.Errorf("front-matter field (%s): %w", "mentions", err)
+	}
+	parsed.Mentions = mentions
+
+	series, err := toString(metaData["series"])
+	if err != nil {
+		return &parsed, fmt.Errorf("front-matter field (%s): %w", "series", err)
+	}
+	parsed.Series = strings.TrimSpace(series)
+
+	theme, err := toString(metaData["theme"])
+	if err != nil {
+		return &parsed, fmt.Errorf("front-matter field (%s): %w", "theme", err)
+	}
+	parsed.Theme = strings.TrimSpace(theme)
+
+	newsletter, err := toString(metaData["newsletter"])
+	if err != nil {
+		return &parsed, fmt.Errorf("front-matter field (%s): %w", "newsletter", err)
+	}
+	newsletter = strings.ToLower(strings.TrimSpace(newsletter))
+	if newsletter != "" && newsletter != "post" && newsletter != "digest" {
+		return &parsed, fmt.Errorf("front-matter field (%s): must be post or digest", "newsletter")
+	}
+	parsed.Newsletter = newsletter
+
+	for _, ext := range []struct {
+		name string
+		val  *bool
+	}{
+		{"math", &parsed.Math},
+		{"diagrams", &parsed.Diagrams},
+		{"admonitions", &parsed.Admonitions},
+		{"embeds", &parsed.Embeds},
+	} {
+		*ext.val, err = toBool(metaData[ext.name], false)
+		if err != nil {
+			return &parsed, fmt.Errorf("front-matter field (%s): %w", ext.name, err)
+		}
+	}
+
+	lang, err := toLang(metaData["lang"])
+	if err != nil {
+		return &parsed, fmt.Errorf("front-matter field (%s): %w", "lang", err)
+	}
+	parsed.Lang = lang
+
+	translations, err := toTranslations(metaData["translations"])
+	if err != nil {
+		return &parsed, fmt.Errorf("front-matter field (%s): %w", "translations", err)
+	}
+	parsed.Translations = translations
+
+	if metaData["comments"] != nil {
+		comments, err := toBool(metaData["comments"], false)
+		if err != nil {
+			return &parsed, fmt.Errorf("front-matter field (%s): %w", "comments", err)
+		}
+		parsed.Comments = &comments
+	}
+
 	favicon, err := toString(metaData["favicon"])
 	if err != nil {
 		return &parsed, fmt.Errorf("front-matter field (%s): %w", "favicon", err)
@@ -0,0 +1,278 @@
+package shared
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTexToMathML(t *testing.T) {
+	t.Run("TestFraction", func(t *testing.T) {
+		out, err := TexToMathML(`\frac{a}{b}`, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, "<mfrac><mi>a</mi><mi>b</mi></mfrac>") {
+			t.Errorf("expected fraction, got %s", out)
+		}
+		if !strings.Contains(out, `display="inline"`) {
+			t.Errorf("expected inline math, got %s", out)
+		}
+	})
+
+	t.Run("TestScriptsAndGreek", func(t *testing.T) {
+		out, err := TexToMathML(`x_i^2 + \alpha`, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, "<msubsup><mi>x</mi><mi>i</mi><mn>2</mn></msubsup>") {
+			t.Errorf("expected sub and superscript, got %s", out)
+		}
+		if !strings.Contains(out, "<mi>α</mi>") {
+			t.Errorf("expected alpha, got %s", out)
+		}
+	})
+
+	t.Run("TestDisplayLimits", func(t *testing.T) {
+		out, err := TexToMathML(`\sum_{i=0}^n i`, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, "<munderover><mo>∑</mo>") {
+			t.Errorf("expected limits on sum, got %s", out)
+		}
+	})
+
+	t.Run("TestMatrix", func(t *testing.T) {
+		out, err := TexToMathML(`\begin{pmatrix}1 & 0 \\ 0 & 1\end{pmatrix}`, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Count(out, "<mtr>") != 2 || strings.Count(out, "<mtd>") != 4 {
+			t.Errorf("expected 2x2 table, got %s", out)
+		}
+	})
+
+	t.Run("TestErrors", func(t *testing.T) {
+		for _, tex := range []string{`\frac{a}`, `{x`, `\nope`, `x^1^2`, strings.Repeat("{", 100)} {
+			if _, err := TexToMathML(tex, false); err == nil {
+				t.Errorf("expected error for %q", tex)
+			}
+		}
+	})
+}
+
+func TestParseTextMath(t *testing.T) {
+	t.Run("TestOptIn", func(t *testing.T) {
+		parsed, err := ParseText("costs $5 or $x^2$")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(parsed.Html, "<math") {
+			t.Errorf("math should be off without front-matter, got %s", parsed.Html)
+		}
+		if parsed.Math {
+			t.Error("expected Math to be false")
+		}
+	})
+
+	t.Run("TestInlineAndDisplay", func(t *testing.T) {
+		text := "---\nmath: true\n---\n\ncosts $5 but $x^2$ is\n\n$$\n\\sqrt{2}\n$$\n\n```math\na \\le b\n```\n"
+		parsed, err := ParseText(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !parsed.Math {
+			t.Error("expected Math to be true")
+		}
+		if !strings.Contains(parsed.Html, "costs $5 but") {
+			t.Errorf("expected price to be left alone, got %s", parsed.Html)
+		}
+		if !strings.Contains(parsed.Html, "<msup><mi>x</mi><mn>2</mn></msup>") {
+			t.Errorf("expected inline math, got %s", parsed.Html)
+		}
+		if !strings.Contains(parsed.Html, "<msqrt><mn>2</mn></msqrt>") {
+			t.Errorf("expected display math, got %s", parsed.Html)
+		}
+		if !strings.Contains(parsed.Html, `<div class="math">`) || !strings.Contains(parsed.Html, "<mo>≤</mo>") {
+			t.Errorf("expected math block, got %s", parsed.Html)
+		}
+	})
+
+	t.Run("TestInvalidType", func(t *testing.T) {
+		_, err := ParseText("---\nmath: yes please\n---\n")
+		if err == nil {
+			t.Fatal("expected error for non bool math")
+		}
+	})
+}
+
+func TestParseTextDiagrams(t *testing.T) {
+	t.Run("TestMermaid", func(t *testing.T) {
+		text := "---\ndiagrams: true\n---\n\n```mermaid\ngraph TD\n  A[Start] -->|go| B{Ok?}\n  B --> C((Done))\n  B -.-> A\n```\n"
+		parsed, err := ParseText(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(parsed.Html, `<div class="diagram"><svg`) {
+			t.Fatalf("expected svg, got %s", parsed.Html)
+		}
+		for _, want := range []string{">Start</text>", ">Ok?</text>", ">go</text>", "<ellipse", "stroke-dasharray"} {
+			if !strings.Contains(parsed.Html, want) {
+				t.Errorf("expected %q in %s", want, parsed.Html)
+			}
+		}
+	})
+
+	t.Run("TestDot", func(t *testing.T) {
+		text := "---\ndiagrams: true\n---\n\n```dot\ndigraph G {\n  rankdir=LR;\n  a [label=\"first\" shape=diamond];\n  a -> b -> c;\n}\n```\n"
+		parsed, err := ParseText(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(parsed.Html, ">first</text>") {
+			t.Errorf("expected label, got %s", parsed.Html)
+		}
+		if strings.Count(parsed.Html, "<line") != 2 {
+			t.Errorf("expected two edges, got %s", parsed.Html)
+		}
+	})
+
+	t.Run("TestUnsupportedFallsBack", func(t *testing.T) {
+		text := "---\ndiagrams: true\n---\n\n```mermaid\nsequenceDiagram\n  A->>B: hi\n```\n"
+		parsed, err := ParseText(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(parsed.Html, `<pre class="mermaid"`) {
+			t.Errorf("expected mermaid source, got %s", parsed.Html)
+		}
+	})
+
+	t.Run("TestCycle", func(t *testing.T) {
+		d, err := parseMermaid("graph LR; a --> b; b --> c; c --> a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.layout()
+		if d.index["a"].rank != 0 || d.index["b"].rank != 1 || d.index["c"].rank != 2 {
+			t.Errorf("unexpected ranks %d %d %d", d.index["a"].rank, d.index["b"].rank, d.index["c"].rank)
+		}
+	})
+}
+
+func TestParseTextAdmonitions(t *testing.T) {
+	text := "---\nadmonitions: true\n---\n\n> [!WARNING]\n> mind the *gap*\n\n> [!NOTE]\n\n> plain quote\n"
+	parsed, err := ParseText(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<blockquote class="admonition warning"><p class="admonition-title">Warning</p>
+<p>mind the <em>gap</em></p>
+</blockquote>`
+	if !strings.Contains(parsed.Html, want) {
+		t.Errorf("expected warning admonition, got %s", parsed.Html)
+	}
+	if !strings.Contains(parsed.Html, `<blockquote class="admonition note">`) {
+		t.Errorf("expected empty note admonition, got %s", parsed.Html)
+	}
+	if !strings.Contains(parsed.Html, "<blockquote>\n<p>plain quote</p>") {
+		t.Errorf("expected plain blockquote, got %s", parsed.Html)
+	}
+}
+
+func TestParseTextEmbeds(t *testing.T) {
+	t.Run("TestPaste", func(t *testing.T) {
+		parsed, err := ParseText("---\nembeds: true\n---\n\n{{< paste erock/main.go >}}\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(parsed.Html, `<iframe class="embed embed-paste" src="https://pastes.sh/erock/main.go"`) {
+			t.Errorf("expected paste iframe, got %s", parsed.Html)
+		}
+		if !strings.Contains(parsed.Html, `sandbox=""`) {
+			t.Errorf("expected sandboxed iframe, got %s", parsed.Html)
+		}
+	})
+
+	t.Run("TestPgs", func(t *testing.T) {
+		parsed, err := ParseText("---\nembeds: true\n---\n\n{{< pgs erock-blog/img/cat.png >}}\n\n{{< pgs erock-blog/demo.mp4 >}}\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(parsed.Html, `src="https://erock-blog.pgs.sh/img/cat.png"`) {
+			t.Errorf("expected pgs image, got %s", parsed.Html)
+		}
+		if !strings.Contains(parsed.Html, `<video class="embed embed-pgs" src="https://erock-blog.pgs.sh/demo.mp4"`) {
+			t.Errorf("expected pgs video, got %s", parsed.Html)
+		}
+	})
+
+	t.Run("TestInvalidTarget", func(t *testing.T) {
+		parsed, err := ParseText("---\nembeds: true\n---\n\n{{< paste ../../etc/passwd >}}\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(parsed.Html, "<iframe") || !strings.Contains(parsed.Html, "embed-error") {
+			t.Errorf("expected embed error, got %s", parsed.Html)
+		}
+	})
+
+	t.Run("TestOptIn", func(t *testing.T) {
+		parsed, err := ParseText("{{< paste erock/main.go >}}\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(parsed.Html, "<iframe") {
+			t.Errorf("embeds should be off without front-matter, got %s", parsed.Html)
+		}
+	})
+}
+
+func TestHtmlPolicyIframes(t *testing.T) {
+	out := HtmlPolicy().Sanitize(`<iframe src="https://evil.example/x/y"></iframe>`)
+	if strings.Contains(out, "evil.example") {
+		t.Errorf("expected foreign iframe to be dropped, got %s", out)
+	}
+}
+
+func TestParseTextLang(t *testing.T) {
+	t.Run("TestTranslations", func(t *testing.T) {
+		text := "---\nlang: de-AT\ntranslations:\n  en: /hello-world\n  fr: bonjour\n---\n\nhallo"
+		parsed, err := ParseText(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Lang != "de-AT" {
+			t.Errorf("expected de-AT, got %q", parsed.Lang)
+		}
+		if parsed.Translations["en"] != "hello-world" || parsed.Translations["fr"] != "bonjour" {
+			t.Errorf("unexpected translations %v", parsed.Translations)
+		}
+	})
+
+	t.Run("TestInvalidLang", func(t *testing.T) {
+		for _, text := range []string{
+			"---\nlang: not a lang\n---\n",
+			"---\ntranslations: [en]\n---\n",
+			"---\ntranslations:\n  \"x y\": slug\n---\n",
+		} {
+			if _, err := ParseText(text); err == nil {
+				t.Errorf("expected error for %q", text)
+			}
+		}
+	})
+}
+
+func TestParseTextNewsletter(t *testing.T) {
+	parsed, err := ParseText("---\nnewsletter: Digest\n---\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Newsletter != "digest" {
+		t.Errorf("expected digest, got %q", parsed.Newsletter)
+	}
+
+	if _, err := ParseText("---\nnewsletter: weekly\n---\n"); err == nil {
+		t.Error("expected unknown newsletter mode to fail")
+	}
+}
@@ -77,7 +77,7 @@ type ApiConfig struct {
 }
 
 func (hc *ApiConfig) HasPrivilegedAccess(apiToken string) bool {
-	user, err := hc.Dbpool.FindUserByToken(apiToken)
+	user, err := hc.Dbpool.FindUserByScopedToken(apiToken, db.TokenScopeFull)
 	if err != nil {
 		return false
 	}
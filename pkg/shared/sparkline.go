@@ -0,0 +1,58 @@
+package shared
+
+import (
+	"strings"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as a row of block characters scaled to the largest
+// value, zeros are drawn as the lowest block.
+func Sparkline(values []int) string {
+	peak := 0
+	for _, value := range values {
+		peak = max(peak, value)
+	}
+
+	var sb strings.Builder
+	for _, value := range values {
+		idx := 0
+		if peak > 0 && value > 0 {
+			idx = (value*(len(sparks)-1) + peak - 1) / peak
+		}
+		sb.WriteRune(sparks[idx])
+	}
+	return sb.String()
+}
+
+// IntervalSeries spreads visit intervals over n consecutive days or months
+// (interval is "day" or "month") starting at origin, intervals without any
+// visits are zero.
+func IntervalSeries(intervals []*db.VisitInterval, origin time.Time, interval string, n int) (visitors []int, reads []int) {
+	layout := time.DateOnly
+	step := func(t time.Time, i int) time.Time { return t.AddDate(0, 0, i) }
+	if interval == "month" {
+		layout = "2006-01"
+		step = func(t time.Time, i int) time.Time { return t.AddDate(0, i, 0) }
+	}
+
+	index := map[string]*db.VisitInterval{}
+	for _, iv := range intervals {
+		if iv.Interval != nil {
+			index[iv.Interval.UTC().Format(layout)] = iv
+		}
+	}
+
+	visitors = make([]int, n)
+	reads = make([]int, n)
+	for i := range n {
+		if iv, ok := index[step(origin.UTC(), i).Format(layout)]; ok {
+			visitors[i] = iv.Visitors
+			reads[i] = iv.ReadThroughs
+		}
+	}
+	return visitors, reads
+}
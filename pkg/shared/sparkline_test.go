@@ -0,0 +1,37 @@
+package shared
+
+import (
+	"testing"
+	"time"
+
+	"github.com/picosh/pico/pkg/db"
+)
+
+func TestSparkline(t *testing.T) {
+	got := Sparkline([]int{0, 1, 4, 8})
+	if got != "▁▂▅█" {
+		t.Errorf("expected ▁▂▅█, got %s", got)
+	}
+	if got := Sparkline([]int{0, 0}); got != "▁▁" {
+		t.Errorf("expected flat line, got %s", got)
+	}
+}
+
+func TestIntervalSeries(t *testing.T) {
+	origin := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	second := origin.AddDate(0, 0, 2)
+	intervals := []*db.VisitInterval{
+		{Interval: &origin, Visitors: 3, ReadThroughs: 1},
+		{Interval: &second, Visitors: 5},
+	}
+	visitors, reads := IntervalSeries(intervals, origin, "day", 4)
+	if len(visitors) != 4 || visitors[0] != 3 || visitors[1] != 0 || visitors[2] != 5 || reads[0] != 1 {
+		t.Errorf("unexpected series %v %v", visitors, reads)
+	}
+
+	month := origin.AddDate(0, 1, 0)
+	visitors, _ = IntervalSeries([]*db.VisitInterval{{Interval: &month, Visitors: 7}}, origin, "month", 3)
+	if visitors[0] != 0 || visitors[1] != 7 || visitors[2] != 0 {
+		t.Errorf("unexpected monthly series %v", visitors)
+	}
+}
@@ -3,6 +3,7 @@ package shared
 import (
 	"fmt"
 	"log/slog"
+	"math"
 	"strings"
 	"time"
 
@@ -16,6 +17,9 @@ type SshAuthHandler struct {
 	DB        AuthFindUser
 	Logger    *slog.Logger
 	Principal string
+	// OrgContext lets members connect as an org, e.g. `ssh acme@pgs.sh`.
+	// Only services that scope what members can do by role enable it.
+	OrgContext bool
 }
 
 type AuthFindUser interface {
@@ -23,6 +27,15 @@ type AuthFindUser interface {
 	FindUserByName(name string) (*db.User, error)
 	FindFeature(userID, name string) (*db.FeatureFlag, error)
 	InsertAccessLog(log *db.AccessLog) error
+	IsSshCertRevoked(caPubkey string, serial int64) (bool, error)
+	FindOrgMember(orgID, userID string) (*db.OrgMember, error)
+}
+
+// CertRevocationList is checked for every ssh certificate so certs issued
+// by pico can be revoked before they expire.  Certs signed by a pico CA
+// that pico has no record of issuing are reported as revoked.
+type CertRevocationList interface {
+	IsSshCertRevoked(caPubkey string, serial int64) (bool, error)
 }
 
 func NewSshAuthHandler(dbh AuthFindUser, logger *slog.Logger, principal string) *SshAuthHandler {
@@ -39,7 +52,7 @@ type AuthedPubkey struct {
 	Identity   string
 }
 
-func PubkeyCertVerify(key ssh.PublicKey, srcPrincipal string) (*AuthedPubkey, error) {
+func PubkeyCertVerify(key ssh.PublicKey, srcPrincipal string, krl CertRevocationList) (*AuthedPubkey, error) {
 	origPubkey := KeyForKeyText(key)
 	authed := &AuthedPubkey{
 		OrigPubkey: origPubkey,
@@ -53,14 +66,14 @@ func PubkeyCertVerify(key ssh.PublicKey, srcPrincipal string) (*AuthedPubkey, er
 			return nil, fmt.Errorf("ssh-cert has type %d", cert.CertType)
 		}
 
-		found := false
+		principal := ""
 		for _, princ := range cert.ValidPrincipals {
 			if princ == "admin" || princ == srcPrincipal {
-				found = true
+				principal = princ
 				break
 			}
 		}
-		if !found {
+		if principal == "" {
 			return nil, fmt.Errorf("ssh-cert principals not valid")
 		}
 
@@ -73,7 +86,29 @@ func PubkeyCertVerify(key ssh.PublicKey, srcPrincipal string) (*AuthedPubkey, er
 			return nil, fmt.Errorf("ssh-cert has expired")
 		}
 
+		// the signature key is only trusted once we know it signed the cert
+		checker := &ssh.CertChecker{}
+		err := checker.CheckCert(principal, cert)
+		if err != nil {
+			return nil, fmt.Errorf("ssh-cert is not valid: %w", err)
+		}
+
+		// pico stores serials as a bigint so it never issues larger ones
+		if cert.Serial > math.MaxInt64 {
+			return nil, fmt.Errorf("ssh-cert serial is not valid")
+		}
+
 		authed.Pubkey = KeyForKeyText(cert.SignatureKey)
+		if krl != nil {
+			revoked, err := krl.IsSshCertRevoked(authed.Pubkey, int64(cert.Serial))
+			if err != nil {
+				return nil, fmt.Errorf("unable to check ssh-cert revocation: %w", err)
+			}
+			if revoked {
+				return nil, fmt.Errorf("ssh-cert has been revoked")
+			}
+		}
+
 		authed.Identity = cert.KeyId
 		return authed, nil
 	}
@@ -85,7 +120,7 @@ func (r *SshAuthHandler) PubkeyAuthHandler(conn ssh.ConnMetadata, key ssh.Public
 	log := r.Logger
 	var user *db.User
 	var err error
-	authed, err := PubkeyCertVerify(key, r.Principal)
+	authed, err := PubkeyCertVerify(key, r.Principal, r.DB)
 	if err != nil {
 		return nil, err
 	}
@@ -136,6 +171,19 @@ func (r *SshAuthHandler) PubkeyAuthHandler(conn ssh.ConnMetadata, key ssh.Public
 		}
 	}
 
+	// org context, e.g. `ssh acme@pgs.sh`
+	var member *db.OrgMember
+	if r.OrgContext && impID == "" && usr != "" && !strings.EqualFold(usr, user.Name) {
+		org, err := r.DB.FindUserByName(usr)
+		if err == nil {
+			member, err = r.DB.FindOrgMember(org.ID, user.ID)
+			if err == nil {
+				log.Info("switching to org context", "org", org.Name, "member", user.Name, "role", member.Role)
+				user = org
+			}
+		}
+	}
+
 	perms := &ssh.Permissions{
 		Extensions: map[string]string{
 			"user_id":  user.ID,
@@ -148,6 +196,11 @@ func (r *SshAuthHandler) PubkeyAuthHandler(conn ssh.ConnMetadata, key ssh.Public
 		perms.Extensions["imp_id"] = impID
 	}
 
+	if member != nil {
+		perms.Extensions["member_id"] = member.UserID
+		perms.Extensions["org_role"] = member.Role
+	}
+
 	return perms, nil
 }
 
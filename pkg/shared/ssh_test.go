@@ -0,0 +1,137 @@
+package shared
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/picosh/pico/pkg/db"
+	"golang.org/x/crypto/ssh"
+)
+
+type testConnMeta struct {
+	ssh.ConnMetadata
+	user string
+}
+
+func (c *testConnMeta) User() string {
+	return c.user
+}
+
+type testAuthDB struct {
+	users   []*db.User
+	keys    map[string]string
+	members []*db.OrgMember
+}
+
+func (d *testAuthDB) FindUserByPubkey(key string) (*db.User, error) {
+	for _, user := range d.users {
+		if d.keys[key] == user.ID {
+			return user, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (d *testAuthDB) FindUserByName(name string) (*db.User, error) {
+	for _, user := range d.users {
+		if user.Name == name {
+			return user, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (d *testAuthDB) FindFeature(userID, name string) (*db.FeatureFlag, error) {
+	return nil, fmt.Errorf("feature not found")
+}
+
+func (d *testAuthDB) InsertAccessLog(log *db.AccessLog) error {
+	return nil
+}
+
+func (d *testAuthDB) IsSshCertRevoked(caPubkey string, serial int64) (bool, error) {
+	return false, nil
+}
+
+func (d *testAuthDB) FindOrgMember(orgID, userID string) (*db.OrgMember, error) {
+	for _, member := range d.members {
+		if member.OrgID == orgID && member.UserID == userID {
+			return member, nil
+		}
+	}
+	return nil, fmt.Errorf("member not found")
+}
+
+func TestPubkeyAuthHandler_OrgContext(t *testing.T) {
+	pubkey := newTestPubkey(t)
+	dbh := &testAuthDB{
+		users: []*db.User{
+			{ID: "alice-id", Name: "alice"},
+			{ID: "bob-id", Name: "bob"},
+			{ID: "acme-id", Name: "acme"},
+		},
+		keys: map[string]string{KeyForKeyText(pubkey): "alice-id"},
+		members: []*db.OrgMember{
+			{OrgID: "acme-id", UserID: "alice-id", Role: db.OrgRoleDeployer},
+		},
+	}
+	handler := NewSshAuthHandler(dbh, slog.New(slog.NewTextHandler(io.Discard, nil)), "pgs")
+	handler.OrgContext = true
+
+	tests := []struct {
+		sshUser string
+		userID  string
+		role    string
+	}{
+		{sshUser: "alice", userID: "alice-id"},
+		{sshUser: "acme", userID: "acme-id", role: db.OrgRoleDeployer},
+		// not a member so the connection stays in the user's context
+		{sshUser: "bob", userID: "alice-id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sshUser, func(t *testing.T) {
+			perms, err := handler.PubkeyAuthHandler(&testConnMeta{user: tt.sshUser}, pubkey)
+			if err != nil {
+				t.Fatalf("auth failed: %v", err)
+			}
+			if perms.Extensions["user_id"] != tt.userID {
+				t.Errorf("expected user_id %s, got %s", tt.userID, perms.Extensions["user_id"])
+			}
+			if perms.Extensions["org_role"] != tt.role {
+				t.Errorf("expected org_role %q, got %q", tt.role, perms.Extensions["org_role"])
+			}
+			if tt.role != "" && perms.Extensions["member_id"] != "alice-id" {
+				t.Errorf("expected member_id alice-id, got %s", perms.Extensions["member_id"])
+			}
+		})
+	}
+}
+
+func TestPubkeyAuthHandler_OrgContextDisabled(t *testing.T) {
+	pubkey := newTestPubkey(t)
+	dbh := &testAuthDB{
+		users: []*db.User{
+			{ID: "alice-id", Name: "alice"},
+			{ID: "acme-id", Name: "acme"},
+		},
+		keys: map[string]string{KeyForKeyText(pubkey): "alice-id"},
+		members: []*db.OrgMember{
+			{OrgID: "acme-id", UserID: "alice-id", Role: db.OrgRoleViewer},
+		},
+	}
+	handler := NewSshAuthHandler(dbh, slog.New(slog.NewTextHandler(io.Discard, nil)), "pico")
+
+	perms, err := handler.PubkeyAuthHandler(&testConnMeta{user: "acme"}, pubkey)
+	if err != nil {
+		t.Fatalf("auth failed: %v", err)
+	}
+	if perms.Extensions["user_id"] != "alice-id" {
+		t.Errorf("expected to stay in the user's context, got user_id %s", perms.Extensions["user_id"])
+	}
+	if _, ok := perms.Extensions["org_role"]; ok {
+		t.Errorf("expected no org_role, got %q", perms.Extensions["org_role"])
+	}
+}
@@ -3,6 +3,7 @@ package tui
 import (
 	"fmt"
 	"math"
+	"strings"
 	"time"
 
 	"git.sr.ht/~rockorager/vaxis"
@@ -16,6 +17,7 @@ import (
 
 type SitesLoaded struct{}
 type SiteStatsLoaded struct{}
+type PostsLoaded struct{}
 
 type AnalyticsPage struct {
 	shared *SharedModel
@@ -23,22 +25,27 @@ type AnalyticsPage struct {
 	loadingSites   bool
 	loadingDetails bool
 	sites          []*db.VisitUrl
+	posts          []*db.PostAnalytics
 	features       []*db.FeatureFlag
 	err            error
 	stats          map[string]*db.SummaryVisits
+	postStats      map[string]*db.PostAnalytics
 	selected       string
 	interval       string
 	focus          string
+	mode           string
 	leftPane       list.Dynamic
 	rightPane      *Pager
 }
 
 func NewAnalyticsPage(shrd *SharedModel) *AnalyticsPage {
 	page := &AnalyticsPage{
-		shared:   shrd,
-		stats:    map[string]*db.SummaryVisits{},
-		interval: "month",
-		focus:    "sites",
+		shared:    shrd,
+		stats:     map[string]*db.SummaryVisits{},
+		postStats: map[string]*db.PostAnalytics{},
+		interval:  "month",
+		focus:     "sites",
+		mode:      "sites",
 	}
 
 	page.leftPane = list.Dynamic{DrawCursor: true, Builder: page.getLeftWidget}
@@ -56,6 +63,7 @@ func (m *AnalyticsPage) Footer() []Shortcut {
 		{Shortcut: "j/k", Text: "choose"},
 		{Shortcut: "tab", Text: "focus"},
 		{Shortcut: "f", Text: "toggle filter (month/day)"},
+		{Shortcut: "p", Text: "toggle sites/posts"},
 	}
 	if m.shared.PlusFeatureFlag != nil {
 		short = append(short, Shortcut{Shortcut: "t", Text: toggle})
@@ -64,6 +72,13 @@ func (m *AnalyticsPage) Footer() []Shortcut {
 }
 
 func (m *AnalyticsPage) getLeftWidget(i uint, cursor uint) vxfw.Widget {
+	if m.mode == "posts" {
+		if int(i) >= len(m.posts) {
+			return nil
+		}
+		return text.New(m.posts[i].Slug)
+	}
+
 	if int(i) >= len(m.sites) {
 		return nil
 	}
@@ -81,7 +96,7 @@ func (m *AnalyticsPage) HandleEvent(ev vaxis.Event, phase vxfw.EventPhase) (vxfw
 		_ = m.fetchFeatures()
 		m.focus = "page"
 		return vxfw.FocusWidgetCmd(m), nil
-	case SitesLoaded:
+	case SitesLoaded, PostsLoaded:
 		if findAnalyticsFeature(m.features) == nil {
 			return vxfw.RedrawCmd{}, nil
 		}
@@ -99,10 +114,36 @@ func (m *AnalyticsPage) HandleEvent(ev vaxis.Event, phase vxfw.EventPhase) (vxfw
 			} else {
 				m.interval = "day"
 			}
+			if m.selected == "" {
+				return vxfw.RedrawCmd{}, nil
+			}
 			m.loadingDetails = true
-			go m.fetchSiteStats(m.selected, m.interval)
+			if m.mode == "posts" {
+				go m.fetchPostStats(m.selected, m.interval)
+			} else {
+				go m.fetchSiteStats(m.selected, m.interval)
+			}
 			return vxfw.RedrawCmd{}, nil
 		}
+		if msg.Matches('p') {
+			if findAnalyticsFeature(m.features) == nil {
+				return nil, nil
+			}
+			if m.mode == "posts" {
+				m.mode = "sites"
+			} else {
+				m.mode = "posts"
+				m.loadingSites = true
+				go m.fetchPosts()
+			}
+			m.selected = ""
+			m.focus = "sites"
+			m.leftPane.SetCursor(0)
+			return vxfw.BatchCmd([]vxfw.Command{
+				vxfw.FocusWidgetCmd(&m.leftPane),
+				vxfw.RedrawCmd{},
+			}), nil
+		}
 		if msg.Matches('t') {
 			enabled, err := m.toggleAnalytics()
 			if err != nil {
@@ -122,6 +163,15 @@ func (m *AnalyticsPage) HandleEvent(ev vaxis.Event, phase vxfw.EventPhase) (vxfw
 		}
 		if msg.Matches(vaxis.KeyEnter) {
 			cursor := int(m.leftPane.Cursor())
+			if m.mode == "posts" {
+				if cursor >= len(m.posts) {
+					return nil, nil
+				}
+				m.selected = m.posts[cursor].PostID
+				m.loadingDetails = true
+				go m.fetchPostStats(m.selected, m.interval)
+				return vxfw.RedrawCmd{}, nil
+			}
 			if cursor >= len(m.sites) {
 				return nil, nil
 			}
@@ -156,6 +206,10 @@ func (m *AnalyticsPage) HandleEvent(ev vaxis.Event, phase vxfw.EventPhase) (vxfw
 
 func (m *AnalyticsPage) focusBorder(brd *Border) {
 	focus := m.focus
+	// the left pane lists either sites or posts
+	if focus == "sites" {
+		focus = m.mode
+	}
 	if focus == brd.Label {
 		brd.Style = vaxis.Style{Foreground: oj}
 	} else {
@@ -175,7 +229,12 @@ func (m *AnalyticsPage) Draw(ctx vxfw.DrawContext) (vxfw.Surface, error) {
 	leftPaneW := float32(ctx.Max.Width) * 0.35
 
 	var wdgt vxfw.Widget = text.New("No sites found")
-	if len(m.sites) > 0 {
+	if m.mode == "posts" {
+		wdgt = text.New("No posts found")
+		if len(m.posts) > 0 {
+			wdgt = &m.leftPane
+		}
+	} else if len(m.sites) > 0 {
 		wdgt = &m.leftPane
 	}
 
@@ -184,7 +243,7 @@ func (m *AnalyticsPage) Draw(ctx vxfw.DrawContext) (vxfw.Surface, error) {
 	}
 
 	leftPane := NewBorder(wdgt)
-	leftPane.Label = "sites"
+	leftPane.Label = m.mode
 	m.focusBorder(leftPane)
 	leftSurf, _ := leftPane.Draw(vxfw.DrawContext{
 		Characters: ctx.Characters,
@@ -198,7 +257,7 @@ func (m *AnalyticsPage) Draw(ctx vxfw.DrawContext) (vxfw.Surface, error) {
 
 	rightPaneW := float32(ctx.Max.Width) * 0.65
 	if m.selected == "" {
-		rightWdgt := text.New("Select a site on the left to view its stats")
+		rightWdgt := text.New(fmt.Sprintf("Select a %s on the left to view its stats", strings.TrimSuffix(m.mode, "s")))
 		rightSurf, _ := rightWdgt.Draw(vxfw.DrawContext{
 			Characters: ctx.Characters,
 			Max: vxfw.Size{
@@ -212,6 +271,10 @@ func (m *AnalyticsPage) Draw(ctx vxfw.DrawContext) (vxfw.Surface, error) {
 
 		ah := 0
 
+		if m.mode == "posts" {
+			return m.drawPost(ctx, root, rightSurf, int(leftPaneW), uint16(rightPaneW))
+		}
+
 		data, err := m.getSiteData()
 		if err != nil {
 			var txt vxfw.Surface
@@ -276,6 +339,84 @@ func (m *AnalyticsPage) Draw(ctx vxfw.DrawContext) (vxfw.Surface, error) {
 	return root, nil
 }
 
+// drawPost draws the breakdown of the selected post into the details pane.
+func (m *AnalyticsPage) drawPost(ctx vxfw.DrawContext, root, rightSurf vxfw.Surface, x int, width uint16) (vxfw.Surface, error) {
+	data, ok := m.postStats[m.selected+":"+m.interval]
+	if !ok {
+		msg := "No data found"
+		if m.loadingDetails {
+			msg = "Loading ..."
+		}
+		txt, _ := text.New(msg).Draw(ctx)
+		m.rightPane.Surface = txt
+		rightPane := NewBorder(m.rightPane)
+		rightPane.Label = "details"
+		m.focusBorder(rightPane)
+		pagerSurf, _ := rightPane.Draw(vxfw.DrawContext{
+			Characters: ctx.Characters,
+			Max:        vxfw.Size{Width: width, Height: ctx.Max.Height},
+		})
+		rightSurf.AddChild(0, 0, pagerSurf)
+		root.AddChild(x, 0, rightSurf)
+		return root, nil
+	}
+
+	rightCtx := vxfw.DrawContext{
+		Characters: vaxis.Characters,
+		Max: vxfw.Size{
+			Width:  width - 2,
+			Height: ctx.Max.Height,
+		},
+	}
+
+	ah := 0
+	for _, wdgt := range []vxfw.Widget{
+		m.postDetail(rightCtx, data),
+		m.urls(rightCtx, data.TopReferers, "referers"),
+		m.devices(rightCtx, data.Intervals),
+		m.visits(rightCtx, data.Intervals),
+	} {
+		surf, _ := wdgt.Draw(rightCtx)
+		rightSurf.AddChild(0, ah, surf)
+		ah += int(surf.Size.Height)
+	}
+
+	m.rightPane.Surface = rightSurf
+	rightPane := NewBorder(m.rightPane)
+	rightPane.Label = "details"
+	m.focusBorder(rightPane)
+	pagerSurf, _ := rightPane.Draw(rightCtx)
+	root.AddChild(x, 0, pagerSurf)
+	return root, nil
+}
+
+// postOrigin is where the trend of a post starts, the current month by day
+// or the last 12 months.
+func postOrigin(interval string) (time.Time, int) {
+	if interval == "day" {
+		return shared.StartOfMonth(), time.Now().Day()
+	}
+	return shared.StartOfMonth().AddDate(0, -11, 0), 12
+}
+
+func (m *AnalyticsPage) postDetail(ctx vxfw.DrawContext, post *db.PostAnalytics) vxfw.Widget {
+	origin, n := postOrigin(m.interval)
+	visitors, reads := shared.IntervalSeries(post.Intervals, origin, m.interval, n)
+	kv := []Kv{
+		{Key: "title", Value: post.Title, Style: vaxis.Style{Foreground: green}},
+		{Key: "views", Value: fmt.Sprintf("%d", post.Views)},
+		{Key: "visitors", Value: fmt.Sprintf("%d %s", post.Visitors, shared.Sparkline(visitors))},
+		{Key: "reads", Value: fmt.Sprintf("%d %s", post.ReadThroughs, shared.Sparkline(reads))},
+		{Key: "read-through", Value: fmt.Sprintf("%.1f%%", post.ReadThroughRate()*100)},
+	}
+
+	rightPane := NewBorder(NewKv(kv))
+	rightPane.Width = ctx.Max.Width
+	rightPane.Label = post.Slug
+	m.focusBorder(rightPane)
+	return rightPane
+}
+
 func (m *AnalyticsPage) getSiteData() (*db.SummaryVisits, error) {
 	val, ok := m.stats[m.selected+":"+m.interval]
 	if !ok {
@@ -364,11 +505,15 @@ func (m *AnalyticsPage) visits(ctx vxfw.DrawContext, intervals []*db.VisitInterv
 		if len(key) > w {
 			w = len(key)
 		}
+		value := fmt.Sprintf("%d", visit.Visitors)
+		if m.mode == "posts" {
+			value = fmt.Sprintf("%d (%d reads)", visit.Visitors, visit.ReadThroughs)
+		}
 		kv = append(
 			kv,
 			Kv{
 				Key:   key,
-				Value: fmt.Sprintf("%d", visit.Visitors),
+				Value: value,
 			},
 		)
 	}
@@ -421,6 +566,39 @@ func (m *AnalyticsPage) fetchSiteStats(site string, interval string) {
 	m.shared.App.PostEvent(SiteStatsLoaded{})
 }
 
+func (m *AnalyticsPage) fetchPosts() {
+	posts, err := m.shared.Dbpool.FindVisitPostList(&db.SummaryOpts{
+		UserID: m.shared.User.ID,
+		Origin: shared.StartOfYear(),
+	})
+	if err != nil {
+		m.loadingSites = false
+		m.err = err
+		return
+	}
+	m.posts = posts
+	m.loadingSites = false
+	m.shared.App.PostEvent(PostsLoaded{})
+}
+
+func (m *AnalyticsPage) fetchPostStats(postID string, interval string) {
+	origin, _ := postOrigin(interval)
+	stats, err := m.shared.Dbpool.PostVisitSummary(&db.SummaryOpts{
+		PostID:   postID,
+		UserID:   m.shared.User.ID,
+		Interval: interval,
+		Origin:   origin,
+	})
+	if err != nil {
+		m.err = err
+		m.loadingDetails = false
+		return
+	}
+	m.postStats[postID+":"+interval] = stats
+	m.loadingDetails = false
+	m.shared.App.PostEvent(SiteStatsLoaded{})
+}
+
 func (m *AnalyticsPage) fetchFeatures() error {
 	features, err := m.shared.Dbpool.FindFeaturesByUser(m.shared.User.ID)
 	m.features = features
@@ -2,6 +2,9 @@ package tui
 
 import (
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"git.sr.ht/~rockorager/vaxis"
@@ -100,12 +103,36 @@ func (m *TokensPage) getWidget(i uint, cursor uint) vxfw.Widget {
 	}
 
 	token := m.tokens[i]
+	scopes := token.Scopes
+	if scopes == "" {
+		scopes = "full access"
+	}
+	expires := "never"
+	if token.ExpiresAt != nil && token.ExpiresAt.Year() < 2100 {
+		expires = token.ExpiresAt.Format(time.DateOnly)
+		if token.ExpiresAt.Before(time.Now()) {
+			expires += " (expired)"
+		}
+	}
+	lastUsed := "never"
+	if token.LastUsedAt != nil {
+		lastUsed = token.LastUsedAt.Format(time.DateTime)
+	}
 	txt := richtext.New([]vaxis.Segment{
 		{Text: "Name: ", Style: style},
 		{Text: token.Name + "\n"},
 
+		{Text: "Scopes: ", Style: style},
+		{Text: scopes + "\n"},
+
 		{Text: "Created: ", Style: style},
-		{Text: token.CreatedAt.Format(time.DateOnly)},
+		{Text: token.CreatedAt.Format(time.DateOnly) + "\n"},
+
+		{Text: "Expires: ", Style: style},
+		{Text: expires + "\n"},
+
+		{Text: "Last used: ", Style: style},
+		{Text: lastUsed},
 	})
 
 	return txt
@@ -117,7 +144,7 @@ func (m *TokensPage) Draw(ctx vxfw.DrawContext) (vxfw.Surface, error) {
 	root := vxfw.NewSurface(w, h, m)
 	ah := 0
 
-	info := text.New("Tokens allows users to generate a 'password' for use with web services that cannot use SSH keys for authentication. For example, tokens are used to access our IRC bouncer.")
+	info := text.New("Tokens allows users to generate a 'password' for use with web services that cannot use SSH keys for authentication. For example, tokens are used to access our IRC bouncer. Tokens can be limited to scopes and set to expire.")
 	brd := NewBorder(info)
 	brd.Label = "desc"
 	brdSurf, _ := brd.Draw(ctx)
@@ -158,11 +185,13 @@ func (m *TokensPage) Draw(ctx vxfw.DrawContext) (vxfw.Surface, error) {
 type AddTokenPage struct {
 	shared *SharedModel
 
-	token string
-	err   error
-	focus string
-	input *TextInput
-	btn   *button.Button
+	token   string
+	err     error
+	focus   string
+	input   *TextInput
+	scopes  *TextInput
+	expires *TextInput
+	btn     *button.Button
 }
 
 func NewAddTokenPage(shrd *SharedModel) *AddTokenPage {
@@ -174,8 +203,10 @@ func NewAddTokenPage(shrd *SharedModel) *AddTokenPage {
 	return &AddTokenPage{
 		shared: shrd,
 
-		input: NewTextInput("enter name"),
-		btn:   btn,
+		input:   NewTextInput("enter name"),
+		scopes:  NewTextInput("scopes (optional)"),
+		expires: NewTextInput("expires in (optional)"),
+		btn:     btn,
 	}
 }
 
@@ -196,13 +227,15 @@ func (m *AddTokenPage) CaptureEvent(ev vaxis.Event) (vxfw.Command, error) {
 				m.token = ""
 				m.err = nil
 				m.input.Reset()
+				m.scopes.Reset()
+				m.expires.Reset()
 				m.shared.App.PostEvent(Navigate{To: "tokens"})
 				return vxfw.BatchCmd([]vxfw.Command{
 					vxfw.CopyToClipboardCmd(copyToken),
 					vxfw.RedrawCmd{},
 				}), nil
 			}
-			token, err := m.addToken(m.input.GetValue())
+			token, err := m.addToken(m.input.GetValue(), m.scopes.GetValue(), m.expires.GetValue())
 			m.token = token
 			m.focus = "button"
 			m.err = err
@@ -221,27 +254,71 @@ func (m *AddTokenPage) HandleEvent(ev vaxis.Event, phase vxfw.EventPhase) (vxfw.
 	case PageIn:
 		m.focus = "input"
 		m.input.Reset()
+		m.scopes.Reset()
+		m.expires.Reset()
 		return m.input.FocusIn()
 	case vaxis.Key:
 		if msg.Matches(vaxis.KeyTab) {
-			if m.focus == "input" {
-				m.focus = "button"
-				cmd, _ := m.input.FocusOut()
-				return vxfw.BatchCmd([]vxfw.Command{
-					cmd,
-					vxfw.FocusWidgetCmd(m.btn),
-				}), nil
+			inputs := []*TextInput{m.input, m.scopes, m.expires}
+			focus := []string{"input", "scopes", "expires", "button"}
+			cur := slices.Index(focus, m.focus)
+			next := (cur + 1) % len(focus)
+			m.focus = focus[next]
+
+			cmds := []vxfw.Command{}
+			if cur >= 0 && cur < len(inputs) {
+				cmd, _ := inputs[cur].FocusOut()
+				cmds = append(cmds, cmd)
+			}
+			if next < len(inputs) {
+				cmd, _ := inputs[next].FocusIn()
+				cmds = append(cmds, cmd)
+			} else {
+				cmds = append(cmds, vxfw.FocusWidgetCmd(m.btn))
 			}
-			m.focus = "input"
-			return m.input.FocusIn()
+			return vxfw.BatchCmd(cmds), nil
 		}
 	}
 
 	return nil, nil
 }
 
-func (m *AddTokenPage) addToken(name string) (string, error) {
-	return m.shared.Dbpool.InsertToken(m.shared.User.ID, name)
+// parseTokenExpiry accepts a duration like "24h" or a number of days
+// like "30d".  An empty string means the token never expires.
+func parseTokenExpiry(expires string, now time.Time) (*time.Time, error) {
+	expires = strings.TrimSpace(expires)
+	if expires == "" {
+		return nil, nil
+	}
+
+	var dur time.Duration
+	if days, found := strings.CutSuffix(expires, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiry: %s", expires)
+		}
+		dur = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		dur, err = time.ParseDuration(expires)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiry: %s", expires)
+		}
+	}
+
+	if dur <= 0 {
+		return nil, fmt.Errorf("expiry must be in the future")
+	}
+	expiresAt := now.Add(dur)
+	return &expiresAt, nil
+}
+
+func (m *AddTokenPage) addToken(name, scopes, expires string) (string, error) {
+	expiresAt, err := parseTokenExpiry(expires, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return m.shared.Dbpool.InsertScopedToken(m.shared.User.ID, name, db.ParseTokenScopes(scopes), expiresAt)
 }
 
 func (m *AddTokenPage) Draw(ctx vxfw.DrawContext) (vxfw.Surface, error) {
@@ -260,6 +337,27 @@ func (m *AddTokenPage) Draw(ctx vxfw.DrawContext) (vxfw.Surface, error) {
 		root.AddChild(0, ah, inputSurf)
 		ah += int(inputSurf.Size.Height)
 
+		scopesHelp := text.New(fmt.Sprintf(
+			"Comma separated list of scopes, leave empty for full access: %s, pipe:sub:<topic>",
+			strings.Join(db.TokenScopes, ", "),
+		))
+		scopesHelpSurf, _ := scopesHelp.Draw(ctx)
+		root.AddChild(0, ah, scopesHelpSurf)
+		ah += int(scopesHelpSurf.Size.Height)
+
+		scopesSurf, _ := m.scopes.Draw(createDrawCtx(ctx, 4))
+		root.AddChild(0, ah, scopesSurf)
+		ah += int(scopesSurf.Size.Height)
+
+		expiresHelp := text.New("Expire the token after a duration like 24h or 30d, leave empty to never expire")
+		expiresHelpSurf, _ := expiresHelp.Draw(ctx)
+		root.AddChild(0, ah, expiresHelpSurf)
+		ah += int(expiresHelpSurf.Size.Height)
+
+		expiresSurf, _ := m.expires.Draw(createDrawCtx(ctx, 4))
+		root.AddChild(0, ah, expiresSurf)
+		ah += int(expiresSurf.Size.Height)
+
 		btnSurf, _ := m.btn.Draw(vxfw.DrawContext{
 			Characters: ctx.Characters,
 			Max:        vxfw.Size{Width: 4, Height: 1},
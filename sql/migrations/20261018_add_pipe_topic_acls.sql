@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS pipe_topic_acls (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  user_id uuid NOT NULL,
+  topic text NOT NULL,
+  principal text NOT NULL,
+  permission text NOT NULL DEFAULT 'rw',
+  created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT pipe_topic_acls_unique_principal UNIQUE (user_id, topic, principal),
+  CONSTRAINT pipe_topic_acls_permission CHECK (permission IN ('read', 'write', 'rw')),
+  CONSTRAINT pipe_topic_acls_pkey PRIMARY KEY (id),
+  CONSTRAINT fk_pipe_topic_acls_app_users
+    FOREIGN KEY(user_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
@@ -0,0 +1,25 @@
+ALTER TABLE pipe_monitors ADD COLUMN IF NOT EXISTS alert_status text NOT NULL DEFAULT '';
+ALTER TABLE pipe_monitors ADD COLUMN IF NOT EXISTS alerted_at timestamp without time zone;
+
+CREATE TABLE IF NOT EXISTS pipe_monitor_targets (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  monitor_id uuid NOT NULL,
+  kind text NOT NULL,
+  target text NOT NULL,
+  secret text NOT NULL DEFAULT '',
+  token text NOT NULL DEFAULT '',
+  confirmed_at timestamp without time zone,
+  created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT pipe_monitor_targets_unique_target UNIQUE (monitor_id, kind, target),
+  CONSTRAINT pipe_monitor_targets_kind CHECK (kind IN ('email', 'webhook', 'topic')),
+  CONSTRAINT pipe_monitor_targets_pkey PRIMARY KEY (id),
+  CONSTRAINT fk_pipe_monitor_targets_pipe_monitors
+    FOREIGN KEY(monitor_id)
+  REFERENCES pipe_monitors(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS pipe_monitor_targets_token_idx
+  ON pipe_monitor_targets (token) WHERE token <> '';
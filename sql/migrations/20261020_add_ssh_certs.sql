@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS ssh_cert_authorities (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  user_id uuid NOT NULL,
+  public_key text NOT NULL,
+  private_key text NOT NULL,
+  created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT ssh_cert_authorities_unique_user UNIQUE (user_id),
+  CONSTRAINT ssh_cert_authorities_pkey PRIMARY KEY (id),
+  CONSTRAINT fk_ssh_cert_authorities_app_users
+    FOREIGN KEY(user_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS ssh_certs (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  ca_id uuid NOT NULL,
+  user_id uuid NOT NULL,
+  serial bigint NOT NULL,
+  key_id text NOT NULL,
+  principals text NOT NULL,
+  public_key text NOT NULL,
+  valid_after timestamp without time zone NOT NULL,
+  valid_before timestamp without time zone NOT NULL,
+  revoked_at timestamp without time zone,
+  created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT ssh_certs_unique_serial UNIQUE (ca_id, serial),
+  CONSTRAINT ssh_certs_pkey PRIMARY KEY (id),
+  CONSTRAINT fk_ssh_certs_ssh_cert_authorities
+    FOREIGN KEY(ca_id)
+  REFERENCES ssh_cert_authorities(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE,
+  CONSTRAINT fk_ssh_certs_app_users
+    FOREIGN KEY(user_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
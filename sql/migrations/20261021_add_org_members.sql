@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS org_members (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  org_id uuid NOT NULL,
+  user_id uuid NOT NULL,
+  role text NOT NULL DEFAULT 'viewer',
+  created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT org_members_unique_member UNIQUE (org_id, user_id),
+  CONSTRAINT org_members_role CHECK (role IN ('owner', 'deployer', 'viewer')),
+  CONSTRAINT org_members_not_self CHECK (org_id <> user_id),
+  CONSTRAINT org_members_pkey PRIMARY KEY (id),
+  CONSTRAINT fk_org_members_org
+    FOREIGN KEY(org_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE,
+  CONSTRAINT fk_org_members_app_users
+    FOREIGN KEY(user_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
@@ -0,0 +1,2 @@
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS scopes text NOT NULL DEFAULT '';
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS last_used_at timestamp without time zone;
@@ -0,0 +1,6 @@
+-- must match the searchVector expression in pkg/db/postgres
+CREATE INDEX IF NOT EXISTS posts_search_idx ON posts USING GIN ((
+  setweight(to_tsvector('english'::regconfig, coalesce(title, '')), 'A') ||
+  setweight(to_tsvector('english'::regconfig, coalesce(description, '')), 'B') ||
+  setweight(to_tsvector('english'::regconfig, coalesce(text, '')), 'C')
+));
@@ -0,0 +1,50 @@
+CREATE TABLE IF NOT EXISTS federation_keys (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  user_id uuid NOT NULL,
+  public_key text NOT NULL,
+  private_key text NOT NULL,
+  created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT federation_keys_unique_user UNIQUE (user_id),
+  CONSTRAINT federation_keys_pkey PRIMARY KEY (id),
+  CONSTRAINT fk_federation_keys_app_users
+    FOREIGN KEY(user_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS federation_followers (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  user_id uuid NOT NULL,
+  actor text NOT NULL,
+  inbox text NOT NULL,
+  created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT federation_followers_unique_actor UNIQUE (user_id, actor),
+  CONSTRAINT federation_followers_pkey PRIMARY KEY (id),
+  CONSTRAINT fk_federation_followers_app_users
+    FOREIGN KEY(user_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS post_mentions (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  post_id uuid NOT NULL,
+  kind varchar(16) NOT NULL,
+  source text NOT NULL,
+  author_name text NOT NULL DEFAULT '',
+  author_url text NOT NULL DEFAULT '',
+  content text NOT NULL DEFAULT '',
+  created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT post_mentions_unique_source UNIQUE (post_id, source),
+  CONSTRAINT post_mentions_pkey PRIMARY KEY (id),
+  CONSTRAINT fk_post_mentions_posts
+    FOREIGN KEY(post_id)
+  REFERENCES posts(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS post_mentions_source_idx ON post_mentions (source);
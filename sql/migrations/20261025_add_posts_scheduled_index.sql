@@ -0,0 +1,4 @@
+-- must match the where clause of FindScheduledPosts in pkg/db/postgres
+CREATE INDEX IF NOT EXISTS posts_scheduled_idx
+  ON posts (publish_at)
+  WHERE data->>'scheduled' = 'true';
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS post_comments (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  post_id uuid NOT NULL,
+  user_id uuid NOT NULL,
+  text text NOT NULL,
+  approved boolean NOT NULL DEFAULT false,
+  created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT post_comments_pkey PRIMARY KEY (id),
+  CONSTRAINT fk_post_comments_posts
+    FOREIGN KEY(post_id)
+  REFERENCES posts(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE,
+  CONSTRAINT fk_post_comments_app_users
+    FOREIGN KEY(user_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS post_comments_post_idx ON post_comments (post_id, created_at);
@@ -0,0 +1,4 @@
+-- speeds up the language filters of the prose feeds
+CREATE INDEX IF NOT EXISTS posts_lang_idx
+  ON posts (lower(data->>'lang'))
+  WHERE coalesce(data->>'lang', '') != '';
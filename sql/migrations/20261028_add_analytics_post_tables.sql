@@ -0,0 +1,77 @@
+-- analytics_post_reads: raw read-through events, a reader reached the end of a post
+CREATE TABLE IF NOT EXISTS analytics_post_reads (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  user_id uuid NOT NULL,
+  post_id uuid NOT NULL,
+  host varchar(253) NOT NULL,
+  ip_address varchar(256) NOT NULL,
+  created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT analytics_post_reads_pkey PRIMARY KEY (id),
+  CONSTRAINT fk_post_reads_app_users
+    FOREIGN KEY(user_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE,
+  CONSTRAINT fk_post_reads_posts
+    FOREIGN KEY(post_id)
+  REFERENCES posts(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_reads_user_created ON analytics_post_reads (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_post_reads_post_created ON analytics_post_reads (post_id, created_at);
+
+-- analytics_monthly_post_visits: daily unique visitor and read-through counts per post
+CREATE TABLE IF NOT EXISTS analytics_monthly_post_visits (
+  id serial NOT NULL,
+  user_id uuid NOT NULL,
+  post_id uuid NOT NULL,
+  visit_date date NOT NULL,
+  unique_visits integer NOT NULL DEFAULT 0,
+  mobile_visits integer NOT NULL DEFAULT 0,
+  desktop_visits integer NOT NULL DEFAULT 0,
+  read_throughs integer NOT NULL DEFAULT 0,
+  created_at timestamp without time zone NOT NULL DEFAULT now(),
+  CONSTRAINT analytics_monthly_post_visits_pkey PRIMARY KEY (id),
+  CONSTRAINT analytics_monthly_post_visits_unique UNIQUE (user_id, post_id, visit_date),
+  CONSTRAINT fk_monthly_post_visits_app_users
+    FOREIGN KEY(user_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE,
+  CONSTRAINT fk_monthly_post_visits_posts
+    FOREIGN KEY(post_id)
+  REFERENCES posts(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_monthly_post_visits_user_date ON analytics_monthly_post_visits (user_id, visit_date);
+CREATE INDEX IF NOT EXISTS idx_monthly_post_visits_post_date ON analytics_monthly_post_visits (post_id, visit_date);
+
+-- analytics_monthly_post_referers: top referers per post per month
+CREATE TABLE IF NOT EXISTS analytics_monthly_post_referers (
+  id serial NOT NULL,
+  user_id uuid NOT NULL,
+  post_id uuid NOT NULL,
+  month date NOT NULL,
+  referer character varying(253) NOT NULL,
+  unique_visits integer NOT NULL DEFAULT 0,
+  rank integer NOT NULL,
+  created_at timestamp without time zone NOT NULL DEFAULT now(),
+  CONSTRAINT analytics_monthly_post_referers_pkey PRIMARY KEY (id),
+  CONSTRAINT analytics_monthly_post_referers_unique UNIQUE (user_id, post_id, month, referer),
+  CONSTRAINT fk_monthly_post_referers_app_users
+    FOREIGN KEY(user_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE,
+  CONSTRAINT fk_monthly_post_referers_posts
+    FOREIGN KEY(post_id)
+  REFERENCES posts(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_monthly_post_referers_post_month ON analytics_monthly_post_referers (post_id, month);
@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS newsletter_subscribers (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  user_id uuid NOT NULL,
+  email varchar(255) NOT NULL,
+  token varchar(64) NOT NULL,
+  status varchar(16) NOT NULL DEFAULT 'pending',
+  bounces integer NOT NULL DEFAULT 0,
+  confirmed_at timestamp without time zone,
+  created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT newsletter_subscribers_pkey PRIMARY KEY (id),
+  CONSTRAINT newsletter_subscribers_unique_email UNIQUE (user_id, email),
+  CONSTRAINT newsletter_subscribers_unique_token UNIQUE (token),
+  CONSTRAINT newsletter_subscribers_status CHECK (status IN ('pending', 'confirmed', 'unsubscribed', 'bounced')),
+  CONSTRAINT fk_newsletter_subscribers_app_users
+    FOREIGN KEY(user_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS newsletter_subscribers_email_idx ON newsletter_subscribers (email);
+
+CREATE TABLE IF NOT EXISTS newsletter_posts (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  user_id uuid NOT NULL,
+  post_id uuid NOT NULL,
+  created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+  sent_at timestamp without time zone,
+  CONSTRAINT newsletter_posts_pkey PRIMARY KEY (id),
+  CONSTRAINT newsletter_posts_unique_post UNIQUE (post_id),
+  CONSTRAINT fk_newsletter_posts_posts
+    FOREIGN KEY(post_id)
+  REFERENCES posts(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE,
+  CONSTRAINT fk_newsletter_posts_app_users
+    FOREIGN KEY(user_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS newsletter_posts_pending_idx ON newsletter_posts (created_at) WHERE sent_at IS NULL;